@@ -0,0 +1,333 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sniplicity/internal/builder"
+	"sniplicity/internal/config"
+	"sniplicity/internal/logging"
+)
+
+// projectFlags are the flags shared by build, watch and serve. Unlike the original
+// flags, only the ones actually given override sniplicity.yaml, so a command behaves the
+// same whatever else is on the command line.
+type projectFlags struct {
+	fs        *flag.FlagSet
+	values    config.Config
+	imgSize   string
+	svgFilter string
+	open      bool
+	quiet     bool
+	only      stringList
+	deploy    bool // Whether the build is for deploy, whose env decides the environment without --env
+}
+
+// stringList is a flag that can be given more than once, collecting every value
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// newProjectFlags defines the flags for one of the project commands
+func newProjectFlags(name, description string, serve bool) *projectFlags {
+	f := &projectFlags{fs: flag.NewFlagSet(name, flag.ExitOnError)}
+	fs, v := f.fs, &f.values
+
+	fs.StringVar(&v.InputDir, "i", "", "source directory (default: from sniplicity.yaml)")
+	fs.StringVar(&v.InputDir, "in", "", "source directory (default: from sniplicity.yaml)")
+	fs.StringVar(&v.OutputDir, "o", "", "output directory (default: from sniplicity.yaml)")
+	fs.StringVar(&v.OutputDir, "out", "", "output directory (default: from sniplicity.yaml)")
+	fs.BoolVar(&v.Verbose, "v", false, "extra console messages")
+	fs.BoolVar(&v.Verbose, "verbose", false, "extra console messages")
+	fs.BoolVar(&f.quiet, "q", false, "only print errors")
+	fs.BoolVar(&f.quiet, "quiet", false, "only print errors")
+	fs.StringVar(&v.LogLevel, "log-level", "", "how much to print: error, warn, info or debug (default: info)")
+	fs.BoolVar(&v.NoColor, "no-color", false, "leave out ANSI colours")
+	if serve {
+		fs.IntVar(&v.Port, "p", 3000, "port for the web server")
+		fs.IntVar(&v.Port, "port", 3000, "port for the web server")
+		fs.StringVar(&v.Host, "host", "", "address the web server listens on, e.g. 0.0.0.0 for the whole network (default: 127.0.0.1)")
+		fs.BoolVar(&v.TLS, "tls", false, "serve over HTTPS with a self-signed certificate")
+		fs.StringVar(&v.TLSCert, "tls-cert", "", "certificate file for HTTPS instead of a self-signed one")
+		fs.StringVar(&v.TLSKey, "tls-key", "", "private key file of --tls-cert")
+		fs.BoolVar(&f.open, "open", false, "open the site in a browser instead of copying its URL to the clipboard")
+	}
+	fs.StringVar(&f.imgSize, "imgsize", "", "automatically add width/height to img tags (on/off)")
+	fs.StringVar(&f.svgFilter, "svgfilter", "", "process SVG files with CSS filters (on/off)")
+	fs.BoolVar(&v.CheckLinks, "check-links", false, "report links to missing pages and files after building")
+	fs.BoolVar(&v.Strict, "strict", false, "fail the build on missing snippets, templates, variables and includes")
+	fs.BoolVar(&v.Stats, "stats", false, "record local build statistics (see: sniplicity stats)")
+	fs.BoolVar(&v.Drafts, "drafts", false, "build and list pages marked draft: true")
+	fs.BoolVar(&v.PlainText, "plain-text", false, "also write a .txt plain text version of each markdown page")
+	fs.BoolVar(&v.SearchIndex, "search-index", false, "write search-index.json for client-side search")
+	fs.Var(&f.only, "only", "only build pages and assets under this source path, e.g. blog/ (repeatable)")
+	fs.BoolVar(&v.CleanOutput, "clean-output", false, "remove output files the build no longer writes")
+	fs.BoolVar(&v.Integrity, "integrity", false, "write integrity.json with the checksum of every output file")
+	fs.IntVar(&v.InlineAssets, "inline-assets", 0, "inline images and fonts up to this many bytes as data URIs")
+	fs.StringVar(&v.Env, "env", "", "build environment, e.g. production (default: development)")
+	fs.StringVar(&v.WatchMode, "watch-mode", "", "how changes are noticed: events or poll (default: events)")
+	fs.StringVar(&v.Diagnostics, "diagnostics", "text", "how build warnings and errors are printed: text or json")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s [flags] [project directory]\n\n", os.Args[0], name)
+		fmt.Fprintf(os.Stderr, "%s\n", description)
+		fmt.Fprintf(os.Stderr, "The project is found by looking for sniplicity.yaml from the current directory up.\n\n")
+		fs.PrintDefaults()
+	}
+	return f
+}
+
+// load parses args and returns the project's config with the given flags applied
+func (f *projectFlags) load(args []string) (config.Config, error) {
+	f.only = nil // Parsed again when sniplicity.yaml changes
+	f.fs.Parse(args)
+	if f.fs.NArg() > 1 {
+		f.fs.Usage()
+		return config.Config{}, fmt.Errorf("expected at most one project directory, got %s", strings.Join(f.fs.Args(), " "))
+	}
+
+	projectDir := f.fs.Arg(0)
+	if projectDir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return config.Config{}, fmt.Errorf("cannot get current working directory: %w", err)
+		}
+		projectDir = findProjectDir(wd)
+	}
+	absProjectDir, err := filepath.Abs(projectDir)
+	if err != nil {
+		return config.Config{}, fmt.Errorf("resolving %s: %w", projectDir, err)
+	}
+
+	cfg, err := config.LoadConfigFromFile(absProjectDir)
+	if err != nil {
+		return config.Config{}, fmt.Errorf("loading config: %w", err)
+	}
+
+	// The environment's settings come first, so the other flags still override them
+	env := ""
+	f.fs.Visit(func(fl *flag.Flag) {
+		if fl.Name == "env" {
+			env = f.values.Env
+		}
+	})
+	if env == "" && f.deploy {
+		env = cfg.DeployEnv()
+	}
+	if env != "" {
+		if err := cfg.SetEnvironment(env); err != nil {
+			return config.Config{}, fmt.Errorf("loading config: %w", err)
+		}
+	}
+
+	var flagErr error
+	f.fs.Visit(func(fl *flag.Flag) {
+		var err error
+		switch fl.Name {
+		case "i", "in":
+			cfg.InputDir, err = filepath.Abs(f.values.InputDir)
+		case "o", "out":
+			cfg.OutputDir, err = filepath.Abs(f.values.OutputDir)
+		case "v", "verbose":
+			cfg.Verbose = f.values.Verbose
+			if cfg.Verbose {
+				cfg.LogLevel = "debug"
+			}
+		case "log-level":
+			_, err = logging.ParseLevel(f.values.LogLevel)
+			cfg.LogLevel = f.values.LogLevel
+		case "q", "quiet":
+			if f.quiet {
+				cfg.LogLevel = "error"
+			}
+		case "no-color":
+			cfg.NoColor = f.values.NoColor
+		case "dry-run":
+			cfg.DryRun = f.values.DryRun
+		case "p", "port":
+			cfg.Port = f.values.Port
+		case "host":
+			cfg.Host = f.values.Host
+		case "tls":
+			cfg.TLS = f.values.TLS
+		case "tls-cert":
+			cfg.TLSCert, err = filepath.Abs(f.values.TLSCert)
+		case "tls-key":
+			cfg.TLSKey, err = filepath.Abs(f.values.TLSKey)
+		case "imgsize":
+			cfg.ImgSize, err = parseOnOff("imgsize", f.imgSize)
+		case "svgfilter":
+			cfg.SvgFilter, err = parseOnOff("svgfilter", f.svgFilter)
+		case "check-links":
+			cfg.CheckLinks = f.values.CheckLinks
+		case "strict":
+			cfg.Strict = f.values.Strict
+		case "stats":
+			cfg.Stats = f.values.Stats
+		case "drafts":
+			cfg.Drafts = f.values.Drafts
+		case "plain-text":
+			cfg.PlainText = f.values.PlainText
+		case "search-index":
+			cfg.SearchIndex = f.values.SearchIndex
+		case "only":
+			cfg.Only = f.only
+		case "clean-output":
+			cfg.CleanOutput = f.values.CleanOutput
+		case "integrity":
+			cfg.Integrity = f.values.Integrity
+		case "env":
+			cfg.Env = f.values.Env
+			if cfg.Env == "" {
+				err = fmt.Errorf("--env needs an environment name, e.g. production")
+			}
+		case "watch-mode":
+			cfg.WatchMode = f.values.WatchMode
+			if cfg.WatchMode != "events" && cfg.WatchMode != "poll" {
+				err = fmt.Errorf("--watch-mode must be events or poll, got %q", cfg.WatchMode)
+			}
+		case "inline-assets":
+			cfg.InlineAssets = f.values.InlineAssets
+			if cfg.InlineAssets < 0 {
+				err = fmt.Errorf("--inline-assets must be 0 or more bytes, got %d", cfg.InlineAssets)
+			}
+		}
+		if err != nil && flagErr == nil {
+			flagErr = err
+		}
+	})
+	if flagErr != nil {
+		return config.Config{}, flagErr
+	}
+
+	cfg.Diagnostics = f.values.Diagnostics
+	if err := checkDiagnostics(cfg.Diagnostics); err != nil {
+		return config.Config{}, err
+	}
+
+	if cfg.ProjectDir == "" && f.values.InputDir == "" {
+		return config.Config{}, fmt.Errorf("no sniplicity.yaml found in %s, create a project with %s init or pass -i and -o", absProjectDir, os.Args[0])
+	}
+	if _, err := os.Stat(cfg.GetAbsoluteInputDir()); err != nil {
+		return config.Config{}, fmt.Errorf("input directory %s does not exist", cfg.GetAbsoluteInputDir())
+	}
+
+	setupLogging(cfg)
+	warnConfigProblems(cfg)
+	return cfg, nil
+}
+
+// runBuild implements `sniplicity build`, which builds the project once
+func runBuild(args []string) error {
+	f := newProjectFlags("build", "Builds the project once and exits, with a non-zero status if the build fails.", false)
+	f.fs.BoolVar(&f.values.DryRun, "dry-run", false, "list the files the build would create, update or delete without writing anything")
+	cfg, err := f.load(args)
+	if err != nil {
+		return err
+	}
+	cfg.Watch, cfg.Serve = false, false
+	return runProject(cfg, false, nil)
+}
+
+// runWatch implements `sniplicity watch`, which builds and then rebuilds on every change
+func runWatch(args []string) error {
+	f := newProjectFlags("watch", "Builds the project, then rebuilds whenever a source file changes.", false)
+	cfg, err := f.load(args)
+	if err != nil {
+		return err
+	}
+	cfg.Watch, cfg.Serve = true, false
+	return runProject(cfg, false, func() (config.Config, error) { return f.load(args) })
+}
+
+// runServe implements `sniplicity serve`, which builds, watches and serves the site
+func runServe(args []string) error {
+	f := newProjectFlags("serve", "Builds the project, rebuilds whenever a source file changes and serves the site.", true)
+	cfg, err := f.load(args)
+	if err != nil {
+		return err
+	}
+	cfg.Watch, cfg.Serve = true, true
+	if f.open {
+		cfg.OpenBrowser = true // Asked for now, whatever open_browser says
+	}
+	return runProject(cfg, !f.open, func() (config.Config, error) { return f.load(args) })
+}
+
+// runProject builds the project described by cfg, watching or serving it as configured.
+// When sniplicity.yaml changes while watching, reload reads the config again.
+func runProject(cfg config.Config, clipboardOnly bool, reload func() (config.Config, error)) error {
+	if !cfg.DryRun {
+		if err := os.MkdirAll(cfg.GetAbsoluteOutputDir(), 0755); err != nil {
+			return fmt.Errorf("cannot create output directory: %w", err)
+		}
+	}
+	if cfg.Diagnostics != "json" {
+		printBanner()
+	}
+
+	b := builder.New(cfg)
+	if clipboardOnly {
+		b = builder.NewWithClipboardOnly(cfg)
+	}
+	b.SetConfigLoader(reload)
+	if err := b.Build(); err != nil {
+		return fmt.Errorf("build failed: %w", err)
+	}
+	return nil
+}
+
+// parseOnOff reads the value of an on/off flag
+func parseOnOff(name, value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "on", "true", "1", "yes":
+		return true, nil
+	case "off", "false", "0", "no":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid value for --%s: %s (use 'on' or 'off')", name, value)
+}
+
+// checkDiagnostics validates the --diagnostics format
+func checkDiagnostics(format string) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown diagnostics format %q (use text or json)", format)
+	}
+	return nil
+}
+
+// setupLogging applies the config's log level and colour choice. With JSON diagnostics
+// every message goes to stderr, keeping stdout for the report.
+func setupLogging(cfg config.Config) {
+	logging.SetLevel(cfg.Level())
+	if cfg.NoColor {
+		logging.SetColor(false)
+	}
+	if cfg.Diagnostics == "json" {
+		logging.SetOutput(os.Stderr, os.Stderr)
+	}
+}
+
+// warnConfigProblems points out unknown keys and bad values in sniplicity.yaml, which
+// would otherwise be silently ignored
+func warnConfigProblems(cfg config.Config) {
+	if cfg.ProjectDir == "" {
+		return
+	}
+	configPath := filepath.Join(cfg.ProjectDir, "sniplicity.yaml")
+	if problems, err := config.ValidateFile(configPath); err == nil {
+		for _, problem := range problems {
+			logging.Warnf("%s:%d:%d: %s", configPath, problem.Line, problem.Column, problem.Message)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"sniplicity/internal/builder"
+	"sniplicity/internal/config"
+)
+
+// runClean implements `sniplicity clean [--dry-run]`, which empties the output directory
+func runClean(args []string) error {
+	fs := flag.NewFlagSet("clean", flag.ExitOnError)
+	var dryRun bool
+	fs.BoolVar(&dryRun, "dry-run", false, "list what would be removed without removing it")
+	fs.BoolVar(&dryRun, "n", false, "list what would be removed without removing it")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s clean [--dry-run]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Removes everything in the project's output folder except hidden files such as .git.\n")
+		fmt.Fprintf(os.Stderr, "The next build writes it all again.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("cannot get current working directory: %w", err)
+	}
+	cfg, err := config.LoadConfigFromFile(findProjectDir(wd))
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if cfg.ProjectDir == "" {
+		return fmt.Errorf("no sniplicity.yaml found, run clean from inside a project")
+	}
+
+	removed, err := builder.Clean(cfg, dryRun)
+	verb := "Removed"
+	if dryRun {
+		verb = "Would remove"
+	}
+	for _, name := range removed {
+		fmt.Printf("  %s %s\n", verb, name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(removed) == 0 {
+		fmt.Printf("%s is already clean\n", cfg.GetAbsoluteOutputDir())
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"sniplicity/internal/config"
+)
+
+// runConfig implements `sniplicity config schema` and `sniplicity config validate`
+func runConfig(args []string) error {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s config schema [-o file]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s config validate [sniplicity.yaml]\n", os.Args[0])
+	}
+	if len(args) == 0 {
+		usage()
+		return fmt.Errorf("config needs a command")
+	}
+
+	switch args[0] {
+	case "schema":
+		return runConfigSchema(args[1:])
+	case "validate":
+		return runConfigValidate(args[1:])
+	}
+
+	usage()
+	return fmt.Errorf("unknown config command %q", args[0])
+}
+
+// runConfigSchema prints the JSON Schema for sniplicity.yaml
+func runConfigSchema(args []string) error {
+	fs := flag.NewFlagSet("config schema", flag.ExitOnError)
+	var outputFile string
+	fs.StringVar(&outputFile, "o", "", "write the schema to this file instead of stdout")
+	fs.StringVar(&outputFile, "out", "", "write the schema to this file instead of stdout")
+	fs.Parse(args)
+
+	data, err := json.MarshalIndent(config.Schema(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding schema: %w", err)
+	}
+	data = append(data, '\n')
+
+	if outputFile == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(outputFile, data, 0644); err != nil {
+		return fmt.Errorf("cannot write %s: %w", outputFile, err)
+	}
+	return nil
+}
+
+// runConfigValidate checks a config file and prints each problem as file:line:column
+func runConfigValidate(args []string) error {
+	fs := flag.NewFlagSet("config validate", flag.ExitOnError)
+	fs.Parse(args)
+
+	configPath := "sniplicity.yaml"
+	if fs.NArg() > 0 {
+		configPath = fs.Arg(0)
+	}
+	if info, err := os.Stat(configPath); err == nil && info.IsDir() {
+		configPath = filepath.Join(configPath, "sniplicity.yaml")
+	}
+
+	problems, err := config.ValidateFile(configPath)
+	if err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		fmt.Printf("%s:%d:%d: %s\n", configPath, problem.Line, problem.Column, problem.Message)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problem(s) in %s", len(problems), configPath)
+	}
+
+	fmt.Printf("%s is valid\n", configPath)
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"sniplicity/internal/deploy"
+	"sniplicity/internal/logging"
+)
+
+// runDeploy implements `sniplicity deploy`, which builds the project for the deploy
+// environment and then publishes it as the deploy section of sniplicity.yaml says
+func runDeploy(args []string) error {
+	f := newProjectFlags("deploy", "Builds the project for production, or deploy's env, then runs the deploy command or rsync from sniplicity.yaml.", false)
+	f.deploy = true
+	cfg, err := f.load(args)
+	if err != nil {
+		return err
+	}
+	if err := cfg.CheckDeploy(); err != nil {
+		return err
+	}
+	cfg.Watch, cfg.Serve = false, false
+
+	if err := runProject(cfg, false, nil); err != nil {
+		return err
+	}
+	logging.Infof("%s", deploy.Describe(cfg))
+	if err := deploy.Run(cfg, func(line string) { logging.Infof("  %s", line) }); err != nil {
+		return err
+	}
+	logging.Infof("Deployed")
+	return nil
+}
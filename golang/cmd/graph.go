@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"sniplicity/internal/builder"
+	"sniplicity/internal/config"
+	"sniplicity/internal/logging"
+)
+
+// runGraph implements `sniplicity graph [--format dot|json]`, which prints which templates,
+// snippets and includes every page depends on
+func runGraph(args []string) error {
+	fs := flag.NewFlagSet("graph", flag.ExitOnError)
+	var format, outputFile string
+	fs.StringVar(&format, "format", "dot", "output format: dot (Graphviz) or json")
+	fs.StringVar(&outputFile, "o", "", "write the graph to this file instead of stdout")
+	fs.StringVar(&outputFile, "out", "", "write the graph to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s graph [--format dot|json] [-o file] [project directory]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Prints the page -> template -> snippet -> include dependency graph without building.\n")
+		fmt.Fprintf(os.Stderr, "Render the DOT output with Graphviz, e.g. %s graph | dot -Tsvg > graph.svg\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if format != "dot" && format != "json" {
+		return fmt.Errorf("--format must be dot or json, not %q", format)
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("expected at most one project directory")
+	}
+
+	dir := fs.Arg(0)
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("cannot get current working directory: %w", err)
+		}
+		dir = findProjectDir(wd)
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("resolving %s: %w", dir, err)
+	}
+	cfg, err := config.LoadConfigFromFile(absDir)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if cfg.ProjectDir == "" {
+		return fmt.Errorf("no sniplicity.yaml found in %s", absDir)
+	}
+
+	// Keep stdout clean for the graph
+	logging.SetOutput(os.Stderr, os.Stderr)
+
+	b := builder.New(cfg)
+	g, err := b.DependencyGraph()
+	b.Diagnostics().WriteText(os.Stderr, logging.Enabled(logging.Warn))
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			return fmt.Errorf("creating %s: %w", outputFile, err)
+		}
+		defer file.Close()
+		out = file
+	}
+	if format == "json" {
+		return g.WriteJSON(out)
+	}
+	return g.WriteDOT(out)
+}
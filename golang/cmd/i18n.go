@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sniplicity/internal/config"
+	"sniplicity/internal/i18n"
+)
+
+// runI18n implements `sniplicity i18n extract`, which collects {{t "..."}} strings into catalogs
+func runI18n(args []string) error {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s i18n extract [--lang fr,de] [--format po|json]\n", os.Args[0])
+	}
+	if len(args) == 0 {
+		usage()
+		return fmt.Errorf("i18n needs a command")
+	}
+	if args[0] != "extract" {
+		usage()
+		return fmt.Errorf("unknown i18n command %q", args[0])
+	}
+
+	fs := flag.NewFlagSet("i18n extract", flag.ExitOnError)
+	var langs, format string
+	fs.StringVar(&langs, "lang", "", "comma separated languages to create or update catalogs for (default: a messages template)")
+	fs.StringVar(&format, "format", "po", "catalog format: po or json")
+	fs.Usage = func() {
+		usage()
+		fmt.Fprintf(os.Stderr, "\nCollects the {{t \"...\"}} strings from the source folder into %s/<lang>.po or .json,\n", i18n.LocalesDir)
+		fmt.Fprintf(os.Stderr, "keeping existing translations. Without --lang it writes %s/%s.pot (or .json).\n\n", i18n.LocalesDir, i18n.TemplateName)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args[1:])
+	if format != "po" && format != "json" {
+		return fmt.Errorf("--format must be po or json, not %q", format)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("cannot get current working directory: %w", err)
+	}
+	cfg, err := config.LoadConfigFromFile(findProjectDir(wd))
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if cfg.ProjectDir == "" {
+		return fmt.Errorf("no sniplicity.yaml found, run i18n from inside a project")
+	}
+
+	messages, err := i18n.ExtractDir(cfg.GetAbsoluteInputDir())
+	if err != nil {
+		return fmt.Errorf("extracting strings: %w", err)
+	}
+	localesDir := filepath.Join(cfg.ProjectDir, i18n.LocalesDir)
+	fmt.Printf("Found %d translatable string(s)\n", len(messages))
+
+	if langs == "" {
+		ext := ".pot"
+		if format == "json" {
+			ext = ".json"
+		}
+		path := filepath.Join(localesDir, i18n.TemplateName+ext)
+		if _, err := i18n.WriteCatalog(path, "", messages, nil); err != nil {
+			return err
+		}
+		fmt.Printf("  wrote %s\n", path)
+		return nil
+	}
+
+	for _, lang := range strings.Split(langs, ",") {
+		lang = strings.TrimSpace(lang)
+		if lang == "" {
+			continue
+		}
+		path := filepath.Join(localesDir, lang+"."+format)
+
+		existing := make(i18n.Catalog)
+		if _, err := os.Stat(path); err == nil {
+			if existing, err = i18n.LoadCatalog(path); err != nil {
+				return err
+			}
+		}
+
+		untranslated, err := i18n.WriteCatalog(path, lang, messages, existing)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("  wrote %s (%d untranslated)\n", path, untranslated)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"sniplicity/internal/scaffold"
+)
+
+// runInit implements `sniplicity init [--theme blog] [dir]`, which scaffolds a new project
+func runInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	var theme string
+	fs.StringVar(&theme, "theme", "basic", "starter to create: "+strings.Join(scaffold.Themes, ", "))
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s init [--theme name] [directory]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Creates sniplicity.yaml, a snip/ folder with starter pages and a www/ output folder.\n")
+		fmt.Fprintf(os.Stderr, "The directory defaults to the current one and is created if needed.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	created, err := scaffold.Create(dir, theme)
+	for _, file := range created {
+		fmt.Printf("  created %s\n", file)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nNew %s project ready. Build it and rebuild as you edit with:\n\n", theme)
+	if dir != "." {
+		fmt.Printf("  cd %s\n", dir)
+	}
+	fmt.Printf("  %s watch\n", os.Args[0])
+	fmt.Printf("\nor preview it in the browser with %s serve.\n", os.Args[0])
+	return nil
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sniplicity/internal/builder"
+	"sniplicity/internal/config"
+	"sniplicity/internal/logging"
+)
+
+// runLegacy runs the original flag-based interface: -i and -o to build a folder, -w and
+// -s to watch and serve it, or no arguments at all for the project selector. It is kept
+// for existing scripts; new ones should use the build, watch and serve commands.
+func runLegacy() {
+	// Command line flags
+	var cfg config.Config
+	var imgSizeFlag string
+	var svgFilterFlag string
+	
+	flag.StringVar(&cfg.InputDir, "i", "", "source directory")
+	flag.StringVar(&cfg.InputDir, "in", "", "source directory")
+	flag.StringVar(&cfg.OutputDir, "o", "", "output directory for compiled files")
+	flag.StringVar(&cfg.OutputDir, "out", "", "output directory for compiled files")
+	flag.BoolVar(&cfg.Watch, "w", false, "keep watching the input directory")
+	flag.BoolVar(&cfg.Watch, "watch", false, "keep watching the input directory")
+	flag.BoolVar(&cfg.Verbose, "v", false, "extra console messages")
+	flag.BoolVar(&cfg.Verbose, "verbose", false, "extra console messages")
+	var quiet bool
+	flag.BoolVar(&quiet, "q", false, "only print errors")
+	flag.BoolVar(&quiet, "quiet", false, "only print errors")
+	flag.StringVar(&cfg.LogLevel, "log-level", "", "how much to print: error, warn, info or debug (default: info)")
+	flag.BoolVar(&cfg.NoColor, "no-color", false, "leave out ANSI colours")
+	flag.BoolVar(&cfg.Serve, "s", false, "start web server and enable watch mode")
+	flag.BoolVar(&cfg.Serve, "serve", false, "start web server and enable watch mode")
+	flag.IntVar(&cfg.Port, "p", 3000, "port for web server (default 3000)")
+	flag.IntVar(&cfg.Port, "port", 3000, "port for web server (default 3000)")
+	flag.StringVar(&cfg.Host, "host", "", "address the web server listens on, e.g. 0.0.0.0 for the whole network (default: 127.0.0.1)")
+	flag.BoolVar(&cfg.TLS, "tls", false, "serve over HTTPS with a self-signed certificate")
+	flag.StringVar(&cfg.TLSCert, "tls-cert", "", "certificate file for HTTPS instead of a self-signed one")
+	flag.StringVar(&cfg.TLSKey, "tls-key", "", "private key file of --tls-cert")
+	flag.StringVar(&imgSizeFlag, "imgsize", "", "automatically add width/height to img tags (on/off, default: on)")
+	flag.StringVar(&svgFilterFlag, "svgfilter", "", "process SVG files with CSS filters (on/off, default: on)")
+	flag.BoolVar(&cfg.CheckLinks, "check-links", false, "report links to missing pages and files after building")
+	flag.BoolVar(&cfg.Strict, "strict", false, "fail the build on missing snippets, templates, variables and includes")
+	flag.BoolVar(&cfg.Stats, "stats", false, "record local build statistics (see: sniplicity stats)")
+	flag.BoolVar(&cfg.Drafts, "drafts", false, "build and list pages marked draft: true")
+	flag.BoolVar(&cfg.PlainText, "plain-text", false, "also write a .txt plain text version of each markdown page")
+	flag.BoolVar(&cfg.CleanOutput, "clean-output", false, "remove output files the build no longer writes")
+	flag.BoolVar(&cfg.Integrity, "integrity", false, "write integrity.json with the checksum of every output file")
+	flag.IntVar(&cfg.InlineAssets, "inline-assets", 0, "inline images and fonts up to this many bytes as data URIs")
+	flag.StringVar(&cfg.Env, "env", "", "build environment, e.g. production (default: development)")
+	flag.StringVar(&cfg.WatchMode, "watch-mode", "", "how changes are noticed: events or poll (default: events)")
+	flag.StringVar(&cfg.Diagnostics, "diagnostics", "text", "how build warnings and errors are printed: text or json")
+	
+	var showVersion bool
+	flag.BoolVar(&showVersion, "version", false, "show version")
+	
+	// Track if -s flag was explicitly provided (for clipboard-only behavior)
+	var explicitServeFlag bool
+	
+	flag.Usage = func() {
+		printUsage()
+		fmt.Fprintf(os.Stderr, "\nThe original flags, without a command:\n\n")
+		fmt.Fprintf(os.Stderr, "  %s -i source_folder -o destination_folder [-w] [-v] [-s [-p port]]\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	
+	flag.Parse()
+	
+	// Check if -s flag was explicitly provided
+	for _, arg := range os.Args[1:] {
+		if arg == "-s" || arg == "-serve" {
+			explicitServeFlag = true
+			break
+		}
+	}
+	
+	if showVersion {
+		fmt.Printf("sniplicity %s\n", version)
+		return
+	}
+	
+	if err := checkDiagnostics(cfg.Diagnostics); err != nil {
+		log.Fatal(err)
+	}
+	if cfg.LogLevel != "" {
+		if _, err := logging.ParseLevel(cfg.LogLevel); err != nil {
+			log.Fatal(err)
+		}
+	}
+	
+	// Determine project directory and handle backward compatibility
+	var explicitInputDir, explicitOutputDir string
+	var explicitImgSize *bool
+	var explicitSvgFilter *bool
+	var isLegacyMode bool
+	
+	// Parse imgsize flag
+	if imgSizeFlag != "" {
+		value, err := parseOnOff("imgsize", imgSizeFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		explicitImgSize = &value
+	}
+	
+	// Parse svgfilter flag
+	if svgFilterFlag != "" {
+		value, err := parseOnOff("svgfilter", svgFilterFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		explicitSvgFilter = &value
+	}
+	
+	// Project directory determination
+	var projectDir string
+	var err error
+	
+	// Check for any explicit command line flags that indicate legacy usage
+	isLegacyMode = cfg.InputDir != "" || cfg.OutputDir != "" || cfg.Watch || cfg.Verbose || cfg.Port != 3000 || explicitImgSize != nil || explicitSvgFilter != nil
+	
+	// Special case: if only -s (serve) flag is provided, treat as project selection mode, not legacy mode
+	if cfg.Serve && cfg.InputDir == "" && cfg.OutputDir == "" && !cfg.Watch && !cfg.Verbose && cfg.Port == 3000 && explicitImgSize == nil && explicitSvgFilter == nil {
+		isLegacyMode = false
+	}
+	
+	if cfg.InputDir != "" || cfg.OutputDir != "" {
+		// Legacy mode: explicit -i and/or -o flags provided
+		explicitInputDir = cfg.InputDir
+		explicitOutputDir = cfg.OutputDir
+		
+		// In legacy mode, determine project directory from input directory
+		if cfg.InputDir != "" {
+			// Use parent directory of input directory as project directory
+			inputAbsPath, err := filepath.Abs(cfg.InputDir)
+			if err != nil {
+				log.Fatalf("Cannot get absolute path for input directory: %v", err)
+			}
+			projectDir = filepath.Dir(inputAbsPath)
+		} else {
+			// Fallback to current working directory
+			projectDir, err = os.Getwd()
+			if err != nil {
+				log.Fatalf("Cannot get current working directory: %v", err)
+			}
+		}
+		
+		cfg.InputDir = ""  // Reset so we can override from config
+		cfg.OutputDir = "" // Reset so we can override from config
+	} else {
+		// Project directory is the current working directory
+		projectDir, err = os.Getwd()
+		if err != nil {
+			log.Fatalf("Cannot get current working directory: %v", err)
+		}
+	}
+	
+	absProjectDir, err := filepath.Abs(projectDir)
+	if err != nil {
+		log.Fatalf("Cannot get absolute project directory: %v", err)
+	}
+	
+	// Certificate paths are relative to where sniplicity was started, not the project
+	for _, path := range []*string{&cfg.TLSCert, &cfg.TLSKey} {
+		if *path == "" {
+			continue
+		}
+		absPath, err := filepath.Abs(*path)
+		if err != nil {
+			log.Fatalf("Cannot get absolute path for %s: %v", *path, err)
+		}
+		*path = absPath
+	}
+	
+	// Load configuration from file (if exists)
+	fileCfg, err := config.LoadConfigFromFile(absProjectDir)
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	if cfg.Env != "" {
+		// Before the other flags, which override the environment's settings
+		if err := fileCfg.SetEnvironment(cfg.Env); err != nil {
+			log.Fatalf("Error loading config: %v", err)
+		}
+	}
+	
+	// With JSON diagnostics stdout is kept for the report
+	fileCfg.Diagnostics = cfg.Diagnostics
+	
+	// Command line flags override config file values
+	if explicitInputDir != "" {
+		// Legacy mode: -i flag overrides everything (absolute path)
+		fileCfg.InputDir = explicitInputDir
+		// Make it relative to project dir if possible, otherwise keep absolute.
+		// Without a config file there is no project dir to be relative to.
+		if rel, err := filepath.Rel(absProjectDir, explicitInputDir); err == nil && !strings.HasPrefix(rel, "..") && fileCfg.ProjectDir != "" {
+			fileCfg.InputDir = rel
+		} else {
+			fileCfg.InputDir = explicitInputDir // Keep absolute
+		}
+	}
+	if explicitOutputDir != "" {
+		// Legacy mode: -o flag overrides everything (absolute path)
+		fileCfg.OutputDir = explicitOutputDir
+		// Make it relative to project dir if possible, otherwise keep absolute.
+		// Without a config file there is no project dir to be relative to.
+		if rel, err := filepath.Rel(absProjectDir, explicitOutputDir); err == nil && !strings.HasPrefix(rel, "..") && fileCfg.ProjectDir != "" {
+			fileCfg.OutputDir = rel
+		} else {
+			fileCfg.OutputDir = explicitOutputDir // Keep absolute
+		}
+	}
+	
+	// In legacy mode, command line flags completely override config file
+	if isLegacyMode {
+		// In legacy mode, serve defaults to false unless explicitly set
+		fileCfg.Serve = cfg.Serve
+		fileCfg.Watch = cfg.Watch
+		fileCfg.Verbose = cfg.Verbose
+		if cfg.Port != 3000 { // Only override if explicitly set
+			fileCfg.Port = cfg.Port
+		}
+		if cfg.Host != "" {
+			fileCfg.Host = cfg.Host
+		}
+		if cfg.TLS {
+			fileCfg.TLS = cfg.TLS
+		}
+		if cfg.TLSCert != "" {
+			fileCfg.TLSCert = cfg.TLSCert
+		}
+		if cfg.TLSKey != "" {
+			fileCfg.TLSKey = cfg.TLSKey
+		}
+		if explicitImgSize != nil {
+			fileCfg.ImgSize = *explicitImgSize
+		}
+		if explicitSvgFilter != nil {
+			fileCfg.SvgFilter = *explicitSvgFilter
+		}
+		fileCfg.CheckLinks = cfg.CheckLinks
+		fileCfg.Strict = cfg.Strict
+		fileCfg.Stats = cfg.Stats
+		fileCfg.Drafts = cfg.Drafts
+		fileCfg.PlainText = cfg.PlainText
+		fileCfg.CleanOutput = cfg.CleanOutput
+		fileCfg.Integrity = cfg.Integrity
+		fileCfg.InlineAssets = cfg.InlineAssets
+		if cfg.Env != "" {
+			fileCfg.Env = cfg.Env
+		}
+		if cfg.WatchMode != "" {
+			fileCfg.WatchMode = cfg.WatchMode
+		}
+	} else {
+		// In project mode, only override if explicitly set
+		if cfg.Watch {
+			fileCfg.Watch = cfg.Watch
+		}
+		if cfg.Verbose {
+			fileCfg.Verbose = cfg.Verbose
+		}
+		if cfg.Serve {
+			fileCfg.Serve = cfg.Serve
+		}
+		if cfg.Port != 3000 { // Only override if explicitly set
+			fileCfg.Port = cfg.Port
+		}
+		if cfg.Host != "" {
+			fileCfg.Host = cfg.Host
+		}
+		if cfg.TLS {
+			fileCfg.TLS = cfg.TLS
+		}
+		if cfg.TLSCert != "" {
+			fileCfg.TLSCert = cfg.TLSCert
+		}
+		if cfg.TLSKey != "" {
+			fileCfg.TLSKey = cfg.TLSKey
+		}
+		if explicitImgSize != nil {
+			fileCfg.ImgSize = *explicitImgSize
+		}
+		if explicitSvgFilter != nil {
+			fileCfg.SvgFilter = *explicitSvgFilter
+		}
+		if cfg.CheckLinks {
+			fileCfg.CheckLinks = cfg.CheckLinks
+		}
+		if cfg.Strict {
+			fileCfg.Strict = cfg.Strict
+		}
+		if cfg.Stats {
+			fileCfg.Stats = cfg.Stats
+		}
+		if cfg.Drafts {
+			fileCfg.Drafts = cfg.Drafts
+		}
+		if cfg.PlainText {
+			fileCfg.PlainText = cfg.PlainText
+		}
+		if cfg.CleanOutput {
+			fileCfg.CleanOutput = cfg.CleanOutput
+		}
+		if cfg.Integrity {
+			fileCfg.Integrity = cfg.Integrity
+		}
+		if cfg.InlineAssets > 0 {
+			fileCfg.InlineAssets = cfg.InlineAssets
+		}
+		if cfg.Env != "" {
+			fileCfg.Env = cfg.Env
+		}
+		if cfg.WatchMode != "" {
+			fileCfg.WatchMode = cfg.WatchMode
+		}
+	}
+	
+	// How much is printed, in either mode
+	if cfg.Verbose {
+		fileCfg.LogLevel = "debug"
+	}
+	if cfg.LogLevel != "" {
+		fileCfg.LogLevel = cfg.LogLevel
+	}
+	if quiet {
+		fileCfg.LogLevel = "error"
+	}
+	fileCfg.NoColor = cfg.NoColor
+	
+	cfg = fileCfg
+	setupLogging(cfg)
+	warnConfigProblems(cfg)
+	
+	// Set legacy mode flag
+	cfg.LegacyMode = isLegacyMode
+	
+	// If serve is enabled, automatically enable watch mode
+	if cfg.Serve {
+		cfg.Watch = true
+	}
+	
+	if cfg.Diagnostics != "json" {
+		printBanner()
+	}
+	
+	// Handle the case where no arguments are provided - start project selection mode
+	if len(os.Args) == 1 {
+		// No arguments provided, start in project selection mode with serve enabled
+		cfg.Serve = true
+		cfg.Watch = true
+		isLegacyMode = false
+	}
+	
+	// In project selection mode (non-legacy with serve), skip project validation and building
+	if !isLegacyMode && cfg.Serve {
+		// Start directly in web server mode for project selection
+		var b *builder.Builder
+		if explicitServeFlag {
+			// Use clipboard-only mode when -s flag was explicitly provided
+			b = builder.NewWithClipboardOnly(cfg)
+		} else {
+			// Use normal mode (with browser opening) when no args provided
+			b = builder.New(cfg)
+		}
+		if err := b.StartProjectSelectionMode(); err != nil {
+			log.Fatalf("Failed to start project selection mode: %v", err)
+		}
+		return
+	}
+	
+	// Check if input directory exists
+	absInputDir := cfg.GetAbsoluteInputDir()
+	if _, err := os.Stat(absInputDir); os.IsNotExist(err) {
+		log.Fatalf("Input directory %s does not exist", absInputDir)
+	}
+	
+	// Create output directory if it doesn't exist
+	absOutputDir := cfg.GetAbsoluteOutputDir()
+	if err := os.MkdirAll(absOutputDir, 0755); err != nil {
+		log.Fatalf("Cannot create output directory: %v", err)
+	}
+	
+	// Initialize and run the builder
+	var b *builder.Builder
+	if cfg.Serve && explicitServeFlag {
+		// Use clipboard-only mode when -s flag was explicitly provided
+		b = builder.NewWithClipboardOnly(cfg)
+	} else {
+		b = builder.New(cfg)
+	}
+	if err := b.Build(); err != nil {
+		log.Fatalf("Build failed: %v", err)
+	}
+}
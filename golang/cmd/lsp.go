@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"sniplicity/internal/config"
+	"sniplicity/internal/logging"
+	"sniplicity/internal/lsp"
+)
+
+// runLSP implements `sniplicity lsp`, a language server speaking JSON-RPC on stdin/stdout
+func runLSP(args []string) error {
+	fs := flag.NewFlagSet("lsp", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s lsp\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Runs a language server on stdin/stdout for editor integrations.\n")
+		fmt.Fprintf(os.Stderr, "The project is taken from the editor's workspace root, or the current directory.\n")
+	}
+	fs.Parse(args)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("cannot get current working directory: %w", err)
+	}
+
+	// stdout carries the protocol, so any messages go to stderr
+	logging.SetOutput(os.Stderr, os.Stderr)
+
+	cfg, err := config.LoadConfigFromFile(findProjectDir(wd))
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	return lsp.Serve(os.Stdin, os.Stdout, cfg.GetAbsoluteInputDir())
+}
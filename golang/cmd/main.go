@@ -1,283 +1,166 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
 	"strings"
 
-	"sniplicity/internal/builder"
-	"sniplicity/internal/config"
+	"sniplicity/internal/logging"
+	"sniplicity/internal/stats"
+
+	"github.com/fatih/color"
 )
 
 const version = "0.1.10"
 
+// printBanner prints the logo, unless messages are turned off with --quiet
 func printBanner() {
-	fmt.Printf("\033[32m            _      \033[36m _  _       _             \033[0m\n")
-	fmt.Printf("\033[32m           (_)     \033[36m| |(_)     (_)  _         \033[0m\n")
-	fmt.Printf("\033[32m  ___ ____  _ ____ \033[36m| | _  ____ _ _| |_ _   _  \033[0m\n")
-	fmt.Printf("\033[32m /___)  _ \\| |  _ \\\033[36m| || |/ ___) (_   _) | | |\033[0m\n")
-	fmt.Printf("\033[32m|___ | | | | | |_| \033[36m| || ( (___| | | |_| |_| |\033[0m\n")
-	fmt.Printf("\033[32m(___/|_| |_|_|  __/\033[36m \\_)_|\\____)_|  \\__)\\__  |\033[0m\n")
-	fmt.Printf("\033[32m             |_|   \033[36m                   (____/ \033[0m\n")
-	fmt.Printf("  \033[2;37mhttp://github.com/davebalmer/sniplicity\033[0m\n")
+	green := color.New(color.FgGreen)
+	cyan := color.New(color.FgCyan)
+	logo := [][2]string{
+		{"            _      ", " _  _       _             "},
+		{"           (_)     ", "| |(_)     (_)  _         "},
+		{"  ___ ____  _ ____ ", "| | _  ____ _ _| |_ _   _  "},
+		{" /___)  _ \\| |  _ \\", "| || |/ ___) (_   _) | | |"},
+		{"|___ | | | | | |_| ", "| || ( (___| | | |_| |_| |"},
+		{"(___/|_| |_|_|  __/", " \\_)_|\\____)_|  \\__)\\__  |"},
+		{"             |_|   ", "                   (____/ "},
+	}
+	for _, line := range logo {
+		logging.Infof("%s%s", green.Sprint(line[0]), cyan.Sprint(line[1]))
+	}
+	logging.Infof("  %s", color.New(color.Faint, color.FgWhite).Sprint("http://github.com/davebalmer/sniplicity"))
 }
 
 func main() {
-	// Command line flags
-	var cfg config.Config
-	var imgSizeFlag string
-	var svgFilterFlag string
-	
-	flag.StringVar(&cfg.InputDir, "i", "", "source directory")
-	flag.StringVar(&cfg.InputDir, "in", "", "source directory")
-	flag.StringVar(&cfg.OutputDir, "o", "", "output directory for compiled files")
-	flag.StringVar(&cfg.OutputDir, "out", "", "output directory for compiled files")
-	flag.BoolVar(&cfg.Watch, "w", false, "keep watching the input directory")
-	flag.BoolVar(&cfg.Watch, "watch", false, "keep watching the input directory")
-	flag.BoolVar(&cfg.Verbose, "v", false, "extra console messages")
-	flag.BoolVar(&cfg.Verbose, "verbose", false, "extra console messages")
-	flag.BoolVar(&cfg.Serve, "s", false, "start web server and enable watch mode")
-	flag.BoolVar(&cfg.Serve, "serve", false, "start web server and enable watch mode")
-	flag.IntVar(&cfg.Port, "p", 3000, "port for web server (default 3000)")
-	flag.IntVar(&cfg.Port, "port", 3000, "port for web server (default 3000)")
-	flag.StringVar(&imgSizeFlag, "imgsize", "", "automatically add width/height to img tags (on/off, default: on)")
-	flag.StringVar(&svgFilterFlag, "svgfilter", "", "process SVG files with CSS filters (on/off, default: on)")
-	
-	var showVersion bool
-	flag.BoolVar(&showVersion, "version", false, "show version")
-	
-	// Track if -s flag was explicitly provided (for clipboard-only behavior)
-	var explicitServeFlag bool
-	
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "\033[1;37mBuild simple static websites using:\033[0m\n\n")
-		fmt.Fprintf(os.Stderr, "  - snippets with \033[32m<!-- copy x -->\033[0m and \033[32m<!-- paste x -->\033[0m\n")
-		fmt.Fprintf(os.Stderr, "  - variables using \033[32m<!-- set y -->\033[0m and \033[32m<!-- global z -->\033[0m\n")
-		fmt.Fprintf(os.Stderr, "  - include files with \033[32m<!-- include filename.html -->\033[0m\n\n")
-		fmt.Fprintf(os.Stderr, "  \033[1;33mSee README.md to get started.\033[0m\n\n")
-		fmt.Fprintf(os.Stderr, "Usage: %s -i source_folder -o destination_folder [-w] [-v] [-s [-p port]]\n\n", os.Args[0])
-		flag.PrintDefaults()
-	}
-	
-	flag.Parse()
-	
-	// Check if -s flag was explicitly provided
-	for _, arg := range os.Args[1:] {
-		if arg == "-s" || arg == "-serve" {
-			explicitServeFlag = true
-			break
-		}
-	}
-	
-	if showVersion {
-		fmt.Printf("sniplicity %s\n", version)
-		return
-	}
-	
-	// Determine project directory and handle backward compatibility
-	var explicitInputDir, explicitOutputDir string
-	var explicitImgSize *bool
-	var explicitSvgFilter *bool
-	var isLegacyMode bool
-	
-	// Parse imgsize flag
-	if imgSizeFlag != "" {
-		switch strings.ToLower(imgSizeFlag) {
-		case "on", "true", "1", "yes":
-			explicitImgSize = &[]bool{true}[0]
-		case "off", "false", "0", "no":
-			explicitImgSize = &[]bool{false}[0]
-		default:
-			log.Fatalf("Invalid value for --imgsize: %s (use 'on' or 'off')", imgSizeFlag)
-		}
-	}
-	
-	// Parse svgfilter flag
-	if svgFilterFlag != "" {
-		switch strings.ToLower(svgFilterFlag) {
-		case "on", "true", "1", "yes":
-			explicitSvgFilter = &[]bool{true}[0]
-		case "off", "false", "0", "no":
-			explicitSvgFilter = &[]bool{false}[0]
-		default:
-			log.Fatalf("Invalid value for --svgfilter: %s (use 'on' or 'off')", svgFilterFlag)
-		}
-	}
-	
-	// Project directory determination
-	var projectDir string
-	var err error
-	
-	// Check for any explicit command line flags that indicate legacy usage
-	isLegacyMode = cfg.InputDir != "" || cfg.OutputDir != "" || cfg.Watch || cfg.Verbose || cfg.Port != 3000 || explicitImgSize != nil || explicitSvgFilter != nil
-	
-	// Special case: if only -s (serve) flag is provided, treat as project selection mode, not legacy mode
-	if cfg.Serve && cfg.InputDir == "" && cfg.OutputDir == "" && !cfg.Watch && !cfg.Verbose && cfg.Port == 3000 && explicitImgSize == nil && explicitSvgFilter == nil {
-		isLegacyMode = false
-	}
-	
-	if cfg.InputDir != "" || cfg.OutputDir != "" {
-		// Legacy mode: explicit -i and/or -o flags provided
-		explicitInputDir = cfg.InputDir
-		explicitOutputDir = cfg.OutputDir
-		
-		// In legacy mode, determine project directory from input directory
-		if cfg.InputDir != "" {
-			// Use parent directory of input directory as project directory
-			inputAbsPath, err := filepath.Abs(cfg.InputDir)
-			if err != nil {
-				log.Fatalf("Cannot get absolute path for input directory: %v", err)
+	stats.Version = version
+	
+	// Commands come first; flags without a command are the original interface
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		switch os.Args[1] {
+		case "build":
+			if err := runBuild(os.Args[2:]); err != nil {
+				log.Fatalf("Build: %v", err)
+			}
+			return
+		case "watch":
+			if err := runWatch(os.Args[2:]); err != nil {
+				log.Fatalf("Watch: %v", err)
+			}
+			return
+		case "serve":
+			if err := runServe(os.Args[2:]); err != nil {
+				log.Fatalf("Serve: %v", err)
+			}
+			return
+		case "version":
+			fmt.Printf("sniplicity %s\n", version)
+			return
+		case "help":
+			printUsage()
+			return
+		case "deploy":
+			if err := runDeploy(os.Args[2:]); err != nil {
+				log.Fatalf("Deploy: %v", err)
+			}
+			return
+		case "render":
+			if err := runRender(os.Args[2:]); err != nil {
+				log.Fatalf("Render failed: %v", err)
+			}
+			return
+		case "lsp":
+			if err := runLSP(os.Args[2:]); err != nil {
+				log.Fatalf("Language server failed: %v", err)
 			}
-			projectDir = filepath.Dir(inputAbsPath)
-		} else {
-			// Fallback to current working directory
-			projectDir, err = os.Getwd()
-			if err != nil {
-				log.Fatalf("Cannot get current working directory: %v", err)
+			return
+		case "syntax":
+			if err := runSyntax(os.Args[2:]); err != nil {
+				log.Fatalf("Syntax export failed: %v", err)
 			}
+			return
+		case "config":
+			if err := runConfig(os.Args[2:]); err != nil {
+				log.Fatalf("Config: %v", err)
+			}
+			return
+		case "stats":
+			if err := runStats(os.Args[2:]); err != nil {
+				log.Fatalf("Stats: %v", err)
+			}
+			return
+		case "init":
+			if err := runInit(os.Args[2:]); err != nil {
+				log.Fatalf("Init: %v", err)
+			}
+			return
+		case "new":
+			if err := runNew(os.Args[2:]); err != nil {
+				log.Fatalf("New: %v", err)
+			}
+			return
+		case "clean":
+			if err := runClean(os.Args[2:]); err != nil {
+				log.Fatalf("Clean: %v", err)
+			}
+			return
+		case "graph":
+			if err := runGraph(os.Args[2:]); err != nil {
+				log.Fatalf("Graph: %v", err)
+			}
+			return
+		case "verify":
+			if err := runVerify(os.Args[2:]); err != nil {
+				log.Fatalf("Verify: %v", err)
+			}
+			return
+		case "report":
+			if err := runReport(os.Args[2:]); err != nil {
+				log.Fatalf("Report: %v", err)
+			}
+			return
+		case "i18n":
+			if err := runI18n(os.Args[2:]); err != nil {
+				log.Fatalf("i18n: %v", err)
+			}
+			return
 		}
 		
-		cfg.InputDir = ""  // Reset so we can override from config
-		cfg.OutputDir = "" // Reset so we can override from config
-	} else {
-		// Project directory is the current working directory
-		projectDir, err = os.Getwd()
-		if err != nil {
-			log.Fatalf("Cannot get current working directory: %v", err)
-		}
+		printUsage()
+		fmt.Fprintf(os.Stderr, "\nUnknown command %q\n", os.Args[1])
+		os.Exit(2)
 	}
 	
-	absProjectDir, err := filepath.Abs(projectDir)
-	if err != nil {
-		log.Fatalf("Cannot get absolute project directory: %v", err)
-	}
-	
-	// Load configuration from file (if exists)
-	fileCfg, err := config.LoadConfigFromFile(absProjectDir)
-	if err != nil {
-		log.Fatalf("Error loading config: %v", err)
-	}
-	
-	// Command line flags override config file values
-	if explicitInputDir != "" {
-		// Legacy mode: -i flag overrides everything (absolute path)
-		fileCfg.InputDir = explicitInputDir
-		// Make it relative to project dir if possible, otherwise keep absolute
-		if rel, err := filepath.Rel(absProjectDir, explicitInputDir); err == nil && !strings.HasPrefix(rel, "..") {
-			fileCfg.InputDir = rel
-		} else {
-			fileCfg.InputDir = explicitInputDir // Keep absolute
-		}
-	}
-	if explicitOutputDir != "" {
-		// Legacy mode: -o flag overrides everything (absolute path)
-		fileCfg.OutputDir = explicitOutputDir
-		// Make it relative to project dir if possible, otherwise keep absolute
-		if rel, err := filepath.Rel(absProjectDir, explicitOutputDir); err == nil && !strings.HasPrefix(rel, "..") {
-			fileCfg.OutputDir = rel
-		} else {
-			fileCfg.OutputDir = explicitOutputDir // Keep absolute
-		}
-	}
-	
-	// In legacy mode, command line flags completely override config file
-	if isLegacyMode {
-		// In legacy mode, serve defaults to false unless explicitly set
-		fileCfg.Serve = cfg.Serve
-		fileCfg.Watch = cfg.Watch
-		fileCfg.Verbose = cfg.Verbose
-		if cfg.Port != 3000 { // Only override if explicitly set
-			fileCfg.Port = cfg.Port
-		}
-		if explicitImgSize != nil {
-			fileCfg.ImgSize = *explicitImgSize
-		}
-		if explicitSvgFilter != nil {
-			fileCfg.SvgFilter = *explicitSvgFilter
-		}
-	} else {
-		// In project mode, only override if explicitly set
-		if cfg.Watch {
-			fileCfg.Watch = cfg.Watch
-		}
-		if cfg.Verbose {
-			fileCfg.Verbose = cfg.Verbose
-		}
-		if cfg.Serve {
-			fileCfg.Serve = cfg.Serve
-		}
-		if cfg.Port != 3000 { // Only override if explicitly set
-			fileCfg.Port = cfg.Port
-		}
-		if explicitImgSize != nil {
-			fileCfg.ImgSize = *explicitImgSize
-		}
-		if explicitSvgFilter != nil {
-			fileCfg.SvgFilter = *explicitSvgFilter
-		}
-	}
-	
-	cfg = fileCfg
-	
-	// Set legacy mode flag
-	cfg.LegacyMode = isLegacyMode
-	
-	// If serve is enabled, automatically enable watch mode
-	if cfg.Serve {
-		cfg.Watch = true
-	}
-	
-	printBanner()
-	
-	// Handle the case where no arguments are provided - start project selection mode
-	if len(os.Args) == 1 {
-		// No arguments provided, start in project selection mode with serve enabled
-		cfg.Serve = true
-		cfg.Watch = true
-		isLegacyMode = false
-	}
-	
-	// In project selection mode (non-legacy with serve), skip project validation and building
-	if !isLegacyMode && cfg.Serve {
-		// Start directly in web server mode for project selection
-		var b *builder.Builder
-		if explicitServeFlag {
-			// Use clipboard-only mode when -s flag was explicitly provided
-			b = builder.NewWithClipboardOnly(cfg)
-		} else {
-			// Use normal mode (with browser opening) when no args provided
-			b = builder.New(cfg)
-		}
-		if err := b.StartProjectSelectionMode(); err != nil {
-			log.Fatalf("Failed to start project selection mode: %v", err)
-		}
-		return
-	}
-	
-	// Check if input directory exists
-	absInputDir := cfg.GetAbsoluteInputDir()
-	if _, err := os.Stat(absInputDir); os.IsNotExist(err) {
-		log.Fatalf("Input directory %s does not exist", absInputDir)
-	}
-	
-	// Create output directory if it doesn't exist
-	absOutputDir := cfg.GetAbsoluteOutputDir()
-	if err := os.MkdirAll(absOutputDir, 0755); err != nil {
-		log.Fatalf("Cannot create output directory: %v", err)
-	}
-	
-	// Initialize and run the builder
-	var b *builder.Builder
-	if cfg.Serve && explicitServeFlag {
-		// Use clipboard-only mode when -s flag was explicitly provided
-		b = builder.NewWithClipboardOnly(cfg)
-	} else {
-		b = builder.New(cfg)
-	}
-	if err := b.Build(); err != nil {
-		log.Fatalf("Build failed: %v", err)
-	}
-}
\ No newline at end of file
+	runLegacy()
+}
+
+// printUsage lists the commands
+func printUsage() {
+	green := color.New(color.FgGreen)
+	fmt.Fprintf(os.Stderr, "%s\n\n", color.New(color.Bold, color.FgWhite).Sprint("Build simple static websites using:"))
+	fmt.Fprintf(os.Stderr, "  - snippets with %s and %s\n", green.Sprint("<!-- copy x -->"), green.Sprint("<!-- paste x -->"))
+	fmt.Fprintf(os.Stderr, "  - variables using %s and %s\n", green.Sprint("<!-- set y -->"), green.Sprint("<!-- global z -->"))
+	fmt.Fprintf(os.Stderr, "  - include files with %s\n\n", green.Sprint("<!-- include filename.html -->"))
+	fmt.Fprintf(os.Stderr, "  %s\n\n", color.New(color.Bold, color.FgYellow).Sprint("See README.md to get started."))
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  build [dir]                     build the project once\n")
+	fmt.Fprintf(os.Stderr, "  watch [dir]                     build, then rebuild when files change\n")
+	fmt.Fprintf(os.Stderr, "  serve [-p port] [dir]           build, rebuild when files change and serve the site\n")
+	fmt.Fprintf(os.Stderr, "  deploy [--env name] [dir]       build for production, then run the deploy step\n")
+	fmt.Fprintf(os.Stderr, "  init [--theme basic|blog] [dir] create a new project\n")
+	fmt.Fprintf(os.Stderr, "  new [--kind name] page.md       create a page from an archetype\n")
+	fmt.Fprintf(os.Stderr, "  clean [--dry-run]               empty the output folder\n")
+	fmt.Fprintf(os.Stderr, "  render [--stdout] file.md       render a single page\n")
+	fmt.Fprintf(os.Stderr, "  i18n extract [--lang fr,de]     collect translatable strings\n")
+	fmt.Fprintf(os.Stderr, "  graph [--format dot|json]       export the page/template/snippet dependency graph\n")
+	fmt.Fprintf(os.Stderr, "  verify <folder or URL>          check a deployed copy against integrity.json\n")
+	fmt.Fprintf(os.Stderr, "  config schema|validate          export or check the sniplicity.yaml schema\n")
+	fmt.Fprintf(os.Stderr, "  report --compare a.json b.json  compare the diagnostics and page sizes of two builds\n")
+	fmt.Fprintf(os.Stderr, "  stats [--json] [--reset]        show local build statistics\n")
+	fmt.Fprintf(os.Stderr, "  syntax [--format textmate|vim]  export editor syntax highlighting\n")
+	fmt.Fprintf(os.Stderr, "  lsp                             run the language server\n")
+	fmt.Fprintf(os.Stderr, "  version                         show the version\n\n")
+	fmt.Fprintf(os.Stderr, "Run %s <command> -h for a command's flags, or %s with no arguments for the project selector.\n", os.Args[0], os.Args[0])
+}
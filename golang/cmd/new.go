@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"sniplicity/internal/config"
+	"sniplicity/internal/scaffold"
+)
+
+// runNew implements `sniplicity new [--kind name] path`, which creates a content file from an archetype
+func runNew(args []string) error {
+	fs := flag.NewFlagSet("new", flag.ExitOnError)
+	var kind string
+	fs.StringVar(&kind, "kind", "", "archetype to start from (default: the file's top folder)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s new [--kind name] path/to/page.md\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Creates a page in the input folder from archetypes/<kind>.md, archetypes/default.md\n")
+		fmt.Fprintf(os.Stderr, "or a built-in draft, filling in its title, date and slug.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("new needs exactly one file path")
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("cannot get current working directory: %w", err)
+	}
+	cfg, err := config.LoadConfigFromFile(findProjectDir(wd))
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if cfg.ProjectDir == "" {
+		return fmt.Errorf("no sniplicity.yaml found, create a project first with: %s init", os.Args[0])
+	}
+
+	path, err := scaffold.NewContent(cfg, fs.Arg(0), kind, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created %s\n", path)
+	return nil
+}
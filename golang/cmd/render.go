@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"sniplicity/internal/builder"
+	"sniplicity/internal/config"
+	"sniplicity/internal/logging"
+)
+
+// runRender implements `sniplicity render file.md [-o out.html]`, which processes a single
+// page with the project's snippets, templates and globals and prints the result
+func runRender(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	var outputFile, inputDir string
+	var strict, explain, stdout bool
+	fs.StringVar(&outputFile, "o", "", "write the rendered page to this file instead of stdout")
+	fs.StringVar(&outputFile, "out", "", "write the rendered page to this file instead of stdout")
+	fs.StringVar(&inputDir, "i", "", "source directory to collect snippets and templates from (default: from sniplicity.yaml)")
+	fs.StringVar(&inputDir, "in", "", "source directory to collect snippets and templates from (default: from sniplicity.yaml)")
+	fs.BoolVar(&stdout, "stdout", false, "write the page to stdout exactly as build writes it, with no newline added, for piping and tests")
+	fs.BoolVar(&strict, "strict", false, "fail on missing snippets, templates, variables and includes")
+	fs.BoolVar(&explain, "explain", false, "print how the page is put together instead: its template and why, the snippets and files it uses and where its variables come from")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s render [-i source_folder] [-o out.html | --stdout] [--explain] file.md\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Use - as the file name to read the page from stdin. Warnings and errors go to stderr,\n")
+		fmt.Fprintf(os.Stderr, "and the exit status is non-zero when the page has errors.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("render needs exactly one file")
+	}
+	path := fs.Arg(0)
+	if outputFile == "-" {
+		outputFile, stdout = "", true
+	}
+	if stdout && outputFile != "" {
+		return fmt.Errorf("--stdout and -o can't be used together")
+	}
+
+	// Reading from stdin: the page gets a virtual name in the working directory
+	var source io.Reader
+	if path == "-" {
+		source = os.Stdin
+		path = "stdin.md"
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("resolving %s: %w", path, err)
+	}
+
+	cfg, err := config.LoadConfigFromFile(findProjectDir(filepath.Dir(absPath)))
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if inputDir != "" {
+		absInput, err := filepath.Abs(inputDir)
+		if err != nil {
+			return fmt.Errorf("resolving %s: %w", inputDir, err)
+		}
+		cfg.InputDir = absInput
+	} else if _, err := os.Stat(cfg.GetAbsoluteInputDir()); err != nil {
+		// No project found - treat the file's own folder as the source directory
+		cfg.InputDir = filepath.Dir(absPath)
+	}
+
+	// Keep stdout clean for the rendered page
+	logging.SetOutput(os.Stderr, os.Stderr)
+	if strict {
+		cfg.Strict = true
+	}
+
+	b := builder.New(cfg)
+	if explain {
+		if source != nil {
+			return fmt.Errorf("--explain needs a file, not stdin")
+		}
+		explanation, err := b.Explain(absPath)
+		b.Diagnostics().WriteText(os.Stderr, logging.Enabled(logging.Warn))
+		if err != nil {
+			return err
+		}
+		explanation.WriteText(os.Stdout)
+		return explanation.Err
+	}
+
+	page, err := b.RenderFile(absPath, source)
+	b.Diagnostics().WriteText(os.Stderr, logging.Enabled(logging.Warn))
+	if err != nil {
+		return err
+	}
+
+	if stdout {
+		_, err = io.WriteString(os.Stdout, page)
+		return err
+	}
+	if outputFile == "" {
+		_, err = fmt.Fprintln(os.Stdout, page)
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputFile), 0755); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", outputFile, err)
+	}
+	return os.WriteFile(outputFile, []byte(page), 0644)
+}
+
+// findProjectDir walks up from dir looking for a sniplicity.yaml, falling back to the
+// current working directory when there isn't one
+func findProjectDir(dir string) string {
+	for current := dir; ; current = filepath.Dir(current) {
+		if _, err := os.Stat(filepath.Join(current, "sniplicity.yaml")); err == nil {
+			return current
+		}
+		if filepath.Dir(current) == current {
+			break
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return dir
+	}
+	return wd
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"sniplicity/internal/diag"
+)
+
+// runReport implements `sniplicity report --compare previous.json current.json`, which
+// shows what changed between the JSON reports of two builds
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	var compare, asJSON, failOnNew bool
+	fs.BoolVar(&compare, "compare", false, "compare two reports written by build --diagnostics json")
+	fs.BoolVar(&asJSON, "json", false, "print the comparison as JSON")
+	fs.BoolVar(&failOnNew, "fail-on-new", false, "exit with an error when the current report has new warnings or errors")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s report --compare [--json] [--fail-on-new] previous.json current.json\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Lists the warnings and errors current.json has that previous.json didn't, the ones it\n")
+		fmt.Fprintf(os.Stderr, "fixed, and the pages whose size changed. Write the reports with\n")
+		fmt.Fprintf(os.Stderr, "  %s build --diagnostics json > report.json\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if !compare || fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected --compare with two reports")
+	}
+
+	previous, err := diag.ReadReport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	current, err := diag.ReadReport(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	comparison := diag.Compare(previous, current)
+
+	if asJSON {
+		data, err := json.MarshalIndent(comparison, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding comparison: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		comparison.WriteText(os.Stdout)
+	}
+
+	if failOnNew && len(comparison.New) > 0 {
+		return fmt.Errorf("%d new warning(s) or error(s)", len(comparison.New))
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"sniplicity/internal/stats"
+)
+
+// runStats implements `sniplicity stats`, which shows the local build statistics
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	var asJSON, reset bool
+	fs.BoolVar(&asJSON, "json", false, "print the raw stats file, for attaching to bug reports")
+	fs.BoolVar(&reset, "reset", false, "delete the stats file")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s stats [--json] [--reset]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Stats are only kept when enabled with stats: true in sniplicity.yaml or --stats.\n")
+		fmt.Fprintf(os.Stderr, "They are stored locally and never sent anywhere.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if reset {
+		if err := stats.Reset(); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %s\n", stats.Path())
+		return nil
+	}
+
+	s, err := stats.Load()
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding stats: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Stats file: %s\n\n", stats.Path())
+	if s.Builds == 0 {
+		fmt.Println("No builds recorded yet. Enable with stats: true in sniplicity.yaml or --stats.")
+		return nil
+	}
+
+	fmt.Printf("Version:        %s (%s/%s)\n", s.Version, s.OS, s.Arch)
+	fmt.Printf("Builds:         %d (%d failed)\n", s.Builds, s.FailedBuilds)
+	fmt.Printf("Since:          %s\n", s.FirstBuild.Format("2006-01-02 15:04"))
+	fmt.Printf("Average build:  %d ms (longest %d ms)\n", s.AverageMs, s.LongestMs)
+	fmt.Printf("Pages:          %d last build (most %d)\n", s.LastPages, s.MostPages)
+	if len(s.Features) > 0 {
+		fmt.Println("Features used (number of builds):")
+		for _, name := range s.FeatureNames() {
+			fmt.Printf("  %-18s %d\n", name, s.Features[name])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"sniplicity/internal/syntax"
+)
+
+// runSyntax implements `sniplicity syntax --format textmate|vim`, which prints an editor
+// grammar generated from the directives the parser supports
+func runSyntax(args []string) error {
+	fs := flag.NewFlagSet("syntax", flag.ExitOnError)
+	var format, outputFile string
+	fs.StringVar(&format, "f", "textmate", "grammar format: "+strings.Join(syntax.Formats, " or "))
+	fs.StringVar(&format, "format", "textmate", "grammar format: "+strings.Join(syntax.Formats, " or "))
+	fs.StringVar(&outputFile, "o", "", "write the grammar to this file instead of stdout")
+	fs.StringVar(&outputFile, "out", "", "write the grammar to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s syntax [--format %s] [-o file]\n\n", os.Args[0], strings.Join(syntax.Formats, "|"))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	data, err := syntax.Generate(format)
+	if err != nil {
+		return err
+	}
+
+	if outputFile == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(outputFile, data, 0644); err != nil {
+		return fmt.Errorf("cannot write %s: %w", outputFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"sniplicity/internal/config"
+	"sniplicity/internal/integrity"
+)
+
+// runVerify implements `sniplicity verify [--manifest file] <folder or URL>`, which checks a
+// deployed copy of the site against the integrity manifest of a build
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	var manifestPath string
+	fs.StringVar(&manifestPath, "manifest", "", "integrity.json to check against (default: the project's build, or the deployed copy's own)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s verify [--manifest integrity.json] <folder or URL>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Checks that every file of a deployed site matches the build that wrote integrity.json\n")
+		fmt.Fprintf(os.Stderr, "(see integrity in sniplicity.yaml). A folder is also checked for files the build didn't write.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected the folder or URL of a deployed copy")
+	}
+	target := fs.Arg(0)
+
+	if manifestPath == "" {
+		manifestPath = projectIntegrityFile()
+	}
+	if manifestPath == "" {
+		manifestPath = integrity.Locate(target)
+	}
+	manifest, err := integrity.Load(manifestPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Checking %s against %s\n", target, manifestPath)
+
+	problems := integrity.Verify(manifest, target)
+	for _, problem := range problems {
+		fmt.Printf("  %s: %s\n", problem.Path, problem.Message)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d file(s) don't match the build", len(problems))
+	}
+	fmt.Printf("All %d files match\n", len(manifest.Files))
+	return nil
+}
+
+// projectIntegrityFile returns the integrity manifest in the output folder of the project
+// around the working directory, or "" when there is none
+func projectIntegrityFile() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	cfg, err := config.LoadConfigFromFile(findProjectDir(wd))
+	if err != nil || cfg.ProjectDir == "" {
+		return ""
+	}
+	path := filepath.Join(cfg.GetAbsoluteOutputDir(), integrity.FileName)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
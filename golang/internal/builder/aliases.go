@@ -0,0 +1,214 @@
+package builder
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"sniplicity/internal/builtin"
+	"sniplicity/internal/logging"
+	"sniplicity/internal/parser"
+	"sniplicity/internal/types"
+)
+
+// redirectsFilename is the Netlify redirect rules file written to the output directory
+const redirectsFilename = "_redirects"
+
+// builtinTemplates returns the templates of the pages sniplicity generates itself, with
+// the project's overrides
+func (b *Builder) builtinTemplates() *builtin.Templates {
+	return builtin.New(b.config.GetAbsoluteBuiltinTemplates())
+}
+
+// pageURL returns the root-relative URL a page is published at
+func (b *Builder) pageURL(fileInfo *types.FileInfo) string {
+	if fileInfo.Permalink != "" {
+		return "/" + fileInfo.Permalink
+	}
+	outputDir := b.config.GetAbsoluteOutputDir()
+	rel, err := filepath.Rel(outputDir, fileInfo.GetOutputPath(outputDir))
+	if err != nil {
+		return ""
+	}
+	return "/" + filepath.ToSlash(rel)
+}
+
+// aliasFile returns the output file for an alias URL: URLs ending in a slash or without
+// an extension become a folder holding an index.html
+func aliasFile(alias string) string {
+	alias = strings.Trim(path.Clean("/"+alias), "/")
+	if alias == "" {
+		return ""
+	}
+	if path.Ext(alias) == "" {
+		return alias + "/index.html"
+	}
+	return alias
+}
+
+// pageRedirect is a page that a redirect directive replaces with a redirect
+type pageRedirect struct {
+	source string // Source file holding the directive
+	file   string // Output file the page would have been written to
+	url    string // URL the page would have been published at
+	target string
+	status string
+}
+
+// collectRedirects takes the pages holding a redirect directive out of the build, to be
+// written as redirects by writeAliases instead
+func (b *Builder) collectRedirects() {
+	b.redirects = nil
+	outputDir := b.config.GetAbsoluteOutputDir()
+	pages := b.files[:0]
+	for _, fileInfo := range b.files {
+		var directive *parser.Directive
+		for _, d := range parser.ParseDirectives(fileInfo.Content) {
+			if d.Type != parser.DirectiveRedirect {
+				continue
+			}
+			if directive != nil {
+				b.diagnostics.Warn(fileInfo.InputPath, 0, "page already redirects, ignoring this redirect")
+				continue
+			}
+			directive = d
+		}
+		if directive == nil {
+			pages = append(pages, fileInfo)
+			continue
+		}
+
+		redirect := pageRedirect{
+			source: fileInfo.InputPath,
+			file:   fileInfo.GetOutputPath(outputDir),
+			url:    b.pageURL(fileInfo),
+			target: parser.ExpandVariables(directive.Args[0], pageVariables(fileInfo, b.Site().Globals)),
+			status: directive.Args[1],
+		}
+		if !parser.IsRedirectStatus(redirect.status) {
+			b.diagnostics.Warn(fileInfo.InputPath, 0, "redirect status %s is not 301, 302, 303, 307 or 308, using 301", redirect.status)
+			redirect.status = "301"
+		}
+		if redirect.target == redirect.url {
+			b.diagnostics.Warn(fileInfo.InputPath, 0, "page redirects to itself, building it as a page")
+			pages = append(pages, fileInfo)
+			continue
+		}
+		b.redirects = append(b.redirects, redirect)
+	}
+	b.files = pages
+}
+
+// redirectSources returns the URLs a redirect rule should match for a page published at
+// url, which static hosts also serve without .html or index.html
+func redirectSources(url string) []string {
+	if strings.HasSuffix(url, "/index.html") {
+		return []string{strings.TrimSuffix(url, "index.html")}
+	}
+	if strings.HasSuffix(url, ".html") {
+		return []string{url, strings.TrimSuffix(url, ".html")}
+	}
+	return []string{url}
+}
+
+// writeAliases emits a redirect for every URL listed in a page's aliases frontmatter,
+// every page with a redirect directive and every redirect_rules entry, either as meta
+// refresh stubs or in the rules file of the deploy format
+func (b *Builder) writeAliases() error {
+	outputDir := b.config.GetAbsoluteOutputDir()
+	format := b.config.RulesFormat()
+
+	// Real pages always win over redirects
+	pages := make(map[string]bool)
+	for _, fileInfo := range b.files {
+		pages[fileInfo.GetOutputPath(outputDir)] = true
+	}
+
+	var rules []redirectRule
+	for _, fileInfo := range b.files {
+		aliases := types.ParseList(fileInfo.Metadata["aliases"])
+		if len(aliases) == 0 {
+			continue
+		}
+		target := b.pageURL(fileInfo)
+
+		for _, alias := range aliases {
+			file := aliasFile(alias)
+			if file == "" {
+				continue
+			}
+			stubPath := filepath.Join(outputDir, filepath.FromSlash(file))
+			if pages[stubPath] {
+				b.diagnostics.Warn(fileInfo.InputPath, 0, "alias %s is already a page, skipping", alias)
+				continue
+			}
+
+			if format != "" {
+				rules = append(rules, redirectRule{from: "/" + strings.TrimPrefix(alias, "/"), to: target, status: "301"})
+				continue
+			}
+
+			if err := b.writeRedirectStub(fileInfo.InputPath, stubPath, target); err != nil {
+				return err
+			}
+			logging.Debugf("  Redirect %s -> %s", alias, target)
+		}
+	}
+
+	for _, redirect := range b.redirects {
+		if pages[redirect.file] {
+			b.diagnostics.Warn(redirect.source, 0, "another page is written to %s, skipping its redirect", redirect.url)
+			continue
+		}
+
+		if format != "" {
+			for _, from := range redirectSources(redirect.url) {
+				rules = append(rules, redirectRule{from: from, to: redirect.target, status: redirect.status})
+			}
+			continue
+		}
+
+		if err := b.writeRedirectStub(redirect.source, redirect.file, redirect.target); err != nil {
+			return err
+		}
+		logging.Debugf("  Redirect %s -> %s", redirect.url, redirect.target)
+	}
+
+	if format != "" {
+		return b.writeHostRules(append(rules, b.redirectRules...))
+	}
+
+	// Without a deploy format, sniplicity.yaml's redirects of single URLs become stubs too
+	for _, rule := range b.redirectRules {
+		file := aliasFile(rule.from)
+		stubPath := filepath.Join(outputDir, filepath.FromSlash(file))
+		switch {
+		case strings.HasSuffix(rule.from, "*"):
+			b.diagnostics.Warn(b.configFile(), yamlKeyLine(b.configFile(), "redirect_rules"), "redirect_rules %s needs deploy_format, HTML redirect pages can't match a pattern", rule.from)
+			continue
+		case file == "" || pages[stubPath]:
+			b.diagnostics.Warn(b.configFile(), yamlKeyLine(b.configFile(), "redirect_rules"), "redirect_rules %s is already a page, skipping", rule.from)
+			continue
+		}
+
+		if err := b.writeRedirectStub("", stubPath, rule.to); err != nil {
+			return err
+		}
+		logging.Debugf("  Redirect %s -> %s", rule.from, rule.to)
+	}
+	return b.writeHostRules(nil)
+}
+
+// writeRedirectStub writes a meta refresh page to target at file, on behalf of source
+func (b *Builder) writeRedirectStub(source, file, target string) error {
+	stub, err := b.builtinTemplates().Render(builtin.RedirectPage, builtin.Redirect{Target: target})
+	if err != nil {
+		return err
+	}
+	if err := b.writeFile(file, stub); err != nil {
+		return fmt.Errorf("cannot write redirect %s: %w", file, err)
+	}
+	b.recordOutput(source, file)
+	return nil
+}
@@ -0,0 +1,149 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sniplicity/internal/logging"
+)
+
+// anchorsFile is the map of {{anchor}} and heading ID redirects written to the root of the output directory
+const anchorsFile = "anchors.json"
+
+// minAnchorSimilarity is the share of their words an anchor's old and new text must have
+// in common for the old ID to redirect to the new one
+const minAnchorSimilarity = 0.5
+
+// setAnchors starts from the {{anchor}} IDs and redirects a manifest recorded
+func (b *Builder) setAnchors(manifest Manifest) {
+	b.anchors = make(map[string]map[string]string, len(manifest.Anchors))
+	for page, anchors := range manifest.Anchors {
+		b.anchors[page] = anchors
+	}
+	b.anchorRedirects = make(map[string]map[string]string, len(manifest.AnchorRedirects))
+	for page, redirects := range manifest.AnchorRedirects {
+		b.anchorRedirects[page] = redirects
+	}
+}
+
+// recordAnchors notes the {{anchor}} and heading IDs of a page this build wrote, by its path relative
+// to the output directory. Each ID the page had before but no longer has redirects to the
+// new ID whose text is most like its own, if there is one alike enough, or the only new
+// one when a single anchor changed. Redirects to the old ID follow it to the new one.
+func (b *Builder) recordAnchors(page string, anchors map[string]string) {
+	b.outputsMu.Lock()
+	defer b.outputsMu.Unlock()
+	if b.anchors == nil {
+		return // No manifest was loaded, so there is nothing to compare with
+	}
+	previous := b.anchors[page]
+	if len(anchors) == 0 {
+		delete(b.anchors, page)
+	} else {
+		b.anchors[page] = anchors
+	}
+
+	var removed, added []string
+	for id := range previous {
+		if _, ok := anchors[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	for id := range anchors {
+		if _, ok := previous[id]; !ok {
+			added = append(added, id)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+
+	redirects := b.anchorRedirects[page]
+	if redirects == nil {
+		redirects = make(map[string]string)
+	}
+	taken := make(map[string]bool)
+	for _, old := range removed {
+		best, bestSimilarity := "", 0.0
+		for _, id := range added {
+			if similarity := wordSimilarity(previous[old], anchors[id]); !taken[id] && similarity > bestSimilarity {
+				best, bestSimilarity = id, similarity
+			}
+		}
+		if bestSimilarity < minAnchorSimilarity && len(removed) == 1 && len(added) == 1 {
+			best = added[0]
+		} else if bestSimilarity < minAnchorSimilarity {
+			continue
+		}
+		taken[best] = true
+		logging.Debugf("  Anchor #%s of %s is now #%s", old, page, best)
+		for from, to := range redirects {
+			if to == old {
+				redirects[from] = best
+			}
+		}
+		redirects[old] = best
+	}
+
+	// An ID the page has again no longer redirects
+	for id := range anchors {
+		delete(redirects, id)
+	}
+	if len(redirects) == 0 {
+		delete(b.anchorRedirects, page)
+	} else {
+		b.anchorRedirects[page] = redirects
+	}
+}
+
+// wordSimilarity returns the share of the words of two texts that both have, from 0 to 1
+func wordSimilarity(a, b string) float64 {
+	aWords, bWords := make(map[string]bool), make(map[string]bool)
+	for _, word := range strings.Fields(a) {
+		aWords[word] = true
+	}
+	for _, word := range strings.Fields(b) {
+		bWords[word] = true
+	}
+	common := 0
+	for word := range aWords {
+		if bWords[word] {
+			common++
+		}
+	}
+	all := len(aWords) + len(bWords) - common
+	if all == 0 {
+		return 0
+	}
+	return float64(common) / float64(all)
+}
+
+// writeAnchorRedirects writes anchors.json, mapping each page's old anchor and heading IDs to the
+// new ones by the page's URL, for a script to follow links to the old ones. Nothing is
+// written while no anchor has changed.
+func (b *Builder) writeAnchorRedirects() error {
+	b.outputsMu.Lock()
+	pages := make(map[string]map[string]string, len(b.anchorRedirects))
+	for page, redirects := range b.anchorRedirects {
+		if _, ok := b.outputs[filepath.Join(b.config.GetAbsoluteOutputDir(), filepath.FromSlash(page))]; ok {
+			pages["/"+page] = redirects
+		}
+	}
+	b.outputsMu.Unlock()
+	if len(pages) == 0 {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(pages, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", anchorsFile, err)
+	}
+	path := filepath.Join(b.config.GetAbsoluteOutputDir(), anchorsFile)
+	if err := b.writeFile(path, append(data, '\n')); err != nil {
+		return fmt.Errorf("cannot write %s: %w", path, err)
+	}
+	b.recordOutput("", path)
+	return nil
+}
@@ -2,157 +2,93 @@ package builder
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/tls"
-	"crypto/x509"
-	"crypto/x509/pkix"
-	"encoding/pem"
+	"errors"
 	"fmt"
-	"io"
-	"log"
-	"math/big"
-	"net"
-	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"runtime/debug"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"sniplicity/internal/buildlog"
 	"sniplicity/internal/config"
+	"sniplicity/internal/diag"
+	"sniplicity/internal/i18n"
+	"sniplicity/internal/logging"
+	"sniplicity/internal/parser"
 	"sniplicity/internal/processor"
 	"sniplicity/internal/types"
 	"sniplicity/internal/watcher"
 	"sniplicity/internal/web"
 
-	"github.com/atotto/clipboard"
 	"github.com/fatih/color"
-	"github.com/skratchdot/open-golang/open"
 )
 
 // Builder handles the main build process
 type Builder struct {
 	config        config.Config
 	files         []*types.FileInfo
-	snippets      map[string][]string
-	templates     map[string][]string
-	globals       map[string]string
+	site          atomic.Pointer[SiteState] // Definitions of the last build, replaced whole by the next
+	defaults      *types.DirectoryDefaults // Per-directory _defaults.yaml frontmatter
 	processor     *processor.Processor
+	diagnostics   *diag.Collector // Warnings and errors from the last build
+	outputs       map[string]string // Files written to the output directory by the current build, with their source
+	outputsMu     sync.Mutex
+	hashes        map[string]string // Content hashes of the files written, for the build manifest
+	previous      map[string]string // Output hashes from the last build's manifest
+	modified      map[string]time.Time // When the content of each output last changed, by path relative to the output directory
+	anchors       map[string]map[string]string // Text of each {{anchor}} ID, by ID, of each page by path relative to the output directory
+	anchorRedirects map[string]map[string]string // New ID of each changed {{anchor}}, by old ID, of each page
+	dependencies  map[string]bool   // Files pages declare they depend on with depends, guarded by outputsMu
+	written       int               // Output files the current build wrote
+	skipped       int               // Output files the current build left alone as their content was the same
+	changes       []Change        // What a dry run would change in the output directory
+	inlined       map[string]inlinedAsset // Assets inlined as data URIs by the current build, by path relative to the sources
+	inlinedMu     sync.Mutex
+	changesMu     sync.Mutex
 	clipboardOnly bool // When true, copy URL to clipboard instead of opening browser
 	watchManager  *watcher.Manager // Manages file watching
-}
-
-// getLocalIP returns the local IP address of the machine
-func getLocalIP() string {
-	conn, err := net.Dial("udp", "8.8.8.8:80")
-	if err != nil {
-		// Fallback to finding local IP through network interfaces
-		addrs, err := net.InterfaceAddrs()
-		if err != nil {
-			return ""
-		}
-		for _, addr := range addrs {
-			if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
-				if ipnet.IP.To4() != nil {
-					return ipnet.IP.String()
-				}
-			}
-		}
-		return ""
-	}
-	defer conn.Close()
-	
-	localAddr := conn.LocalAddr().(*net.UDPAddr)
-	return localAddr.IP.String()
-}
-
-// generateSelfSignedCert generates an in-memory self-signed certificate for HTTPS
-func generateSelfSignedCert() (tls.Certificate, error) {
-	// Generate a new RSA private key
-	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return tls.Certificate{}, fmt.Errorf("generating private key: %w", err)
-	}
-
-	// Create certificate template
-	template := x509.Certificate{
-		SerialNumber: big.NewInt(1),
-		Subject: pkix.Name{
-			Organization:  []string{"Sniplicity Dev"},
-			Country:       []string{"US"},
-			Province:      []string{""},
-			Locality:      []string{"Local"},
-			StreetAddress: []string{""},
-			PostalCode:    []string{""},
-		},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().Add(365 * 24 * time.Hour), // Valid for 1 year
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		BasicConstraintsValid: true,
-		IsCA:                  false,
-		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
-		DNSNames:              []string{"localhost"},
-	}
-
-	// Add local IP to certificate if available
-	if localIP := getLocalIP(); localIP != "" {
-		if ip := net.ParseIP(localIP); ip != nil {
-			template.IPAddresses = append(template.IPAddresses, ip)
-		}
-	}
-
-	// Generate subject key identifier
-	pubKeyBytes, err := x509.MarshalPKIXPublicKey(&privKey.PublicKey)
-	if err != nil {
-		return tls.Certificate{}, fmt.Errorf("marshaling public key: %w", err)
-	}
-	template.SubjectKeyId = pubKeyBytes[:20] // Use first 20 bytes as key ID
-
-	// Create certificate
-	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &privKey.PublicKey, privKey)
-	if err != nil {
-		return tls.Certificate{}, fmt.Errorf("creating certificate: %w", err)
-	}
-
-	// Encode certificate
-	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
-
-	// Encode private key
-	privKeyDER, err := x509.MarshalPKCS8PrivateKey(privKey)
-	if err != nil {
-		return tls.Certificate{}, fmt.Errorf("marshaling private key: %w", err)
-	}
-	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privKeyDER})
-
-	// Create TLS certificate
-	cert, err := tls.X509KeyPair(certPEM, keyPEM)
-	if err != nil {
-		return tls.Certificate{}, fmt.Errorf("creating X509 key pair: %w", err)
-	}
-
-	return cert, nil
+	loadConfig    func() (config.Config, error) // Reads the config again when sniplicity.yaml changes, may be nil
+	configHash    string // Hash of sniplicity.yaml as the last build read it
+	buildMu       sync.Mutex // Held for the whole of a build, so builds run one at a time
+	deployMu      sync.Mutex // Held while deploying, so deploys run one at a time
+	redirects     []pageRedirect // Pages replaced by a redirect directive in the current build
+	buildLog      *buildlog.Log  // Status and output of the builds, for the web interface
+	delimiters    parser.Delimiters // Variable delimiters set by sniplicity.yaml, which pages may override
+	headingIDs    types.HeadingIDs  // How markdown headings get their IDs, set by sniplicity.yaml
+	gitHistory    types.GitHistory  // Last commit of each source file with git_info, nil without
+	dates         types.Dates       // Reads frontmatter dates with the formats and time zone of sniplicity.yaml
+	languages     types.Languages   // Languages of sniplicity.yaml, none for a site in one
+	pageTemplates []pageTemplateRule // Templates of markdown pages that name none, most specific first
+	redirectRules []redirectRule    // Redirects of sniplicity.yaml
+	headerRules   []headerRule      // Response headers of sniplicity.yaml
+	remoteSources []remoteSource    // remote_data sources of sniplicity.yaml
+	entriesMu     sync.Mutex
+	entries       map[string]string // Pages rendered for index directives with render=true in the current build, by path
+	searchEntries []searchEntry     // Pages written by the current build, for the search index
+	summary       *buildSummary     // Counts and timings of the current build, for build_summary
 }
 
 // New creates a new Builder instance
 func New(cfg config.Config) *Builder {
 	b := &Builder{
 		config:        cfg,
-		snippets:      make(map[string][]string),
-		templates:     make(map[string][]string),
-		globals:       make(map[string]string),
-		processor:     processor.New(cfg.Verbose),
+		processor:     processor.New(),
+		diagnostics:   diag.NewCollector(),
+		buildLog:      buildlog.New(),
 		clipboardOnly: false, // Default to opening browser
 	}
+	b.processor.SetDiagnostics(b.diagnostics)
+	b.processor.SetWriter(b.writeFile)
+	b.processor.SetEntryRenderer(b.renderEntry)
 	
 	// Initialize watch manager
-	b.watchManager = watcher.NewManager(func() {
-		if err := b.doBuild(); err != nil {
-			log.Printf("Build error: %v", err)
-		}
-	})
+	b.watchManager = watcher.NewManager(b.rebuild)
 	
 	return b
 }
@@ -161,19 +97,17 @@ func New(cfg config.Config) *Builder {
 func NewWithClipboardOnly(cfg config.Config) *Builder {
 	b := &Builder{
 		config:        cfg,
-		snippets:      make(map[string][]string),
-		templates:     make(map[string][]string),
-		globals:       make(map[string]string),
-		processor:     processor.New(cfg.Verbose),
+		processor:     processor.New(),
+		diagnostics:   diag.NewCollector(),
+		buildLog:      buildlog.New(),
 		clipboardOnly: true, // Copy to clipboard instead of opening browser
 	}
+	b.processor.SetDiagnostics(b.diagnostics)
+	b.processor.SetWriter(b.writeFile)
+	b.processor.SetEntryRenderer(b.renderEntry)
 	
 	// Initialize watch manager
-	b.watchManager = watcher.NewManager(func() {
-		if err := b.doBuild(); err != nil {
-			log.Printf("Build error: %v", err)
-		}
-	})
+	b.watchManager = watcher.NewManager(b.rebuild)
 	
 	return b
 }
@@ -181,14 +115,15 @@ func NewWithClipboardOnly(cfg config.Config) *Builder {
 // Build performs the main build process
 func (b *Builder) Build() error {
 	if b.config.Serve {
+		logging.SetHook(b.buildLog.Add) // Shown in the web interface's build console
 		green := color.New(color.FgGreen, color.Bold)
 		cyan := color.New(color.FgCyan)
-		fmt.Printf("%s%s is watching files in %s and serving\n\n", 
+		logging.Infof("%s%s is watching files in %s and serving\n", 
 			green.Sprint("snip"), cyan.Sprint("licity"), cyan.Sprint(b.config.GetAbsoluteInputDir()))
 	} else if b.config.Watch {
 		green := color.New(color.FgGreen, color.Bold)
 		cyan := color.New(color.FgCyan)
-		fmt.Printf("%s%s is watching files in %s\n\n", 
+		logging.Infof("%s%s is watching files in %s\n", 
 			green.Sprint("snip"), cyan.Sprint("licity"), cyan.Sprint(b.config.GetAbsoluteInputDir()))
 	}
 
@@ -210,27 +145,82 @@ func (b *Builder) Build() error {
 func (b *Builder) StartProjectSelectionMode() error {
 	green := color.New(color.FgGreen, color.Bold)
 	cyan := color.New(color.FgCyan)
-	fmt.Printf("%s%s project selector starting\n\n", 
+	logging.SetHook(b.buildLog.Add)
+	logging.Infof("%s%s project selector starting\n", 
 		green.Sprint("snip"), cyan.Sprint("licity"))
 
 	return b.startWebServerOnly()
 }
 
+// doBuild builds the whole site
 func (b *Builder) doBuild() error {
-	if b.config.Verbose {
-		green := color.New(color.FgGreen)
-		fmt.Printf("Loading %s files...\n", green.Sprint("sniplicity"))
+	if len(b.config.Only) > 0 {
+		return b.doScopedBuild(BuildScope{Only: b.config.Only})
 	}
+	return b.doScopedBuild(BuildScope{Full: true})
+}
+
+// doScopedBuild builds as much of the site as scope asks for. A partial build keeps the
+// outputs recorded by the previous build and adds to them, so the manifest it writes
+// still covers the whole site.
+func (b *Builder) doScopedBuild(scope BuildScope) (err error) {
+	b.buildMu.Lock()
+	defer b.buildMu.Unlock()
+	partial := scope.partial()
+	if partial && b.hashes == nil && len(scope.Only) > 0 {
+		b.restoreOutputs() // Build on what the last build recorded in the manifest
+	}
+	if partial && b.hashes == nil {
+		partial, scope = false, BuildScope{Full: true} // Nothing built yet to build on
+	}
+	start := time.Now()
+	b.summary = newBuildSummary(start)
+	logging.SetLevel(b.config.Level()) // The web interface can turn verbose on and off between builds
+	b.buildLog.Start(scope.String())
+	defer func() { b.buildLog.Finish(b.diagnostics.Items(), err) }()
+	defer func() { b.recordStats(start, err) }()
+	defer func() { b.reportDiagnostics(err) }()
+	defer func() {
+		// Pages recover from their own panics; one outside them still shouldn't take down
+		// the server, but is reported with its stack so it gets fixed
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w while building: %v\n%s", errInternal, r, debug.Stack())
+		}
+	}()
+	if !partial {
+		// A partial build only sees the includes of the pages it builds
+		defer func() { b.watchManager.SetFiles(b.watchedFiles()) }()
+	}
+	b.configHash = b.configFileHash()
+	logging.Debugf("Loading %s files...", color.GreenString("sniplicity"))
 
 	// Reset state
 	b.files = nil
-	b.snippets = make(map[string][]string)
-	b.templates = make(map[string][]string)
-	b.globals = make(map[string]string)
+	b.diagnostics.Reset()
+	if partial {
+		b.keepOutputs()
+	} else {
+		b.outputsMu.Lock()
+		b.outputs = make(map[string]string)
+		b.hashes = make(map[string]string)
+		b.outputsMu.Unlock()
+		b.loadManifest()
+	}
+	b.outputsMu.Lock()
+	b.written, b.skipped = 0, 0
+	b.outputsMu.Unlock()
+	b.changesMu.Lock()
+	b.changes = nil
+	b.changesMu.Unlock()
+	b.inlinedMu.Lock()
+	b.inlined = make(map[string]inlinedAsset)
+	b.inlinedMu.Unlock()
 
 	// Create output directory
-	if err := os.MkdirAll(b.config.GetAbsoluteOutputDir(), 0755); err != nil {
-		return fmt.Errorf("cannot create output directory: %w", err)
+	if !b.config.DryRun {
+		if err := os.MkdirAll(b.config.GetAbsoluteOutputDir(), 0755); err != nil {
+			return fmt.Errorf("cannot create output directory: %w", err)
+		}
 	}
 
 	// Get file list - this matches Python version's get_file_list exactly
@@ -240,42 +230,19 @@ func (b *Builder) doBuild() error {
 	}
 
 	// PHASE 1: Pre-load files to collect templates/snippets/globals
-	// This matches Python's "Pre-loading files to collect templates..." exactly
-	if b.config.Verbose {
-		fmt.Println("Pre-loading files to collect templates...")
-	}
-	
-	tempFiles := make([]*types.FileInfo, 0)
-	for _, item := range fileList {
-		relPath, filename, isMarkdownStr := item[0], item[1], item[2]
-		inputPath := filepath.Join(b.config.GetAbsoluteInputDir(), relPath, filename)
-		
-		isMarkdown := isMarkdownStr == "true"
-		// Create FileInfo but DON'T process markdown yet in pre-loading phase
-		fileInfo := types.NewFileInfoRaw(inputPath, filename, isMarkdown)
-		fileInfo.OutputRelPath = relPath
-		
-		if err := fileInfo.LoadRaw(); err != nil {
-			if b.config.Verbose {
-				log.Printf("Warning: Cannot read file %s", inputPath)
-			}
-			continue
-		}
-		tempFiles = append(tempFiles, fileInfo)
-	}
-
-	// Collect snippets, templates, and globals from raw content
-	if err := b.collectSnippetsAndGlobals(tempFiles); err != nil {
-		return fmt.Errorf("error collecting snippets: %w", err)
+	if err := b.collectDefinitions(fileList); err != nil {
+		return err
 	}
+	b.summary.endPhase("Definitions")
 
 	// PHASE 2: Reload files with template processing
 	// This matches Python's "Reloading files with template processing..."
-	if b.config.Verbose {
-		fmt.Println("Reloading files with template processing...")
-	}
+	logging.Debugf("Reloading files with template processing...")
 	
+	site := b.Site()
 	b.files = make([]*types.FileInfo, 0)
+	claimed := make(map[string]string)
+	dependencies := make(map[string]bool)
 	for _, item := range fileList {
 		relPath, filename, isMarkdownStr := item[0], item[1], item[2]
 		inputPath := filepath.Join(b.config.GetAbsoluteInputDir(), relPath, filename)
@@ -283,61 +250,281 @@ func (b *Builder) doBuild() error {
 		isMarkdown := isMarkdownStr == "true"
 		fileInfo := types.NewFileInfo(inputPath, filename, isMarkdown)
 		fileInfo.OutputRelPath = relPath
+		fileInfo.Delimiters = b.delimiters
+		fileInfo.HeadingIDs = b.headingIDs
 		
 		// Now load WITH template processing (templates are available)
-		if err := fileInfo.LoadWithTemplates(b.templates, b.globals); err != nil {
-			if b.config.Verbose {
-				log.Printf("Warning: Cannot read file %s", inputPath)
-			}
+		err := b.runPage(fileInfo, func() error { return fileInfo.LoadWithTemplates(site.Templates, site.Globals) })
+		if errors.Is(err, errPageLimit) || errors.Is(err, errInternal) {
+			b.diagnostics.Error(inputPath, 0, "%v", err)
+			continue
+		}
+		if err != nil {
+			b.diagnostics.Warn(inputPath, 0, "cannot read file: %v", err)
+			continue
+		}
+		b.defaults.Apply(fileInfo.Metadata, relPath)
+		b.applyPageTemplate(fileInfo, relPath)
+		if !b.config.Drafts && types.IsDraft(fileInfo.Metadata) {
+			logging.Debugf("  Skipping draft %s", filepath.Join(relPath, filename))
 			continue
 		}
+		b.applyPermalink(fileInfo, relPath, claimed)
+		b.checkOutputPath(fileInfo)
+		for _, path := range b.resolveDependencies(fileInfo) {
+			dependencies[path] = true
+		}
 		b.files = append(b.files, fileInfo)
 	}
+	b.setDependencies(dependencies)
+	if err := b.addNotFoundPage(); err != nil {
+		return err
+	}
+	b.linkTranslations()
+	b.collectRedirects()
+	b.setSitemapPages()
+	if err := b.selectPages(scope); err != nil {
+		return err
+	}
+	b.summary.endPhase("Loading")
 
-	// Process files in exact Python order:
+	// Process files in exact Python order. A page that fails a step is reported and
+	// dropped, and the build fails at the end of step 4.
 	// 1. Process includes
-	if err := b.processIncludes(); err != nil {
-		return fmt.Errorf("error processing includes: %w", err)
-	}
+	b.processIncludes()
+	b.summary.endPhase("Includes")
 
-	// 2. Process index commands (before snippets and variables)
-	if err := b.processIndexCommands(); err != nil {
-		return fmt.Errorf("error processing index commands: %w", err)
-	}
+	// 2. Process index and sitemap-page commands (before snippets and variables)
+	b.processIndexCommands()
+	b.summary.endPhase("Indexes")
 
 	// 3. Process snippets
-	if err := b.processSnippets(); err != nil {
-		return fmt.Errorf("error processing snippets: %w", err)
-	}
+	b.processSnippets()
+	b.summary.endPhase("Snippets")
 
 	// 4. Process variables and write files
-	if err := b.processVariables(); err != nil {
-		return fmt.Errorf("error processing variables: %w", err)
+	b.processVariables()
+	if err := b.buildError(); err != nil {
+		return err
 	}
+	b.summary.endPhase("Rendering")
 
 	// 5. Copy assets (non-processed files) - AFTER all processing is complete
-	if err := b.copyAssets(); err != nil {
-		return fmt.Errorf("error copying assets: %w", err)
+	if scope.includesAssets() {
+		err := b.countAssets(func() error {
+			if err := b.copyAssets(scope); err != nil {
+				return fmt.Errorf("error copying assets: %w", err)
+			}
+
+			// Bundle the JavaScript entry point over its copied source
+			if b.config.JSEntry != "" {
+				b.bundleJS()
+				return b.buildError()
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
 	}
 
-	// Success message
+	if b.config.InlineAssets > 0 {
+		b.reportInlined()
+	}
+	b.summary.endPhase("Assets")
+
+	// Redirects, stale files and links concern the whole site, so a partial build
+	// leaves them as the last full build made them
+	if partial {
+		return b.finishPartialBuild(scope)
+	}
+
+	// 6. Write redirects for frontmatter aliases
+	if err := b.writeAliases(); err != nil {
+		return fmt.Errorf("error writing redirects: %w", err)
+	}
+	if b.config.SearchIndex {
+		if err := b.writeSearchIndex(); err != nil {
+			return err
+		}
+	}
+	if err := b.writeAnchorRedirects(); err != nil {
+		return err
+	}
+
+	// 7. Remove output files this build didn't write, before links are checked against them.
+	// The integrity manifest is written last, but belongs to this build's output.
+	if b.config.Integrity {
+		b.recordOutput("", b.integrityFile())
+	}
+	if b.config.CleanOutput {
+		b.removeStaleOutput()
+	}
+	b.summary.endPhase("Site files")
+
+	// 8. Report broken internal links, which a dry run can't do as nothing was written
+	if b.diagnostics.RuleLevel(diag.BrokenLink) != diag.Off && !b.config.DryRun {
+		if err := b.checkLinks(); err != nil {
+			return fmt.Errorf("error checking links: %w", err)
+		}
+		if err := b.buildError(); err != nil {
+			return err
+		}
+		b.summary.endPhase("Links")
+	}
+
+	if b.config.DryRun {
+		b.reportChanges()
+		return nil
+	}
+
+	// 9. Record what was written so the next build can skip unchanged files
+	if b.config.Integrity {
+		if err := b.writeIntegrity(); err != nil {
+			return err
+		}
+	}
+	if err := b.writeManifest(); err != nil {
+		b.diagnostics.Warn(b.manifestFile(), 0, "cannot write build manifest: %v", err)
+	}
+	b.summary.endPhase("Manifest")
+
+	// Success message, left out when stdout is reserved for the JSON report
+	if b.config.Diagnostics == "json" {
+		return nil
+	}
 	green := color.New(color.FgGreen, color.Bold)
 	cyan := color.New(color.FgCyan)
-	fmt.Printf("%s from %s to %s\n", 
-		green.Sprint("Compiled"), cyan.Sprint(b.config.GetAbsoluteInputDir()), cyan.Sprint(b.config.GetAbsoluteOutputDir()))
+	if b.config.BuildSummary {
+		b.printSummary("Compiled")
+	} else {
+		logging.Infof("%s from %s to %s, %s", 
+			green.Sprint("Compiled"), cyan.Sprint(b.config.GetAbsoluteInputDir()), cyan.Sprint(b.config.GetAbsoluteOutputDir()), b.writeSummary())
+	}
 	
 	if !b.config.Watch {
-		fmt.Printf("%s\n", green.Sprint("Success!"))
+		logging.Infof("%s", green.Sprint("Success!"))
 	}
 
 	return nil
 }
 
+// collectDefinitions pre-loads every source file and collects the site's snippets,
+// templates and globals, replacing whatever a previous build collected
+func (b *Builder) collectDefinitions(fileList [][3]string) error {
+	site := newSiteState()
+	b.entriesMu.Lock()
+	b.entries = make(map[string]string)
+	b.entriesMu.Unlock()
+	delimiters, err := parser.ParseDelimiters(b.config.Delimiters)
+	if err != nil {
+		return fmt.Errorf("in sniplicity.yaml: %w", err)
+	}
+	b.delimiters = delimiters
+	if b.dates, err = types.NewDates(b.config.DateFormats, b.config.Timezone, b.config.DateFormat); err != nil {
+		return fmt.Errorf("in sniplicity.yaml: %w", err)
+	}
+	if b.languages, err = types.NewLanguages(b.config.Languages); err != nil {
+		return fmt.Errorf("in sniplicity.yaml: %w", err)
+	}
+	if b.headingIDs, err = types.NewHeadingIDs(b.config.HeadingIDs); err != nil {
+		return fmt.Errorf("in sniplicity.yaml: %w", err)
+	}
+	if b.pageTemplates, err = parsePageTemplates(b.config.PageTemplates); err != nil {
+		return fmt.Errorf("in sniplicity.yaml: %w", err)
+	}
+	if b.redirectRules, err = parseRedirectRules(b.config.RedirectRules); err != nil {
+		return fmt.Errorf("in sniplicity.yaml: %w", err)
+	}
+	if b.headerRules, err = parseHeaderRules(b.config.Headers); err != nil {
+		return fmt.Errorf("in sniplicity.yaml: %w", err)
+	}
+	if b.remoteSources, err = parseRemoteData(b.config.RemoteData); err != nil {
+		return fmt.Errorf("in sniplicity.yaml: %w", err)
+	}
+	if err := b.loadSharedGlobals(site.Globals); err != nil {
+		return err
+	}
+	b.loadRemoteData(site.Globals)
+	b.setEnvironment(site.Globals)
+	b.defaults = types.NewDirectoryDefaults(b.config.GetAbsoluteInputDir())
+	b.processor.SetDirectoryDefaults(b.defaults)
+	b.processor.SetPermalinks(b.config.Permalinks)
+	b.processor.SetLanguages(b.languages)
+	b.processor.SetDates(b.dates)
+	b.gitHistory = nil
+	if b.config.GitInfo {
+		if b.gitHistory, err = b.loadGitHistory(); err != nil {
+			b.diagnostics.Warn("", 0, "git_info: %v", err)
+		}
+	}
+	b.processor.SetGitHistory(b.gitHistory)
+	b.diagnostics.SetRules(b.lintRules())
+	b.processor.SetDrafts(b.config.Drafts)
+	b.processor.SetBuiltinTemplates(b.builtinTemplates())
+	b.processor.ResetExternalIncludes()
+
+	catalogs, err := i18n.LoadCatalogs(filepath.Join(b.config.ProjectDir, i18n.LocalesDir), filepath.Join(b.config.ProjectDir, i18n.StringsDir))
+	if err != nil {
+		return fmt.Errorf("loading translations: %w", err)
+	}
+	b.processor.SetCatalogs(i18n.WithFallback(catalogs, b.config.TranslationFallback()))
+	b.processor.SetFallbackLanguage(b.config.TranslationFallback())
+	b.processor.SetSEO(b.config.SiteURL, b.config.SEO)
+	b.processor.SetNormalizeWhitespace(b.config.NormalizeWhitespace)
+	languageGlobals, err := b.loadLanguageGlobals()
+	if err != nil {
+		return err
+	}
+	b.processor.SetLanguageGlobals(languageGlobals)
+
+	// This matches Python's "Pre-loading files to collect templates..." exactly
+	logging.Debugf("Pre-loading files to collect templates...")
+	tempFiles := b.loadRawFiles(fileList)
+
+	// Collect snippets, templates, and globals from raw content
+	if err := b.collectSnippetsAndGlobals(tempFiles, site); err != nil {
+		return fmt.Errorf("error collecting snippets: %w", err)
+	}
+	if err := b.addSearchSnippet(site); err != nil {
+		return fmt.Errorf("search snippet: %w", err)
+	}
+
+	b.site.Store(site)
+	return nil
+}
+
+// loadRawFiles reads every source file with its frontmatter and directory defaults, but
+// without applying templates
+func (b *Builder) loadRawFiles(fileList [][3]string) []*types.FileInfo {
+	tempFiles := make([]*types.FileInfo, 0)
+	for _, item := range fileList {
+		relPath, filename, isMarkdownStr := item[0], item[1], item[2]
+		inputPath := filepath.Join(b.config.GetAbsoluteInputDir(), relPath, filename)
+		
+		isMarkdown := isMarkdownStr == "true"
+		// Create FileInfo but DON'T process markdown yet in pre-loading phase
+		fileInfo := types.NewFileInfoRaw(inputPath, filename, isMarkdown)
+		fileInfo.OutputRelPath = relPath
+		fileInfo.Delimiters = b.delimiters
+		fileInfo.HeadingIDs = b.headingIDs
+		
+		if err := fileInfo.LoadRaw(); err != nil {
+			logging.Warnf("Cannot read file %s: %v", inputPath, err)
+			continue
+		}
+		b.defaults.Apply(fileInfo.Metadata, relPath)
+		tempFiles = append(tempFiles, fileInfo)
+	}
+	return tempFiles
+}
+
 // getFileList matches Python's get_file_list exactly
 func (b *Builder) getFileList(sourceDir string) ([][3]string, error) {
 	var fileList [][3]string
+	warned := make(map[string]bool)
 
-	err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+	err := filepath.Walk(sourceDir, b.walkTolerant(sourceDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -363,27 +550,30 @@ func (b *Builder) getFileList(sourceDir string) ([][3]string, error) {
 			fileList = append(fileList, [3]string{relPath, filename, "true"})
 		} else if ext == ".html" || ext == ".htm" {
 			fileList = append(fileList, [3]string{relPath, filename, "false"})
+		} else {
+			return nil
 		}
 
+		b.checkDepth(relPath, warned)
+
 		return nil
-	})
+	}))
 
 	return fileList, err
 }
 
-func (b *Builder) collectSnippetsAndGlobals(files []*types.FileInfo) error {
-	if b.config.Verbose {
-		green := color.New(color.FgGreen)
-		fmt.Printf("Finding all %s, templates, and globals...\n", green.Sprint("snippets"))
-		fmt.Println("Processing files in this order:")
+func (b *Builder) collectSnippetsAndGlobals(files []*types.FileInfo, site *SiteState) error {
+	if logging.Enabled(logging.Debug) {
+		logging.Debugf("Finding all %s, templates, and globals...", color.GreenString("snippets"))
+		logging.Debugf("Processing files in this order:")
 		for _, file := range files {
-			fmt.Printf("  %s\n", file.Filename)
+			logging.Debugf("  %s", file.Filename)
 		}
 	}
 
 	// First collect all snippets and templates - matches Python exactly
 	for _, fileInfo := range files {
-		err := b.processor.CollectSnippetsFromFile(fileInfo, b.snippets, b.templates, b.config.Verbose)
+		err := b.processor.CollectSnippetsFromFile(fileInfo, site.Snippets, site.Templates)
 		if err != nil {
 			return err
 		}
@@ -391,7 +581,7 @@ func (b *Builder) collectSnippetsAndGlobals(files []*types.FileInfo) error {
 
 	// Then collect all globals - matches Python exactly  
 	for _, fileInfo := range files {
-		err := b.processor.CollectGlobalsFromFile(fileInfo, b.globals, b.config.Verbose)
+		err := b.processor.CollectGlobalsFromFile(fileInfo, site.Globals)
 		if err != nil {
 			return err
 		}
@@ -400,66 +590,147 @@ func (b *Builder) collectSnippetsAndGlobals(files []*types.FileInfo) error {
 	return nil
 }
 
-func (b *Builder) processIncludes() error {
-	if b.config.Verbose {
-		cyan := color.New(color.FgCyan)
-		fmt.Printf("Processing %s...\n", cyan.Sprint("includes"))
-	}
+func (b *Builder) processIncludes() {
+	logging.Debugf("Processing %s...", color.CyanString("includes"))
 
+	site := b.Site()
+	b.eachPage(func(fileInfo *types.FileInfo) error {
+		return b.processor.ProcessIncludes(fileInfo, b.config.GetAbsoluteInputDir(), site.Globals)
+	})
+}
+
+// setSitemapPages lists every page that will be written for sitemap-page directives,
+// before a partial build narrows the pages down to the ones it builds
+func (b *Builder) setSitemapPages() {
+	pages := make([]processor.SitemapPage, 0, len(b.files))
 	for _, fileInfo := range b.files {
-		err := b.processor.ProcessIncludes(fileInfo, b.config.GetAbsoluteInputDir())
-		if err != nil {
-			return err
+		if b.isNotFoundPage(fileInfo) {
+			continue
 		}
+		pages = append(pages, processor.SitemapPage{
+			URL:      strings.TrimPrefix(b.pageURL(fileInfo), "/"),
+			File:     b.outputRel(fileInfo.GetOutputPath(b.config.GetAbsoluteOutputDir())),
+			Metadata: fileInfo.Metadata,
+		})
 	}
-	return nil
+	b.processor.SetSitemapPages(pages)
 }
 
-func (b *Builder) processIndexCommands() error {
-	if b.config.Verbose {
-		fmt.Println("Processing index commands...")
-	}
+func (b *Builder) processIndexCommands() {
+	logging.Debugf("Processing index commands...")
 
-	for _, fileInfo := range b.files {
-		err := b.processor.ProcessIndexCommands(fileInfo, b.config.GetAbsoluteInputDir(), b.templates, b.snippets, b.globals)
+	site := b.Site()
+	b.eachPage(func(fileInfo *types.FileInfo) error {
+		if err := b.processor.ProcessIndexCommands(fileInfo, b.config.GetAbsoluteInputDir(), site.Templates, site.Snippets, site.Globals); err != nil {
+			return err
+		}
+		return b.processor.ProcessSitemapCommands(fileInfo, site.Templates, site.Snippets, site.Globals)
+	})
+}
+
+func (b *Builder) processSnippets() {
+	logging.Debugf("Processing %s in each file...", color.GreenString("snippets"))
+
+	site := b.Site()
+	b.eachPage(func(fileInfo *types.FileInfo) error {
+		return b.processor.ProcessSnippets(fileInfo, site.Snippets)
+	})
+}
+
+func (b *Builder) processVariables() {
+	logging.Debugf("Writing files...")
+	b.searchEntries = nil
+
+	outputDir := b.config.GetAbsoluteOutputDir()
+	site := b.Site()
+	waiting := make(map[*types.FileInfo][2]string)
+	b.eachPage(func(fileInfo *types.FileInfo) error {
+		// Only write pages that made it through the limits
+		page, body, err := b.processor.RenderPageWithBody(fileInfo, outputDir, site.Templates, site.Snippets, site.Globals, b.config.ImgSize)
 		if err != nil {
 			return err
 		}
+		if err := fileInfo.Limits.Check(len(page)); err != nil {
+			return err
+		}
+		// Pages showing when others last changed wait until those are written
+		if processor.HasLastModified(page) {
+			waiting[fileInfo] = [2]string{page, body}
+			return nil
+		}
+		return b.writePage(fileInfo, page, body)
+	})
+	if len(waiting) == 0 {
+		return
 	}
-	return nil
+	b.eachPage(func(fileInfo *types.FileInfo) error {
+		rendered, ok := waiting[fileInfo]
+		if !ok {
+			return nil
+		}
+		page := processor.ResolveLastModified(rendered[0], b.lastModified)
+		body := processor.ResolveLastModified(rendered[1], b.lastModified)
+		return b.writePage(fileInfo, page, body)
+	})
 }
 
-func (b *Builder) processSnippets() error {
-	if b.config.Verbose {
-		green := color.New(color.FgGreen)
-		fmt.Printf("Processing %s in each file...\n", green.Sprint("snippets"))
+// writePage writes a rendered page, and its plain text version when there is one, and
+// adds it to the search index
+func (b *Builder) writePage(fileInfo *types.FileInfo, page, body string) error {
+	outputDir := b.config.GetAbsoluteOutputDir()
+	if err := b.processor.WritePage(fileInfo, outputDir, page); err != nil {
+		return err
 	}
+	b.recordOutput(fileInfo.InputPath, fileInfo.GetOutputPath(outputDir))
+	b.recordAnchors(b.outputRel(fileInfo.GetOutputPath(outputDir)), fileInfo.Anchors)
+	b.addSearchEntry(fileInfo, page, body)
 
-	for _, fileInfo := range b.files {
-		err := b.processor.ProcessSnippets(fileInfo, b.snippets)
-		if err != nil {
+	// Plain text versions are for markdown pages, whose body is prose
+	if b.config.PlainText && types.IsMarkdownFile(fileInfo.InputPath) {
+		if err := b.processor.WritePlainText(fileInfo, outputDir, body); err != nil {
 			return err
 		}
+		b.recordOutput(fileInfo.InputPath, fileInfo.GetPlainTextPath(outputDir))
 	}
 	return nil
 }
 
-func (b *Builder) processVariables() error {
-	if b.config.Verbose {
-		fmt.Println("Writing files...")
+// watchIgnored reports whether a change to path shouldn't trigger a rebuild: it matches a
+// watch_ignore pattern, or is in the output or manifest folder nested inside the sources.
+// A file a page declares it depends on is never ignored.
+func (b *Builder) watchIgnored(path string) bool {
+	if isWithin(path, b.config.GetAbsoluteOutputDir()) {
+		return true
+	}
+	if b.isDependency(path) {
+		return false
+	}
+	if manifest := b.manifestFile(); manifest != "" && isWithin(path, filepath.Dir(manifest)) {
+		return true
 	}
 
-	for _, fileInfo := range b.files {
-		err := b.processor.ProcessVariables(fileInfo, b.config.GetAbsoluteOutputDir(), b.templates, b.snippets, b.globals, b.config.ImgSize, b.config.Verbose)
-		if err != nil {
-			return err
+	rel, err := filepath.Rel(b.config.GetAbsoluteInputDir(), path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range b.config.WatchIgnore {
+		if config.MatchPattern(pattern, rel) {
+			return true
 		}
 	}
-	return nil
+	return false
+}
+
+// startWatcher watches the input directory, replacing any watcher already running, with
+// file system events or by polling as watch_mode says
+func (b *Builder) startWatcher() error {
+	b.watchManager.SetPollInterval(b.config.WatchPollInterval())
+	return b.watchManager.Start(b.config.GetAbsoluteInputDir(), b.watchIgnored)
 }
 
 func (b *Builder) watchFiles() error {
-	if err := b.watchManager.Start(b.config.GetAbsoluteInputDir()); err != nil {
+	if err := b.startWatcher(); err != nil {
 		return fmt.Errorf("cannot start file watcher: %w", err)
 	}
 	defer b.watchManager.Stop()
@@ -468,366 +739,114 @@ func (b *Builder) watchFiles() error {
 	select {}
 }
 
-// hostAndWatch starts both file watching and web server with graceful shutdown
+// hostAndWatch builds on changes and serves the project until Ctrl+C
 func (b *Builder) hostAndWatch() error {
-	// Start file watcher if watch mode is enabled
-	if b.config.Watch {
-		if err := b.watchManager.Start(b.config.GetAbsoluteInputDir()); err != nil {
+	return b.serve(false)
+}
+
+// startWebServerOnly starts the web server for project selection, watching a project
+// once one is opened
+func (b *Builder) startWebServerOnly() error {
+	return b.serve(true)
+}
+
+// serve runs the web server, and the file watcher when watch is on and a project is open,
+// until Ctrl+C. selector is set when sniplicity started without a project.
+func (b *Builder) serve(selector bool) error {
+	watching := b.config.Watch && b.config.ProjectDir != "" && b.config.InputDir != ""
+	if watching {
+		if err := b.startWatcher(); err != nil {
 			return fmt.Errorf("cannot start file watcher: %w", err)
 		}
 		defer b.watchManager.Stop()
 	}
 
-	// Create custom handler that properly handles absolute paths for local navigation
-	fileServer := http.FileServer(http.Dir(b.config.GetAbsoluteOutputDir()))
-	
-	// Create web interface handler
-	webHandler, err := web.NewHandler(&b.config, func(newConfig *config.Config) error {
-		// This callback is called when configuration is saved via web interface
-		// Update the config and trigger a rebuild
-		b.config = *newConfig
-		
-		// Rebuild with the new configuration
-		if err := b.doBuild(); err != nil {
-			return fmt.Errorf("rebuild failed: %w", err)
-		}
-		
-		return nil
-	}, func(newProjectPath string) error {
-		// This callback is called when a project is switched via web interface
-		// Reload configuration from the new project and rebuild
-		newConfig, err := config.LoadConfigFromFile(newProjectPath)
-		if err != nil {
-			return fmt.Errorf("loading config from new project: %w", err)
-		}
-		
-		b.config = newConfig
-		
-		// Rebuild with the new project
-		if err := b.doBuild(); err != nil {
-			return fmt.Errorf("rebuild failed: %w", err)
-		}
-		
-		return nil
-	})
+	webHandler, err := web.NewHandler(&b.config, b.applyConfig, b.switchProject)
 	if err != nil {
 		return fmt.Errorf("creating web handler: %w", err)
 	}
+	webHandler.OnBuild(b.buildRequest)
+	webHandler.SetBuildLog(b.buildLog)
+	webHandler.OnInventory(b.Inventory)
+	webHandler.OnDeploy(b.Deploy)
 	
 	// Add current project to recent projects when starting server
 	if err := webHandler.AddCurrentProjectToRecent(); err != nil {
-		fmt.Printf("Warning: could not add current project to recent list: %v\n", err)
+		logging.Warnf("Could not add current project to recent list: %v", err)
 	}
-	
-	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Handle sniplicity configuration interface
-		if strings.HasPrefix(r.URL.Path, "/sniplicity") {
-			webHandler.ServeHTTP(w, r)
-			return
-		}
-		
-		// Handle root path
-		if r.URL.Path == "/" {
-			// If not in legacy mode (no explicit command line params), redirect to project selector
-			if !b.config.LegacyMode {
-				http.Redirect(w, r, "/sniplicity", http.StatusTemporaryRedirect)
-				return
-			}
-			
-			// Legacy mode: serve index.html file directly without redirect
-			indexPath := filepath.Join(b.config.GetAbsoluteOutputDir(), "index.html")
-			http.ServeFile(w, r, indexPath)
-			return
-		}
-		
-		// Custom handling for file vs directory conflicts
-		// Always check if the requested path corresponds to an actual file first
-		requestedPath := strings.TrimPrefix(r.URL.Path, "/")
-		filePath := filepath.Join(b.config.GetAbsoluteOutputDir(), requestedPath)
-		
-		// Security: clean the path to prevent directory traversal
-		filePath = filepath.Clean(filePath)
-		outputDir := b.config.GetAbsoluteOutputDir()
-		if !strings.HasPrefix(filePath, outputDir) {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
-		}
-		
-		if b.config.Verbose {
-			fmt.Printf("DEBUG: Requested path: %s -> File path: %s\n", r.URL.Path, filePath)
-		}
-		
-		// Check if the exact file exists
-		if info, err := os.Stat(filePath); err == nil && !info.IsDir() {
-			// File exists and is not a directory, serve it directly
-			if b.config.Verbose {
-				fmt.Printf("DEBUG: Serving file directly: %s\n", filePath)
-			}
-			http.ServeFile(w, r, filePath)
-			return
-		}
-		
-		// If no file found, let the default file server handle it (for directories, etc.)
-		if b.config.Verbose {
-			fmt.Printf("DEBUG: Using default file server for: %s\n", r.URL.Path)
-		}
-		fileServer.ServeHTTP(w, r)
-	})
 
-	// Create HTTP server
-	server := &http.Server{
-		Addr:    fmt.Sprintf("0.0.0.0:%d", b.config.Port),
-		Handler: handler,
+	server := web.NewServer(&b.config, webHandler, selector, b.clipboardOnly)
+	if err := server.Start(); err != nil {
+		return err
 	}
-
-	// Start server in goroutine - default to HTTP for better dev experience
-	go func() {
-		cyan := color.New(color.FgCyan)
-		
-		// Default to HTTP for local development
-		serverURL := fmt.Sprintf("http://127.0.0.1:%d", b.config.Port)
-		fmt.Printf("Starting web server at %s\n", cyan.Sprint(serverURL))
-		
-		if localIP := getLocalIP(); localIP != "" {
-			localURL := fmt.Sprintf("http://%s:%d", localIP, b.config.Port)
-			fmt.Printf("Network access available at %s\n", cyan.Sprint(localURL))
-		}
-		
-		// Try to copy URL to clipboard
-		if err := clipboard.WriteAll(serverURL); err == nil {
-			fmt.Printf("✓ URL copied to clipboard - you can paste it anywhere!\n")
-		} else {
-			fmt.Printf("ℹ Copy this URL: %s\n", cyan.Sprint(serverURL))
-		}
-		
-		// Try to open browser automatically (unless clipboard-only mode)
-		if !b.clipboardOnly {
-			if err := open.Run(serverURL); err == nil {
-				fmt.Printf("✓ Opening in your default browser...\n")
-			} else {
-				fmt.Printf("ℹ Please open the URL above in your browser\n")
-			}
-		}
-		
-		fmt.Println()
-		
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Printf("HTTP server error: %v", err)
-		}
-	}()
 	
 	// Handle graceful shutdown
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
-	fmt.Printf("%s\n", color.New(color.FgYellow).Sprint("Press Ctrl+C to stop watching and server"))
+	stopping := "web server"
+	if watching {
+		stopping = "file watcher and web server"
+	}
+	logging.Infof("%s", color.New(color.FgYellow).Sprint("Press Ctrl+C to stop the "+stopping))
 	
 	// Wait for signal
 	<-c
 	
 	green := color.New(color.FgGreen)
-	fmt.Printf("\n%s\n", green.Sprint("Stopping file watcher and web server..."))
+	logging.Infof("\n%s", green.Sprint("Stopping "+stopping+"..."))
 	
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	
 	if err := server.Shutdown(ctx); err != nil {
-		log.Printf("Server shutdown error: %v", err)
+		logging.Errorf("Server shutdown: %v", err)
 	}
 	
-	fmt.Printf("%s\n", green.Sprint("Done!"))
+	logging.Infof("%s", green.Sprint("Done!"))
 	return nil
 }
 
-// startWebServerOnly starts just the web server for project selection, without file watching
-func (b *Builder) startWebServerOnly() error {
-	// Start file watcher if we have a project and watch mode is enabled
-	if b.config.ProjectDir != "" && b.config.InputDir != "" && b.config.Watch {
-		if err := b.watchManager.Start(b.config.GetAbsoluteInputDir()); err != nil {
-			log.Printf("Warning: Cannot start file watcher: %v", err)
-		} else {
-			defer b.watchManager.Stop()
-		}
-	}
-	// Create web interface handler
-	webHandler, err := web.NewHandler(&b.config, func(newConfig *config.Config) error {
-		// This callback is called when configuration is saved via web interface
-		// Update the config and trigger a rebuild
-		b.config = *newConfig
-		
-		// Rebuild with the new configuration
-		if err := b.doBuild(); err != nil {
-			return fmt.Errorf("rebuild failed: %w", err)
-		}
-		
-		return nil
-	}, func(newProjectPath string) error {
-		// This callback is called when a project is switched via web interface
-		// Reload configuration from the new project and rebuild
-		newConfig, err := config.LoadConfigFromFile(newProjectPath)
-		if err != nil {
-			return fmt.Errorf("loading config from new project: %w", err)
-		}
-		
-		// Preserve watch mode and serve settings from the original config
-		newConfig.Watch = b.config.Watch
-		newConfig.Serve = b.config.Serve
-		
-		b.config = newConfig
-		
-		// Switch watcher to new project directory if watch mode is enabled
-		if b.config.Watch {
-			if err := b.watchManager.Switch(b.config.GetAbsoluteInputDir()); err != nil {
-				log.Printf("Warning: could not switch file watcher: %v", err)
-			}
-		}
-		
-		// Rebuild with the new project
-		if err := b.doBuild(); err != nil {
-			return fmt.Errorf("rebuild failed: %w", err)
-		}
-		
-		return nil
-	})
-	if err != nil {
-		return fmt.Errorf("creating web handler: %w", err)
-	}
-	
-	// Add current project to recent projects when starting server
-	if err := webHandler.AddCurrentProjectToRecent(); err != nil {
-		fmt.Printf("Warning: could not add current project to recent list: %v\n", err)
+// applyConfig uses the settings saved in the web interface and rebuilds with them
+func (b *Builder) applyConfig(newConfig *config.Config) error {
+	b.config = *newConfig
+	if err := b.doBuild(); err != nil {
+		return fmt.Errorf("rebuild failed: %w", err)
 	}
-	
-	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Handle sniplicity configuration interface
-		if strings.HasPrefix(r.URL.Path, "/sniplicity") {
-			webHandler.ServeHTTP(w, r)
-			return
-		}
-		
-		// If we have a project with an output directory, serve files from it
-		if b.config.ProjectDir != "" && b.config.OutputDir != "" {
-			outputDir := b.config.GetAbsoluteOutputDir()
-			
-			// Handle root path by serving index.html directly
-			if r.URL.Path == "/" {
-				indexPath := filepath.Join(outputDir, "index.html")
-				if _, err := os.Stat(indexPath); err == nil {
-					http.ServeFile(w, r, indexPath)
-					return
-				}
-			}
-			
-			// Serve other files from output directory
-			requestedPath := strings.TrimPrefix(r.URL.Path, "/")
-			filePath := filepath.Join(outputDir, requestedPath)
-			
-			// Security: clean the path to prevent directory traversal
-			filePath = filepath.Clean(filePath)
-			if !strings.HasPrefix(filePath, outputDir) {
-				http.Error(w, "Forbidden", http.StatusForbidden)
-				return
-			}
-			
-			// Check if the exact file exists
-			if info, err := os.Stat(filePath); err == nil && !info.IsDir() {
-				http.ServeFile(w, r, filePath)
-				return
-			}
-			
-			// If no file found, use default file server for directory listings, etc.
-			fileServer := http.FileServer(http.Dir(outputDir))
-			fileServer.ServeHTTP(w, r)
-			return
-		}
-		
-		// No project active - redirect to project selection
-		if r.URL.Path != "/sniplicity" {
-			http.Redirect(w, r, "/sniplicity", http.StatusTemporaryRedirect)
-			return
-		}
-	})
+	return nil
+}
 
-	// Create HTTP server
-	server := &http.Server{
-		Addr:    fmt.Sprintf("0.0.0.0:%d", b.config.Port),
-		Handler: handler,
+// switchProject opens the project chosen in the web interface: its config is loaded,
+// keeping whether to watch and serve, the watcher moves to it and it is built
+func (b *Builder) switchProject(newProjectPath string) error {
+	newConfig, err := config.LoadConfigFromFile(newProjectPath)
+	if err != nil {
+		return fmt.Errorf("loading config from new project: %w", err)
 	}
+	newConfig.Watch = b.config.Watch
+	newConfig.Serve = b.config.Serve
+	b.config = newConfig
 
-	// Start server in goroutine - default to HTTP for better dev experience
-	go func() {
-		cyan := color.New(color.FgCyan)
-		
-		// Default to HTTP for local development
-		serverURL := fmt.Sprintf("http://127.0.0.1:%d", b.config.Port)
-		projectSelectorURL := serverURL + "/sniplicity"
-		
-		fmt.Printf("Starting web server at %s\n", cyan.Sprint(serverURL))
-		
-		if localIP := getLocalIP(); localIP != "" {
-			localURL := fmt.Sprintf("http://%s:%d", localIP, b.config.Port)
-			fmt.Printf("Network access available at %s\n", cyan.Sprint(localURL))
-		}
-		
-		// Try to copy project selector URL to clipboard
-		if err := clipboard.WriteAll(projectSelectorURL); err == nil {
-			fmt.Printf("✓ Project selector URL copied to clipboard - you can paste it anywhere!\n")
-		} else {
-			fmt.Printf("ℹ Copy this URL: %s\n", cyan.Sprint(projectSelectorURL))
-		}
-		
-		// Try to open browser automatically to project selector (unless clipboard-only mode)
-		if !b.clipboardOnly {
-			if err := open.Run(projectSelectorURL); err == nil {
-				fmt.Printf("✓ Opening project selector in your default browser...\n")
-			} else {
-				fmt.Printf("ℹ Please open the URL above in your browser\n")
-			}
-		}
-		
-		fmt.Println()
-		
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Printf("HTTP server error: %v", err)
+	if b.config.Watch {
+		if err := b.startWatcher(); err != nil {
+			logging.Warnf("Could not switch file watcher: %v", err)
 		}
-	}()
-	
-	// Handle graceful shutdown
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-
-	fmt.Printf("%s\n", color.New(color.FgYellow).Sprint("Press Ctrl+C to stop server"))
-	
-	// Wait for signal
-	<-c
-	
-	green := color.New(color.FgGreen)
-	fmt.Printf("\n%s\n", green.Sprint("Stopping web server..."))
-	
-	// Graceful shutdown with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	
-	if err := server.Shutdown(ctx); err != nil {
-		log.Printf("Server shutdown error: %v", err)
 	}
-	
-	fmt.Printf("%s\n", green.Sprint("Done!"))
+	if err := b.doBuild(); err != nil {
+		return fmt.Errorf("rebuild failed: %w", err)
+	}
 	return nil
 }
 
 // copyAssets copies all non-processed files (CSS, JS, images, etc.) from input to output directory
-func (b *Builder) copyAssets() error {
+func (b *Builder) copyAssets(scope BuildScope) error {
 	inputDir := b.config.GetAbsoluteInputDir()
 	outputDir := b.config.GetAbsoluteOutputDir()
 	
-	if b.config.Verbose {
-		green := color.New(color.FgGreen)
-		fmt.Printf("Copying %s...\n", green.Sprint("assets"))
-	}
+	logging.Debugf("Copying %s...", color.GreenString("assets"))
 	
-	return filepath.Walk(inputDir, func(path string, info os.FileInfo, err error) error {
+	return filepath.Walk(inputDir, b.walkTolerant(inputDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -847,19 +866,17 @@ func (b *Builder) copyAssets() error {
 		isProcessedFile := ext == ".md" || ext == ".mdown" || ext == ".markdown" || 
 		                   ext == ".html" || ext == ".htm"
 		
-		if isProcessedFile {
+		if isProcessedFile || info.Name() == types.DefaultsFilename || slices.Contains(b.hostRulesFiles(), filepath.ToSlash(relPath)) {
 			// Skip files that are processed by sniplicity
 			return nil
 		}
+		if b.excludedAsset(inputDir, relPath) || !scope.includes(relPath) {
+			return nil
+		}
 
 		// Copy the asset file
 		outputPath := filepath.Join(outputDir, relPath)
 		
-		// Create directory if it doesn't exist
-		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
-			return fmt.Errorf("creating directory %s: %w", filepath.Dir(outputPath), err)
-		}
-
 		// Handle SVG files with filter processing if enabled
 		if b.config.SvgFilter && ext == ".svg" {
 			if err := b.processSVGFile(path, outputPath); err != nil {
@@ -871,32 +888,21 @@ func (b *Builder) copyAssets() error {
 				return fmt.Errorf("copying %s to %s: %w", path, outputPath, err)
 			}
 		}
+		b.recordOutput(path, outputPath)
 
-		if b.config.Verbose {
-			cyan := color.New(color.FgCyan)
-			fmt.Printf("  Copied %s\n", cyan.Sprint(relPath))
-		}
+		logging.Debugf("  Copied %s", color.CyanString(relPath))
 
 		return nil
-	})
+	}))
 }
 
-// copyFile copies a single file from src to dst
+// copyFile copies a single file from src to dst, keeping its permissions
 func (b *Builder) copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer sourceFile.Close()
-
-	destFile, err := os.Create(dst)
+	data, err := os.ReadFile(src)
 	if err != nil {
 		return err
 	}
-	defer destFile.Close()
-
-	_, err = io.Copy(destFile, sourceFile)
-	if err != nil {
+	if err := b.writeFile(dst, data); err != nil || b.config.DryRun {
 		return err
 	}
 
@@ -924,7 +930,7 @@ func (b *Builder) processSVGFile(src, dst string) error {
 	}
 
 	// Write the processed content to destination
-	if err := os.WriteFile(dst, []byte(processedContent), 0644); err != nil {
+	if err := b.writeFile(dst, []byte(processedContent)); err != nil {
 		return fmt.Errorf("writing processed SVG file: %w", err)
 	}
 
@@ -0,0 +1,73 @@
+package builder
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"sniplicity/internal/logging"
+)
+
+// bundleJS bundles and, with minify on, minifies the js_entry script with esbuild,
+// writing the result to js_bundle in the output directory. Watch and serve builds get an
+// inline source map, unless they are production builds.
+func (b *Builder) bundleJS() {
+	inputDir := b.config.GetAbsoluteInputDir()
+	entry := filepath.Join(inputDir, filepath.FromSlash(b.config.JSEntry))
+	if _, err := os.Stat(entry); err != nil {
+		b.diagnostics.Error(entry, 0, "js_entry not found")
+		return
+	}
+
+	esbuild := b.config.Esbuild
+	if esbuild == "" {
+		esbuild = "esbuild"
+	}
+	path, err := exec.LookPath(esbuild)
+	if err != nil {
+		b.diagnostics.Error(entry, 0, "js_entry is set but %s was not found; install it from https://esbuild.github.io or set esbuild to its path", esbuild)
+		return
+	}
+
+	bundle := b.config.JSBundle
+	if bundle == "" {
+		bundle = b.config.JSEntry
+	}
+	outputPath := filepath.Join(b.config.GetAbsoluteOutputDir(), filepath.FromSlash(bundle))
+
+	args := []string{entry, "--bundle", "--log-level=error"}
+	if b.config.Minify {
+		args = append(args, "--minify")
+	}
+	if b.config.JSTarget != "" {
+		args = append(args, "--target="+b.config.JSTarget)
+	}
+	if b.config.Watch && !b.config.IsProduction() {
+		args = append(args, "--sourcemap=inline")
+	}
+
+	// The bundle comes back on stdout so it goes through writeFile like every other output
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, args...)
+	cmd.Dir = inputDir
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		var exitErr *exec.ExitError
+		if message == "" || !errors.As(err, &exitErr) {
+			message = err.Error()
+		}
+		b.diagnostics.Error(entry, 0, "esbuild failed: %s", message)
+		return
+	}
+
+	if err := b.writeFile(outputPath, stdout.Bytes()); err != nil {
+		b.diagnostics.Error(entry, 0, "cannot write %s: %v", outputPath, err)
+		return
+	}
+	b.recordOutput(entry, outputPath)
+	logging.Debugf("  Bundled %s into %s", b.config.JSEntry, bundle)
+}
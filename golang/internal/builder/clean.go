@@ -0,0 +1,198 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sniplicity/internal/config"
+	"sniplicity/internal/logging"
+)
+
+// recordOutput notes a file the current build wrote to the output directory and the
+// source file it was made from, or "" when it was made from several
+func (b *Builder) recordOutput(source, path string) {
+	b.outputsMu.Lock()
+	defer b.outputsMu.Unlock()
+	b.outputs[filepath.Clean(path)] = source
+}
+
+// removeStaleOutput deletes files in the output directory that the build just finished
+// didn't write, such as pages whose source was deleted or renamed. Hidden files and
+// folders like .git are never touched.
+func (b *Builder) removeStaleOutput() {
+	outputDir := b.config.GetAbsoluteOutputDir()
+	if err := checkCleanable(b.config); err != nil {
+		b.diagnostics.Warn("", 0, "not removing stale output: %v", err)
+		return
+	}
+
+	b.outputsMu.Lock()
+	defer b.outputsMu.Unlock()
+
+	var stale []string
+	err := filepath.WalkDir(outputDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != outputDir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, written := b.outputs[path]; !d.IsDir() && !written {
+			stale = append(stale, path)
+		}
+		return nil
+	})
+	if err != nil {
+		b.diagnostics.Warn(outputDir, 0, "cannot look for stale output: %v", err)
+		return
+	}
+
+	for _, path := range stale {
+		if b.config.DryRun {
+			b.planChange("delete", path)
+			continue
+		}
+		if err := b.removeOutputFile(path); err != nil {
+			b.diagnostics.Warn(path, 0, "cannot remove stale output: %v", err)
+			continue
+		}
+		logging.Debugf("  Removed stale %s", path)
+	}
+}
+
+// rebuild runs a watch mode build, then removes the outputs of sources that were deleted
+// or renamed away. Unlike clean_output this only touches files the builder made itself.
+func (b *Builder) rebuild(removed []string) {
+	b.reloadConfig()
+	orphans := b.outputsOf(removed)
+	if err := b.doBuild(); err != nil {
+		logging.Errorf("Build failed: %v", err)
+		return
+	}
+
+	b.outputsMu.Lock()
+	defer b.outputsMu.Unlock()
+	for _, path := range orphans {
+		if _, written := b.outputs[path]; written {
+			continue // Another source writes it now, e.g. after a rename from .md to .html
+		}
+		if err := b.removeOutputFile(path); err != nil && !os.IsNotExist(err) {
+			logging.Warnf("Cannot remove %s: %v", path, err)
+			continue
+		}
+		logging.Debugf("  Removed %s", path)
+	}
+}
+
+// outputsOf returns the files the last build wrote from the given sources, or from
+// anything inside them when they are folders
+func (b *Builder) outputsOf(sources []string) []string {
+	b.outputsMu.Lock()
+	defer b.outputsMu.Unlock()
+
+	var outputs []string
+	for output, source := range b.outputs {
+		if source == "" {
+			continue
+		}
+		for _, removed := range sources {
+			if isWithin(source, filepath.Clean(removed)) {
+				outputs = append(outputs, output)
+				break
+			}
+		}
+	}
+	sort.Strings(outputs)
+	return outputs
+}
+
+// removeOutputFile deletes a file from the output directory along with any folders that
+// leaves empty, stopping at the first one that isn't
+func (b *Builder) removeOutputFile(path string) error {
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	outputDir := b.config.GetAbsoluteOutputDir()
+	for dir := filepath.Dir(path); dir != outputDir && isWithin(dir, outputDir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// Clean empties a project's output directory, apart from hidden files and folders like
+// .git, and returns what it removed relative to the output directory. With dryRun it
+// only returns what it would remove.
+func Clean(cfg config.Config, dryRun bool) ([]string, error) {
+	if err := checkCleanable(cfg); err != nil {
+		return nil, err
+	}
+
+	outputDir := cfg.GetAbsoluteOutputDir()
+	entries, err := os.ReadDir(outputDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading output directory: %w", err)
+	}
+
+	var removed []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			name += string(filepath.Separator)
+		}
+		if !dryRun {
+			if err := os.RemoveAll(filepath.Join(outputDir, entry.Name())); err != nil {
+				return removed, fmt.Errorf("cannot remove %s: %w", name, err)
+			}
+		}
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+	return removed, nil
+}
+
+// checkCleanable refuses output directories that deleting files from could hurt: the
+// filesystem root, the home folder and any that holds or is inside the sources or project
+func checkCleanable(cfg config.Config) error {
+	outputDir := filepath.Clean(cfg.GetAbsoluteOutputDir())
+	inputDir := filepath.Clean(cfg.GetAbsoluteInputDir())
+
+	if filepath.Dir(outputDir) == outputDir {
+		return fmt.Errorf("output directory %s is the filesystem root", outputDir)
+	}
+	if home, err := os.UserHomeDir(); err == nil && filepath.Clean(home) == outputDir {
+		return fmt.Errorf("output directory %s is the home folder", outputDir)
+	}
+	if isWithin(inputDir, outputDir) {
+		return fmt.Errorf("output directory %s contains the input directory", outputDir)
+	}
+	if isWithin(outputDir, inputDir) {
+		return fmt.Errorf("output directory %s is inside the input directory", outputDir)
+	}
+	if cfg.ProjectDir != "" && isWithin(filepath.Clean(cfg.ProjectDir), outputDir) {
+		return fmt.Errorf("output directory %s contains the project", outputDir)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "sniplicity.yaml")); err == nil {
+		return fmt.Errorf("output directory %s holds a sniplicity.yaml", outputDir)
+	}
+	return nil
+}
+
+// isWithin reports whether path is dir or somewhere inside it
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,66 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"sniplicity/internal/parser"
+	"sniplicity/internal/processor"
+	"sniplicity/internal/types"
+)
+
+// declaredDependencies returns the paths a page names in depends directives and its
+// depends frontmatter, as written, in order
+func declaredDependencies(fileInfo *types.FileInfo, vars map[string]string) []string {
+	dependencies := types.ParseList(fileInfo.Metadata["depends"])
+	for i, line := range fileInfo.Content {
+		if directive := parser.ParseLine(line, i); directive != nil && directive.Type == parser.DirectiveDepends {
+			dependencies = append(dependencies, parser.ExpandVariables(directive.Args[0], vars))
+		}
+	}
+	return dependencies
+}
+
+// resolveDependencies finds the files a page depends on, the same way as includes,
+// warning about those that don't exist
+func (b *Builder) resolveDependencies(fileInfo *types.FileInfo) []string {
+	var files []string
+	for _, dependency := range declaredDependencies(fileInfo, pageVariables(fileInfo, b.Site().Globals)) {
+		path := processor.ResolveIncludePath(dependency, filepath.Dir(fileInfo.InputPath), b.config.GetAbsoluteInputDir())
+		if _, err := os.Stat(path); err != nil {
+			b.diagnostics.Warn(fileInfo.InputPath, 0, "depends on %s, which doesn't exist", dependency)
+		}
+		files = append(files, filepath.Clean(path))
+	}
+	return files
+}
+
+// setDependencies records the files the pages of this build declare they depend on
+func (b *Builder) setDependencies(dependencies map[string]bool) {
+	b.outputsMu.Lock()
+	defer b.outputsMu.Unlock()
+	b.dependencies = dependencies
+}
+
+// isDependency reports whether a page declares it depends on path
+func (b *Builder) isDependency(path string) bool {
+	b.outputsMu.Lock()
+	defer b.outputsMu.Unlock()
+	return b.dependencies[filepath.Clean(path)]
+}
+
+// externalDependencies returns the declared dependencies outside the input directory,
+// sorted, which the watcher has to be told about
+func (b *Builder) externalDependencies() []string {
+	b.outputsMu.Lock()
+	defer b.outputsMu.Unlock()
+	var files []string
+	for path := range b.dependencies {
+		if !isWithin(path, b.config.GetAbsoluteInputDir()) {
+			files = append(files, path)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
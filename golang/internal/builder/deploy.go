@@ -0,0 +1,38 @@
+package builder
+
+import (
+	"fmt"
+
+	"sniplicity/internal/deploy"
+	"sniplicity/internal/logging"
+)
+
+// Deploy builds the whole site for the deploy environment and publishes it with the
+// project's deploy step, logging the step's output so it appears in the Build Console.
+// The preview keeps the deploy build until the next rebuild, which goes back to the
+// project's own environment.
+func (b *Builder) Deploy() error {
+	b.deployMu.Lock()
+	defer b.deployMu.Unlock()
+	b.reloadConfig()
+	if err := b.config.CheckDeploy(); err != nil {
+		return err
+	}
+
+	cfg := b.config
+	if err := b.config.SetEnvironment(b.config.DeployEnv()); err != nil {
+		return err
+	}
+	defer func() { b.config = cfg }()
+
+	if err := b.doBuild(); err != nil {
+		return fmt.Errorf("build failed: %w", err)
+	}
+	logging.Infof("%s", deploy.Describe(b.config))
+	if err := deploy.Run(b.config, func(line string) { logging.Infof("  %s", line) }); err != nil {
+		logging.Errorf("Deploy failed: %v", err)
+		return err
+	}
+	logging.Infof("Deployed")
+	return nil
+}
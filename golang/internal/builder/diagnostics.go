@@ -0,0 +1,58 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+
+	"sniplicity/internal/diag"
+	"sniplicity/internal/logging"
+)
+
+// Diagnostics returns the warnings and errors collected by the last build or render
+func (b *Builder) Diagnostics() *diag.Collector {
+	return b.diagnostics
+}
+
+// buildError returns an error if any page failed or strict mode turned broken references
+// into errors
+func (b *Builder) buildError() error {
+	if n := b.diagnostics.Count(diag.Error); n > 0 {
+		return fmt.Errorf("%d error(s) found", n)
+	}
+	return nil
+}
+
+// reportDiagnostics prints the warnings and errors of the build that just finished, as a
+// summary or, with --diagnostics=json, as a JSON report on stdout
+func (b *Builder) reportDiagnostics(err error) {
+	if b.config.Diagnostics != "json" {
+		b.diagnostics.WriteText(os.Stdout, logging.Enabled(logging.Warn))
+		return
+	}
+
+	// A failed build should never report zero errors
+	if err != nil && b.diagnostics.Count(diag.Error) == 0 {
+		b.diagnostics.Error("", 0, "%v", err)
+	}
+	if err := b.diagnostics.WriteJSON(os.Stdout, b.pageSizes()); err != nil {
+		logging.Errorf("Cannot write diagnostics: %v", err)
+	}
+}
+
+// pageSizes returns the size of every page the build wrote, by its output path relative to
+// the output directory, for comparing reports with sniplicity report --compare. A dry run
+// writes none.
+func (b *Builder) pageSizes() map[string]int64 {
+	if b.config.DryRun {
+		return nil
+	}
+	outputDir := b.config.GetAbsoluteOutputDir()
+	sizes := make(map[string]int64, len(b.files))
+	for _, fileInfo := range b.files {
+		path := fileInfo.GetOutputPath(outputDir)
+		if info, err := os.Stat(path); err == nil {
+			sizes[b.outputRel(path)] = info.Size()
+		}
+	}
+	return sizes
+}
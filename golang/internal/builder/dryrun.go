@@ -0,0 +1,116 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"sniplicity/internal/logging"
+
+	"github.com/fatih/color"
+)
+
+// Change is an output file a dry run would create, update or delete
+type Change struct {
+	Action string `json:"action"` // "create", "update" or "delete"
+	Path   string `json:"path"`   // Relative to the output directory, with forward slashes
+}
+
+// Changes returns what the last dry run would have changed in the output directory, sorted by path
+func (b *Builder) Changes() []Change {
+	b.changesMu.Lock()
+	defer b.changesMu.Unlock()
+
+	changes := append([]Change{}, b.changes...)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// writeFile writes an output file, creating its folder first. Files whose content hasn't
+// changed are left alone so they keep their modification times. In a dry run it only notes
+// whether the file would be created or updated; files that wouldn't change are left out.
+func (b *Builder) writeFile(path string, data []byte) error {
+	if b.config.InlineAssets > 0 {
+		data = b.inlineAssets(path, data)
+	}
+	if b.config.Minify {
+		data = b.minifyOutput(path, data)
+	}
+	if !b.config.DryRun {
+		hash := hashContent(data)
+		b.outputsMu.Lock()
+		b.hashes[filepath.Clean(path)] = hash
+		b.outputsMu.Unlock()
+
+		if b.unchanged(path, data, hash) {
+			b.keepModified(path)
+			b.countWrite(false)
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("cannot create output directory %s: %w", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+		b.setModified(path)
+		b.countWrite(true)
+		return nil
+	}
+
+	existing, err := os.ReadFile(path)
+	switch {
+	case err != nil:
+		b.planChange("create", path)
+	case !bytes.Equal(existing, data):
+		b.planChange("update", path)
+	}
+	return nil
+}
+
+// countWrite counts an output file the build wrote, or left alone as it was the same
+func (b *Builder) countWrite(written bool) {
+	b.outputsMu.Lock()
+	defer b.outputsMu.Unlock()
+	if written {
+		b.written++
+	} else {
+		b.skipped++
+	}
+}
+
+// writeSummary describes how many output files the build wrote and how many it left
+// unchanged, for the build's success message
+func (b *Builder) writeSummary() string {
+	b.outputsMu.Lock()
+	defer b.outputsMu.Unlock()
+	return fmt.Sprintf("%d written, %d unchanged", b.written, b.skipped)
+}
+
+// planChange records a change a dry run would make
+func (b *Builder) planChange(action, path string) {
+	rel, err := filepath.Rel(b.config.GetAbsoluteOutputDir(), path)
+	if err != nil {
+		rel = path
+	}
+
+	b.changesMu.Lock()
+	defer b.changesMu.Unlock()
+	b.changes = append(b.changes, Change{Action: action, Path: filepath.ToSlash(rel)})
+}
+
+// reportChanges lists what the dry run would change, followed by a count of each kind
+func (b *Builder) reportChanges() {
+	changes := b.Changes()
+	counts := make(map[string]int)
+	for _, change := range changes {
+		counts[change.Action]++
+		logging.Infof("  %-6s %s", change.Action, change.Path)
+	}
+
+	green := color.New(color.FgGreen, color.Bold)
+	logging.Infof("%s %s: %d to create, %d to update, %d to delete, nothing written",
+		green.Sprint("Dry run"), color.CyanString(b.config.GetAbsoluteOutputDir()), counts["create"], counts["update"], counts["delete"])
+}
@@ -0,0 +1,51 @@
+package builder
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"sniplicity/internal/config"
+	"sniplicity/internal/logging"
+)
+
+// productionExclude are the file patterns every production build leaves out
+var productionExclude = []string{"*.map"}
+
+// setEnvironment makes the build environment available to pages as {{env}}, and as
+// env.<name> for conditionals like <!-- if env.development -->
+func (b *Builder) setEnvironment(globals map[string]string) {
+	env := b.config.Environment()
+	globals["env"] = env
+	globals["env."+env] = "true"
+}
+
+// excludedAsset reports whether a production build leaves out the asset at relPath:
+// source maps, files matching production_exclude, and unminified originals such as
+// app.js when app.min.js sits beside them
+func (b *Builder) excludedAsset(inputDir, relPath string) bool {
+	if !b.config.IsProduction() {
+		return false
+	}
+
+	rel := filepath.ToSlash(relPath)
+	patterns := append(append([]string{}, productionExclude...), b.config.ProductionExclude...)
+	for _, pattern := range patterns {
+		if config.MatchPattern(pattern, rel) {
+			logging.Debugf("  Leaving out %s (matches %s)", rel, pattern)
+			return true
+		}
+	}
+
+	ext := path.Ext(rel)
+	base := strings.TrimSuffix(rel, ext)
+	if (ext == ".js" || ext == ".css") && !strings.HasSuffix(base, ".min") {
+		minified := filepath.Join(inputDir, filepath.FromSlash(base+".min"+ext))
+		if _, err := os.Stat(minified); err == nil {
+			logging.Debugf("  Leaving out %s (unminified original of %s)", rel, path.Base(base+".min"+ext))
+			return true
+		}
+	}
+	return false
+}
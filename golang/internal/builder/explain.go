@@ -0,0 +1,329 @@
+package builder
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"sniplicity/internal/config"
+	"sniplicity/internal/graph"
+	"sniplicity/internal/i18n"
+	"sniplicity/internal/parser"
+	"sniplicity/internal/types"
+	"sniplicity/internal/web"
+)
+
+// The steps of loadSinglePage that can add to a page's metadata, as traced for Explain
+const (
+	traceFrontmatter   = "frontmatter"
+	traceDefaults      = "defaults"
+	tracePageTemplates = "page_templates"
+	tracePath          = "path"
+)
+
+// Explanation is how one page is put together: the template it gets and why, the
+// snippets and files it uses, and where each variable it refers to comes from
+type Explanation struct {
+	Page      string // Relative to the input directory
+	URL       string // Where the build writes it, relative to the site root
+	Draft     bool   // Whether builds without drafts leave it out
+	Template  string // Name of its template, "" for none
+	Reason    string // Why it gets that template
+	Uses      []ExplainedUse
+	Variables []ExplainedVariable
+	Err       error // Why rendering failed, nil when it worked
+}
+
+// ExplainedUse is a template, snippet, included file or declared dependency a page uses,
+// directly or through what it uses
+type ExplainedUse struct {
+	Depth   int // 1 for what the page uses itself, 2 for what those use and so on
+	Kind    graph.Kind
+	Name    string
+	Path    string // File it is defined in, "" when missing
+	Missing bool
+	Seen    bool // Whether it was listed further up already, with what it uses
+}
+
+// ExplainedVariable is a variable a page, its template or its snippets refer to
+type ExplainedVariable struct {
+	Name   string
+	Value  string
+	Source string // Where the value comes from, or why there is none
+}
+
+// Explain collects the definitions of the project and explains how the page at path is
+// put together, then renders it so the diagnostics report its problems
+func (b *Builder) Explain(path string) (*Explanation, error) {
+	sources := make(map[string]string) // Where each metadata key came from
+	values := make(map[string]string)  // The value it had then
+	trace := func(fileInfo *types.FileInfo, step string) {
+		for key, value := range fileInfo.Metadata {
+			if text, known := values[key]; known && text == fmt.Sprint(value) {
+				continue
+			}
+			sources[key] = b.metadataSource(step, fileInfo, key)
+			values[key] = fmt.Sprint(value)
+		}
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s: %w", path, err)
+	}
+	fileInfo, err := b.loadSinglePage(path, nil, trace)
+	if err != nil {
+		return nil, err
+	}
+
+	fileList, err := b.getFileList(b.config.GetAbsoluteInputDir())
+	if err != nil {
+		return nil, fmt.Errorf("cannot get file list: %w", err)
+	}
+	files := b.loadRawFiles(fileList)
+	g := b.definitionGraph(files)
+	page := b.addPage(g, fileInfo)
+
+	e := &Explanation{
+		Page:  b.inputRel(absPath),
+		URL:   prettyURL(b.pageURL(fileInfo)),
+		Draft: types.IsDraft(fileInfo.Metadata),
+	}
+	e.Template, e.Reason = b.explainTemplate(fileInfo, sources)
+	e.Uses = explainUses(g, page)
+
+	// Variables of the page and everything it uses, each with the value the build gives it
+	globalSources := b.globalSources(files)
+	languageGlobals, _ := b.loadLanguageGlobals()
+	lang, _ := fileInfo.Metadata["lang"].(string)
+	names := make(map[string]bool)
+	variables := b.nodeVariables(append(files, fileInfo), g)
+	for _, id := range append([]string{page.ID}, useIDs(e.Uses)...) {
+		for _, name := range variables[id] {
+			names[name] = true
+		}
+	}
+	for _, name := range sortedKeys(names) {
+		e.Variables = append(e.Variables, b.explainVariable(name, fileInfo, sources, globalSources, languageGlobals[lang], lang))
+	}
+
+	_, e.Err = b.renderSinglePage(fileInfo, path)
+	return e, nil
+}
+
+// metadataSource describes where the step of loading a page that gave it key got it from
+func (b *Builder) metadataSource(step string, fileInfo *types.FileInfo, key string) string {
+	relDir := fileInfo.OutputRelPath
+	switch step {
+	case traceFrontmatter:
+		if key == "word_count" || key == "reading_time" {
+			return "counted from the page's source, unless its frontmatter sets it"
+		}
+		return "frontmatter"
+	case traceDefaults:
+		if source := b.defaults.Source(relDir, key); source != "" {
+			return source
+		}
+		return types.DefaultsFilename
+	case tracePageTemplates:
+		rel := filepath.ToSlash(filepath.Join(relDir, filepath.Base(fileInfo.InputPath)))
+		for _, rule := range b.pageTemplates {
+			if config.MatchPattern(rule.pattern, rel) {
+				return "page_templates " + rule.pattern
+			}
+		}
+		return "page_templates"
+	case tracePath:
+		if key == "lang" || key == "translation_key" {
+			return "the page's path and languages"
+		}
+		if key == "section" || key == "dir" || strings.HasPrefix(key, "section.") {
+			return "the page's folder"
+		}
+		return "its permalink"
+	}
+	return step
+}
+
+// explainTemplate returns the template a page gets, like the build picks it, and why
+func (b *Builder) explainTemplate(fileInfo *types.FileInfo, sources map[string]string) (string, string) {
+	for _, directive := range parser.ParseDirectives(fileInfo.Content) {
+		if directive.Type == parser.DirectiveSet && directive.Name == "template" {
+			return directive.Args[0], b.setDirectiveSource(fileInfo, "template")
+		}
+	}
+	if name, ok := fileInfo.Metadata["template"].(string); ok && name != "" {
+		return name, sources["template"]
+	}
+	if name := b.Site().Globals["template"]; name != "" {
+		return name, "template global"
+	}
+	return "", ""
+}
+
+// setDirectiveSource describes the set directive of a page that sets name, with its line
+func (b *Builder) setDirectiveSource(fileInfo *types.FileInfo, name string) string {
+	if line := b.definitionLine(b.inputRel(fileInfo.InputPath), name, parser.DirectiveSet); line > 0 {
+		return fmt.Sprintf("set directive on line %d", line)
+	}
+	return "set directive"
+}
+
+// explainUses lists what a page uses in the order it uses them, depth first, with what
+// each of those uses below it. Something used twice is listed again without its uses.
+func explainUses(g *graph.Graph, page *graph.Node) []ExplainedUse {
+	nodes := make(map[string]*graph.Node, len(g.Nodes))
+	for _, node := range g.Nodes {
+		nodes[node.ID] = node
+	}
+	uses := make(map[string][]string)
+	for _, edge := range g.Edges {
+		uses[edge.From] = append(uses[edge.From], edge.To)
+	}
+
+	var list []ExplainedUse
+	seen := map[string]bool{page.ID: true}
+	var walk func(id string, depth int)
+	walk = func(id string, depth int) {
+		for _, to := range uses[id] {
+			node := nodes[to]
+			list = append(list, ExplainedUse{Depth: depth, Kind: node.Kind, Name: node.Name, Path: node.Path, Missing: node.Missing, Seen: seen[to]})
+			if !seen[to] {
+				seen[to] = true
+				walk(to, depth+1)
+			}
+		}
+	}
+	walk(page.ID, 1)
+	return list
+}
+
+// useIDs returns the graph node IDs of uses
+func useIDs(uses []ExplainedUse) []string {
+	ids := make([]string, 0, len(uses))
+	for _, use := range uses {
+		ids = append(ids, graph.ID(use.Kind, use.Name))
+	}
+	return ids
+}
+
+// explainVariable finds the value a page's variable gets, in the order the build gives
+// them precedence: a set directive, the page's metadata, its language's globals, the
+// site's globals and then the variables the build provides, such as snippet_id
+func (b *Builder) explainVariable(name string, fileInfo *types.FileInfo, sources map[string]string, globalSources map[string]web.Definition, languageGlobals map[string]string, lang string) ExplainedVariable {
+	v := ExplainedVariable{Name: name}
+	for _, directive := range parser.ParseDirectives(fileInfo.Content) {
+		if directive.Type == parser.DirectiveSet && directive.Name == name {
+			v.Value, v.Source = directive.Args[0], b.setDirectiveSource(fileInfo, name)
+		}
+	}
+	if v.Source != "" {
+		return v
+	}
+
+	if value, ok := fileInfo.Metadata[name]; ok {
+		v.Value = fmt.Sprint(value)
+		v.Source = sources[name]
+		if _, isString := value.(string); !isString {
+			v.Source += ", not a string so {{" + name + "}} isn't expanded"
+		}
+		return v
+	}
+	if value, ok := languageGlobals[name]; ok {
+		v.Value = value
+		v.Source = filepath.ToSlash(filepath.Join(i18n.LocalesDir, lang+i18n.GlobalsSuffix))
+		return v
+	}
+	if value, ok := b.Site().Globals[name]; ok {
+		v.Value = value
+		switch definition, known := globalSources[name]; {
+		case known && definition.Line > 0:
+			v.Source = fmt.Sprintf("global in %s:%d", definition.Path, definition.Line)
+		case known:
+			v.Source = "global in " + definition.Path
+		case name == "env" || strings.HasPrefix(name, "env."):
+			v.Source = "the build environment"
+		default:
+			v.Source = "global"
+		}
+		return v
+	}
+
+	switch {
+	case name == "content":
+		v.Source = "the page's content, in its template"
+	case name == "uuid" || name == "random" || name == "counter":
+		v.Source = "helper"
+	case name == "snippet_id":
+		v.Source = "set for each paste of a snippet, e.g. name-1, name-2"
+	case strings.HasPrefix(name, "slot."):
+		v.Source = "set when the page fills the slot"
+	case name == "hreflang" || name == "filepath":
+		v.Source = "set by the build"
+	default:
+		v.Source = "undefined"
+	}
+	return v
+}
+
+// WriteText prints the explanation for people
+func (e *Explanation) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Page:     %s\n", e.Page)
+	fmt.Fprintf(w, "Output:   %s\n", e.URL)
+	if e.Draft {
+		fmt.Fprintf(w, "Draft:    yes, left out of builds without --drafts\n")
+	}
+	if e.Template == "" {
+		fmt.Fprintf(w, "Template: none\n")
+	} else {
+		fmt.Fprintf(w, "Template: %s (from %s)\n", e.Template, e.Reason)
+	}
+
+	fmt.Fprintf(w, "\nUses:\n")
+	if len(e.Uses) == 0 {
+		fmt.Fprintf(w, "  nothing\n")
+	}
+	for _, use := range e.Uses {
+		where := use.Path
+		switch {
+		case use.Missing:
+			where = "MISSING"
+		case use.Kind == graph.Include || use.Kind == graph.Data:
+			where = ""
+		}
+		line := fmt.Sprintf("%s%s %s", strings.Repeat("  ", use.Depth), use.Kind, use.Name)
+		if where != "" {
+			line += " (" + where + ")"
+		}
+		if use.Seen {
+			line += ", see above"
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	fmt.Fprintf(w, "\nVariables:\n")
+	if len(e.Variables) == 0 {
+		fmt.Fprintf(w, "  none\n")
+	}
+	width := 0
+	for _, v := range e.Variables {
+		if len(v.Name) > width {
+			width = len(v.Name)
+		}
+	}
+	for _, v := range e.Variables {
+		value := v.Value
+		if runes := []rune(value); len(runes) > 50 {
+			value = string(runes[:47]) + "..."
+		}
+		if value != "" {
+			value = fmt.Sprintf("%q ", strings.ReplaceAll(value, "\n", " "))
+		}
+		fmt.Fprintf(w, "  %-*s %s(%s)\n", width, v.Name, value, v.Source)
+	}
+
+	if e.Err != nil {
+		fmt.Fprintf(w, "\nRendering failed: %v\n", e.Err)
+	}
+}
@@ -0,0 +1,58 @@
+package builder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"sniplicity/internal/types"
+)
+
+// gitCommitMarker starts each commit in the git log loadGitHistory reads, as its
+// --format writes it
+const gitCommitMarker = "\x00commit\x00"
+
+// loadGitHistory reads the last commit of every file in the input directory from git,
+// for git_info. Files git doesn't track are left out.
+func (b *Builder) loadGitHistory() (types.GitHistory, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git_info needs git, which was not found on the PATH")
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", "-c", "core.quotePath=false", "log", "--no-renames", "--relative", "--name-only",
+		"--format=%x00commit%x00%aI%x00%an", "--", ".")
+	cmd.Dir = b.config.GetAbsoluteInputDir()
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if message := strings.TrimSpace(stderr.String()); message != "" && errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("reading git history: %s", message)
+		}
+		return nil, fmt.Errorf("reading git history: %w", err)
+	}
+
+	// The log is newest first, so the first commit a file appears in is its last one
+	history := make(types.GitHistory)
+	var commit types.GitCommit
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if header, ok := strings.CutPrefix(line, gitCommitMarker); ok {
+			date, author, _ := strings.Cut(header, "\x00")
+			t, err := time.Parse(time.RFC3339, date)
+			if err != nil {
+				return nil, fmt.Errorf("reading git history: commit date %q: %w", date, err)
+			}
+			commit = types.GitCommit{Date: t, Author: author}
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		if _, seen := history[line]; !seen {
+			history[line] = commit
+		}
+	}
+	return history, nil
+}
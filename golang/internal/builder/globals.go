@@ -0,0 +1,88 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"sniplicity/internal/config"
+	"sniplicity/internal/logging"
+
+	"github.com/kirsle/configdir"
+	"gopkg.in/yaml.v3"
+)
+
+// userGlobalsFile returns the globals file shared by every project of the current user
+func userGlobalsFile() string {
+	return filepath.Join(configdir.LocalConfig("sniplicity"), "globals.yaml")
+}
+
+// sharedGlobalsFiles returns the shared globals files in the order they are merged: the
+// user's own, then the project's globals_file
+func (b *Builder) sharedGlobalsFiles() []string {
+	files := []string{userGlobalsFile()}
+	if path := b.config.GetAbsoluteGlobalsFile(); path != "" {
+		files = append(files, path)
+	}
+	return files
+}
+
+// loadSharedGlobals adds to site the globals from the user's globals.yaml and the project's
+// globals_file, so values like a legal footer can be defined once for many projects, then
+// the globals of sniplicity.yaml, which may differ per environment. Globals defined in the
+// sources override them. The user's file is optional, but a globals_file that can't be
+// read fails the build.
+func (b *Builder) loadSharedGlobals(site map[string]string) error {
+	user := userGlobalsFile()
+	globals, err := readGlobals(user)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		b.diagnostics.Warn(user, 0, "cannot read shared globals: %v", err)
+	default:
+		b.mergeGlobals(site, user, globals)
+	}
+
+	if path := b.config.GetAbsoluteGlobalsFile(); path != "" {
+		globals, err = readGlobals(path)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("globals_file %s does not exist", path)
+		}
+		if err != nil {
+			return fmt.Errorf("reading globals_file %s: %w", path, err)
+		}
+		b.mergeGlobals(site, path, globals)
+	}
+
+	// Their environment variables were expanded when sniplicity.yaml was read
+	for name, value := range b.config.Globals {
+		site[name] = value
+	}
+	return nil
+}
+
+// mergeGlobals adds globals read from path to site, replacing any with the same name,
+// with the environment variables in their values expanded
+func (b *Builder) mergeGlobals(site map[string]string, path string, globals map[string]string) {
+	logging.Debugf("  Loaded %d shared %s from %s", len(globals), plural(len(globals), "global", "globals"), path)
+	for name, value := range globals {
+		value, unset := config.ExpandEnvVars(value)
+		for _, variable := range unset {
+			b.diagnostics.Warn(path, 0, "global %s uses ${%s}, which is not set", name, variable)
+		}
+		site[name] = value
+	}
+}
+
+// readGlobals reads a YAML file of name: value pairs
+func readGlobals(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var globals map[string]string
+	if err := yaml.Unmarshal(data, &globals); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	return globals, nil
+}
@@ -0,0 +1,225 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sniplicity/internal/graph"
+	"sniplicity/internal/parser"
+	"sniplicity/internal/processor"
+	"sniplicity/internal/types"
+)
+
+// DependencyGraph returns which templates, snippets, included files and declared
+// dependencies every page uses, and what those use in turn, without building anything
+func (b *Builder) DependencyGraph() (*graph.Graph, error) {
+	inputDir := b.config.GetAbsoluteInputDir()
+	b.diagnostics.Reset()
+
+	fileList, err := b.getFileList(inputDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get file list: %w", err)
+	}
+	if err := b.collectDefinitions(fileList); err != nil {
+		return nil, err
+	}
+	g := b.graphOf(b.loadRawFiles(fileList))
+	g.Sort()
+	return g, nil
+}
+
+// graphOf builds the dependency graph of files from the snippets and templates collected
+// from them
+func (b *Builder) graphOf(files []*types.FileInfo) *graph.Graph {
+	g := b.definitionGraph(files)
+	for _, fileInfo := range files {
+		if !b.config.Drafts && types.IsDraft(fileInfo.Metadata) {
+			continue
+		}
+		b.addPage(g, fileInfo)
+	}
+	return g
+}
+
+// definitionGraph returns a graph of the snippets and templates defined in files and the
+// snippets they paste, without any pages
+func (b *Builder) definitionGraph(files []*types.FileInfo) *graph.Graph {
+	g := graph.New()
+	for _, fileInfo := range files {
+		b.addDefinitions(g, fileInfo)
+	}
+	site := b.Site()
+	for name, lines := range site.Snippets {
+		b.addPastes(g, b.snippetNode(g, name), lines)
+	}
+	for name, lines := range site.Templates {
+		b.addPastes(g, b.templateNode(g, name), lines)
+	}
+	return g
+}
+
+// addPage adds a page with its template and the snippets and files it uses
+func (b *Builder) addPage(g *graph.Graph, fileInfo *types.FileInfo) *graph.Node {
+	page := g.Node(graph.Page, b.inputRel(fileInfo.InputPath))
+	page.Path = page.Name
+	vars := pageVariables(fileInfo, b.Site().Globals)
+	if name := vars["template"]; name != "" {
+		g.Depend(page, b.templateNode(g, name))
+	}
+	for _, dependency := range declaredDependencies(fileInfo, vars) {
+		fullPath := processor.ResolveIncludePath(dependency, filepath.Dir(fileInfo.InputPath), b.config.GetAbsoluteInputDir())
+		node := g.Node(graph.Data, b.inputRel(fullPath))
+		if _, err := os.Stat(fullPath); err != nil {
+			node.Missing = true
+		} else {
+			node.Path = node.Name
+		}
+		g.Depend(page, node)
+	}
+	b.addUses(g, page, fileInfo.Content, filepath.Dir(fileInfo.InputPath), vars, []string{fileInfo.InputPath})
+	return page
+}
+
+// addDefinitions records which file each snippet and template is defined in. Like the
+// build, a later definition replaces an earlier one.
+func (b *Builder) addDefinitions(g *graph.Graph, fileInfo *types.FileInfo) {
+	for i, line := range fileInfo.Content {
+		directive := parser.ParseLine(line, i)
+		if directive == nil {
+			continue
+		}
+		switch directive.Type {
+		case parser.DirectiveCopy, parser.DirectiveCut:
+			b.snippetNode(g, directive.Name).Path = b.inputRel(fileInfo.InputPath)
+		case parser.DirectiveTemplate:
+			b.templateNode(g, directive.Name).Path = b.inputRel(fileInfo.InputPath)
+		}
+	}
+}
+
+// addUses records the snippets, templates and includes used by lines, following includes
+// into the files they name. Template and cut blocks are left out, as their content
+// belongs to the template or snippet they define rather than the page.
+func (b *Builder) addUses(g *graph.Graph, from *graph.Node, lines []string, baseDir string, vars map[string]string, stack []string) {
+	var blocks []bool // Whether each open block is left out
+	skipping := 0     // How many of them are
+	for i, line := range lines {
+		if len(blocks) > 0 && parser.IsBlockEnd(line) {
+			if blocks[len(blocks)-1] {
+				skipping--
+			}
+			blocks = blocks[:len(blocks)-1]
+			continue
+		}
+		directive := parser.ParseLine(line, i)
+		if directive == nil {
+			continue
+		}
+		switch directive.Type {
+		case parser.DirectiveCopy:
+			blocks = append(blocks, false)
+			continue
+		case parser.DirectiveCut, parser.DirectiveTemplate:
+			blocks = append(blocks, true)
+			skipping++
+			continue
+		}
+		if skipping > 0 {
+			continue
+		}
+
+		switch directive.Type {
+		case parser.DirectivePaste:
+			g.Depend(from, b.snippetNode(g, directive.Name))
+		case parser.DirectiveIndex:
+			if len(directive.Args) > 1 {
+				g.Depend(from, b.templateNode(g, directive.Args[1]))
+			}
+		case parser.DirectiveSitemap:
+			if len(directive.Args) > 0 {
+				g.Depend(from, b.templateNode(g, directive.Args[0]))
+			}
+		case parser.DirectiveInclude:
+			fullPath := processor.ResolveIncludePath(parser.ExpandVariables(directive.Args[0], vars), baseDir, b.config.GetAbsoluteInputDir())
+			include := g.Node(graph.Include, b.inputRel(fullPath))
+			g.Depend(from, include)
+			if containsPath(stack, fullPath) {
+				continue // Circular, which the build reports
+			}
+			data, err := os.ReadFile(fullPath)
+			if err != nil {
+				include.Missing = true
+				continue
+			}
+			include.Path = include.Name
+			b.addUses(g, include, strings.Split(string(data), "\n"), filepath.Dir(fullPath), vars, append(stack, fullPath))
+		}
+	}
+}
+
+// addPastes records the snippets pasted in a snippet or template
+func (b *Builder) addPastes(g *graph.Graph, from *graph.Node, lines []string) {
+	for i, line := range lines {
+		if directive := parser.ParseLine(line, i); directive != nil && directive.Type == parser.DirectivePaste {
+			g.Depend(from, b.snippetNode(g, directive.Name))
+		}
+	}
+}
+
+// snippetNode returns the node of a snippet, marked missing when no file defines it
+func (b *Builder) snippetNode(g *graph.Graph, name string) *graph.Node {
+	node := g.Node(graph.Snippet, name)
+	_, defined := b.Site().Snippets[name]
+	node.Missing = !defined
+	return node
+}
+
+// templateNode returns the node of a template, marked missing when no file defines it
+func (b *Builder) templateNode(g *graph.Graph, name string) *graph.Node {
+	node := g.Node(graph.Template, name)
+	_, defined := b.Site().Templates[name]
+	node.Missing = !defined
+	return node
+}
+
+// inputRel returns path relative to the input directory with forward slashes, or as it
+// is when it lies outside
+func (b *Builder) inputRel(path string) string {
+	rel, err := filepath.Rel(b.config.GetAbsoluteInputDir(), path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// pageVariables returns the variables a page's include paths and template name are
+// resolved with: globals, then frontmatter, then set directives, as in the build
+func pageVariables(fileInfo *types.FileInfo, globals map[string]string) map[string]string {
+	vars := make(map[string]string)
+	for k, v := range globals {
+		vars[k] = v
+	}
+	for k, v := range fileInfo.Metadata {
+		if str, ok := v.(string); ok {
+			vars[k] = str
+		}
+	}
+	for _, directive := range parser.ParseDirectives(fileInfo.Content) {
+		if directive.Type == parser.DirectiveSet {
+			vars[directive.Name] = directive.Args[0]
+		}
+	}
+	return vars
+}
+
+// containsPath reports whether path is in paths
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
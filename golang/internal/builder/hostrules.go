@@ -0,0 +1,312 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sniplicity/internal/config"
+	"sniplicity/internal/logging"
+	"sniplicity/internal/parser"
+	"sniplicity/internal/web"
+)
+
+// Files of redirect and header rules the build writes for each deploy format. Hand-written
+// ones at the top of the input directory are kept, ahead of the generated rules.
+const (
+	headersFilename  = "_headers"
+	vercelFilename   = "vercel.json"
+	htaccessFilename = ".htaccess"
+)
+
+// redirectRule is a redirect for a host's rules file
+type redirectRule struct {
+	from   string // URL path, ending in * to match everything below it
+	to     string // URL to redirect to, where :splat stands for what * matched
+	status string
+}
+
+// headerRule is the response headers sent for the URLs matching a pattern
+type headerRule struct {
+	pattern string      // URL path, ending in * to match everything below it
+	headers [][2]string // Names and values, in the order they were written
+}
+
+// parseRedirectRules reads redirect_rules, more specific rules first so they win on hosts
+// that use the first rule that matches
+func parseRedirectRules(rules map[string]string) ([]redirectRule, error) {
+	var parsed []redirectRule
+	for _, from := range patternOrder(rules) {
+		fields := strings.Fields(rules[from])
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("redirect_rules %s must be a URL and an optional status, e.g. /new/ 301", from)
+		}
+		rule := redirectRule{from: "/" + strings.TrimPrefix(from, "/"), to: fields[0], status: "301"}
+		if len(fields) == 2 {
+			rule.status = fields[1]
+		}
+		if !parser.IsRedirectStatus(rule.status) {
+			return nil, fmt.Errorf("redirect_rules %s has status %s, use 301, 302, 303, 307 or 308", from, rule.status)
+		}
+		if strings.Contains(strings.TrimSuffix(rule.from, "*"), "*") {
+			return nil, fmt.Errorf("redirect_rules %s can only have * at the end", from)
+		}
+		parsed = append(parsed, rule)
+	}
+	return parsed, nil
+}
+
+// parseHeaderRules reads headers, more specific patterns first
+func parseHeaderRules(headers map[string]string) ([]headerRule, error) {
+	var parsed []headerRule
+	for _, pattern := range patternOrder(headers) {
+		rule := headerRule{pattern: "/" + strings.TrimPrefix(pattern, "/")}
+		if strings.Contains(strings.TrimSuffix(rule.pattern, "*"), "*") {
+			return nil, fmt.Errorf("headers %s can only have * at the end", pattern)
+		}
+		for _, line := range strings.Split(headers[pattern], "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			name, value, ok := strings.Cut(line, ":")
+			name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+			if !ok || name == "" || strings.ContainsAny(name, " \t") {
+				return nil, fmt.Errorf("headers %s must be Name: value lines, not %q", pattern, strings.TrimSpace(line))
+			}
+			rule.headers = append(rule.headers, [2]string{name, value})
+		}
+		parsed = append(parsed, rule)
+	}
+	return parsed, nil
+}
+
+// patternOrder returns the URL patterns of a rules map with exact URLs first, then the
+// longest patterns, so a more specific rule comes before one it overlaps
+func patternOrder(rules map[string]string) []string {
+	patterns := make([]string, 0, len(rules))
+	for pattern := range rules {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		a, b := patterns[i], patterns[j]
+		if aWild, bWild := strings.HasSuffix(a, "*"), strings.HasSuffix(b, "*"); aWild != bWild {
+			return bWild
+		}
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return a < b
+	})
+	return patterns
+}
+
+// hostRulesFiles returns the files the deploy format's rules are written to, relative to
+// the output directory. The hand-written versions aren't copied as assets, as writing the
+// rules adds them.
+func (b *Builder) hostRulesFiles() []string {
+	switch b.config.RulesFormat() {
+	case config.FormatNetlify:
+		return []string{redirectsFilename, headersFilename}
+	case config.FormatVercel:
+		return []string{vercelFilename}
+	case config.FormatApache:
+		return []string{htaccessFilename}
+	}
+	return nil
+}
+
+// writeHostRules writes the redirects and the headers of sniplicity.yaml in the deploy
+// format, after any hand-written rules
+func (b *Builder) writeHostRules(redirects []redirectRule) error {
+	switch b.config.RulesFormat() {
+	case config.FormatNetlify:
+		if err := b.writeRulesFile(redirectsFilename, netlifyRedirects(redirects)); err != nil {
+			return err
+		}
+		return b.writeRulesFile(headersFilename, netlifyHeaders(b.headerRules))
+	case config.FormatVercel:
+		return b.writeVercelConfig(redirects)
+	case config.FormatApache:
+		rules := apacheRules(redirects, b.headerRules)
+		if !b.handWritten(htaccessFilename, "ErrorDocument 404") {
+			rules = append([]string{"ErrorDocument 404 /" + web.NotFoundPage}, rules...)
+		}
+		return b.writeRulesFile(htaccessFilename, rules)
+	}
+
+	if len(b.headerRules) > 0 {
+		b.diagnostics.Warn(b.configFile(), yamlKeyLine(b.configFile(), "headers"), "headers need deploy_format to say which host to write them for")
+	}
+	return nil
+}
+
+// writeRulesFile writes a rules file of the output directory: the hand-written one from
+// the input directory followed by the generated rules, or nothing when both are empty
+func (b *Builder) writeRulesFile(name string, rules []string) error {
+	content := ""
+	if data, err := os.ReadFile(filepath.Join(b.config.GetAbsoluteInputDir(), name)); err == nil {
+		content = strings.TrimRight(string(data), "\n") + "\n"
+	}
+	if len(rules) > 0 {
+		content += strings.Join(rules, "\n") + "\n"
+	}
+	if strings.TrimSpace(content) == "" {
+		return nil
+	}
+
+	rulesPath := filepath.Join(b.config.GetAbsoluteOutputDir(), name)
+	if err := b.writeFile(rulesPath, []byte(content)); err != nil {
+		return fmt.Errorf("cannot write %s: %w", rulesPath, err)
+	}
+	b.recordOutput("", rulesPath)
+	logging.Debugf("  Wrote %d rules to %s", len(rules), rulesPath)
+	return nil
+}
+
+// handWritten reports whether the hand-written version of a rules file in the input
+// directory has a line starting with prefix
+func (b *Builder) handWritten(name, prefix string) bool {
+	data, err := os.ReadFile(filepath.Join(b.config.GetAbsoluteInputDir(), name))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// netlifyRedirects returns redirects as lines of a Netlify _redirects file
+func netlifyRedirects(redirects []redirectRule) []string {
+	lines := make([]string, 0, len(redirects))
+	for _, rule := range redirects {
+		lines = append(lines, fmt.Sprintf("%s  %s  %s", rule.from, rule.to, rule.status))
+	}
+	return lines
+}
+
+// netlifyHeaders returns headers as lines of a Netlify _headers file
+func netlifyHeaders(headers []headerRule) []string {
+	var lines []string
+	for _, rule := range headers {
+		lines = append(lines, rule.pattern)
+		for _, header := range rule.headers {
+			lines = append(lines, fmt.Sprintf("  %s: %s", header[0], header[1]))
+		}
+	}
+	return lines
+}
+
+// writeVercelConfig writes vercel.json with the redirects and headers added to the
+// hand-written vercel.json, if there is one
+func (b *Builder) writeVercelConfig(redirects []redirectRule) error {
+	vercel := make(map[string]interface{})
+	source := filepath.Join(b.config.GetAbsoluteInputDir(), vercelFilename)
+	if data, err := os.ReadFile(source); err == nil {
+		if err := json.Unmarshal(data, &vercel); err != nil {
+			return fmt.Errorf("reading %s: %w", source, err)
+		}
+	}
+	if len(vercel) == 0 && len(redirects) == 0 && len(b.headerRules) == 0 {
+		return nil
+	}
+
+	if len(redirects) > 0 {
+		existing, _ := vercel["redirects"].([]interface{})
+		for _, rule := range redirects {
+			existing = append(existing, map[string]interface{}{
+				"source":      vercelPattern(rule.from),
+				"destination": strings.ReplaceAll(rule.to, ":splat", ":splat*"),
+				"statusCode":  redirectStatusCode(rule.status),
+			})
+		}
+		vercel["redirects"] = existing
+	}
+	if len(b.headerRules) > 0 {
+		existing, _ := vercel["headers"].([]interface{})
+		for _, rule := range b.headerRules {
+			headers := make([]map[string]string, 0, len(rule.headers))
+			for _, header := range rule.headers {
+				headers = append(headers, map[string]string{"key": header[0], "value": header[1]})
+			}
+			existing = append(existing, map[string]interface{}{"source": vercelPattern(rule.pattern), "headers": headers})
+		}
+		vercel["headers"] = existing
+	}
+
+	data, err := json.MarshalIndent(vercel, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", vercelFilename, err)
+	}
+	vercelPath := filepath.Join(b.config.GetAbsoluteOutputDir(), vercelFilename)
+	if err := b.writeFile(vercelPath, append(data, '\n')); err != nil {
+		return fmt.Errorf("cannot write %s: %w", vercelPath, err)
+	}
+	b.recordOutput("", vercelPath)
+	logging.Debugf("  Wrote %d redirects and %d header rules to %s", len(redirects), len(b.headerRules), vercelPath)
+	return nil
+}
+
+// vercelPattern turns a URL pattern into a Vercel source, where a trailing * becomes
+// the :splat* parameter
+func vercelPattern(pattern string) string {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return prefix + ":splat*"
+	}
+	return pattern
+}
+
+// redirectStatusCode returns a redirect status as a number
+func redirectStatusCode(status string) int {
+	var code int
+	fmt.Sscanf(status, "%d", &code)
+	return code
+}
+
+// apacheRules returns redirects and headers as lines of an Apache .htaccess file, for
+// mod_alias and mod_headers
+func apacheRules(redirects []redirectRule, headers []headerRule) []string {
+	var lines []string
+	for _, rule := range redirects {
+		lines = append(lines, fmt.Sprintf("RedirectMatch %s %s %s", rule.status, apacheQuote(apachePattern(rule.from)), apacheQuote(strings.ReplaceAll(rule.to, ":splat", "$1"))))
+	}
+	if len(headers) == 0 {
+		return lines
+	}
+
+	lines = append(lines, "<IfModule mod_headers.c>")
+	for _, rule := range headers {
+		indent := "  "
+		if rule.pattern != "/*" {
+			lines = append(lines, fmt.Sprintf("  <If %s>", apacheQuote("%{REQUEST_URI} =~ m#"+apachePattern(rule.pattern)+"#")))
+			indent = "    "
+		}
+		for _, header := range rule.headers {
+			lines = append(lines, fmt.Sprintf("%sHeader set %s %s", indent, header[0], apacheQuote(header[1])))
+		}
+		if rule.pattern != "/*" {
+			lines = append(lines, "  </If>")
+		}
+	}
+	return append(lines, "</IfModule>")
+}
+
+// apachePattern turns a URL pattern into an anchored regular expression, where a trailing
+// * captures the rest of the URL
+func apachePattern(pattern string) string {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return "^" + regexp.QuoteMeta(prefix) + "(.*)$"
+	}
+	return "^" + regexp.QuoteMeta(pattern) + "$"
+}
+
+// apacheQuote quotes an argument of an Apache directive, where only quotes are escaped
+func apacheQuote(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
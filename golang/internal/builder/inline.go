@@ -0,0 +1,162 @@
+package builder
+
+import (
+	"encoding/base64"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sniplicity/internal/logging"
+	"sniplicity/internal/processor"
+
+	"github.com/fatih/color"
+)
+
+// inlineTypes are the MIME types of the images and fonts that may be inlined, by extension
+var inlineTypes = map[string]string{
+	".avif":  "image/avif",
+	".gif":   "image/gif",
+	".ico":   "image/x-icon",
+	".jpeg":  "image/jpeg",
+	".jpg":   "image/jpeg",
+	".png":   "image/png",
+	".svg":   "image/svg+xml",
+	".webp":  "image/webp",
+	".otf":   "font/otf",
+	".ttf":   "font/ttf",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+}
+
+// inlineSrcRegex matches src attributes with quoted values
+var inlineSrcRegex = regexp.MustCompile(`(?i)(\bsrc\s*=\s*)(?:"([^"]*)"|'([^']*)')`)
+
+// inlineSchemeRegex matches URLs with a scheme such as https: or data:
+var inlineSchemeRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+// inlineURLRegex matches CSS url() references, quoted or not, in stylesheets and style attributes
+var inlineURLRegex = regexp.MustCompile(`(?i)(\burl\(\s*)(?:"([^"]*)"|'([^']*)'|([^'")\s]*))(\s*\))`)
+
+// inlinedAsset is an asset that was inlined, for the report at the end of the build
+type inlinedAsset struct {
+	size  int
+	count int
+}
+
+// inlineAssets replaces src attributes and url() references in an HTML or CSS output file
+// with data URIs when they point at an image or font no bigger than inline_assets bytes.
+// References are resolved against the output file's location and read from the sources,
+// as pages are written before assets are copied.
+func (b *Builder) inlineAssets(outputPath string, data []byte) []byte {
+	ext := strings.ToLower(filepath.Ext(outputPath))
+	if ext != ".html" && ext != ".htm" && ext != ".css" {
+		return data
+	}
+
+	fromDir := path.Dir(b.outputRel(outputPath))
+	content := string(data)
+	if ext != ".css" {
+		content = inlineSrcRegex.ReplaceAllStringFunc(content, func(match string) string {
+			groups := inlineSrcRegex.FindStringSubmatch(match)
+			if dataURI, ok := b.dataURI(fromDir, groups[2]+groups[3]); ok {
+				return groups[1] + `"` + dataURI + `"`
+			}
+			return match
+		})
+	}
+	content = inlineURLRegex.ReplaceAllStringFunc(content, func(match string) string {
+		groups := inlineURLRegex.FindStringSubmatch(match)
+		if dataURI, ok := b.dataURI(fromDir, groups[2]+groups[3]+groups[4]); ok {
+			return groups[1] + `"` + dataURI + `"` + groups[5]
+		}
+		return match
+	})
+	return []byte(content)
+}
+
+// dataURI returns the data URI for a reference from a file in fromDir, or false when it
+// isn't a local image or font small enough to inline
+func (b *Builder) dataURI(fromDir, ref string) (string, bool) {
+	if ref == "" || strings.HasPrefix(ref, "//") || strings.ContainsAny(ref, "?#") || inlineSchemeRegex.MatchString(ref) {
+		return "", false // External, already inlined, or a fragment such as an SVG sprite
+	}
+	mimeType, ok := inlineTypes[strings.ToLower(path.Ext(ref))]
+	if !ok {
+		return "", false
+	}
+	unescaped, err := url.PathUnescape(ref)
+	if err != nil {
+		return "", false
+	}
+
+	rel := path.Clean(path.Join(fromDir, unescaped))
+	if strings.HasPrefix(unescaped, "/") {
+		rel = path.Clean(strings.TrimPrefix(unescaped, "/"))
+	}
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+
+	source := filepath.Join(b.config.GetAbsoluteInputDir(), filepath.FromSlash(rel))
+	info, err := os.Stat(source)
+	if err != nil || info.IsDir() || info.Size() > int64(b.config.InlineAssets) {
+		return "", false
+	}
+	content, err := os.ReadFile(source)
+	if err != nil {
+		return "", false
+	}
+	if mimeType == "image/svg+xml" && b.config.SvgFilter {
+		// Inline the SVG as it is copied, with its CSS filters applied
+		processed, err := processor.ProcessSVGFilters(string(content))
+		if err != nil || len(processed) > b.config.InlineAssets {
+			return "", false
+		}
+		content = []byte(processed)
+	}
+
+	b.inlinedMu.Lock()
+	asset := b.inlined[rel]
+	asset.size = len(content)
+	asset.count++
+	b.inlined[rel] = asset
+	b.inlinedMu.Unlock()
+
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(content), true
+}
+
+// reportInlined lists each asset that was inlined with its size and how many times
+func (b *Builder) reportInlined() {
+	b.inlinedMu.Lock()
+	defer b.inlinedMu.Unlock()
+	if len(b.inlined) == 0 {
+		logging.Debugf("  No assets small enough to inline")
+		return
+	}
+
+	paths := make([]string, 0, len(b.inlined))
+	for rel := range b.inlined {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	references := 0
+	for _, rel := range paths {
+		asset := b.inlined[rel]
+		references += asset.count
+		logging.Infof("  Inlined %s (%d bytes) in %d %s", color.CyanString(rel), asset.size, asset.count, plural(asset.count, "place", "places"))
+	}
+	logging.Infof("Inlined %d %s as data URIs in %d %s", len(paths), plural(len(paths), "asset", "assets"), references, plural(references, "place", "places"))
+}
+
+// plural returns singular when n is 1 and plural otherwise
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
@@ -0,0 +1,34 @@
+package builder
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"sniplicity/internal/integrity"
+	"sniplicity/internal/logging"
+)
+
+// integrityFile returns where the integrity manifest is written
+func (b *Builder) integrityFile() string {
+	return filepath.Join(b.config.GetAbsoluteOutputDir(), integrity.FileName)
+}
+
+// writeIntegrity records the checksum and size of every file in the output directory,
+// so a deployed copy can be checked against this build with sniplicity verify
+func (b *Builder) writeIntegrity() error {
+	manifest, err := integrity.Build(b.config.GetAbsoluteOutputDir())
+	if err != nil {
+		return fmt.Errorf("cannot write %s: %w", integrity.FileName, err)
+	}
+	data, err := manifest.Encode()
+	if err != nil {
+		return err
+	}
+	path := b.integrityFile()
+	if err := b.writeFile(path, data); err != nil {
+		return fmt.Errorf("cannot write %s: %w", path, err)
+	}
+	b.recordOutput("", path)
+	logging.Debugf("  Wrote checksums of %d files to %s", len(manifest.Files), path)
+	return nil
+}
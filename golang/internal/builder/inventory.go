@@ -0,0 +1,239 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sniplicity/internal/diag"
+	"sniplicity/internal/graph"
+	"sniplicity/internal/parser"
+	"sniplicity/internal/types"
+	"sniplicity/internal/web"
+)
+
+// Inventory lists the snippets, templates and globals of the last build, where each is
+// defined and which pages use it, along with snippets and templates pages use that
+// nothing defines. It waits for a running build to finish.
+func (b *Builder) Inventory() (web.Inventory, error) {
+	b.buildMu.Lock()
+	defer b.buildMu.Unlock()
+	if b.defaults == nil {
+		return web.Inventory{}, fmt.Errorf("the site hasn't been built yet")
+	}
+
+	// The build has reported any problems with the files already
+	diagnostics := b.diagnostics
+	b.diagnostics = diag.NewCollector()
+	defer func() { b.diagnostics = diagnostics }()
+
+	fileList, err := b.getFileList(b.config.GetAbsoluteInputDir())
+	if err != nil {
+		return web.Inventory{}, fmt.Errorf("cannot get file list: %w", err)
+	}
+	files := b.loadRawFiles(fileList)
+	g := b.graphOf(files)
+	g.Sort()
+	users := pageUsers(g)
+
+	inventory := web.Inventory{Snippets: []web.Definition{}, Templates: []web.Definition{}}
+	for _, node := range g.Nodes {
+		definition := web.Definition{Name: node.Name, Path: node.Path, Missing: node.Missing, UsedBy: users[node.ID]}
+		switch node.Kind {
+		case graph.Snippet:
+			definition.Line = b.definitionLine(definition.Path, node.Name, parser.DirectiveCopy, parser.DirectiveCut)
+			inventory.Snippets = append(inventory.Snippets, definition)
+		case graph.Template:
+			definition.Line = b.definitionLine(definition.Path, node.Name, parser.DirectiveTemplate)
+			inventory.Templates = append(inventory.Templates, definition)
+		}
+	}
+	inventory.Globals = b.globalDefinitions(files, g, users)
+	return inventory, nil
+}
+
+// globalDefinitions lists the globals with the file that last set them and the pages
+// using them, directly or through what the pages use
+func (b *Builder) globalDefinitions(files []*types.FileInfo, g *graph.Graph, users map[string][]string) []web.Definition {
+	sources := b.globalSources(files)
+
+	// Pages using each variable, through every node whose content refers to it
+	variableUsers := make(map[string]map[string]bool)
+	for id, names := range b.nodeVariables(files, g) {
+		for _, name := range names {
+			if variableUsers[name] == nil {
+				variableUsers[name] = make(map[string]bool)
+			}
+			for _, page := range users[id] {
+				variableUsers[name][page] = true
+			}
+		}
+	}
+
+	site := b.Site()
+	globals := make([]web.Definition, 0, len(site.Globals))
+	for name, value := range site.Globals {
+		definition := sources[name]
+		definition.Name, definition.Value = name, value
+		definition.UsedBy = sortedKeys(variableUsers[name])
+		globals = append(globals, definition)
+	}
+	sort.Slice(globals, func(i, j int) bool { return globals[i].Name < globals[j].Name })
+	return globals
+}
+
+// globalSources returns the file, and line, that last set each global: a shared globals
+// file, sniplicity.yaml or a global directive in one of files
+func (b *Builder) globalSources(files []*types.FileInfo) map[string]web.Definition {
+	sources := make(map[string]web.Definition)
+	for _, path := range b.sharedGlobalsFiles() {
+		globals, err := readGlobals(path)
+		if err != nil {
+			continue
+		}
+		for name := range globals {
+			sources[name] = web.Definition{Path: b.inputRel(path), Line: yamlKeyLine(path, name)}
+		}
+	}
+	if path := b.configFile(); path != "" {
+		for name := range b.config.Globals {
+			sources[name] = web.Definition{Path: b.inputRel(path)}
+		}
+	}
+	for _, fileInfo := range files {
+		for i, line := range fileInfo.Content {
+			if directive := parser.ParseLine(line, i); directive != nil && directive.Type == parser.DirectiveGlobal {
+				path := b.inputRel(fileInfo.InputPath)
+				sources[directive.Name] = web.Definition{Path: path, Line: b.definitionLine(path, directive.Name, parser.DirectiveGlobal)}
+			}
+		}
+	}
+	return sources
+}
+
+// nodeVariables returns the variables the content of each page, snippet, template and
+// included file refers to, by node ID
+func (b *Builder) nodeVariables(files []*types.FileInfo, g *graph.Graph) map[string][]string {
+	variables := make(map[string][]string)
+	for _, fileInfo := range files {
+		variables[graph.ID(graph.Page, b.inputRel(fileInfo.InputPath))] = parser.VariableNames(strings.Join(fileInfo.Content, "\n"))
+	}
+	site := b.Site()
+	for name, lines := range site.Snippets {
+		variables[graph.ID(graph.Snippet, name)] = parser.VariableNames(strings.Join(lines, "\n"))
+	}
+	for name, lines := range site.Templates {
+		variables[graph.ID(graph.Template, name)] = parser.VariableNames(strings.Join(lines, "\n"))
+	}
+	for _, node := range g.Nodes {
+		if node.Kind != graph.Include || node.Missing {
+			continue
+		}
+		if data, err := os.ReadFile(b.inputPath(node.Path)); err == nil {
+			variables[node.ID] = parser.VariableNames(string(data))
+		}
+	}
+	return variables
+}
+
+// pageUsers returns the pages that use each node, directly or through other nodes, by
+// node ID. A page counts as using itself.
+func pageUsers(g *graph.Graph) map[string][]string {
+	usedBy := make(map[string][]string)
+	for _, edge := range g.Edges {
+		usedBy[edge.To] = append(usedBy[edge.To], edge.From)
+	}
+	nodes := make(map[string]*graph.Node, len(g.Nodes))
+	for _, node := range g.Nodes {
+		nodes[node.ID] = node
+	}
+
+	users := make(map[string][]string, len(g.Nodes))
+	for _, node := range g.Nodes {
+		pages := make(map[string]bool)
+		seen := map[string]bool{node.ID: true}
+		queue := []string{node.ID}
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			if nodes[id].Kind == graph.Page {
+				pages[nodes[id].Name] = true
+			}
+			for _, from := range usedBy[id] {
+				if !seen[from] {
+					seen[from] = true
+					queue = append(queue, from)
+				}
+			}
+		}
+		users[node.ID] = sortedKeys(pages)
+	}
+	return users
+}
+
+// definitionLine returns the 1-based line of the last directive of one of the given types
+// defining name in the file at path, relative to the input directory, or 0. Like the
+// build, a later definition replaces an earlier one.
+func (b *Builder) definitionLine(path, name string, kinds ...parser.DirectiveType) int {
+	if path == "" {
+		return 0
+	}
+	data, err := os.ReadFile(b.inputPath(path))
+	if err != nil {
+		return 0
+	}
+	lines := strings.Split(string(data), "\n")
+	if types.IsMarkdownFile(path) {
+		lines = parser.HideCodeFences(lines)
+	}
+	found := 0
+	for i, line := range lines {
+		directive := parser.ParseLine(line, i)
+		if directive == nil || directive.Name != name {
+			continue
+		}
+		for _, kind := range kinds {
+			if directive.Type == kind {
+				found = i + 1
+			}
+		}
+	}
+	return found
+}
+
+// inputPath turns a path from inputRel back into an absolute one
+func (b *Builder) inputPath(rel string) string {
+	if filepath.IsAbs(rel) {
+		return rel
+	}
+	return filepath.Join(b.config.GetAbsoluteInputDir(), filepath.FromSlash(rel))
+}
+
+// yamlKeyLine returns the 1-based line of a top-level key in a YAML file, or 0
+func yamlKeyLine(path, key string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		for _, quote := range []string{"", `"`, "'"} {
+			if strings.HasPrefix(line, quote+key+quote+":") {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
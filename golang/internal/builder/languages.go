@@ -0,0 +1,101 @@
+package builder
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sniplicity/internal/i18n"
+	"sniplicity/internal/types"
+)
+
+// loadLanguageGlobals reads the globals of each language from locales/<lang>.globals.yaml,
+// such as a translated site name or footer
+func (b *Builder) loadLanguageGlobals() (map[string]map[string]string, error) {
+	dir := filepath.Join(b.config.ProjectDir, i18n.LocalesDir)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", dir, err)
+	}
+
+	globals := make(map[string]map[string]string)
+	for _, entry := range entries {
+		lang, ok := strings.CutSuffix(entry.Name(), i18n.GlobalsSuffix)
+		if !ok || lang == "" || entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		values, err := readGlobals(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		globals[lang] = values
+	}
+	return globals, nil
+}
+
+// linkTranslations gives every page that exists in more than one language an hreflang
+// variable of <link rel="alternate"> tags for each version, the default language's also
+// being the x-default. Pages are versions of each other when they share a translation_key.
+func (b *Builder) linkTranslations() {
+	if len(b.languages) == 0 {
+		return
+	}
+
+	versions := make(map[string][]*types.FileInfo)
+	for _, fileInfo := range b.files {
+		key, _ := fileInfo.Metadata["translation_key"].(string)
+		if key != "" {
+			versions[key] = append(versions[key], fileInfo)
+		}
+	}
+
+	siteURL := strings.TrimRight(b.config.SiteURL, "/")
+	for _, pages := range versions {
+		if len(pages) < 2 {
+			continue
+		}
+		sort.SliceStable(pages, func(i, j int) bool {
+			return b.languageOrder(pages[i]) < b.languageOrder(pages[j])
+		})
+
+		var links []string
+		for _, fileInfo := range pages {
+			lang, _ := fileInfo.Metadata["lang"].(string)
+			href := html.EscapeString(siteURL + prettyURL(b.pageURL(fileInfo)))
+			links = append(links, fmt.Sprintf(`<link rel="alternate" hreflang="%s" href="%s">`, html.EscapeString(lang), href))
+			if lang == b.languages.Default() {
+				links = append(links, fmt.Sprintf(`<link rel="alternate" hreflang="x-default" href="%s">`, href))
+			}
+		}
+		for _, fileInfo := range pages {
+			fileInfo.Metadata["hreflang"] = strings.Join(links, "\n")
+		}
+	}
+}
+
+// languageOrder returns where a page's language comes in languages, with languages not
+// listed there last
+func (b *Builder) languageOrder(fileInfo *types.FileInfo) int {
+	lang, _ := fileInfo.Metadata["lang"].(string)
+	for i, known := range b.languages {
+		if lang == known {
+			return i
+		}
+	}
+	return len(b.languages)
+}
+
+// prettyURL drops the index.html a URL ends in, leaving the folder
+func prettyURL(url string) string {
+	if strings.HasSuffix(url, "/index.html") {
+		return strings.TrimSuffix(url, "index.html")
+	}
+	return url
+}
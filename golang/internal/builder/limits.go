@@ -0,0 +1,67 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"sniplicity/internal/types"
+)
+
+// errPageLimit marks a page that ran past page_timeout or max_page_size
+var errPageLimit = types.ErrPageLimit
+
+// errInternal marks a bug in sniplicity, such as a panic, rather than a problem with the sources
+var errInternal = errors.New("internal error")
+
+// runPage runs one processing step for a page within page_timeout and max_page_size. The
+// step checks the page's limits as it grows and stops at the first check it fails; the
+// finished page is checked once more. A panic in step fails only the page, reported with
+// its stack so the bug can be found.
+func (b *Builder) runPage(fileInfo *types.FileInfo, step func() error) error {
+	start := time.Now()
+	fileInfo.Limits = b.pageLimits(start)
+	defer func() { fileInfo.Limits = nil }()
+
+	err := recoverStep(step)
+	b.summary.addPageTime(fileInfo, time.Since(start))
+	if err != nil {
+		return err
+	}
+	return fileInfo.Limits.Check(types.ContentSize(fileInfo.Content))
+}
+
+// pageLimits returns the limits for a page step that starts at start
+func (b *Builder) pageLimits(start time.Time) *types.PageLimits {
+	limits := &types.PageLimits{MaxSize: b.config.MaxPageSize}
+	if b.config.PageTimeout > 0 {
+		limits.Timeout = time.Duration(b.config.PageTimeout) * time.Second
+		limits.Deadline = start.Add(limits.Timeout)
+	}
+	return limits
+}
+
+// recoverStep runs step, turning a panic into an errInternal error with the stack
+func recoverStep(step func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v\n%s", errInternal, r, debug.Stack())
+		}
+	}()
+	return step()
+}
+
+// eachPage runs step for every page within the page limits. A page that fails is reported
+// and left out of the rest of the build, so one bad page can't stall or stop the others.
+func (b *Builder) eachPage(step func(fileInfo *types.FileInfo) error) {
+	kept := b.files[:0]
+	for _, fileInfo := range b.files {
+		if err := b.runPage(fileInfo, func() error { return step(fileInfo) }); err != nil {
+			b.diagnostics.Error(fileInfo.InputPath, 0, "%v", err)
+			continue
+		}
+		kept = append(kept, fileInfo)
+	}
+	b.files = kept
+}
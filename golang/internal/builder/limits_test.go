@@ -0,0 +1,103 @@
+package builder
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"sniplicity/internal/types"
+)
+
+// writeSources writes files into dir/src by their path relative to it
+func writeSources(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, "src", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMaxPageSize(t *testing.T) {
+	dir := t.TempDir()
+	// A snippet pasting itself twice doubles the page on every pass
+	block := strings.Repeat(strings.Repeat("x", 1023)+"\n", 64)
+	writeSources(t, dir, map[string]string{
+		"ok.html":   "<p>fine</p>\n",
+		"grow.html": "<!-- copy grow -->\n" + block + "<!-- paste grow -->\n<!-- paste grow -->\n<!-- end -->\n<!-- paste grow -->\n",
+	})
+	b := newPathsBuilder(dir, 0)
+	b.config.MaxPageSize = 1
+	b.Build()
+
+	items := b.diagnostics.Items()
+	if len(items) != 1 || items[0].File != filepath.Join(dir, "src", "grow.html") || !strings.Contains(items[0].Message, "max_page_size is 1 MB") {
+		t.Fatalf("got %v, want one max_page_size error for grow.html", items)
+	}
+	// The paste loop stops at the first paste over the limit rather than finishing its pass
+	if strings.Contains(items[0].Message, "page grew to 2.") {
+		t.Errorf("got %q, want the page stopped just past 1 MB", items[0].Message)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out", "grow.html")); !os.IsNotExist(err) {
+		t.Errorf("grow.html was written, want it left out: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out", "ok.html")); err != nil {
+		t.Errorf("ok.html wasn't written: %v", err)
+	}
+}
+
+func TestPageTimeout(t *testing.T) {
+	dir := t.TempDir()
+	writeSources(t, dir, map[string]string{
+		"page.html":    "<!-- include part.html -->\n",
+		"part.html":    "<p>part</p>\n",
+		"snippet.html": "<!-- copy s -->\n<p>s</p>\n<!-- end -->\n<!-- paste s -->\n",
+	})
+	b := newPathsBuilder(dir, 0)
+	inputDir := b.config.GetAbsoluteInputDir()
+	site := b.Site()
+
+	// A step that is past its deadline stops at its next check
+	expired := &types.PageLimits{Deadline: time.Now().Add(-time.Second), Timeout: time.Second}
+	for _, test := range []struct {
+		name string
+		page string
+		step func(fileInfo *types.FileInfo) error
+	}{
+		{"include", "page.html", func(fileInfo *types.FileInfo) error {
+			return b.processor.ProcessIncludes(fileInfo, inputDir, site.Globals)
+		}},
+		{"paste", "snippet.html", func(fileInfo *types.FileInfo) error {
+			return b.processor.ProcessSnippets(fileInfo, site.Snippets)
+		}},
+		{"render", "page.html", func(fileInfo *types.FileInfo) error {
+			_, _, err := b.processor.RenderPageWithBody(fileInfo, b.config.GetAbsoluteOutputDir(), site.Templates, site.Snippets, site.Globals, false)
+			return err
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			load := func() *types.FileInfo {
+				fileInfo := types.NewFileInfo(filepath.Join(inputDir, test.page), test.page, false)
+				if err := fileInfo.LoadWithTemplates(nil, nil); err != nil {
+					t.Fatal(err)
+				}
+				return fileInfo
+			}
+			if err := test.step(load()); err != nil {
+				t.Fatalf("without limits: %v", err)
+			}
+			fileInfo := load()
+			fileInfo.Limits = expired
+			if err := test.step(fileInfo); !errors.Is(err, types.ErrPageLimit) || !strings.Contains(err.Error(), "page_timeout") {
+				t.Errorf("got %v, want a page_timeout error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package builder
+
+import (
+	"sniplicity/internal/diag"
+	"sniplicity/internal/linkcheck"
+	"sniplicity/internal/logging"
+)
+
+// checkLinks reports internal links in the generated pages that point at missing files
+func (b *Builder) checkLinks() error {
+	broken, err := linkcheck.Check(b.config.GetAbsoluteOutputDir())
+	if err != nil {
+		return err
+	}
+
+	for _, link := range broken {
+		b.diagnostics.Check(diag.BrokenLink, link.File, link.Line, "broken link %s", link.URL)
+	}
+	if len(broken) == 0 {
+		logging.Debugf("  No broken links found")
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package builder
+
+import "sniplicity/internal/diag"
+
+// lintRules returns the level of each lint rule. Strict mode and check_links set the
+// defaults, and the lint section of sniplicity.yaml overrides them rule by rule.
+func (b *Builder) lintRules() map[diag.Rule]diag.Level {
+	levels := make(map[diag.Rule]diag.Level, len(diag.DefaultRules))
+	for rule, level := range diag.DefaultRules {
+		levels[rule] = level
+	}
+	if b.config.Strict {
+		levels[diag.MissingReference] = diag.Error
+		levels[diag.UndefinedVariable] = diag.Error
+	}
+	if b.config.CheckLinks {
+		levels[diag.BrokenLink] = diag.Warning
+	}
+
+	for name, value := range b.config.Lint {
+		rule := diag.Rule(name)
+		if _, known := diag.DefaultRules[rule]; !known {
+			continue // sniplicity.yaml validation already warned about it
+		}
+		if level, err := diag.ParseRuleLevel(value); err == nil {
+			levels[rule] = level
+		}
+	}
+	return levels
+}
@@ -0,0 +1,245 @@
+package builder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ManifestPath is where the build manifest is kept, relative to the project directory
+const ManifestPath = ".sniplicity/manifest.json"
+
+// Manifest records what the last successful build read and wrote. Its output hashes let
+// the next build skip rewriting files whose content hasn't changed, so their modification
+// times are kept and sync tools don't upload them again.
+type Manifest struct {
+	Inputs          map[string]ManifestInput     `json:"inputs"`                     // By path relative to the input directory
+	Outputs         map[string]string            `json:"outputs"`                    // Content hash by path relative to the output directory
+	Modified        map[string]time.Time         `json:"modified,omitempty"`         // When the content of each output last changed
+	Anchors         map[string]map[string]string `json:"anchors,omitempty"`          // Text of each {{anchor}} and heading ID, by ID, of each page
+	AnchorRedirects map[string]map[string]string `json:"anchor_redirects,omitempty"` // New ID of each changed {{anchor}}, by old ID, of each page
+}
+
+// ManifestInput is one source file with the output files made from it
+type ManifestInput struct {
+	Hash    string   `json:"hash"`
+	Outputs []string `json:"outputs"`
+}
+
+// hashContent returns the hex SHA-256 of data
+func hashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// manifestFile returns the manifest's location, or "" outside a project
+func (b *Builder) manifestFile() string {
+	if b.config.ProjectDir == "" {
+		return ""
+	}
+	return filepath.Join(b.config.ProjectDir, filepath.FromSlash(ManifestPath))
+}
+
+// loadManifest reads the output hashes of the previous build. A missing or unreadable
+// manifest just means every output is compared with the file on disk instead. The file
+// is removed until this build succeeds, as a failed build may leave outputs that no
+// longer match it.
+func (b *Builder) loadManifest() {
+	b.previous = nil
+	b.modified = make(map[string]time.Time)
+	b.setAnchors(Manifest{})
+	path := b.manifestFile()
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var manifest Manifest
+	if json.Unmarshal(data, &manifest) == nil {
+		b.previous = manifest.Outputs
+		for rel, modified := range manifest.Modified {
+			b.modified[rel] = modified
+		}
+		b.setAnchors(manifest)
+	}
+	if !b.config.DryRun {
+		os.Remove(path)
+	}
+}
+
+// restoreOutputs starts from the outputs the last build recorded in the manifest, for a
+// build limited by only that runs without a full build before it. Without a manifest
+// there is nothing to start from and the build becomes a full one.
+func (b *Builder) restoreOutputs() {
+	path := b.manifestFile()
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var manifest Manifest
+	if json.Unmarshal(data, &manifest) != nil {
+		return
+	}
+
+	inputDir := b.config.GetAbsoluteInputDir()
+	outputDir := b.config.GetAbsoluteOutputDir()
+	b.outputsMu.Lock()
+	defer b.outputsMu.Unlock()
+	b.outputs = make(map[string]string, len(manifest.Outputs))
+	b.hashes = make(map[string]string, len(manifest.Outputs))
+	b.modified = make(map[string]time.Time, len(manifest.Modified))
+	for rel, hash := range manifest.Outputs {
+		output := filepath.Join(outputDir, filepath.FromSlash(rel))
+		b.outputs[output] = ""
+		b.hashes[output] = hash
+	}
+	for sourceRel, input := range manifest.Inputs {
+		for _, rel := range input.Outputs {
+			b.outputs[filepath.Join(outputDir, filepath.FromSlash(rel))] = filepath.Join(inputDir, filepath.FromSlash(sourceRel))
+		}
+	}
+	for rel, modified := range manifest.Modified {
+		b.modified[rel] = modified
+	}
+	b.setAnchors(manifest)
+}
+
+// unchanged reports whether the output file at path already holds content with the given
+// hash, checking the manifest first and the file itself when the manifest can't tell
+func (b *Builder) unchanged(path string, data []byte, hash string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() != int64(len(data)) {
+		return false
+	}
+	if previous, ok := b.previous[b.outputRel(path)]; ok {
+		return previous == hash
+	}
+	existing, err := os.ReadFile(path)
+	return err == nil && bytes.Equal(existing, data)
+}
+
+// keepModified sets the modification time of an output file whose content didn't change
+// back to when it last did, in case it was touched or copied since, so the Last-Modified
+// header it is served with and its sitemap lastmod stay the same
+func (b *Builder) keepModified(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	rel := b.outputRel(path)
+	b.outputsMu.Lock()
+	modified, known := b.modified[rel]
+	if !known {
+		b.modified[rel] = info.ModTime()
+	}
+	b.outputsMu.Unlock()
+	if known && !info.ModTime().Equal(modified) {
+		os.Chtimes(path, modified, modified)
+	}
+}
+
+// setModified records that the content of an output file changed when it was written
+func (b *Builder) setModified(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	b.outputsMu.Lock()
+	b.modified[b.outputRel(path)] = info.ModTime()
+	b.outputsMu.Unlock()
+}
+
+// lastModified returns when the content of an output file, relative to the output
+// directory, last changed: as this build or the last one wrote it, or else as the file
+// says, or now for a file that isn't there
+func (b *Builder) lastModified(rel string) time.Time {
+	b.outputsMu.Lock()
+	modified, known := b.modified[rel]
+	b.outputsMu.Unlock()
+	if known {
+		return modified
+	}
+	if info, err := os.Stat(filepath.Join(b.config.GetAbsoluteOutputDir(), filepath.FromSlash(rel))); err == nil {
+		return info.ModTime()
+	}
+	return time.Now()
+}
+
+// outputRel returns path relative to the output directory, with forward slashes
+func (b *Builder) outputRel(path string) string {
+	rel, err := filepath.Rel(b.config.GetAbsoluteOutputDir(), path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// writeManifest saves the hashes of the build that just finished, with each source file
+// mapped to the outputs made from it
+func (b *Builder) writeManifest() error {
+	path := b.manifestFile()
+	if path == "" {
+		return nil
+	}
+
+	inputDir := b.config.GetAbsoluteInputDir()
+	manifest := Manifest{Inputs: make(map[string]ManifestInput), Outputs: make(map[string]string), Modified: make(map[string]time.Time),
+		Anchors: make(map[string]map[string]string), AnchorRedirects: make(map[string]map[string]string)}
+
+	b.outputsMu.Lock()
+	for output, source := range b.outputs {
+		rel := b.outputRel(output)
+		if hash, ok := b.hashes[output]; ok {
+			manifest.Outputs[rel] = hash
+		}
+		if modified, ok := b.modified[rel]; ok {
+			manifest.Modified[rel] = modified
+		}
+		if anchors, ok := b.anchors[rel]; ok {
+			manifest.Anchors[rel] = anchors
+		}
+		if redirects, ok := b.anchorRedirects[rel]; ok {
+			manifest.AnchorRedirects[rel] = redirects
+		}
+		if source == "" {
+			continue // Generated from several pages, like _redirects
+		}
+		sourceRel, err := filepath.Rel(inputDir, source)
+		if err != nil {
+			continue
+		}
+		sourceRel = filepath.ToSlash(sourceRel)
+		input := manifest.Inputs[sourceRel]
+		input.Outputs = append(input.Outputs, rel)
+		manifest.Inputs[sourceRel] = input
+	}
+	b.outputsMu.Unlock()
+
+	for sourceRel, input := range manifest.Inputs {
+		if data, err := os.ReadFile(filepath.Join(inputDir, filepath.FromSlash(sourceRel))); err == nil {
+			input.Hash = hashContent(data)
+		}
+		sort.Strings(input.Outputs)
+		manifest.Inputs[sourceRel] = input
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding manifest: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("cannot create %s: %w", filepath.Dir(path), err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
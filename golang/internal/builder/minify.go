@@ -0,0 +1,167 @@
+package builder
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// rawElements are the elements whose content minifying leaves alone, by tag name
+var rawElements = map[string]bool{"pre": true, "textarea": true, "script": true, "style": true}
+
+// blockElements are the elements that whitespace next to never shows around, by tag name
+var blockElements = map[string]bool{
+	"!doctype": true, "html": true, "head": true, "body": true, "title": true, "meta": true, "link": true,
+	"script": true, "style": true, "noscript": true, "base": true, "header": true, "footer": true,
+	"main": true, "nav": true, "section": true, "article": true, "aside": true, "div": true, "p": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "ul": true, "ol": true,
+	"li": true, "dl": true, "dt": true, "dd": true, "table": true, "thead": true, "tbody": true,
+	"tfoot": true, "tr": true, "th": true, "td": true, "caption": true, "colgroup": true, "col": true,
+	"form": true, "fieldset": true, "legend": true, "figure": true, "figcaption": true,
+	"blockquote": true, "hr": true, "br": true, "pre": true, "textarea": true, "details": true,
+	"summary": true, "template": true, "address": true, "option": true, "optgroup": true,
+}
+
+// tagNameRegex matches the name of an HTML tag, or !doctype for a doctype, in its first group
+var tagNameRegex = regexp.MustCompile(`^</?(!?[a-zA-Z][-\w]*)`)
+
+// whitespaceRegex matches runs of whitespace
+var whitespaceRegex = regexp.MustCompile(`\s+`)
+
+// cssTokenRegex matches the CSS strings and comments that minifying must treat as a whole
+var cssTokenRegex = regexp.MustCompile(`"(?:[^"\\\n]|\\.)*"|'(?:[^'\\\n]|\\.)*'|/\*[\s\S]*?(?:\*/|$)`)
+
+// cssSpaceRegex matches whitespace around CSS punctuation that doesn't need it
+var cssSpaceRegex = regexp.MustCompile(`\s*([{};,>])\s*`)
+
+// minifyOutput minifies an HTML or CSS output file with minify on. Files already named
+// .min.css are left as they are.
+func (b *Builder) minifyOutput(outputPath string, data []byte) []byte {
+	switch ext := strings.ToLower(filepath.Ext(outputPath)); {
+	case ext == ".html" || ext == ".htm":
+		return []byte(minifyHTML(string(data)))
+	case ext == ".css" && !strings.HasSuffix(strings.ToLower(outputPath), ".min.css"):
+		return []byte(minifyCSS(string(data)))
+	}
+	return data
+}
+
+// minifyHTML returns page without its comments and with its whitespace collapsed: runs of
+// it become one space, and it is dropped next to block elements, where it never shows.
+// pre, textarea and script elements are kept as they are, style elements get their CSS
+// minified, and conditional comments are kept for the browsers that read them.
+func minifyHTML(page string) string {
+	var out, text strings.Builder
+	previous := "html" // The last tag written, so leading whitespace is dropped
+
+	// flush writes the text since the last tag, collapsed, before the tag next
+	flush := func(next string) {
+		collapsed := whitespaceRegex.ReplaceAllString(text.String(), " ")
+		text.Reset()
+		if blockElements[previous] {
+			collapsed = strings.TrimLeft(collapsed, " ")
+		}
+		if blockElements[next] {
+			collapsed = strings.TrimRight(collapsed, " ")
+		}
+		out.WriteString(collapsed)
+	}
+
+	for page != "" {
+		start := strings.IndexByte(page, '<')
+		if start < 0 {
+			text.WriteString(page)
+			break
+		}
+		text.WriteString(page[:start])
+		page = page[start:]
+
+		if strings.HasPrefix(page, "<!--") {
+			end := strings.Index(page[4:], "-->")
+			if end < 0 {
+				end = len(page) - 4 - 3 // An unclosed comment runs to the end of the page
+			}
+			comment := page[:4+end+3]
+			page = page[len(comment):]
+			if strings.HasPrefix(comment, "<!--[if") || strings.HasPrefix(comment, "<!--<![endif]") || strings.HasSuffix(comment, "<![endif]-->") {
+				flush("")
+				out.WriteString(comment)
+				previous = ""
+			}
+			continue
+		}
+
+		end := tagEnd(page)
+		match := tagNameRegex.FindStringSubmatch(page[:end])
+		if match == nil {
+			text.WriteString("<") // A < that doesn't start a tag is text
+			page = page[1:]
+			continue
+		}
+		name := strings.ToLower(match[1])
+		flush(name)
+		out.WriteString(page[:end])
+		page = page[end:]
+		previous = name
+
+		// Raw elements keep their content up to the closing tag
+		if rawElements[name] && !strings.HasPrefix(match[0], "</") {
+			closing := strings.Index(strings.ToLower(page), "</"+name)
+			if closing < 0 {
+				closing = len(page)
+			}
+			content := page[:closing]
+			if name == "style" {
+				content = minifyCSS(content)
+			}
+			out.WriteString(content)
+			page = page[closing:]
+		}
+	}
+	flush("html")
+	return out.String()
+}
+
+// tagEnd returns the index just past the > that ends the tag at the start of s, skipping
+// any > in quoted attribute values, or len(s) if the tag isn't closed
+func tagEnd(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// minifyCSS returns css without its comments and the whitespace it doesn't need. Strings
+// are kept as they are, as are /*! comments, which by convention hold a license.
+func minifyCSS(css string) string {
+	var out strings.Builder
+	squeeze := func(s string) {
+		s = cssSpaceRegex.ReplaceAllString(whitespaceRegex.ReplaceAllString(s, " "), "$1")
+		// Where a comment was taken out, the whitespace on both sides of it is one space
+		if written := out.String(); written == "" || strings.HasSuffix(written, " ") || strings.HasSuffix(written, "*/") {
+			s = strings.TrimLeft(s, " ")
+		}
+		out.WriteString(strings.ReplaceAll(s, ";}", "}"))
+	}
+	last := 0
+	for _, loc := range cssTokenRegex.FindAllStringIndex(css, -1) {
+		squeeze(css[last:loc[0]])
+		token := css[loc[0]:loc[1]]
+		if !strings.HasPrefix(token, "/*") || strings.HasPrefix(token, "/*!") {
+			out.WriteString(token)
+		}
+		last = loc[1]
+	}
+	squeeze(css[last:])
+	return strings.TrimSpace(out.String())
+}
@@ -0,0 +1,101 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sniplicity/internal/config"
+)
+
+func TestMinifyHTML(t *testing.T) {
+	for _, test := range []struct {
+		in, want string
+	}{
+		{"<!DOCTYPE html>\n<html>\n  <head>\n    <title>Hi</title>\n  </head>\n</html>\n", "<!DOCTYPE html><html><head><title>Hi</title></head></html>"},
+		{"<p>\n  Some   <b>bold</b>\n  text\n</p>", "<p>Some <b>bold</b> text</p>"},
+		{"<ul>\n  <li><a href=\"/\">Home</a></li>\n  <li>About</li>\n</ul>", `<ul><li><a href="/">Home</a></li><li>About</li></ul>`},
+		// Inline elements keep a space between them
+		{"<span>a</span>\n<span>b</span>", "<span>a</span> <span>b</span>"},
+		// Comments go, and the text around them still gets one space
+		{"<p>a <!-- note --> b</p>", "<p>a b</p>"},
+		{"<!--[if IE]><p>old</p><![endif]-->\n<p>new</p>", "<!--[if IE]><p>old</p><![endif]--><p>new</p>"},
+		// Raw elements keep their content
+		{"<pre>  a\n    b  </pre>\n<p>x</p>", "<pre>  a\n    b  </pre><p>x</p>"},
+		{"<textarea>\n  keep  </textarea>", "<textarea>\n  keep  </textarea>"},
+		{"<script>\n  if (a < b) {  go()  }\n</script>", "<script>\n  if (a < b) {  go()  }\n</script>"},
+		{"<style>\n  p {\n    color: red;\n  }\n</style>", "<style>p{color: red}</style>"},
+		// A > in an attribute value doesn't end the tag
+		{"<a title=\"a > b\">\n  x\n</a>", `<a title="a > b"> x </a>`},
+		{"<p>1 < 2</p>", "<p>1 < 2</p>"},
+	} {
+		if got := minifyHTML(test.in); got != test.want {
+			t.Errorf("minifyHTML(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestMinifyCSS(t *testing.T) {
+	for _, test := range []struct {
+		in, want string
+	}{
+		{"body {\n  margin: 0;\n  padding: 0;\n}\n", "body{margin: 0;padding: 0}"},
+		{"/* layout */\nh1, h2 > a {\n  color: red;\n}", "h1,h2>a{color: red}"},
+		{"/*! MIT licensed */\na { b: c }", "/*! MIT licensed */a{b: c}"},
+		{`a::after { content: "  /* not a comment */  "; }`, `a::after{content: "  /* not a comment */  "}`},
+		{"a /* descendant */ b {}", "a b{}"},
+		{"@media (max-width: 600px) {\n  .a { display: none; }\n}", "@media (max-width: 600px){.a{display: none}}"},
+	} {
+		if got := minifyCSS(test.in); got != test.want {
+			t.Errorf("minifyCSS(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestMinifyEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	writeSources(t, dir, map[string]string{
+		"index.html": "<div>\n  <p>Hello   there</p>\n</div>\n",
+		"style.css":  "body {\n  margin: 0;\n}\n",
+	})
+	settings := "input_dir: src\noutput_dir: out\nminify: false\nenvironments:\n  production:\n    minify: true\n"
+	if err := os.WriteFile(filepath.Join(dir, "sniplicity.yaml"), []byte(settings), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	build := func(env string) (page, css string) {
+		t.Helper()
+		cfg, err := config.LoadConfigFromFile(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.ProjectDir = dir
+		if env != "" {
+			if err := cfg.SetEnvironment(env); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := New(cfg).Build(); err != nil {
+			t.Fatal(err)
+		}
+		pageData, err := os.ReadFile(filepath.Join(dir, "out", "index.html"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cssData, err := os.ReadFile(filepath.Join(dir, "out", "style.css"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(pageData), string(cssData)
+	}
+
+	page, css := build("")
+	if !strings.Contains(page, "<p>Hello   there</p>") || css != "body {\n  margin: 0;\n}\n" {
+		t.Errorf("development build wrote %q and %q, want them as they are", page, css)
+	}
+	page, css = build("production")
+	if page != "<div><p>Hello there</p></div>" || css != "body{margin: 0}" {
+		t.Errorf("production build wrote %q and %q, want them minified", page, css)
+	}
+}
@@ -0,0 +1,61 @@
+package builder
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"sniplicity/internal/builtin"
+	"sniplicity/internal/logging"
+	"sniplicity/internal/types"
+	"sniplicity/internal/web"
+)
+
+// notFoundTemplate is the template the generated 404 page is written with, when the sources
+// define one of that name
+const notFoundTemplate = "404"
+
+// notFoundSource is the generated 404 page when there is a 404 template to put it in
+var notFoundSource = []string{
+	"---",
+	"template: " + notFoundTemplate,
+	"title: Page not found",
+	"---",
+	"<h1>Page not found</h1>",
+	`<p>The page you were looking for isn't here. <a href="/">Go to the home page</a></p>`,
+}
+
+// isNotFoundPage reports whether a page is the site's 404.html, which hosts serve for
+// missing paths and which the site map and search index leave out
+func (b *Builder) isNotFoundPage(fileInfo *types.FileInfo) bool {
+	return b.outputRel(fileInfo.GetOutputPath(b.config.GetAbsoluteOutputDir())) == web.NotFoundPage
+}
+
+// addNotFoundPage adds a 404.html to the pages when none of the sources makes one. It is
+// written with the template named 404, like any page, so it gets the site's globals,
+// navigation and styles; without that template it is the built-in 404 page.
+func (b *Builder) addNotFoundPage() error {
+	for _, fileInfo := range b.files {
+		if b.isNotFoundPage(fileInfo) {
+			return nil
+		}
+	}
+
+	source := strings.Join(notFoundSource, "\n")
+	if _, exists := b.Site().Templates[notFoundTemplate]; !exists {
+		markup, err := b.builtinTemplates().Render(builtin.NotFoundPage, builtin.NotFound{})
+		if err != nil {
+			return fmt.Errorf("cannot render %s: %w", builtin.NotFoundPage, err)
+		}
+		source = string(markup)
+	}
+
+	fileInfo := types.NewFileInfo(filepath.Join(b.config.GetAbsoluteInputDir(), web.NotFoundPage), web.NotFoundPage, false)
+	fileInfo.Delimiters = b.delimiters
+	if err := fileInfo.LoadFromReader(strings.NewReader(source)); err != nil {
+		return fmt.Errorf("cannot create %s: %w", web.NotFoundPage, err)
+	}
+	logging.Debugf("  Adding %s", web.NotFoundPage)
+	b.files = append(b.files, fileInfo)
+	return nil
+}
@@ -0,0 +1,60 @@
+package builder
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"sniplicity/internal/config"
+	"sniplicity/internal/logging"
+	"sniplicity/internal/types"
+)
+
+// pageTemplateRule gives markdown pages whose source path matches a pattern a template
+type pageTemplateRule struct {
+	pattern  string
+	template string
+}
+
+// parsePageTemplates reads page_templates, longest patterns first so the most specific
+// rule wins
+func parsePageTemplates(templates map[string]string) ([]pageTemplateRule, error) {
+	rules := make([]pageTemplateRule, 0, len(templates))
+	for pattern, template := range templates {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("page_templates pattern %q is not a valid glob", pattern)
+		}
+		if template == "" {
+			return nil, fmt.Errorf("page_templates %s needs a template name", pattern)
+		}
+		rules = append(rules, pageTemplateRule{pattern: pattern, template: template})
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if len(rules[i].pattern) != len(rules[j].pattern) {
+			return len(rules[i].pattern) > len(rules[j].pattern)
+		}
+		return rules[i].pattern < rules[j].pattern
+	})
+	return rules, nil
+}
+
+// applyPageTemplate gives a markdown page that names no template in its frontmatter or
+// _defaults.yaml the one of the first page_templates rule matching its path. A set
+// template directive in the page still wins, as it does over the frontmatter.
+func (b *Builder) applyPageTemplate(fileInfo *types.FileInfo, relPath string) {
+	if len(b.pageTemplates) == 0 || !types.IsMarkdownFile(fileInfo.InputPath) {
+		return
+	}
+	if _, named := fileInfo.Metadata["template"]; named {
+		return
+	}
+	rel := filepath.ToSlash(filepath.Join(relPath, filepath.Base(fileInfo.InputPath)))
+	for _, rule := range b.pageTemplates {
+		if config.MatchPattern(rule.pattern, rel) {
+			logging.Debugf("  Template '%s' for %s from page_templates %s", rule.template, rel, rule.pattern)
+			fileInfo.Metadata["template"] = rule.template
+			return
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sniplicity/internal/types"
+)
+
+// maxPathLength is the classic Windows MAX_PATH limit. Sniplicity itself copes with
+// longer paths, but zip tools, FTP clients and older Windows software often don't.
+const maxPathLength = 260
+
+// pathDepth returns the number of directory levels in a slash or OS separated relative path
+func pathDepth(relPath string) int {
+	relPath = filepath.ToSlash(relPath)
+	if relPath == "" || relPath == "." {
+		return 0
+	}
+	return len(strings.Split(strings.Trim(relPath, "/"), "/"))
+}
+
+// checkDepth warns (once per directory) about source directories nested deeper than the
+// configured max_depth
+func (b *Builder) checkDepth(relDir string, warned map[string]bool) {
+	if b.config.MaxDepth > 0 && pathDepth(relDir) > b.config.MaxDepth && !warned[relDir] {
+		warned[relDir] = true
+		b.diagnostics.Warn(filepath.Join(b.config.GetAbsoluteInputDir(), relDir), 0,
+			"nested %d levels deep (max_depth is %d)", pathDepth(relDir), b.config.MaxDepth)
+	}
+}
+
+// checkOutputPath warns about a page whose output path, once its permalink is applied,
+// exceeds MAX_PATH
+func (b *Builder) checkOutputPath(fileInfo *types.FileInfo) {
+	outputPath := fileInfo.GetOutputPath(b.config.GetAbsoluteOutputDir())
+	if len(outputPath) >= maxPathLength {
+		b.diagnostics.Warn(fileInfo.InputPath, 0,
+			"output path %s is %d characters long, some tools can't handle paths over %d", outputPath, len(outputPath), maxPathLength-1)
+	}
+}
+
+// walkTolerant wraps a filepath.WalkFunc so an unreadable entry below the root (too
+// long a name, permission denied, a vanished file) is reported and skipped instead of
+// aborting the whole walk
+func (b *Builder) walkTolerant(root string, fn filepath.WalkFunc) filepath.WalkFunc {
+	return func(path string, info os.FileInfo, err error) error {
+		if err != nil && path != root {
+			b.diagnostics.Warn(path, 0, "skipped: %v", err)
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return fn(path, info, err)
+	}
+}
+
+// applyPermalink sets a page's language, section and its output path from the configured
+// languages and permalink rules, and then its file and git variables, warning when two
+// pages end up at the same URL. claimed maps permalinks to the source that took them.
+func (b *Builder) applyPermalink(fileInfo *types.FileInfo, relPath string, claimed map[string]string) {
+	sourcePath := filepath.Join(relPath, filepath.Base(fileInfo.InputPath))
+	b.languages.Localize(sourcePath, fileInfo.Metadata)
+	b.languages.Section(sourcePath, fileInfo.Metadata)
+	fileInfo.Permalink = b.languages.Permalink(b.config.Permalinks, sourcePath, fileInfo.Metadata, b.dates)
+	types.AddFileVariables(fileInfo.Metadata, fileInfo.InputPath, sourcePath, b.pageURL(fileInfo), b.gitHistory)
+	if fileInfo.Permalink == "" || claimed == nil {
+		return
+	}
+
+	if other, exists := claimed[fileInfo.Permalink]; exists {
+		b.diagnostics.Warn(fileInfo.InputPath, 0, "permalink /%s is already used by %s", fileInfo.Permalink, other)
+		return
+	}
+	claimed[fileInfo.Permalink] = fileInfo.InputPath
+}
@@ -0,0 +1,117 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sniplicity/internal/config"
+	"sniplicity/internal/types"
+)
+
+// newPathsBuilder returns a builder for a project in dir writing to dir/out
+func newPathsBuilder(dir string, maxDepth int) *Builder {
+	cfg := config.DefaultConfig()
+	cfg.ProjectDir = dir
+	cfg.InputDir = "src"
+	cfg.OutputDir = "out"
+	cfg.MaxDepth = maxDepth
+	return New(cfg)
+}
+
+func TestPathDepth(t *testing.T) {
+	for _, test := range []struct {
+		relPath string
+		want    int
+	}{
+		{"", 0},
+		{".", 0},
+		{"blog", 1},
+		{"blog/2024", 2},
+		{"/blog/2024/", 2},
+		{filepath.Join("a", "b", "c", "d"), 4},
+		{strings.Repeat("deep/", 20), 20},
+	} {
+		if got := pathDepth(test.relPath); got != test.want {
+			t.Errorf("pathDepth(%q) = %d, want %d", test.relPath, got, test.want)
+		}
+	}
+}
+
+func TestCheckDepth(t *testing.T) {
+	dir := t.TempDir()
+	deep := filepath.Join("a", "b", "c", "d")
+	if err := os.MkdirAll(filepath.Join(dir, "src", deep), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"one.md", "two.html"} {
+		if err := os.WriteFile(filepath.Join(dir, "src", deep, name), []byte("text"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "src", "index.md"), []byte("text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := newPathsBuilder(dir, 3)
+	if _, err := b.getFileList(b.config.GetAbsoluteInputDir()); err != nil {
+		t.Fatal(err)
+	}
+	items := b.diagnostics.Items()
+	if len(items) != 1 {
+		t.Fatalf("got %d warnings, want one for the deep folder: %v", len(items), items)
+	}
+	if want := filepath.Join(dir, "src", deep); items[0].File != want || !strings.Contains(items[0].Message, "nested 4 levels deep") {
+		t.Errorf("got %s, want a warning that %s is nested 4 levels deep", items[0], want)
+	}
+
+	b = newPathsBuilder(dir, 0)
+	if _, err := b.getFileList(b.config.GetAbsoluteInputDir()); err != nil {
+		t.Fatal(err)
+	}
+	if items := b.diagnostics.Items(); len(items) != 0 {
+		t.Errorf("got %v with max_depth off, want no warnings", items)
+	}
+}
+
+func TestCheckOutputPath(t *testing.T) {
+	const dir = "/project"
+	outputDir := filepath.Join(dir, "out")
+	// A name that makes the output path exactly one character short of MAX_PATH
+	fits := strings.Repeat("x", maxPathLength-1-len(outputDir)-len("/.html"))
+
+	for _, test := range []struct {
+		name      string
+		filename  string
+		permalink string
+		warn      bool
+	}{
+		{"short", "index.md", "", false},
+		{"just fits", fits + ".html", "", false},
+		{"at MAX_PATH", fits + "x.html", "", true},
+		// The source name is longer than the .html it is written to
+		{"markdown renamed to html", fits + ".md", "", false},
+		{"markdown over MAX_PATH", fits + "x.md", "", true},
+		{"permalink shortens it", fits + "xxxx.md", "short/", false},
+		{"permalink lengthens it", "a.md", strings.Repeat("p", maxPathLength) + "/", true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			b := newPathsBuilder(dir, 0)
+			fileInfo := types.NewFileInfo(filepath.Join(dir, "src", test.filename), test.filename, types.IsMarkdownFile(test.filename))
+			fileInfo.Permalink = test.permalink
+			b.checkOutputPath(fileInfo)
+
+			items := b.diagnostics.Items()
+			if !test.warn {
+				if len(items) != 0 {
+					t.Errorf("got %v for %s, want no warnings", items, fileInfo.GetOutputPath(outputDir))
+				}
+				return
+			}
+			if len(items) != 1 || items[0].File != fileInfo.InputPath || !strings.Contains(items[0].Message, fileInfo.GetOutputPath(outputDir)) {
+				t.Errorf("got %v, want one warning naming %s", items, fileInfo.GetOutputPath(outputDir))
+			}
+		})
+	}
+}
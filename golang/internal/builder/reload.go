@@ -0,0 +1,94 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+
+	"sniplicity/internal/builtin"
+	"sniplicity/internal/config"
+	"sniplicity/internal/logging"
+
+	"github.com/fatih/color"
+)
+
+// configFile returns the project's sniplicity.yaml, or "" outside a project
+func (b *Builder) configFile() string {
+	if b.config.ProjectDir == "" {
+		return ""
+	}
+	return filepath.Join(b.config.ProjectDir, "sniplicity.yaml")
+}
+
+// configFileHash returns the hash of sniplicity.yaml as it is now, or "" if it can't be read
+func (b *Builder) configFileHash() string {
+	path := b.configFile()
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return hashContent(data)
+}
+
+// SetConfigLoader sets how the config is read again when sniplicity.yaml changes in watch
+// mode, so the command line flags can be applied on top of it as they were at startup.
+// Without one the file is read as it is.
+func (b *Builder) SetConfigLoader(load func() (config.Config, error)) {
+	b.loadConfig = load
+}
+
+// watchedFiles returns the files outside the input directory that the build depends on:
+// the project config, the shared globals, overrides of the built-in pages and any
+// includes and declared dependencies from elsewhere
+func (b *Builder) watchedFiles() []string {
+	files := append(b.processor.ExternalIncludes(), b.sharedGlobalsFiles()...)
+	files = append(files, b.externalDependencies()...)
+	if dir := b.config.GetAbsoluteBuiltinTemplates(); dir != "" {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			for _, name := range builtin.Names {
+				files = append(files, filepath.Join(dir, name))
+			}
+		}
+	}
+	if path := b.configFile(); path != "" {
+		files = append(files, path)
+	}
+	return files
+}
+
+// reloadConfig reads sniplicity.yaml again if it changed since the last build. The folders
+// and server settings stay as they are, as the watcher and server are already running on
+// them. A config that can't be read is reported and the previous one kept.
+func (b *Builder) reloadConfig() {
+	hash := b.configFileHash()
+	if hash == "" || hash == b.configHash {
+		return
+	}
+
+	var cfg config.Config
+	var err error
+	if b.loadConfig != nil {
+		cfg, err = b.loadConfig()
+	} else {
+		cfg, err = config.LoadConfigFromFile(b.config.ProjectDir)
+	}
+	if err != nil {
+		logging.Errorf("Cannot reload %s: %v", b.configFile(), err)
+		return
+	}
+
+	if cfg.GetAbsoluteInputDir() != b.config.GetAbsoluteInputDir() || cfg.GetAbsoluteOutputDir() != b.config.GetAbsoluteOutputDir() {
+		logging.Warnf("input_dir and output_dir changes take effect when sniplicity is restarted")
+	}
+	cfg.ProjectDir = b.config.ProjectDir
+	cfg.InputDir, cfg.OutputDir = b.config.InputDir, b.config.OutputDir
+	cfg.Watch, cfg.Serve, cfg.Port = b.config.Watch, b.config.Serve, b.config.Port
+	cfg.Host, cfg.TLS, cfg.TLSCert, cfg.TLSKey = b.config.Host, b.config.TLS, b.config.TLSCert, b.config.TLSKey
+	cfg.DryRun, cfg.NoColor = b.config.DryRun, b.config.NoColor
+	cfg.Diagnostics, cfg.LegacyMode = b.config.Diagnostics, b.config.LegacyMode
+
+	b.config = cfg
+	logging.Infof("Reloaded %s", color.CyanString("sniplicity.yaml"))
+}
@@ -0,0 +1,282 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sniplicity/internal/logging"
+	"sniplicity/internal/parser"
+
+	"gopkg.in/yaml.v3"
+)
+
+// remoteDataPath is where fetched remote data is cached, relative to the project directory
+const remoteDataPath = ".sniplicity/remote"
+
+// defaultRemoteCache is how long fetched remote data is reused when remote_data sets no cache
+const defaultRemoteCache = time.Hour
+
+// maxRemoteSize is the most remote data read from one URL
+const maxRemoteSize = 10 << 20
+
+// remoteClient fetches remote data
+var remoteClient = &http.Client{Timeout: 30 * time.Second}
+
+// remoteSource is a remote_data entry of sniplicity.yaml
+type remoteSource struct {
+	name     string
+	url      string
+	format   string        // "json" or "yaml"
+	cache    time.Duration // How long a fetched copy is reused
+	mapping  [][2]string   // Global names and the paths of their values, in order; empty for every value
+	tokenEnv string        // Environment variable holding a bearer token, if any
+}
+
+// parseRemoteData reads remote_data, in name order
+func parseRemoteData(remote map[string]map[string]string) ([]remoteSource, error) {
+	names := make([]string, 0, len(remote))
+	for name := range remote {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sources []remoteSource
+	for _, name := range names {
+		entry := remote[name]
+		source := remoteSource{name: name, url: entry["url"], format: entry["format"], cache: defaultRemoteCache, tokenEnv: entry["token_env"]}
+		u, err := url.Parse(source.url)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("remote_data %s needs an http or https url", name)
+		}
+		if source.format == "" {
+			source.format = "json"
+			if ext := path.Ext(u.Path); ext == ".yaml" || ext == ".yml" {
+				source.format = "yaml"
+			}
+		}
+		if source.format != "json" && source.format != "yaml" {
+			return nil, fmt.Errorf("remote_data %s has format %s, use json or yaml", name, source.format)
+		}
+		if cache := entry["cache"]; cache != "" {
+			d, err := time.ParseDuration(cache)
+			if cache == "0" {
+				d, err = 0, nil
+			}
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("remote_data %s has cache %s, use a duration such as 30m or 1h", name, cache)
+			}
+			source.cache = d
+		}
+		for _, line := range strings.Split(entry["map"], "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			global, field, ok := strings.Cut(line, ":")
+			global, field = strings.TrimSpace(global), strings.TrimSpace(field)
+			if !ok || global == "" || field == "" {
+				return nil, fmt.Errorf("remote_data %s map must be name: path lines, not %q", name, strings.TrimSpace(line))
+			}
+			source.mapping = append(source.mapping, [2]string{global, field})
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// loadRemoteData fetches each remote_data source and adds its values to globals as
+// <name>.<global>. A source that can't be fetched is reported and left out, or its last
+// fetched copy is used if there is one, so a network problem doesn't stop the build.
+func (b *Builder) loadRemoteData(globals map[string]string) {
+	for _, source := range b.remoteSources {
+		data, err := b.fetchRemote(source)
+		if err != nil {
+			b.diagnostics.Warn("", 0, "remote_data %s: %v", source.name, err)
+			continue
+		}
+		values, missing, err := remoteValues(source, data)
+		if err != nil {
+			b.diagnostics.Warn("", 0, "remote_data %s: %v", source.name, err)
+			continue
+		}
+		for _, field := range missing {
+			b.diagnostics.Warn("", 0, "remote_data %s has no %s", source.name, field)
+		}
+		for name, value := range values {
+			globals[name] = value
+		}
+		logging.Debugf("  Loaded %d %s from %s", len(values), plural(len(values), "global", "globals"), source.url)
+	}
+}
+
+// fetchRemote returns the data of a source, from the cache while it is fresh
+func (b *Builder) fetchRemote(source remoteSource) ([]byte, error) {
+	cacheFile := b.remoteCacheFile(source.name)
+	var cached []byte
+	if cacheFile != "" {
+		if info, err := os.Stat(cacheFile); err == nil {
+			cached, _ = os.ReadFile(cacheFile)
+			if cached != nil && time.Since(info.ModTime()) < source.cache {
+				return cached, nil
+			}
+		}
+	}
+
+	data, err := downloadRemote(source)
+	if err != nil {
+		if cached != nil {
+			b.diagnostics.Warn("", 0, "remote_data %s: %v, using the copy fetched before", source.name, err)
+			return cached, nil
+		}
+		return nil, err
+	}
+	if cacheFile != "" && !b.config.DryRun {
+		if err := os.MkdirAll(filepath.Dir(cacheFile), 0755); err == nil {
+			err = os.WriteFile(cacheFile, data, 0644)
+		}
+		if err != nil {
+			logging.Debugf("  Cannot cache remote_data %s: %v", source.name, err)
+		}
+	}
+	return data, nil
+}
+
+// remoteCacheFile returns where a source's data is cached, or "" outside a project
+func (b *Builder) remoteCacheFile(name string) string {
+	if b.config.ProjectDir == "" {
+		return ""
+	}
+	return filepath.Join(b.config.ProjectDir, filepath.FromSlash(remoteDataPath), url.PathEscape(name)+".data")
+}
+
+// downloadRemote fetches a source's URL
+func downloadRemote(source remoteSource) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, source.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if source.format == "json" {
+		req.Header.Set("Accept", "application/json")
+	}
+	if source.tokenEnv != "" {
+		token := os.Getenv(source.tokenEnv)
+		if token == "" {
+			return nil, fmt.Errorf("token_env %s is not set", source.tokenEnv)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", source.url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", source.url, err)
+	}
+	if len(data) > maxRemoteSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", source.url, maxRemoteSize)
+	}
+	return data, nil
+}
+
+// remoteValues turns a source's data into globals: the values its map names, or every
+// value, each under <name>. and its path. Objects and lists become one global per value
+// inside them, such as release.assets.0.name. The paths map names that the data lacks
+// are returned as missing.
+func remoteValues(source remoteSource, data []byte) (map[string]string, []string, error) {
+	var doc interface{}
+	var err error
+	if source.format == "yaml" {
+		err = yaml.Unmarshal(data, &doc)
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&doc)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading %s as %s: %w", source.url, source.format, err)
+	}
+
+	values := make(map[string]string)
+	if len(source.mapping) == 0 {
+		flattenRemote(source.name, doc, values)
+		return values, nil, nil
+	}
+	var missing []string
+	for _, m := range source.mapping {
+		value, ok := lookupRemote(doc, m[1])
+		if !ok {
+			missing = append(missing, m[1])
+			continue
+		}
+		flattenRemote(source.name+"."+m[0], value, values)
+	}
+	return values, missing, nil
+}
+
+// lookupRemote follows a dotted path such as assets.0.name into decoded data. A leading
+// $. is allowed, as in JSONPath.
+func lookupRemote(doc interface{}, fieldPath string) (interface{}, bool) {
+	fieldPath = strings.TrimPrefix(strings.TrimPrefix(fieldPath, "$"), ".")
+	if fieldPath == "" {
+		return doc, true
+	}
+	for _, key := range strings.Split(fieldPath, ".") {
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			doc = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			doc = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// flattenRemote adds value to values as name, or each value inside it as name.<key>. A
+// list also gets name.count. Values are escaped, as they are text from elsewhere, and
+// protected from variable and directive processing.
+func flattenRemote(name string, value interface{}, values map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			flattenRemote(name+"."+key, item, values)
+		}
+	case []interface{}:
+		for i, item := range v {
+			flattenRemote(name+"."+strconv.Itoa(i), item, values)
+		}
+		values[name+".count"] = strconv.Itoa(len(v))
+	case nil:
+		values[name] = ""
+	case float64:
+		values[name] = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		values[name] = parser.Literal(html.EscapeString(fmt.Sprintf("%v", v)))
+	}
+}
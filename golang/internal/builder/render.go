@@ -0,0 +1,151 @@
+package builder
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"sniplicity/internal/processor"
+	"sniplicity/internal/types"
+)
+
+// RenderFile runs the whole pipeline for a single source file, using the snippets,
+// templates and globals of the entire project, and returns the finished page without
+// writing anything. If source is non-nil the page content is read from it and path
+// only supplies the file name (and location, for relative includes).
+func (b *Builder) RenderFile(path string, source io.Reader) (string, error) {
+	fileInfo, err := b.loadSinglePage(path, source, nil)
+	if err != nil {
+		return "", err
+	}
+	return b.renderSinglePage(fileInfo, path)
+}
+
+// loadSinglePage collects the project's definitions and reads one page the way the build
+// does, calling trace, if set, after each step that can add to its metadata
+func (b *Builder) loadSinglePage(path string, source io.Reader, trace func(fileInfo *types.FileInfo, step string)) (*types.FileInfo, error) {
+	if trace == nil {
+		trace = func(*types.FileInfo, string) {}
+	}
+	inputDir := b.config.GetAbsoluteInputDir()
+	b.diagnostics.Reset()
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s: %w", path, err)
+	}
+
+	fileList, err := b.getFileList(inputDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get file list: %w", err)
+	}
+
+	if err := b.collectDefinitions(fileList); err != nil {
+		return nil, err
+	}
+
+	// Files outside the input directory render as if they were at its root
+	relPath, err := filepath.Rel(inputDir, filepath.Dir(absPath))
+	if err != nil || relPath == "." || strings.HasPrefix(relPath, "..") {
+		relPath = ""
+	}
+
+	filename := filepath.Base(absPath)
+	fileInfo := types.NewFileInfo(absPath, filename, types.IsMarkdownFile(filename))
+	fileInfo.OutputRelPath = relPath
+	fileInfo.Delimiters = b.delimiters
+	fileInfo.HeadingIDs = b.headingIDs
+
+	if source != nil {
+		err = fileInfo.LoadFromReader(source)
+	} else {
+		site := b.Site()
+		err = fileInfo.LoadWithTemplates(site.Templates, site.Globals)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	trace(fileInfo, traceFrontmatter)
+	b.defaults.Apply(fileInfo.Metadata, relPath)
+	trace(fileInfo, traceDefaults)
+	b.applyPageTemplate(fileInfo, relPath)
+	trace(fileInfo, tracePageTemplates)
+	b.applyPermalink(fileInfo, relPath, nil)
+	trace(fileInfo, tracePath)
+	return fileInfo, nil
+}
+
+// renderSinglePage runs a page read by loadSinglePage through the rest of the pipeline
+func (b *Builder) renderSinglePage(fileInfo *types.FileInfo, path string) (string, error) {
+	inputDir := b.config.GetAbsoluteInputDir()
+	site := b.Site()
+
+	// Same phase order as a full build
+	if err := b.processor.ProcessIncludes(fileInfo, inputDir, site.Globals); err != nil {
+		return "", fmt.Errorf("error processing includes: %w", err)
+	}
+	if err := b.processor.ProcessIndexCommands(fileInfo, inputDir, site.Templates, site.Snippets, site.Globals); err != nil {
+		return "", fmt.Errorf("error processing index commands: %w", err)
+	}
+	if err := b.processor.ProcessSnippets(fileInfo, site.Snippets); err != nil {
+		return "", fmt.Errorf("error processing snippets: %w", err)
+	}
+
+	page, err := b.processor.RenderPage(fileInfo, b.config.GetAbsoluteOutputDir(), site.Templates, site.Snippets, site.Globals, b.config.ImgSize)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	if err := b.buildError(); err != nil {
+		return "", err
+	}
+	return b.processor.FinishPage(processor.ResolveLastModified(page, b.lastModified)), nil
+}
+
+// renderEntry renders the content of a page for the index directives that list it with
+// render=true, the way the build renders the page but without its template. Index and
+// sitemap-page directives in it are left out, so listings can't render each other. Each
+// page is rendered once per build however many listings show it.
+func (b *Builder) renderEntry(inputPath string) (string, error) {
+	b.entriesMu.Lock()
+	body, ok := b.entries[inputPath]
+	b.entriesMu.Unlock()
+	if ok {
+		return body, nil
+	}
+
+	inputDir := b.config.GetAbsoluteInputDir()
+	relPath, err := filepath.Rel(inputDir, filepath.Dir(inputPath))
+	if err != nil || relPath == "." {
+		relPath = ""
+	}
+	fileInfo := types.NewFileInfo(inputPath, filepath.Base(inputPath), types.IsMarkdownFile(inputPath))
+	fileInfo.OutputRelPath = relPath
+	fileInfo.Delimiters = b.delimiters
+	fileInfo.HeadingIDs = b.headingIDs
+	site := b.Site()
+	if err := fileInfo.LoadWithTemplates(site.Templates, site.Globals); err != nil {
+		return "", err
+	}
+	b.defaults.Apply(fileInfo.Metadata, relPath)
+	b.applyPageTemplate(fileInfo, relPath)
+	b.applyPermalink(fileInfo, relPath, nil)
+
+	// The page reports its own problems when it is built
+	quiet := b.processor.Quiet()
+	if err := quiet.ProcessIncludes(fileInfo, inputDir, site.Globals); err != nil {
+		return "", err
+	}
+	if err := quiet.ProcessSnippets(fileInfo, site.Snippets); err != nil {
+		return "", err
+	}
+	_, body, err = quiet.RenderPageWithBody(fileInfo, b.config.GetAbsoluteOutputDir(), site.Templates, site.Snippets, site.Globals, false)
+	if err != nil {
+		return "", err
+	}
+
+	b.entriesMu.Lock()
+	b.entries[inputPath] = body
+	b.entriesMu.Unlock()
+	return body, nil
+}
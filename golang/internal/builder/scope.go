@@ -0,0 +1,167 @@
+package builder
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"sniplicity/internal/config"
+	"sniplicity/internal/diag"
+	"sniplicity/internal/logging"
+	"sniplicity/internal/types"
+	"sniplicity/internal/web"
+
+	"github.com/fatih/color"
+)
+
+// BuildScope selects how much of the site a build makes. The zero value, like Full,
+// builds everything.
+type BuildScope struct {
+	Full       bool
+	Paths      []string // Pages to build, relative to the input directory
+	AssetsOnly bool     // Copy assets and bundle JavaScript without building pages
+	Only       []string // Source paths whose pages and assets are built, like the only setting
+}
+
+// partial reports whether the scope leaves part of the site as the last build made it
+func (s BuildScope) partial() bool {
+	return !s.Full && (len(s.Paths) > 0 || s.AssetsOnly || len(s.Only) > 0)
+}
+
+// includesAssets reports whether the scope copies assets
+func (s BuildScope) includesAssets() bool {
+	return !s.partial() || s.AssetsOnly || len(s.Only) > 0
+}
+
+// includes reports whether the scope builds the source at relPath, relative to the input
+// directory. A path ending in a slash, or without wildcards, covers everything below it.
+func (s BuildScope) includes(relPath string) bool {
+	if len(s.Only) == 0 {
+		return true
+	}
+	relPath = filepath.ToSlash(relPath)
+	for _, only := range s.Only {
+		only = strings.TrimPrefix(filepath.ToSlash(only), "/")
+		dir := strings.TrimSuffix(only, "/")
+		if relPath == dir || strings.HasPrefix(relPath, dir+"/") || config.MatchPattern(only, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// String describes the scope for logs and the build API
+func (s BuildScope) String() string {
+	switch {
+	case !s.partial():
+		return "full"
+	case s.AssetsOnly:
+		return "assets"
+	case len(s.Only) > 0:
+		return "only " + strings.Join(s.Only, ", ")
+	}
+	return "paths"
+}
+
+// Rebuild builds the site, or the part of it scope selects, and returns the diagnostics
+// of that build. Builds run one at a time, so a request made during a build waits for it.
+// A partial build needs a full one to have run first and makes one instead otherwise.
+func (b *Builder) Rebuild(scope BuildScope) ([]diag.Diagnostic, error) {
+	err := b.doScopedBuild(scope)
+	return b.diagnostics.Items(), err
+}
+
+// buildRequest runs a build for the web interface's build API. A full build reads
+// sniplicity.yaml again first, like one started by a change, in case the change was missed.
+func (b *Builder) buildRequest(req web.BuildRequest) ([]diag.Diagnostic, error) {
+	scope := BuildScope{Full: req.Full, Paths: req.Paths, AssetsOnly: req.AssetsOnly}
+	if !scope.partial() {
+		b.reloadConfig()
+	}
+	return b.Rebuild(scope)
+}
+
+// keepOutputs starts a partial build from the outputs of the last build, which the files
+// it writes are compared with and added to
+func (b *Builder) keepOutputs() {
+	b.outputsMu.Lock()
+	defer b.outputsMu.Unlock()
+	b.previous = make(map[string]string, len(b.hashes))
+	for path, hash := range b.hashes {
+		b.previous[b.outputRel(path)] = hash
+	}
+}
+
+// selectPages narrows the loaded pages down to the ones scope builds
+func (b *Builder) selectPages(scope BuildScope) error {
+	if !scope.partial() {
+		return nil
+	}
+	if scope.AssetsOnly {
+		b.files = nil
+		return nil
+	}
+	if len(scope.Only) > 0 {
+		selected := b.files[:0]
+		for _, fileInfo := range b.files {
+			if scope.includes(b.inputRel(fileInfo.InputPath)) {
+				selected = append(selected, fileInfo)
+			}
+		}
+		b.files = selected
+		if len(b.files) == 0 {
+			logging.Warnf("No pages under %s", strings.Join(scope.Only, ", "))
+		}
+		return nil
+	}
+
+	byPath := make(map[string]*types.FileInfo, len(b.files))
+	for _, fileInfo := range b.files {
+		byPath[b.inputRel(fileInfo.InputPath)] = fileInfo
+	}
+	selected := make([]*types.FileInfo, 0, len(scope.Paths))
+	for _, path := range scope.Paths {
+		rel := filepath.ToSlash(filepath.Clean(filepath.FromSlash(strings.TrimPrefix(path, "/"))))
+		fileInfo, ok := byPath[rel]
+		if !ok {
+			return fmt.Errorf("%s is not a page in %s", path, b.config.GetAbsoluteInputDir())
+		}
+		selected = append(selected, fileInfo)
+	}
+	b.files = selected
+	return nil
+}
+
+// finishPartialBuild records a partial build in the manifest, which still lists the
+// outputs of the rest of the site
+func (b *Builder) finishPartialBuild(scope BuildScope) error {
+	if b.config.DryRun {
+		b.reportChanges()
+		return nil
+	}
+	if b.config.Integrity {
+		if err := b.writeIntegrity(); err != nil {
+			return err
+		}
+	}
+	if err := b.writeManifest(); err != nil {
+		b.diagnostics.Warn(b.manifestFile(), 0, "cannot write build manifest: %v", err)
+	}
+	b.summary.endPhase("Manifest")
+	if b.config.Diagnostics == "json" {
+		return nil
+	}
+
+	green := color.New(color.FgGreen, color.Bold)
+	switch {
+	case b.config.BuildSummary && scope.AssetsOnly:
+		b.printSummary("Copied assets")
+	case b.config.BuildSummary:
+		b.printSummary("Compiled")
+	case scope.AssetsOnly:
+		logging.Infof("%s assets to %s, %s", green.Sprint("Copied"), color.CyanString(b.config.GetAbsoluteOutputDir()), b.writeSummary())
+	default:
+		logging.Infof("%s %d page(s) to %s, %s", green.Sprint("Compiled"), len(b.files), color.CyanString(b.config.GetAbsoluteOutputDir()), b.writeSummary())
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sniplicity/internal/builtin"
+	"sniplicity/internal/logging"
+	"sniplicity/internal/plaintext"
+	"sniplicity/internal/types"
+)
+
+// searchIndexFile is the file search_index writes to the output directory
+const searchIndexFile = "search-index.json"
+
+// searchSnippet is the snippet that pastes the built-in search box, unless the site
+// defines its own snippet of that name
+const searchSnippet = "search"
+
+var (
+	// searchHeadingRegex matches a heading with its content
+	searchHeadingRegex = regexp.MustCompile(`(?is)<h[1-6]\b[^>]*>(.*?)</h[1-6]\s*>`)
+
+	// searchTitleRegex matches the title of a page
+	searchTitleRegex = regexp.MustCompile(`(?is)<title\b[^>]*>(.*?)</title\s*>`)
+)
+
+// searchEntry is one page of the search index, in a shape Lunr and Fuse can index as it is
+type searchEntry struct {
+	Title    string   `json:"title"`
+	URL      string   `json:"url"`
+	Lang     string   `json:"lang,omitempty"`
+	Headings []string `json:"headings,omitempty"`
+	Content  string   `json:"content"`
+}
+
+// addSearchEntry adds a written page to the search index from its rendered HTML and its
+// body without the template, so navigation and footers don't turn up in every search.
+// Pages that aren't HTML, set search: false in their frontmatter or are the 404 page are
+// left out.
+func (b *Builder) addSearchEntry(fileInfo *types.FileInfo, page, body string) {
+	if !b.config.SearchIndex {
+		return
+	}
+	if excludedFromSearch(fileInfo.Metadata) || b.isNotFoundPage(fileInfo) {
+		return
+	}
+	ext := strings.ToLower(filepath.Ext(fileInfo.GetOutputPath(b.config.GetAbsoluteOutputDir())))
+	if ext != ".html" && ext != ".htm" {
+		return
+	}
+
+	entry := searchEntry{URL: prettyURL(b.pageURL(fileInfo))}
+	entry.Lang, _ = fileInfo.Metadata["lang"].(string)
+	for _, match := range searchHeadingRegex.FindAllStringSubmatch(body, -1) {
+		if heading := searchText(match[1]); heading != "" {
+			entry.Headings = append(entry.Headings, heading)
+		}
+	}
+	entry.Title, _ = fileInfo.Metadata["title"].(string)
+	if entry.Title == "" {
+		if match := searchTitleRegex.FindStringSubmatch(page); match != nil {
+			entry.Title = searchText(match[1])
+		}
+	}
+	if entry.Title == "" && len(entry.Headings) > 0 {
+		entry.Title = entry.Headings[0]
+	}
+	entry.Content = searchText(body)
+	b.searchEntries = append(b.searchEntries, entry)
+}
+
+// excludedFromSearch reports whether a page's frontmatter sets search: false
+func excludedFromSearch(metadata map[string]interface{}) bool {
+	switch v := metadata["search"].(type) {
+	case bool:
+		return !v
+	case string:
+		v = strings.ToLower(strings.TrimSpace(v))
+		return v == "false" || v == "no"
+	}
+	return false
+}
+
+// searchText returns the text of an HTML fragment on one line
+func searchText(fragment string) string {
+	return strings.Join(strings.Fields(plaintext.FromHTML(fragment)), " ")
+}
+
+// writeSearchIndex writes the search index of the pages this build wrote, by URL
+func (b *Builder) writeSearchIndex() error {
+	entries := b.searchEntries
+	if entries == nil {
+		entries = []searchEntry{}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].URL < entries[j].URL })
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", searchIndexFile, err)
+	}
+	path := filepath.Join(b.config.GetAbsoluteOutputDir(), searchIndexFile)
+	if err := b.writeFile(path, data); err != nil {
+		return fmt.Errorf("cannot write %s: %w", path, err)
+	}
+	b.recordOutput("", path)
+	logging.Debugf("  Indexed %d pages for search in %s", len(entries), path)
+	return nil
+}
+
+// addSearchSnippet makes the built-in search box available as <!-- paste search -->,
+// unless the site has a search snippet of its own
+func (b *Builder) addSearchSnippet(site *SiteState) error {
+	if !b.config.SearchIndex {
+		return nil
+	}
+	if _, exists := site.Snippets[searchSnippet]; exists {
+		return nil
+	}
+	markup, err := b.builtinTemplates().Render(builtin.SearchBox, builtin.Search{Index: "/" + searchIndexFile})
+	if err != nil {
+		return err
+	}
+	site.Snippets[searchSnippet] = strings.Split(strings.TrimRight(string(markup), "\n"), "\n")
+	return nil
+}
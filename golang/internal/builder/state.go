@@ -0,0 +1,28 @@
+package builder
+
+// SiteState is the snippets, templates and globals a build collected from the project.
+// It is made once per build and not changed after, so pages can be rendered from it
+// in parallel and the preview and render endpoints can read it while the next build
+// collects its own.
+type SiteState struct {
+	Snippets  map[string][]string
+	Templates map[string][]string
+	Globals   map[string]string
+}
+
+// newSiteState returns an empty SiteState to collect a build's definitions in
+func newSiteState() *SiteState {
+	return &SiteState{
+		Snippets:  make(map[string][]string),
+		Templates: make(map[string][]string),
+		Globals:   make(map[string]string),
+	}
+}
+
+// Site returns the definitions of the last build, empty before the first one
+func (b *Builder) Site() *SiteState {
+	if site := b.site.Load(); site != nil {
+		return site
+	}
+	return newSiteState()
+}
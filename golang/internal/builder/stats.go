@@ -0,0 +1,77 @@
+package builder
+
+import (
+	"time"
+
+	"sniplicity/internal/config"
+	"sniplicity/internal/logging"
+	"sniplicity/internal/stats"
+	"sniplicity/internal/types"
+)
+
+// buildFeatures lists the optional features the current build uses
+func (b *Builder) buildFeatures() []string {
+	var features []string
+	add := func(used bool, name string) {
+		if used {
+			features = append(features, name)
+		}
+	}
+
+	add(b.config.Watch, "watch")
+	add(b.config.Serve, "serve")
+	add(b.config.Serve && b.config.UsesTLS(), "tls")
+	add(b.config.Watch && b.config.WatchPollInterval() > 0, "watch_poll")
+	add(b.config.ImgSize, "imgsize")
+	add(b.config.SvgFilter, "svgfilter")
+	add(len(b.config.Permalinks) > 0, "permalinks")
+	add(len(b.config.Languages) > 0, "languages")
+	add(len(b.config.PageTemplates) > 0, "page_templates")
+	add(b.config.SEO, "seo")
+	add(b.config.SearchIndex, "search_index")
+	add(b.config.NormalizeWhitespace, "normalize_whitespace")
+	add(b.config.RulesFormat() == config.FormatNetlify, "netlify_redirects")
+	add(b.config.RulesFormat() == config.FormatVercel, "vercel_rules")
+	add(b.config.RulesFormat() == config.FormatApache, "htaccess_rules")
+	add(len(b.config.RedirectRules) > 0, "redirect_rules")
+	add(len(b.config.Headers) > 0, "headers")
+	add(b.config.CheckLinks, "check_links")
+	add(b.config.Strict, "strict")
+	add(b.config.CleanOutput, "clean_output")
+	add(b.config.Integrity, "integrity")
+	add(b.config.JSEntry != "", "js_bundle")
+	add(b.config.InlineAssets > 0, "inline_assets")
+	site := b.Site()
+	add(len(site.Templates) > 0, "templates")
+	add(len(site.Snippets) > 0, "snippets")
+	add(len(site.Globals) > 0, "globals")
+	add(b.config.GlobalsFile != "", "globals_file")
+
+	markdown := false
+	for _, fileInfo := range b.files {
+		if types.IsMarkdownFile(fileInfo.InputPath) {
+			markdown = true
+			break
+		}
+	}
+	add(markdown, "markdown")
+
+	return features
+}
+
+// recordStats adds a finished build to the local stats file when stats are enabled
+func (b *Builder) recordStats(start time.Time, buildErr error) {
+	if !b.config.Stats {
+		return
+	}
+
+	err := stats.Record(stats.Build{
+		Duration: time.Since(start),
+		Failed:   buildErr != nil,
+		Pages:    len(b.files),
+		Features: b.buildFeatures(),
+	})
+	if err != nil {
+		logging.Debugf("Could not record build stats: %v", err)
+	}
+}
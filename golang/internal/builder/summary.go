@@ -0,0 +1,170 @@
+package builder
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+
+	"sniplicity/internal/diag"
+	"sniplicity/internal/logging"
+	"sniplicity/internal/types"
+)
+
+// summarySlowestPages is how many pages the build summary lists as the slowest
+const summarySlowestPages = 5
+
+// summaryPhase is one phase of a build and how long it took
+type summaryPhase struct {
+	name     string
+	duration time.Duration
+}
+
+// buildSummary collects what the build_summary table shows while a build runs
+type buildSummary struct {
+	mu        sync.Mutex
+	start     time.Time
+	mark      time.Time                         // When the last phase ended
+	phases    []summaryPhase                    // Phases in the order they ran
+	pages     map[*types.FileInfo]time.Duration // Time spent on each page over all its steps
+	copied    int                               // Assets written by the build
+	unchanged int                               // Assets left alone as their content was the same
+}
+
+// newBuildSummary starts collecting the summary of a build that started at start
+func newBuildSummary(start time.Time) *buildSummary {
+	return &buildSummary{start: start, mark: start, pages: make(map[*types.FileInfo]time.Duration)}
+}
+
+// endPhase records the time since the last phase ended as the time of the named phase
+func (s *buildSummary) endPhase(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.phases = append(s.phases, summaryPhase{name: name, duration: now.Sub(s.mark)})
+	s.mark = now
+}
+
+// addPageTime adds the time one step took to a page's total
+func (s *buildSummary) addPageTime(fileInfo *types.FileInfo, duration time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pages[fileInfo] += duration
+}
+
+// setAssets records how many assets the build copied and how many were unchanged
+func (s *buildSummary) setAssets(copied, unchanged int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.copied, s.unchanged = copied, unchanged
+}
+
+// writeCounts returns how many output files the build has written and left alone so far
+func (b *Builder) writeCounts() (written, skipped int) {
+	b.outputsMu.Lock()
+	defer b.outputsMu.Unlock()
+	return b.written, b.skipped
+}
+
+// countAssets runs copy, the build's asset step, and records for the build summary how
+// many files it wrote and left alone
+func (b *Builder) countAssets(copy func() error) error {
+	written, skipped := b.writeCounts()
+	err := copy()
+	afterWritten, afterSkipped := b.writeCounts()
+	b.summary.setAssets(afterWritten-written, afterSkipped-skipped)
+	return err
+}
+
+// printSummary prints the build_summary table of the build that just finished
+func (b *Builder) printSummary(title string) {
+	s := b.summary
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total := time.Since(s.start)
+
+	bold := color.New(color.Bold)
+	green := color.New(color.FgGreen, color.Bold)
+	cyan := color.New(color.FgCyan)
+	count := func(n int, c *color.Color) string {
+		if n == 0 {
+			return fmt.Sprint(n)
+		}
+		return c.Sprint(n)
+	}
+
+	logging.Infof("%s from %s to %s, %s", green.Sprint(title), cyan.Sprint(b.config.GetAbsoluteInputDir()), cyan.Sprint(b.config.GetAbsoluteOutputDir()), b.writeSummary())
+	rows := [][2]string{
+		{"Pages built", count(len(b.files), color.New(color.FgGreen))},
+		{"Assets copied", fmt.Sprintf("%s, %d unchanged", count(s.copied, color.New(color.FgGreen)), s.unchanged)},
+		{"Warnings", count(b.diagnostics.Count(diag.Warning), color.New(color.FgYellow))},
+		{"Errors", count(b.diagnostics.Count(diag.Error), color.New(color.FgRed))},
+	}
+	for _, row := range rows {
+		logging.Infof("  %-14s %s", row[0], row[1])
+	}
+
+	// Phases that took most of the build stand out
+	logging.Infof("  %s", bold.Sprint("Phase"))
+	width := len("Total")
+	for _, phase := range s.phases {
+		width = max(width, len(phase.name))
+	}
+	for _, phase := range s.phases {
+		share := 0.0
+		if total > 0 {
+			share = float64(phase.duration) / float64(total)
+		}
+		shade := cyan
+		switch {
+		case share >= 0.5:
+			shade = color.New(color.FgRed)
+		case share >= 0.2:
+			shade = color.New(color.FgYellow)
+		}
+		logging.Infof("    %-*s %s %s", width, phase.name, shade.Sprintf("%9s", roundDuration(phase.duration)),
+			shade.Sprintf("%3.0f%% %s", share*100, strings.Repeat("█", int(share*20+0.5))))
+	}
+	logging.Infof("    %-*s %s", width, "Total", bold.Sprintf("%9s", roundDuration(total)))
+
+	if len(s.pages) == 0 {
+		return
+	}
+	pages := make([]*types.FileInfo, 0, len(s.pages))
+	for fileInfo := range s.pages {
+		pages = append(pages, fileInfo)
+	}
+	sort.Slice(pages, func(i, j int) bool {
+		if s.pages[pages[i]] != s.pages[pages[j]] {
+			return s.pages[pages[i]] > s.pages[pages[j]]
+		}
+		return pages[i].InputPath < pages[j].InputPath
+	})
+	if len(pages) > summarySlowestPages {
+		pages = pages[:summarySlowestPages]
+	}
+	logging.Infof("  %s", bold.Sprint("Slowest pages"))
+	for _, fileInfo := range pages {
+		name := fileInfo.InputPath
+		if rel, err := filepath.Rel(b.config.GetAbsoluteInputDir(), name); err == nil {
+			name = filepath.ToSlash(rel)
+		}
+		logging.Infof("    %s %s", cyan.Sprintf("%9s", roundDuration(s.pages[fileInfo])), name)
+	}
+}
+
+// roundDuration rounds a duration to what is worth showing in the build summary
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(100 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
@@ -0,0 +1,197 @@
+package buildlog
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"sniplicity/internal/diag"
+	"sniplicity/internal/logging"
+)
+
+// MaxLines is how many log lines are kept, the oldest being dropped first
+const MaxLines = 2000
+
+// States of a build
+const (
+	Idle      = "idle"      // No build has run yet
+	Building  = "building"  // A build is running
+	Succeeded = "succeeded" // The last build finished without errors
+	Failed    = "failed"    // The last build failed
+)
+
+// Status describes the running or last build
+type Status struct {
+	State      string    `json:"state"`
+	Build      int       `json:"build"` // Number of the build, counting from 1 since sniplicity started
+	Scope      string    `json:"scope,omitempty"`
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished"` // Zero while building
+	DurationMS int64     `json:"duration_ms"`
+	Errors     int       `json:"errors"`
+	Warnings   int       `json:"warnings"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Line is one message printed while sniplicity works
+type Line struct {
+	Seq   int       `json:"seq"`   // Position in the log, counting from 1
+	Build int       `json:"build"` // Build that was running or had last run when it was printed
+	Level string    `json:"level"` // error, warn, info or debug
+	Text  string    `json:"text"`
+	Time  time.Time `json:"time"`
+}
+
+// Event is a change to the log sent to subscribers: either a new line or a new status
+type Event struct {
+	Line   *Line
+	Status *Status
+}
+
+// Log keeps the status of the builds and the messages printed during them. It is safe
+// for concurrent use.
+type Log struct {
+	mu          sync.Mutex
+	status      Status
+	lines       []Line
+	seq         int
+	subscribers map[chan Event]struct{}
+}
+
+// ansi matches the colour codes of terminal output
+var ansi = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// New returns an empty log
+func New() *Log {
+	return &Log{
+		status:      Status{State: Idle},
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Start records that a build of the given scope began
+func (l *Log) Start(scope string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.status = Status{
+		State:   Building,
+		Build:   l.status.Build + 1,
+		Scope:   scope,
+		Started: time.Now(),
+	}
+	l.publish(Event{Status: l.copyStatus()})
+}
+
+// Finish records the outcome of the running build. Its diagnostics are added to the log,
+// as they are printed separately from other messages.
+func (l *Log) Finish(diagnostics []diag.Diagnostic, err error) {
+	for _, d := range diagnostics {
+		level := logging.Warn
+		if d.Level == diag.Error {
+			level = logging.Error
+		}
+		l.Add(level, d.String())
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.status.State = Succeeded
+	l.status.Finished = time.Now()
+	l.status.DurationMS = l.status.Finished.Sub(l.status.Started).Milliseconds()
+	for _, d := range diagnostics {
+		switch d.Level {
+		case diag.Error:
+			l.status.Errors++
+		case diag.Warning:
+			l.status.Warnings++
+		}
+	}
+	if err != nil {
+		l.status.State = Failed
+		l.status.Error = err.Error()
+	}
+	l.publish(Event{Status: l.copyStatus()})
+}
+
+// Add appends a message to the log, without its terminal colours. It fits logging.SetHook.
+func (l *Log) Add(level logging.Level, text string) {
+	text = strings.TrimRight(ansi.ReplaceAllString(text, ""), "\n")
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	line := Line{Seq: l.seq, Build: l.status.Build, Level: level.String(), Text: text, Time: time.Now()}
+	l.lines = append(l.lines, line)
+	if len(l.lines) > MaxLines {
+		l.lines = append([]Line(nil), l.lines[len(l.lines)-MaxLines:]...)
+	}
+	l.publish(Event{Line: &line})
+}
+
+// Status returns the status of the running or last build
+func (l *Log) Status() Status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.status
+}
+
+// Lines returns the kept lines printed during the given build, or since the last one
+// started when build is 0
+func (l *Log) Lines(build int) []Line {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.linesOf(build)
+}
+
+// Subscribe returns the status, the lines of the current build and a channel of the
+// changes that follow, until cancel is called. A subscriber that falls behind misses
+// events rather than holding up the build.
+func (l *Log) Subscribe() (Status, []Line, <-chan Event, func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events := make(chan Event, 256)
+	l.subscribers[events] = struct{}{}
+	cancel := func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, ok := l.subscribers[events]; ok {
+			delete(l.subscribers, events)
+			close(events)
+		}
+	}
+	return l.status, l.linesOf(0), events, cancel
+}
+
+// linesOf returns the lines of a build, the current one for 0. l.mu must be held.
+func (l *Log) linesOf(build int) []Line {
+	if build == 0 {
+		build = l.status.Build
+	}
+	lines := []Line{}
+	for _, line := range l.lines {
+		if line.Build == build {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// copyStatus returns a copy of the status to send to subscribers. l.mu must be held.
+func (l *Log) copyStatus() *Status {
+	status := l.status
+	return &status
+}
+
+// publish sends an event to every subscriber with room for it. l.mu must be held.
+func (l *Log) publish(event Event) {
+	for events := range l.subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package builtin
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaults holds the markup used unless a project overrides it
+//
+//go:embed templates
+var defaults embed.FS
+
+// Names lists the templates of the pages and fragments sniplicity generates itself
+var Names = []string{RedirectPage, ListingPage, NotFoundPage, SitemapList, SearchBox}
+
+const (
+	RedirectPage = "redirect.html" // Stub written at a moved page's old URL
+	ListingPage  = "listing.html"  // Folder listing shown by the preview server
+	NotFoundPage = "404.html"      // Page for missing paths, written when the site has no 404.html or 404 template
+	SitemapList  = "sitemap.html"  // Wrapper around the list a sitemap-page directive writes
+	SearchBox    = "search.html"   // The search snippet of sites with a search index
+)
+
+// Redirect is the data of RedirectPage
+type Redirect struct {
+	Target string // URL visitors are sent on to
+}
+
+// Listing is the data of ListingPage
+type Listing struct {
+	Path    string // URL path of the folder, ending in a slash
+	Parent  string // URL path of the folder above, "" at the root
+	Entries []ListingEntry
+}
+
+// ListingEntry is one file or folder in a Listing
+type ListingEntry struct {
+	Name     string
+	Href     string // Relative to the listed folder, ending in a slash for folders
+	Dir      bool
+	Size     int64
+	Modified time.Time
+}
+
+// NotFound is the data of NotFoundPage
+type NotFound struct {
+	Path string // URL path that was asked for, "" for the 404.html a build writes
+}
+
+// Sitemap is the data of SitemapList
+type Sitemap struct {
+	Items template.HTML // The rendered <li> items of the site's pages and folders
+}
+
+// Search is the data of SearchBox
+type Search struct {
+	Index string // URL of the search index
+}
+
+// Templates renders the built-in pages, preferring a project's own versions
+type Templates struct {
+	dir string
+}
+
+// New returns templates that are read from dir when it holds a file of the same name,
+// and embedded defaults otherwise. dir may be "" for the defaults only.
+func New(dir string) *Templates {
+	return &Templates{dir: dir}
+}
+
+// Render executes the named template with data. Overrides are read every time, so edits
+// show up without a restart.
+func (t *Templates) Render(name string, data interface{}) ([]byte, error) {
+	tmpl, err := t.parse(name)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, fmt.Errorf("rendering %s: %w", name, err)
+	}
+	return out.Bytes(), nil
+}
+
+// Source returns the markup of the named template: the project's override when there is
+// one, otherwise the default
+func (t *Templates) Source(name string) ([]byte, error) {
+	if t.dir != "" {
+		data, err := os.ReadFile(filepath.Join(t.dir, name))
+		if err == nil {
+			return data, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+	}
+	data, err := defaults.ReadFile("templates/" + name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown built-in template %s", name)
+	}
+	return data, nil
+}
+
+// parse reads and parses the named template
+func (t *Templates) parse(name string) (*template.Template, error) {
+	source, err := t.Source(name)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(name).Funcs(funcs).Parse(string(source))
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// funcs are the helpers available to the templates
+var funcs = template.FuncMap{
+	"size": formatSize,
+}
+
+// formatSize returns a file size for people to read, e.g. 1.5 KB
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, prefix := float64(size)/unit, 0
+	for value >= unit && prefix < 3 {
+		value /= unit
+		prefix++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "KMGT"[prefix])
+}
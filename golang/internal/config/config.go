@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"time"
+
+	"sniplicity/internal/logging"
 
 	"gopkg.in/yaml.v3"
 )
@@ -18,24 +22,149 @@ type Config struct {
 	Verbose    bool     `yaml:"verbose"`    // Whether to enable verbose logging
 	Serve      bool     `yaml:"serve"`      // Whether to serve files via HTTP
 	Port       int      `yaml:"port"`       // Port for HTTP server
+	Host       string   `yaml:"host"`       // Address the HTTP server listens on, e.g. 0.0.0.0 for every network
+	TLS        bool     `yaml:"tls"`        // Whether the web server uses HTTPS
+	TLSCert    string   `yaml:"tls_cert"`   // Certificate file for HTTPS, relative to the project directory (default: a self-signed one)
+	TLSKey     string   `yaml:"tls_key"`    // Private key file of TLSCert
+	Auth       map[string]string `yaml:"auth"` // Credentials the web server asks for: username and password, or token
+	OpenBrowser bool    `yaml:"open_browser"` // Whether the web server's URL may be opened in a browser when it starts
+	Clipboard  bool     `yaml:"clipboard"`  // Whether the web server's URL is copied to the clipboard when it starts
 	ImgSize    bool     `yaml:"imgsize"`    // Whether to add width/height attributes to images
 	SvgFilter  bool     `yaml:"svgfilter"`  // Whether to process SVG files with CSS filters
+	MaxDepth   int      `yaml:"max_depth"`  // Directory depth beyond which a warning is printed
+	Permalinks map[string]string `yaml:"permalinks"` // Output URL patterns by source directory
+	PageTemplates map[string]string `yaml:"page_templates"` // Templates of markdown pages that don't name one, by source path pattern
+	Redirects  string   `yaml:"redirects"`  // How frontmatter aliases are emitted: "html" stubs or a "netlify" _redirects file
+	CheckLinks bool     `yaml:"check_links"` // Whether to report broken internal links after each build
+	Strict     bool     `yaml:"strict"`     // Whether missing snippets, templates, variables and includes fail the build
+	Stats      bool     `yaml:"stats"`      // Whether to keep local build statistics (never sent anywhere)
+	Drafts     bool     `yaml:"drafts"`     // Whether pages marked draft: true are built and listed
+	PlainText  bool     `yaml:"plain_text"` // Whether markdown pages also get a .txt plain text version
+	SearchIndex bool    `yaml:"search_index"` // Whether full builds write search-index.json for client-side search
+	NormalizeWhitespace bool `yaml:"normalize_whitespace"` // Whether pages are written with trailing whitespace trimmed and one final newline
+	Only       []string `yaml:"only"`       // Source paths the build is limited to, e.g. "blog/", empty for the whole site
+	CleanOutput bool    `yaml:"clean_output"` // Whether output files the build no longer writes are removed after each build
+	Integrity  bool     `yaml:"integrity"`  // Whether each build writes integrity.json with the checksum of every output file
+	PageTimeout int     `yaml:"page_timeout"`  // Seconds one page may take in each processing step before it is failed, 0 for no limit
+	MaxPageSize int     `yaml:"max_page_size"` // Megabytes a page may grow to while processing before it is failed, 0 for no limit
+	JSEntry    string   `yaml:"js_entry"`   // Script bundled and minified with esbuild, relative to the input directory
+	JSBundle   string   `yaml:"js_bundle"`  // Where the bundle is written, relative to the output directory (default: same as JSEntry)
+	JSTarget   string   `yaml:"js_target"`  // esbuild target for older browsers, e.g. "es2015"
+	Esbuild    string   `yaml:"esbuild"`    // Path to the esbuild executable (default: esbuild on the PATH)
+	InlineAssets int    `yaml:"inline_assets"` // Images and fonts up to this many bytes are inlined as data URIs, 0 to switch off
+	Env        string   `yaml:"env"`        // Build environment, e.g. "development" or "production"
+	ProductionExclude []string `yaml:"production_exclude"` // Extra file patterns left out of production builds
+	WatchIgnore []string `yaml:"watch_ignore"` // File patterns whose changes don't trigger a rebuild
+	WatchMode  string   `yaml:"watch_mode"` // How changes are noticed: "events" from the file system or "poll" to scan for them
+	PollInterval int    `yaml:"poll_interval"` // Milliseconds between scans with watch_mode: poll
+	GlobalsFile string  `yaml:"globals_file"` // YAML file of globals shared with other projects, relative to the project directory
+	Delimiters string   `yaml:"delimiters"` // Marks around variable references, e.g. "[[ ]]", empty for "{{ }}"
+	BuiltinTemplates string `yaml:"builtin_templates"` // Folder of overrides for the pages sniplicity generates itself, relative to the project directory
+	Lint       map[string]string `yaml:"lint"` // Level of each lint rule by name: "off", "warning" or "error"
+	LogLevel   string   `yaml:"log_level"`  // How much is printed: "error", "warn", "info" or "debug", empty for the default
+	DateFormats []string `yaml:"date_formats"` // More frontmatter date formats, written like strftime, e.g. "%d.%m.%Y"
+	DateFormat string   `yaml:"dateformat"` // How {{name | dateformat}} shows dates, written like strftime, empty for "%B %-d, %Y"
+	Timezone   string   `yaml:"timezone"`   // Time zone of dates without an offset, e.g. "Europe/Berlin", empty for UTC
+	Languages  []string `yaml:"languages"`  // Languages of a multilingual site, the default first, e.g. ["en", "de"]
+	FallbackLanguage string `yaml:"fallback_language"` // Language whose strings stand in for missing translations, empty for the first of Languages
+	SiteURL    string   `yaml:"site_url"`   // Address the site is published at, e.g. "https://example.com", for links that must be absolute
+	SEO        bool     `yaml:"seo"`        // Whether pages without a seo directive get its meta tags before their </head>
+	Deploy     map[string]string `yaml:"deploy"` // How the built site is published: a shell command, an rsync destination, an S3 bucket, a git branch or an FTP or SFTP server
+	DeployFormat string `yaml:"deploy_format"` // Host whose redirect and header files are written: "netlify", "vercel" or "apache", empty for HTML redirect pages
+	RedirectRules map[string]string `yaml:"redirect_rules"` // Redirects by old URL, to the new URL and an optional status
+	Headers    map[string]string `yaml:"headers"` // Response headers by URL pattern, one "Name: value" per line
+	RemoteData map[string]map[string]string `yaml:"remote_data"` // Data fetched at build time for pages to use as globals, by name: url, format, cache, map and token_env
+	HeadingIDs map[string]string `yaml:"heading_ids"` // How markdown headings get their IDs: prefix, transliterate and duplicates
+	GitInfo    bool     `yaml:"git_info"`   // Whether pages get {{git.lastmod}} and {{git.author}} from the last commit of their source
+	BuildSummary bool   `yaml:"build_summary"` // Whether builds end with a table of counts, phase times and the slowest pages
+	Globals    map[string]string `yaml:"globals"` // Globals for every page, which global directives in the sources override
+	Minify     bool     `yaml:"minify"`     // Whether pages, stylesheets and the js_entry bundle are minified
+	DryRun     bool     `yaml:"-"`          // Whether to report what a build would change without writing anything (a per-run choice, not saved to YAML)
+	NoColor    bool     `yaml:"-"`          // Whether to leave out ANSI colours even on a terminal (a per-run choice, not saved to YAML)
+	Diagnostics string  `yaml:"-"`          // How build warnings are printed: "text" or "json" (a per-run choice, not saved to YAML)
 	LegacyMode bool     `yaml:"-"`          // Whether running in legacy mode (not saved to YAML)
+	envRefs    map[string]envRef // Settings written with ${NAME} in sniplicity.yaml, by key path, which saving keeps
+	settings   *yaml.Node   // Settings of sniplicity.yaml without environments, which saving keeps where an environment overrides them
+	environments *yaml.Node // Overrides of each environment as sniplicity.yaml has them, nil without any
+	environment *environment // Overrides applied for the current environment, nil for none
 }
 
-// ConfigFile represents the structure of the configuration file on disk
+// ConfigFile represents the structure of the configuration file on disk. The desc, enum,
+// min and max tags feed the JSON Schema and validation in schema.go.
 type ConfigFile struct {
-	Name      string   `yaml:"name"`
-	InputDir  string   `yaml:"input_dir"`
-	OutputDir string   `yaml:"output_dir"`
-	Watch     bool     `yaml:"watch"`
-	Verbose   bool     `yaml:"verbose"`
-	Serve     bool     `yaml:"serve"`
-	Port      int      `yaml:"port"`
-	ImgSize   *bool    `yaml:"imgsize,omitempty"`   // Pointer to handle optional field
-	SvgFilter *bool    `yaml:"svgfilter,omitempty"` // Pointer to handle optional field
+	Name      string   `yaml:"name" desc:"Friendly name for the project"`
+	InputDir  string   `yaml:"input_dir" desc:"Source folder, relative to the project (default: snip)"`
+	OutputDir string   `yaml:"output_dir" desc:"Folder the site is built into, relative to the project (default: www)"`
+	Watch     bool     `yaml:"watch" desc:"Rebuild when source files change"`
+	Verbose   bool     `yaml:"verbose" desc:"Print extra console messages"`
+	Serve     bool     `yaml:"serve" desc:"Start the web server (enables watch)"`
+	Port      int      `yaml:"port" desc:"Port for the web server (default: 3000)" min:"1" max:"65535"`
+	Host      string   `yaml:"host,omitempty" desc:"Address the web server listens on: 127.0.0.1 for this machine only, or 0.0.0.0 to preview from other devices on the network (default: 127.0.0.1)"`
+	TLS       bool     `yaml:"tls,omitempty" desc:"Serve over HTTPS, with a self-signed certificate unless tls_cert and tls_key are set"`
+	TLSCert   string   `yaml:"tls_cert,omitempty" desc:"Certificate file (PEM) for HTTPS, relative to the project, e.g. from mkcert"`
+	TLSKey    string   `yaml:"tls_key,omitempty" desc:"Private key file (PEM) of tls_cert, relative to the project"`
+	Auth      map[string]string `yaml:"auth,omitempty" desc:"Protect the web server and site preview with a username and password, or an access token" keys:"username,password,token"`
+	OpenBrowser *bool  `yaml:"open_browser,omitempty" desc:"Open the web server in a browser when it starts, where sniplicity would (default: true)"` // Pointer so false can be told from unset
+	Clipboard *bool    `yaml:"clipboard,omitempty" desc:"Copy the web server's URL to the clipboard when it starts (default: true)"` // Pointer so false can be told from unset
+	ImgSize   *bool    `yaml:"imgsize,omitempty" desc:"Add width and height attributes to images (default: true)"`     // Pointer to handle optional field
+	SvgFilter *bool    `yaml:"svgfilter,omitempty" desc:"Process SVG files with CSS filters (default: true)"` // Pointer to handle optional field
+	MaxDepth  int      `yaml:"max_depth,omitempty" desc:"Warn about source folders nested deeper than this (default: 16)" min:"1"`
+	Permalinks map[string]string `yaml:"permalinks,omitempty" desc:"Output URL patterns by source folder, e.g. blog: /:year/:month/:slug/"`
+	PageTemplates map[string]string `yaml:"page_templates,omitempty" desc:"Template of markdown pages that name none in their frontmatter or a set directive, by source path pattern, e.g. docs/**: docs; the longest matching pattern wins"`
+	Redirects string   `yaml:"redirects,omitempty" desc:"How aliases are written when deploy_format isn't set (default: html)" enum:"html,netlify"`
+	CheckLinks bool    `yaml:"check_links,omitempty" desc:"Report broken internal links after each build"`
+	Strict    bool     `yaml:"strict,omitempty" desc:"Fail the build on missing snippets, templates, variables and includes"`
+	Stats     bool     `yaml:"stats,omitempty" desc:"Keep local build statistics for bug reports (never sent anywhere)"`
+	Drafts    bool     `yaml:"drafts,omitempty" desc:"Build and list pages marked draft: true"`
+	PlainText bool     `yaml:"plain_text,omitempty" desc:"Also write a .txt plain text version of each markdown page, without its template"`
+	NormalizeWhitespace bool `yaml:"normalize_whitespace,omitempty" desc:"Write pages with LF line endings, without trailing spaces and tabs and ending in exactly one newline; preformatted and textarea content is kept as it is"`
+	SearchIndex bool   `yaml:"search_index,omitempty" desc:"Write search-index.json with the title, URL, headings and text of every page, for search with Lunr, Fuse or the built-in search snippet"`
+	Only      []string `yaml:"only,omitempty" desc:"Limit builds to pages and assets under these source paths, e.g. blog/ or docs/*.md, while still using every snippet, template and global; the rest of the output is left as it is"`
+	CleanOutput bool   `yaml:"clean_output,omitempty" desc:"Remove files from the output folder that the build no longer writes (hidden files are kept)"`
+	Integrity bool     `yaml:"integrity,omitempty" desc:"Write integrity.json with the SHA-256 and size of every output file, for sniplicity verify"`
+	PageTimeout *int   `yaml:"page_timeout,omitempty" desc:"Seconds a page may take in each processing step before it is failed, 0 for no limit (default: 30)" min:"0"` // Pointer so 0 can switch it off
+	MaxPageSize *int   `yaml:"max_page_size,omitempty" desc:"Megabytes a page may grow to while processing before it is failed, 0 for no limit (default: 64)" min:"0"` // Pointer so 0 can switch it off
+	JSEntry   string   `yaml:"js_entry,omitempty" desc:"Script to bundle and minify with esbuild, relative to the input folder"`
+	JSBundle  string   `yaml:"js_bundle,omitempty" desc:"Where the bundle is written, relative to the output folder (default: same path as js_entry)"`
+	JSTarget  string   `yaml:"js_target,omitempty" desc:"Browsers or JavaScript version the bundle must run on, e.g. es2015 or chrome58,safari11"`
+	Esbuild   string   `yaml:"esbuild,omitempty" desc:"Path to the esbuild executable (default: esbuild on the PATH)"`
+	InlineAssets int   `yaml:"inline_assets,omitempty" desc:"Inline images and fonts up to this many bytes into pages and stylesheets as data URIs, 0 to switch off" min:"0"`
+	Env       string   `yaml:"env,omitempty" desc:"Build environment, which pages can test with env.<name>; production leaves out source maps and unminified originals (default: development)"`
+	ProductionExclude []string `yaml:"production_exclude,omitempty" desc:"More file patterns to leave out of production builds, e.g. *.test.js or debug/*"`
+	WatchIgnore []string `yaml:"watch_ignore,omitempty" desc:"File patterns whose changes don't trigger a rebuild (default: .git, node_modules and editor temporary files)"`
+	WatchMode string   `yaml:"watch_mode,omitempty" desc:"How changes are noticed: file system events, or poll for network drives and containers that don't deliver them (default: events)" enum:"events,poll"`
+	PollInterval int   `yaml:"poll_interval,omitempty" desc:"Milliseconds between scans of the sources with watch_mode: poll (default: 1000)" min:"100"`
+	GlobalsFile string `yaml:"globals_file,omitempty" desc:"YAML file of globals shared with other projects, relative to the project, e.g. ../shared-globals.yaml"`
+	Delimiters string  `yaml:"delimiters,omitempty" desc:"Marks around variable references, as opening and closing separated by a space, for pages that contain {{ }} of their own, e.g. [[ ]] (default: {{ }})"`
+	BuiltinTemplates string `yaml:"builtin_templates,omitempty" desc:"Folder of your own versions of the redirect, folder listing, 404 and site map markup sniplicity generates, relative to the project (default: builtin)"`
+	Lint      map[string]string `yaml:"lint,omitempty" desc:"Level of each lint check, e.g. missing-alt: warning" keys:"missing-reference,undefined-variable,broken-link,missing-alt,snippet-collision" enum:"off,warning,error"`
+	LogLevel  string   `yaml:"log_level,omitempty" desc:"How much is printed while building (default: info, or debug with verbose)" enum:"error,warn,info,debug"`
+	DateFormats []string `yaml:"date_formats,omitempty" desc:"More frontmatter date formats to read, written like strftime, e.g. %d.%m.%Y"`
+	DateFormat string   `yaml:"dateformat,omitempty" desc:"How {{name | dateformat}} shows dates without a format of their own, written like strftime, e.g. %d.%m.%Y (default: %B %-d, %Y, as in September 3, 2024)"`
+	Timezone  string   `yaml:"timezone,omitempty" desc:"Time zone of dates written without an offset, like date-only ones, e.g. Europe/Berlin (default: UTC)"`
+	Languages []string `yaml:"languages,omitempty" desc:"Languages of a multilingual site, the default first, e.g. [en, de]; pages are in one by a de/ top folder, an about.de.md suffix or their lang"`
+	FallbackLanguage string `yaml:"fallback_language,omitempty" desc:"Language whose translations are used for strings a page's language lacks, and for pages without a lang (default: the first of languages)"`
+	SiteURL   string   `yaml:"site_url,omitempty" desc:"Address the site is published at, e.g. https://example.com, for links that must be absolute such as hreflang alternates"`
+	SEO       bool     `yaml:"seo,omitempty" desc:"Add the canonical link, Open Graph and Twitter card tags a seo directive writes before the </head> of every page that has neither"`
+	Deploy    map[string]string `yaml:"deploy,omitempty" desc:"How sniplicity deploy publishes the site after building it: a shell command run in the project, an rsync destination such as user@host:/var/www, an S3 or Cloudflare R2 bucket, a git branch such as gh-pages to commit to and push, or an ftp or sftp server to upload changed files to, and the env to build for (default: production)" keys:"command,rsync,bucket,region,endpoint,prefix,access_key_env,secret_key_env,cache_control,branch,remote,message,ftp,sftp,port,user_env,password_env,env"`
+	DeployFormat string `yaml:"deploy_format,omitempty" desc:"Host to write redirect and header files for: netlify (_redirects and _headers), vercel (vercel.json) or apache (.htaccess), instead of HTML redirect pages" enum:"netlify,vercel,apache"`
+	RedirectRules map[string]string `yaml:"redirect_rules,omitempty" desc:"Redirects by old URL, to the new URL and an optional status, e.g. /old/: /new/ 301; a trailing * matches the rest of the old URL, which :splat puts into the new one"`
+	Headers   map[string]string `yaml:"headers,omitempty" desc:"Response headers by URL pattern, one Name: value per line, e.g. /*: 'X-Frame-Options: DENY'; needs deploy_format"`
+	RemoteData map[string]map[string]string `yaml:"remote_data,omitempty" desc:"JSON or YAML fetched at build time whose values become globals named after the entry, e.g. release: {url: https://api.github.com/repos/owner/app/releases/latest, map: 'version: tag_name'} gives {{release.version}}; cache is how long a fetched copy is reused (default: 1h) and token_env names an environment variable holding a bearer token" keys:"url,format,cache,map,token_env"`
+	HeadingIDs map[string]string `yaml:"heading_ids,omitempty" desc:"How markdown headings get their IDs: a prefix put before each, transliterate: true to keep accented letters as plain ones (e.g. Über becomes uber, not ber), and duplicates for repeated headings: number (intro-1), ordinal (intro-2) or underscore (intro_1)" keys:"prefix,transliterate,duplicates"`
+	GitInfo   bool     `yaml:"git_info,omitempty" desc:"Give pages {{git.lastmod}} and {{git.author}}, the date and author of the last git commit of their source; needs git"`
+	BuildSummary bool  `yaml:"build_summary,omitempty" desc:"End each build with a table of pages built, assets copied, warnings, errors, the time each phase took and the slowest pages, instead of a single line; left out with --quiet"`
+	Globals   map[string]string `yaml:"globals,omitempty" desc:"Globals for every page, e.g. analytics_id: G-12345; global directives in the sources override them"`
+	Minify    bool     `yaml:"minify,omitempty" desc:"Minify the HTML pages, CSS stylesheets and js_entry bundle written, usually only in a production environment"`
+	Environments map[string]map[string]interface{} `yaml:"environments,omitempty" desc:"Settings that differ per build environment, by environment name, e.g. production: {site_url: https://example.com, drafts: false}; the environment is env, --env or, for deploy, deploy's env" settings:"true"`
 }
 
+// DefaultPollInterval is the milliseconds between scans with watch_mode: poll
+const DefaultPollInterval = 1000
+
+// DefaultBuiltinTemplates is the folder overrides of the built-in pages are read from
+const DefaultBuiltinTemplates = "builtin"
+
 // DefaultConfig returns a config with sensible defaults
 func DefaultConfig() Config {
 	return Config{
@@ -47,23 +176,108 @@ func DefaultConfig() Config {
 		Port:      3000,
 		ImgSize:   true,    // default to enabled
 		SvgFilter: true,    // default to enabled
+		OpenBrowser: true,  // skipped anyway without a desktop session
+		Clipboard: true,
+		MaxDepth:  16,      // warn about source trees nested deeper than this
+		Redirects: "html",  // meta refresh stubs work on any host
+		PageTimeout: 30,    // seconds, generous for any sane page
+		MaxPageSize: 64,    // megabytes
+		Env:       "development",
+		WatchIgnore: DefaultWatchIgnore,
+		PollInterval: DefaultPollInterval,
+		BuiltinTemplates: DefaultBuiltinTemplates,
+	}
+}
+
+// Level returns the log level: log_level if it is set, otherwise debug with verbose and info without
+func (c *Config) Level() logging.Level {
+	if c.LogLevel != "" {
+		if level, err := logging.ParseLevel(c.LogLevel); err == nil {
+			return level
+		}
+	}
+	if c.Verbose {
+		return logging.Debug
+	}
+	return logging.Info
+}
+
+// Environment returns the build environment, development unless env is set
+func (c *Config) Environment() string {
+	if c.Env == "" {
+		return "development"
+	}
+	return c.Env
+}
+
+// IsProduction reports whether this is a production build
+func (c *Config) IsProduction() bool {
+	return c.Environment() == "production"
+}
+
+// WatchPollInterval returns how often watch mode scans the sources with watch_mode: poll,
+// or 0 when it waits for file system events
+func (c *Config) WatchPollInterval() time.Duration {
+	if c.WatchMode != "poll" {
+		return 0
 	}
+	if c.PollInterval <= 0 {
+		return DefaultPollInterval * time.Millisecond
+	}
+	return time.Duration(c.PollInterval) * time.Millisecond
+}
+
+// TranslationFallback returns the language whose translations stand in for missing ones:
+// fallback_language, or else the first of languages, or "" for none
+func (c *Config) TranslationFallback() string {
+	if c.FallbackLanguage != "" {
+		return c.FallbackLanguage
+	}
+	if len(c.Languages) > 0 {
+		return c.Languages[0]
+	}
+	return ""
 }
 
 // GetAbsoluteInputDir returns the absolute path to the input directory
 func (c *Config) GetAbsoluteInputDir() string {
-	if filepath.IsAbs(c.InputDir) {
-		return c.InputDir
-	}
-	return filepath.Join(c.ProjectDir, c.InputDir)
+	return c.absolutePath(c.InputDir)
 }
 
 // GetAbsoluteOutputDir returns the absolute path to the output directory
 func (c *Config) GetAbsoluteOutputDir() string {
-	if filepath.IsAbs(c.OutputDir) {
-		return c.OutputDir
+	return c.absolutePath(c.OutputDir)
+}
+
+// GetAbsoluteBuiltinTemplates returns the absolute path to the folder of built-in page
+// overrides, or "" outside a project
+func (c *Config) GetAbsoluteBuiltinTemplates() string {
+	if c.ProjectDir == "" || c.BuiltinTemplates == "" {
+		return ""
+	}
+	return c.absolutePath(c.BuiltinTemplates)
+}
+
+// GetAbsoluteGlobalsFile returns the absolute path to globals_file, or "" when it isn't set
+func (c *Config) GetAbsoluteGlobalsFile() string {
+	if c.GlobalsFile == "" {
+		return ""
+	}
+	return c.absolutePath(c.GlobalsFile)
+}
+
+// absolutePath resolves dir against the project directory. The result is always
+// absolute (falling back to the working directory when no project is set), which
+// also lets Go apply its long path handling on Windows.
+func (c *Config) absolutePath(dir string) string {
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	path := filepath.Join(c.ProjectDir, dir)
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
 	}
-	return filepath.Join(c.ProjectDir, c.OutputDir)
+	return path
 }
 
 // LoadConfigFromFile loads configuration from sniplicity.yaml in the given project directory
@@ -84,11 +298,35 @@ func LoadConfigFromFile(projectDir string) (Config, error) {
 		return cfg, fmt.Errorf("reading config file: %w", err)
 	}
 	
-	var configFile ConfigFile
-	if err := yaml.Unmarshal(data, &configFile); err != nil {
+	// Values may use environment variables, so one project builds differently in CI.
+	// The overrides of each environment are kept apart from the settings they override.
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
 		return cfg, fmt.Errorf("parsing config file: %w", err)
 	}
-	
+	cfg.environments = detachEnvironments(&doc)
+	cfg.envRefs = make(map[string]envRef)
+	for _, name := range expandEnvNodes(&doc, "", cfg.envRefs) {
+		logging.Warnf("sniplicity.yaml uses ${%s}, which is not set", name)
+	}
+	var configFile ConfigFile
+	if len(doc.Content) > 0 {
+		if err := doc.Decode(&configFile); err != nil {
+			return cfg, fmt.Errorf("parsing config file: %w", err)
+		}
+		cfg.settings = doc.Content[0]
+	}
+	applyConfigFile(&cfg, configFile)
+
+	if err := cfg.SetEnvironment(cfg.Environment()); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// applyConfigFile sets cfg from the settings of sniplicity.yaml, keeping the defaults of
+// those it leaves out
+func applyConfigFile(cfg *Config, configFile ConfigFile) {
 	// Apply config file values, using defaults if not specified
 	if configFile.Name != "" {
 		cfg.Name = configFile.Name
@@ -111,8 +349,83 @@ func LoadConfigFromFile(projectDir string) (Config, error) {
 	if configFile.Port != 0 {
 		cfg.Port = configFile.Port
 	}
-	
-	return cfg, nil
+	cfg.Host = configFile.Host
+	cfg.TLS = configFile.TLS
+	cfg.TLSCert = configFile.TLSCert
+	cfg.TLSKey = configFile.TLSKey
+	cfg.Auth = configFile.Auth
+	if configFile.OpenBrowser != nil {
+		cfg.OpenBrowser = *configFile.OpenBrowser
+	}
+	if configFile.Clipboard != nil {
+		cfg.Clipboard = *configFile.Clipboard
+	}
+	if configFile.MaxDepth != 0 {
+		cfg.MaxDepth = configFile.MaxDepth
+	}
+	if len(configFile.Permalinks) > 0 {
+		cfg.Permalinks = configFile.Permalinks
+	}
+	cfg.PageTemplates = configFile.PageTemplates
+	if configFile.Redirects != "" {
+		cfg.Redirects = configFile.Redirects
+	}
+	cfg.CheckLinks = configFile.CheckLinks
+	cfg.Strict = configFile.Strict
+	cfg.Stats = configFile.Stats
+	cfg.Drafts = configFile.Drafts
+	cfg.PlainText = configFile.PlainText
+	cfg.SearchIndex = configFile.SearchIndex
+	cfg.NormalizeWhitespace = configFile.NormalizeWhitespace
+	cfg.Only = configFile.Only
+	cfg.CleanOutput = configFile.CleanOutput
+	cfg.Integrity = configFile.Integrity
+	if configFile.PageTimeout != nil {
+		cfg.PageTimeout = *configFile.PageTimeout
+	}
+	if configFile.MaxPageSize != nil {
+		cfg.MaxPageSize = *configFile.MaxPageSize
+	}
+	cfg.JSEntry = configFile.JSEntry
+	cfg.JSBundle = configFile.JSBundle
+	cfg.JSTarget = configFile.JSTarget
+	cfg.Esbuild = configFile.Esbuild
+	cfg.InlineAssets = configFile.InlineAssets
+	if configFile.Env != "" {
+		cfg.Env = configFile.Env
+	}
+	cfg.ProductionExclude = configFile.ProductionExclude
+	if configFile.WatchIgnore != nil {
+		cfg.WatchIgnore = configFile.WatchIgnore
+	}
+	cfg.WatchMode = configFile.WatchMode
+	if configFile.PollInterval != 0 {
+		cfg.PollInterval = configFile.PollInterval
+	}
+	cfg.GlobalsFile = configFile.GlobalsFile
+	cfg.Delimiters = configFile.Delimiters
+	if configFile.BuiltinTemplates != "" {
+		cfg.BuiltinTemplates = configFile.BuiltinTemplates
+	}
+	cfg.Lint = configFile.Lint
+	cfg.LogLevel = configFile.LogLevel
+	cfg.DateFormats = configFile.DateFormats
+	cfg.DateFormat = configFile.DateFormat
+	cfg.Timezone = configFile.Timezone
+	cfg.Languages = configFile.Languages
+	cfg.FallbackLanguage = configFile.FallbackLanguage
+	cfg.SiteURL = configFile.SiteURL
+	cfg.SEO = configFile.SEO
+	cfg.Deploy = configFile.Deploy
+	cfg.DeployFormat = configFile.DeployFormat
+	cfg.RedirectRules = configFile.RedirectRules
+	cfg.Headers = configFile.Headers
+	cfg.RemoteData = configFile.RemoteData
+	cfg.HeadingIDs = configFile.HeadingIDs
+	cfg.GitInfo = configFile.GitInfo
+	cfg.BuildSummary = configFile.BuildSummary
+	cfg.Globals = configFile.Globals
+	cfg.Minify = configFile.Minify
 }
 
 // SaveConfigToFile saves configuration to sniplicity.yaml in the project directory
@@ -131,11 +444,82 @@ func (c *Config) SaveConfigToFile() error {
 		Verbose:   c.Verbose,
 		Serve:     c.Serve,
 		Port:      c.Port,
+		Host:      c.Host,
+		TLS:       c.TLS,
+		TLSCert:   c.TLSCert,
+		TLSKey:    c.TLSKey,
+		Auth:      c.Auth,
 		ImgSize:   &c.ImgSize,
 		SvgFilter: &c.SvgFilter,
+		MaxDepth:  c.MaxDepth,
+		Permalinks: c.Permalinks,
+		PageTemplates: c.PageTemplates,
+		Redirects: c.Redirects,
+		CheckLinks: c.CheckLinks,
+		Strict:    c.Strict,
+		Stats:     c.Stats,
+		Drafts:    c.Drafts,
+		PlainText: c.PlainText,
+		SearchIndex: c.SearchIndex,
+		NormalizeWhitespace: c.NormalizeWhitespace,
+		Only:      c.Only,
+		CleanOutput: c.CleanOutput,
+		Integrity: c.Integrity,
+		PageTimeout: &c.PageTimeout,
+		MaxPageSize: &c.MaxPageSize,
+		JSEntry:   c.JSEntry,
+		JSBundle:  c.JSBundle,
+		JSTarget:  c.JSTarget,
+		Esbuild:   c.Esbuild,
+		InlineAssets: c.InlineAssets,
+		Env:       c.Env,
+		ProductionExclude: c.ProductionExclude,
+		GlobalsFile: c.GlobalsFile,
+		Delimiters: c.Delimiters,
+		Lint:      c.Lint,
+		LogLevel:  c.LogLevel,
+		DateFormats: c.DateFormats,
+		DateFormat: c.DateFormat,
+		Timezone:  c.Timezone,
+		Languages: c.Languages,
+		FallbackLanguage: c.FallbackLanguage,
+		SiteURL:   c.SiteURL,
+		SEO:       c.SEO,
+		Deploy:    c.Deploy,
+		DeployFormat: c.DeployFormat,
+		RedirectRules: c.RedirectRules,
+		Headers:   c.Headers,
+		RemoteData: c.RemoteData,
+		HeadingIDs: c.HeadingIDs,
+		GitInfo:   c.GitInfo,
+		BuildSummary: c.BuildSummary,
+		Globals:   c.Globals,
+		Minify:    c.Minify,
+	}
+	if !slices.Equal(c.WatchIgnore, DefaultWatchIgnore) {
+		configFile.WatchIgnore = c.WatchIgnore // Left out while it is the default, to keep the file short
+	}
+	configFile.WatchMode = c.WatchMode
+	if c.BuiltinTemplates != DefaultBuiltinTemplates {
+		configFile.BuiltinTemplates = c.BuiltinTemplates
+	}
+	if c.PollInterval != DefaultPollInterval {
+		configFile.PollInterval = c.PollInterval
+	}
+	if !c.OpenBrowser {
+		configFile.OpenBrowser = &c.OpenBrowser
+	}
+	if !c.Clipboard {
+		configFile.Clipboard = &c.Clipboard
 	}
 	
-	data, err := yaml.Marshal(configFile)
+	var doc yaml.Node
+	if err := doc.Encode(configFile); err != nil {
+		return fmt.Errorf("marshaling config: %w", err)
+	}
+	c.restoreSettings(&doc)
+	restoreEnvRefs(&doc, "", c.envRefs)
+	data, err := yaml.Marshal(&doc)
 	if err != nil {
 		return fmt.Errorf("marshaling config: %w", err)
 	}
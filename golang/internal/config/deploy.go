@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultDeployEnv is the build environment sniplicity deploy builds for unless deploy
+// sets env
+const DefaultDeployEnv = "production"
+
+// Deploy targets, chosen by which of their keys the deploy section sets
+const (
+	DeployCommand = "command" // A shell command run in the project directory
+	DeployRsync   = "rsync"   // rsync to a destination
+	DeployBucket  = "bucket"  // Sync with an S3-compatible bucket
+	DeployBranch  = "branch"  // Commit to a git branch and push it, e.g. gh-pages
+	DeployFTP     = "ftp"     // Upload to an FTP or FTPS server
+	DeploySFTP    = "sftp"    // Upload over SFTP with the sftp program
+)
+
+// deployTargets are the deploy targets in the order they are looked for
+var deployTargets = []string{DeployCommand, DeployRsync, DeployBucket, DeployBranch, DeployFTP, DeploySFTP}
+
+// Deploy formats, the hosts whose redirect and header files the build can write
+const (
+	FormatNetlify = "netlify" // _redirects and _headers
+	FormatVercel  = "vercel"  // vercel.json
+	FormatApache  = "apache"  // .htaccess
+)
+
+// DefaultDeployRemote is the git remote a deploy branch is pushed to unless deploy sets remote
+const DefaultDeployRemote = "origin"
+
+// DeployEnv returns the build environment the site is built for before deploying it
+func (c *Config) DeployEnv() string {
+	if env := c.Deploy["env"]; env != "" {
+		return env
+	}
+	return DefaultDeployEnv
+}
+
+// DeployTarget returns how the site is deployed, or "" when deploy doesn't say
+func (c *Config) DeployTarget() string {
+	for _, target := range deployTargets {
+		if c.Deploy[target] != "" {
+			return target
+		}
+	}
+	return ""
+}
+
+// DeployCredentialEnvs returns the environment variables holding the bucket's access key
+// and secret key, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY unless deploy names others
+func (c *Config) DeployCredentialEnvs() (accessKeyEnv, secretKeyEnv string) {
+	accessKeyEnv, secretKeyEnv = c.Deploy["access_key_env"], c.Deploy["secret_key_env"]
+	if accessKeyEnv == "" {
+		accessKeyEnv = "AWS_ACCESS_KEY_ID"
+	}
+	if secretKeyEnv == "" {
+		secretKeyEnv = "AWS_SECRET_ACCESS_KEY"
+	}
+	return accessKeyEnv, secretKeyEnv
+}
+
+// DeployLoginEnvs returns the environment variables holding the user name and password
+// for an FTP or SFTP server, DEPLOY_USER and DEPLOY_PASSWORD unless deploy names others
+func (c *Config) DeployLoginEnvs() (userEnv, passwordEnv string) {
+	userEnv, passwordEnv = c.Deploy["user_env"], c.Deploy["password_env"]
+	if userEnv == "" {
+		userEnv = "DEPLOY_USER"
+	}
+	if passwordEnv == "" {
+		passwordEnv = "DEPLOY_PASSWORD"
+	}
+	return userEnv, passwordEnv
+}
+
+// DeployRemote returns the git remote a deploy branch is pushed to
+func (c *Config) DeployRemote() string {
+	if remote := c.Deploy["remote"]; remote != "" {
+		return remote
+	}
+	return DefaultDeployRemote
+}
+
+// RulesFormat returns the host whose redirect and header files the build writes, or ""
+// for HTML redirect pages. redirects: netlify, from before deploy_format, means netlify.
+func (c *Config) RulesFormat() string {
+	if c.DeployFormat == "" && c.Redirects == "netlify" {
+		return FormatNetlify
+	}
+	return c.DeployFormat
+}
+
+// CheckDeploy returns an error when deploy doesn't say how to publish the site
+func (c *Config) CheckDeploy() error {
+	var targets []string
+	for _, target := range deployTargets {
+		if c.Deploy[target] != "" {
+			targets = append(targets, target)
+		}
+	}
+	switch len(targets) {
+	case 0:
+		return fmt.Errorf("deploy needs a command, an rsync destination, a bucket, a branch or an ftp or sftp server in sniplicity.yaml")
+	case 1:
+		return nil
+	}
+	return fmt.Errorf("deploy can have one of %s, not %s and %s", strings.Join(deployTargets, ", "), targets[0], targets[1])
+}
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"sniplicity/internal/logging"
+
+	"gopkg.in/yaml.v3"
+)
+
+// environmentsKey is the setting of sniplicity.yaml holding the overrides of each environment
+const environmentsKey = "environments"
+
+// environment is the overrides of one environment, as applied to a config
+type environment struct {
+	keys   []string               // Settings the environment overrides
+	values map[string]interface{} // What it set them to, to tell them from later changes
+}
+
+// detachEnvironments takes the environments setting out of a parsed sniplicity.yaml and
+// returns its value, or nil if there is none
+func detachEnvironments(doc *yaml.Node) *yaml.Node {
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	root := doc.Content[0]
+	i := mappingIndex(root, environmentsKey)
+	if i < 0 {
+		return nil
+	}
+	value := root.Content[i+1]
+	root.Content = slices.Delete(root.Content, i, i+2)
+	return value
+}
+
+// Environments returns the names of the environments sniplicity.yaml has overrides for
+func (c *Config) Environments() []string {
+	if c.environments == nil || c.environments.Kind != yaml.MappingNode {
+		return nil
+	}
+	var names []string
+	for i := 0; i+1 < len(c.environments.Content); i += 2 {
+		names = append(names, c.environments.Content[i].Value)
+	}
+	return names
+}
+
+// overrides returns the settings environments gives the named environment, with their
+// environment variables expanded, or nil if it has none
+func (c *Config) overrides(name string) (*yaml.Node, error) {
+	if c.environments == nil || c.environments.Tag == "!!null" {
+		return nil, nil
+	}
+	if c.environments.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s must be a list of environments with their settings", environmentsKey)
+	}
+	value := mappingValue(c.environments, name)
+	if value == nil || value.Tag == "!!null" {
+		return nil, nil
+	}
+	if value.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s.%s must be a list of settings", environmentsKey, name)
+	}
+	overrides := copyNode(value)
+	for _, variable := range expandEnvNodes(overrides, environmentsKey+"."+name, nil) {
+		logging.Warnf("sniplicity.yaml uses ${%s} in %s.%s, which is not set", variable, environmentsKey, name)
+	}
+	for i := 0; i+1 < len(overrides.Content); i += 2 {
+		if key := overrides.Content[i].Value; key == "env" || key == environmentsKey {
+			return nil, fmt.Errorf("%s.%s cannot set %s", environmentsKey, name, key)
+		}
+	}
+	return overrides, nil
+}
+
+// SetEnvironment makes name the build environment, with the settings environments gives
+// it in place of those of the rest of sniplicity.yaml. Settings the previous environment
+// overrode go back to sniplicity.yaml's. Settings it changes are replaced whatever they
+// were set to since loading, so command line flags must be applied after it.
+func (c *Config) SetEnvironment(name string) error {
+	overrides, err := c.overrides(name)
+	if err != nil {
+		return err
+	}
+	var keys []string
+	if c.environment != nil {
+		keys = c.environment.keys
+	}
+	var overridden []string
+	if overrides != nil {
+		for i := 0; i+1 < len(overrides.Content); i += 2 {
+			overridden = append(overridden, overrides.Content[i].Value)
+		}
+	}
+	c.Env, c.environment = name, nil
+	keys = append(slices.Clone(keys), overridden...)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	// Read the settings again with the overrides merged in, and take the ones that change
+	settings := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	if c.settings != nil {
+		settings = copyNode(c.settings)
+	}
+	if overrides != nil {
+		mergeNodes(settings, overrides)
+	}
+	var configFile ConfigFile
+	if err := settings.Decode(&configFile); err != nil {
+		return fmt.Errorf("%s.%s: %w", environmentsKey, name, err)
+	}
+	merged := DefaultConfig()
+	applyConfigFile(&merged, configFile)
+
+	applied := &environment{values: make(map[string]interface{})}
+	for _, key := range keys {
+		from, to := settingField(&merged, key), settingField(c, key)
+		if !from.IsValid() || !to.IsValid() {
+			return fmt.Errorf("%s.%s: unknown setting %q", environmentsKey, name, key)
+		}
+		to.Set(from)
+		if slices.Contains(overridden, key) && !slices.Contains(applied.keys, key) {
+			applied.keys = append(applied.keys, key)
+			applied.values[key] = from.Interface()
+		}
+	}
+	if len(applied.keys) > 0 {
+		c.environment = applied
+	}
+	return nil
+}
+
+// restoreSettings puts back, in a config about to be saved, the settings of sniplicity.yaml
+// that the current environment overrides and that still have the environment's value, and
+// the environments themselves, so saving doesn't make one environment's settings everyone's
+func (c *Config) restoreSettings(doc *yaml.Node) {
+	root := doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return
+	}
+	if c.environment != nil {
+		for _, key := range c.environment.keys {
+			if !reflect.DeepEqual(settingField(c, key).Interface(), c.environment.values[key]) {
+				continue // Changed since, so the change is kept
+			}
+			original := mappingValue(c.settings, key)
+			i := mappingIndex(root, key)
+			switch {
+			case i < 0 && original != nil:
+				root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, copyNode(original))
+			case i < 0:
+			case original != nil:
+				root.Content[i+1] = copyNode(original)
+			default:
+				root.Content = slices.Delete(root.Content, i, i+2)
+			}
+		}
+	}
+	if c.environments != nil {
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: environmentsKey}, c.environments)
+	}
+}
+
+// settingField returns the field of cfg that the sniplicity.yaml setting key sets, or the
+// zero Value if there is none
+func settingField(cfg *Config, key string) reflect.Value {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0] == key {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+// mappingIndex returns the index of key among the keys and values of a mapping node, or -1
+func mappingIndex(mapping *yaml.Node, key string) int {
+	if mapping == nil {
+		return -1
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if i := mappingIndex(mapping, key); i >= 0 {
+		return mapping.Content[i+1]
+	}
+	return nil
+}
+
+// mergeNodes merges the mapping overrides into the mapping base: mappings in both are
+// merged in turn, and anything else in overrides replaces what base has
+func mergeNodes(base, overrides *yaml.Node) {
+	for i := 0; i+1 < len(overrides.Content); i += 2 {
+		key, value := overrides.Content[i], overrides.Content[i+1]
+		existing := mappingValue(base, key.Value)
+		switch {
+		case existing != nil && existing.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+			mergeNodes(existing, value)
+		case existing != nil:
+			*existing = *copyNode(value)
+		default:
+			base.Content = append(base.Content, copyNode(key), copyNode(value))
+		}
+	}
+}
+
+// copyNode returns a deep copy of a YAML node
+func copyNode(node *yaml.Node) *yaml.Node {
+	copied := *node
+	copied.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		copied.Content[i] = copyNode(child)
+	}
+	return &copied
+}
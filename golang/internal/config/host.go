@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// DefaultHost is the address the web server listens on unless host is set: this machine only
+const DefaultHost = "127.0.0.1"
+
+// ServerHost returns the address the web server listens on
+func (c *Config) ServerHost() string {
+	if c.Host == "" {
+		return DefaultHost
+	}
+	return c.Host
+}
+
+// ListenAddr returns the host and port the web server listens on
+func (c *Config) ListenAddr() string {
+	return net.JoinHostPort(c.ServerHost(), strconv.Itoa(c.Port))
+}
+
+// IsPublicHost reports whether other devices can reach the web server, because it listens
+// on something other than the loopback interface
+func (c *Config) IsPublicHost() bool {
+	host := c.ServerHost()
+	if host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || !ip.IsLoopback()
+}
+
+// UsesTLS reports whether the web server uses HTTPS, which setting a certificate implies
+func (c *Config) UsesTLS() bool {
+	return c.TLS || c.TLSCert != "" || c.TLSKey != ""
+}
+
+// ServerProtocol returns "https" when the web server uses TLS and "http" otherwise
+func (c *Config) ServerProtocol() string {
+	if c.UsesTLS() {
+		return "https"
+	}
+	return "http"
+}
+
+// GetAbsoluteTLSCert returns the absolute path to tls_cert, or "" when it isn't set
+func (c *Config) GetAbsoluteTLSCert() string {
+	if c.TLSCert == "" {
+		return ""
+	}
+	return c.absolutePath(c.TLSCert)
+}
+
+// GetAbsoluteTLSKey returns the absolute path to tls_key, or "" when it isn't set
+func (c *Config) GetAbsoluteTLSKey() string {
+	if c.TLSKey == "" {
+		return ""
+	}
+	return c.absolutePath(c.TLSKey)
+}
+
+// ServerURLs returns the URL to open the site at on this machine, and the one other devices
+// on the network can use, which is "" when they can't reach it. localIP is this machine's
+// address on the network, used when the server listens on every interface.
+func (c *Config) ServerURLs(protocol, localIP string) (string, string) {
+	host := c.ServerHost()
+	ip := net.ParseIP(host)
+	if ip != nil && ip.IsUnspecified() {
+		local := fmt.Sprintf("%s://127.0.0.1:%d", protocol, c.Port)
+		if localIP == "" {
+			return local, ""
+		}
+		return local, fmt.Sprintf("%s://%s", protocol, net.JoinHostPort(localIP, strconv.Itoa(c.Port)))
+	}
+
+	url := fmt.Sprintf("%s://%s", protocol, c.ListenAddr())
+	if !c.IsPublicHost() {
+		return url, ""
+	}
+	return url, url
+}
+
+// AuthToken returns the access token the web server asks for, or "" when there is none
+func (c *Config) AuthToken() string {
+	return c.Auth["token"]
+}
+
+// AuthCredentials returns the username and password the web server asks for, which are
+// both "" when there are none
+func (c *Config) AuthCredentials() (string, string) {
+	return c.Auth["username"], c.Auth["password"]
+}
+
+// RequiresAuth reports whether the web server asks visitors to sign in
+func (c *Config) RequiresAuth() bool {
+	username, password := c.AuthCredentials()
+	return c.AuthToken() != "" || username != "" || password != ""
+}
+
+// CheckAuth returns an error when auth is set but can't be used
+func (c *Config) CheckAuth() error {
+	username, password := c.AuthCredentials()
+	if (username == "") != (password == "") {
+		return fmt.Errorf("auth needs both a username and a password")
+	}
+	return nil
+}
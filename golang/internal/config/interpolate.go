@@ -0,0 +1,114 @@
+package config
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envVarRegex matches ${NAME} and ${NAME:-default}, and $${ which stands for a literal ${
+var envVarRegex = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// ExpandEnvVars replaces each ${NAME} in text with the environment variable NAME, and
+// each ${NAME:-default} with NAME or, when NAME is unset or empty, the default. $${NAME}
+// is kept as ${NAME}. It also returns the variables that were unset without a default,
+// which expand to nothing.
+func ExpandEnvVars(text string) (string, []string) {
+	if !strings.Contains(text, "${") {
+		return text, nil
+	}
+	var unset []string
+	expanded := envVarRegex.ReplaceAllStringFunc(text, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+		parts := envVarRegex.FindStringSubmatch(match)
+		value, ok := os.LookupEnv(parts[1])
+		switch {
+		case strings.Contains(match, ":-") && value == "":
+			return parts[2]
+		case !ok:
+			unset = append(unset, parts[1])
+		}
+		return value
+	})
+	return expanded, unset
+}
+
+// envRef is a setting written with ${NAME} in sniplicity.yaml and the value it expanded to
+type envRef struct {
+	raw      string
+	expanded string
+}
+
+// expandEnvNodes expands the environment variables in the values of a parsed
+// sniplicity.yaml, recording each value that had any in refs by its key path, e.g.
+// deploy.bucket, when refs isn't nil. Unquoted values are read again, so port: ${PORT}
+// is still a number. It returns the variables that were unset without a default.
+func expandEnvNodes(node *yaml.Node, path string, refs map[string]envRef) []string {
+	var unset []string
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			unset = append(unset, expandEnvNodes(child, path, refs)...)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			unset = append(unset, expandEnvNodes(node.Content[i], joinKeyPath(path, node.Content[i-1].Value), refs)...)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			unset = append(unset, expandEnvNodes(child, joinKeyPath(path, strconv.Itoa(i)), refs)...)
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "${") {
+			return nil
+		}
+		expanded, missing := ExpandEnvVars(node.Value)
+		if refs != nil {
+			refs[path] = envRef{raw: node.Value, expanded: expanded}
+		}
+		node.Value = expanded
+		if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			node.Tag = ""
+			node.Tag = node.ShortTag()
+		}
+		unset = missing
+	}
+	return unset
+}
+
+// restoreEnvRefs puts the ${NAME} references back into the values of a config about to be
+// saved that still have the value they expanded to, so saving never writes out the
+// environment, such as an API token, and the project keeps building differently in CI
+func restoreEnvRefs(node *yaml.Node, path string, refs map[string]envRef) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			restoreEnvRefs(child, path, refs)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			restoreEnvRefs(node.Content[i], joinKeyPath(path, node.Content[i-1].Value), refs)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			restoreEnvRefs(child, joinKeyPath(path, strconv.Itoa(i)), refs)
+		}
+	case yaml.ScalarNode:
+		if ref, ok := refs[path]; ok && ref.expanded == node.Value {
+			node.Value, node.Tag, node.Style = ref.raw, "!!str", 0
+		}
+	}
+}
+
+// joinKeyPath adds a key to the path of the mapping it is in
+func joinKeyPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
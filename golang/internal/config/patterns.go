@@ -0,0 +1,66 @@
+package config
+
+import (
+	"path"
+	"strings"
+)
+
+// DefaultWatchIgnore are the watch_ignore patterns used when sniplicity.yaml sets none:
+// version control and package folders, and the temporary files editors save beside the
+// file being edited
+var DefaultWatchIgnore = []string{
+	".git", ".hg", ".svn", "node_modules",
+	"*.swp", "*.swo", "*.swx", "4913", // vim
+	"*~", ".#*", "#*#", // emacs and others
+	"*___jb_tmp___", "*___jb_old___", // JetBrains
+	"*.tmp", ".DS_Store", "Thumbs.db",
+}
+
+// MatchPattern reports whether a path relative to the sources, with forward slashes,
+// matches a production_exclude, watch_ignore or page_templates glob. Patterns without a
+// slash match the file or folder name anywhere, like in .gitignore; a pattern with a slash
+// matches from the source folder down, and one naming a folder also covers everything
+// inside it. A ** folder matches any number of folders, so docs/** is all of docs.
+func MatchPattern(pattern, rel string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if strings.Contains(pattern, "**") {
+		return matchParts(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+	}
+	if !strings.Contains(strings.TrimSuffix(pattern, "/"), "/") {
+		pattern = strings.TrimSuffix(pattern, "/")
+		for _, part := range strings.Split(rel, "/") {
+			if matched, _ := path.Match(pattern, part); matched {
+				return true
+			}
+		}
+		return false
+	}
+	if matched, _ := path.Match(pattern, rel); matched {
+		return true
+	}
+	dir := strings.TrimSuffix(strings.TrimSuffix(pattern, "*"), "/")
+	return strings.HasPrefix(rel, dir+"/") && !strings.ContainsAny(dir, "*?[")
+}
+
+// matchParts matches the folders and name of a path against those of a pattern, where a
+// ** part matches any number of them, none included
+func matchParts(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skip := 0; skip <= len(parts); skip++ {
+				if matchParts(pattern[1:], parts[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], parts[0]); !matched {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
@@ -0,0 +1,351 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// schemaField describes one key of sniplicity.yaml, read from the ConfigFile struct tags
+type schemaField struct {
+	Key         string
+	Type        string // JSON Schema type: string, boolean, integer, array or object
+	Description string
+	Enum        []string // Allowed values; for objects, allowed values of each entry
+	Keys        []string // Allowed entry names of an object, any if empty
+	Nested      bool     // Whether each entry of an object is itself an object, whose entry names Keys lists
+	Settings    bool     // Whether each entry of an object is itself a set of settings, like environments
+	Min, Max    *int
+}
+
+// schemaFields returns the keys of sniplicity.yaml in ConfigFile field order
+func schemaFields() []schemaField {
+	t := reflect.TypeOf(ConfigFile{})
+	fields := make([]schemaField, 0, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+
+		field := schemaField{Key: key, Description: f.Tag.Get("desc")}
+
+		kind := f.Type.Kind()
+		if kind == reflect.Ptr {
+			kind = f.Type.Elem().Kind()
+		}
+		switch kind {
+		case reflect.Bool:
+			field.Type = "boolean"
+		case reflect.Int:
+			field.Type = "integer"
+		case reflect.Map:
+			field.Type = "object"
+			field.Nested = f.Type.Elem().Kind() == reflect.Map
+		case reflect.Slice:
+			field.Type = "array"
+		default:
+			field.Type = "string"
+		}
+
+		if enum := f.Tag.Get("enum"); enum != "" {
+			field.Enum = strings.Split(enum, ",")
+		}
+		if keys := f.Tag.Get("keys"); keys != "" {
+			field.Keys = strings.Split(keys, ",")
+		}
+		field.Settings = f.Tag.Get("settings") == "true"
+		if n, err := strconv.Atoi(f.Tag.Get("min")); err == nil {
+			field.Min = &n
+		}
+		if n, err := strconv.Atoi(f.Tag.Get("max")); err == nil {
+			field.Max = &n
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+// Schema returns a JSON Schema (draft-07) describing sniplicity.yaml
+func Schema() map[string]interface{} {
+	properties := make(map[string]interface{})
+	for _, field := range schemaFields() {
+		property := map[string]interface{}{
+			"type":        field.Type,
+			"description": field.Description,
+		}
+		switch field.Type {
+		case "object":
+			values := map[string]interface{}{"type": "string"}
+			if len(field.Enum) > 0 {
+				values["enum"] = field.Enum
+			}
+			names := property
+			if field.Nested {
+				names = map[string]interface{}{"type": "object", "additionalProperties": values}
+				property["additionalProperties"] = names
+			} else {
+				property["additionalProperties"] = values
+			}
+			if len(field.Keys) > 0 {
+				names["propertyNames"] = map[string]interface{}{"enum": field.Keys}
+			}
+		case "array":
+			property["items"] = map[string]string{"type": "string"}
+		default:
+			if len(field.Enum) > 0 {
+				property["enum"] = field.Enum
+			}
+		}
+		if field.Min != nil {
+			property["minimum"] = *field.Min
+		}
+		if field.Max != nil {
+			property["maximum"] = *field.Max
+		}
+		properties[field.Key] = property
+	}
+	for _, field := range schemaFields() {
+		if field.Settings {
+			settings := make(map[string]interface{}, len(properties))
+			for key, property := range properties {
+				if key != field.Key && key != "env" {
+					settings[key] = property
+				}
+			}
+			properties[field.Key].(map[string]interface{})["additionalProperties"] = map[string]interface{}{
+				"type":                 "object",
+				"properties":           settings,
+				"additionalProperties": false,
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"title":                "sniplicity.yaml",
+		"description":          "Sniplicity project configuration",
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// ValidationError is a problem found in a config file, with its position in the file
+type ValidationError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+// Error formats the problem with its position
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// ValidateFile checks a sniplicity.yaml file against the schema
+func ValidateFile(configPath string) ([]ValidationError, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	return Validate(data)
+}
+
+// Validate checks config file content against the schema. The error is only set when
+// the content isn't valid YAML at all.
+func Validate(data []byte) ([]ValidationError, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing config file: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil // Empty file, all defaults
+	}
+	expandEnvNodes(&doc, "", nil) // Check the values builds will see
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return []ValidationError{{root.Line, root.Column, "expected key: value pairs at the top level"}}, nil
+	}
+
+	fields := make(map[string]schemaField)
+	var keys []string
+	for _, field := range schemaFields() {
+		fields[field.Key] = field
+		keys = append(keys, field.Key)
+	}
+	sort.Strings(keys)
+
+	return checkSettings(root, "", fields, keys), nil
+}
+
+// checkSettings checks the settings of a mapping: the whole file, or one environment of
+// environments, whose keys are shown after prefix
+func checkSettings(mapping *yaml.Node, prefix string, fields map[string]schemaField, keys []string) []ValidationError {
+	var problems []ValidationError
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		keyNode, valueNode := mapping.Content[i], mapping.Content[i+1]
+
+		field, known := fields[keyNode.Value]
+		if !known {
+			message := fmt.Sprintf("unknown key %q", prefix+keyNode.Value)
+			if suggestion := closestKey(keyNode.Value, keys); suggestion != "" {
+				message += fmt.Sprintf(", did you mean %q?", suggestion)
+			}
+			problems = append(problems, ValidationError{keyNode.Line, keyNode.Column, message})
+			continue
+		}
+		if prefix != "" && (field.Settings || field.Key == "env") {
+			message := fmt.Sprintf("%s cannot be set per environment", field.Key)
+			problems = append(problems, ValidationError{keyNode.Line, keyNode.Column, message})
+			continue
+		}
+
+		if !field.Settings {
+			if message, at := checkValue(field, valueNode); message != "" {
+				problems = append(problems, ValidationError{at.Line, at.Column, message})
+			}
+			continue
+		}
+		if valueNode.Tag == "!!null" {
+			continue
+		}
+		if valueNode.Kind != yaml.MappingNode {
+			problems = append(problems, ValidationError{valueNode.Line, valueNode.Column, fmt.Sprintf("%s must be a list of key: value pairs", field.Key)})
+			continue
+		}
+		for j := 0; j+1 < len(valueNode.Content); j += 2 {
+			name, settings := valueNode.Content[j], valueNode.Content[j+1]
+			switch {
+			case settings.Tag == "!!null":
+			case settings.Kind != yaml.MappingNode:
+				message := fmt.Sprintf("%s.%s must be a list of settings", field.Key, name.Value)
+				problems = append(problems, ValidationError{settings.Line, settings.Column, message})
+			default:
+				problems = append(problems, checkSettings(settings, field.Key+"."+name.Value+".", fields, keys)...)
+			}
+		}
+	}
+	return problems
+}
+
+// checkValue returns a description of what's wrong with a value and the node it is
+// about, or "" if the value is valid
+func checkValue(field schemaField, node *yaml.Node) (string, *yaml.Node) {
+	if node.Tag == "!!null" {
+		return "", node // Empty values fall back to the default
+	}
+
+	switch field.Type {
+	case "boolean":
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!bool" {
+			return fmt.Sprintf("%s must be true or false", field.Key), node
+		}
+	case "integer":
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!int" {
+			return fmt.Sprintf("%s must be a whole number", field.Key), node
+		}
+		n, err := strconv.Atoi(node.Value)
+		if err != nil {
+			return fmt.Sprintf("%s must be a whole number", field.Key), node
+		}
+		if field.Min != nil && n < *field.Min {
+			return fmt.Sprintf("%s must be at least %d", field.Key, *field.Min), node
+		}
+		if field.Max != nil && n > *field.Max {
+			return fmt.Sprintf("%s must be at most %d", field.Key, *field.Max), node
+		}
+	case "object":
+		if node.Kind != yaml.MappingNode {
+			return fmt.Sprintf("%s must be a list of key: value pairs", field.Key), node
+		}
+		if field.Nested {
+			for i := 1; i < len(node.Content); i += 2 {
+				entry := field
+				entry.Key, entry.Nested = field.Key+"."+node.Content[i-1].Value, false
+				if message, at := checkValue(entry, node.Content[i]); message != "" {
+					return message, at
+				}
+			}
+			return "", node
+		}
+		for i := 1; i < len(node.Content); i += 2 {
+			key, value := node.Content[i-1], node.Content[i]
+			if len(field.Keys) > 0 && !slices.Contains(field.Keys, key.Value) {
+				return fmt.Sprintf("unknown %s entry %q (use %s)", field.Key, key.Value, strings.Join(field.Keys, ", ")), key
+			}
+			if value.Kind != yaml.ScalarNode {
+				return fmt.Sprintf("%s.%s must be a single value", field.Key, key.Value), value
+			}
+			if len(field.Enum) > 0 && !slices.Contains(field.Enum, value.Value) {
+				return fmt.Sprintf("%s.%s must be one of %s", field.Key, key.Value, strings.Join(field.Enum, ", ")), value
+			}
+		}
+	case "array":
+		if node.Kind != yaml.SequenceNode {
+			return fmt.Sprintf("%s must be a list", field.Key), node
+		}
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Sprintf("%s must be a list of single values", field.Key), item
+			}
+		}
+	case "string":
+		if node.Kind != yaml.ScalarNode {
+			return fmt.Sprintf("%s must be a single value", field.Key), node
+		}
+		if len(field.Enum) > 0 {
+			for _, allowed := range field.Enum {
+				if node.Value == allowed {
+					return "", node
+				}
+			}
+			return fmt.Sprintf("%s must be one of %s", field.Key, strings.Join(field.Enum, ", ")), node
+		}
+	}
+
+	return "", node
+}
+
+// closestKey suggests a known key for a misspelt one, or "" if nothing is close
+func closestKey(key string, keys []string) string {
+	best, bestDistance := "", 3 // Only suggest keys within two edits
+	for _, candidate := range keys {
+		if d := editDistance(strings.ToLower(key), candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr := make([]int, len(b)+1)
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev = curr
+	}
+	return prev[len(b)]
+}
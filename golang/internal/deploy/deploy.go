@@ -0,0 +1,115 @@
+package deploy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+
+	"sniplicity/internal/config"
+)
+
+// Command returns the command that publishes the built site: deploy's shell command, run
+// in the project directory, or rsync copying the output directory to its destination
+func Command(cfg config.Config) (*exec.Cmd, error) {
+	if err := cfg.CheckDeploy(); err != nil {
+		return nil, err
+	}
+
+	var cmd *exec.Cmd
+	switch cfg.DeployTarget() {
+	case config.DeployBucket, config.DeployBranch, config.DeployFTP, config.DeploySFTP:
+		return nil, fmt.Errorf("deploy to a %s is done by sniplicity itself, not by a command", cfg.DeployTarget())
+	case config.DeployRsync:
+		destination := cfg.Deploy["rsync"]
+		path, err := exec.LookPath("rsync")
+		if err != nil {
+			return nil, fmt.Errorf("deploy has an rsync destination but rsync was not found on the PATH")
+		}
+		// The trailing separator copies the folder's content rather than the folder itself
+		source := strings.TrimRight(cfg.GetAbsoluteOutputDir(), `/\`) + "/"
+		cmd = exec.Command(path, "-az", "--delete", "--exclude=.*", source, destination)
+	case config.DeployCommand:
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/C", cfg.Deploy["command"])
+		} else {
+			cmd = exec.Command("sh", "-c", cfg.Deploy["command"])
+		}
+	}
+	cmd.Dir = cfg.ProjectDir
+	// The command can use the folders without knowing the project's layout
+	cmd.Env = append(os.Environ(),
+		"SNIPLICITY_OUTPUT_DIR="+cfg.GetAbsoluteOutputDir(),
+		"SNIPLICITY_ENV="+cfg.Environment(),
+	)
+	return cmd, nil
+}
+
+// Run publishes the built site, passing each line the deploy step prints to output as
+// it happens
+func Run(cfg config.Config, output func(string)) error {
+	switch cfg.DeployTarget() {
+	case config.DeployBucket:
+		return syncBucket(cfg, output)
+	case config.DeployBranch:
+		return pushBranch(cfg, output)
+	case config.DeployFTP:
+		return uploadFTP(cfg, output)
+	case config.DeploySFTP:
+		return uploadSFTP(cfg, output)
+	}
+	cmd, err := Command(cfg)
+	if err != nil {
+		return err
+	}
+	if err := stream(cmd, output); err != nil {
+		if cfg.DeployTarget() == config.DeployRsync {
+			return fmt.Errorf("rsync failed: %w", err)
+		}
+		return fmt.Errorf("deploy command failed: %w", err)
+	}
+	return nil
+}
+
+// stream runs cmd, passing each line it prints to output as it happens
+func stream(cmd *exec.Cmd, output func(string)) error {
+	reader, writer := io.Pipe()
+	cmd.Stdout, cmd.Stderr = writer, writer
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			output(scanner.Text())
+		}
+		io.Copy(io.Discard, reader) // Keep the command from blocking on an overlong line
+	}()
+
+	err := cmd.Run()
+	writer.Close()
+	wg.Wait()
+	return err
+}
+
+// Describe says what the deploy step is going to do, for the log
+func Describe(cfg config.Config) string {
+	switch cfg.DeployTarget() {
+	case config.DeployRsync:
+		return fmt.Sprintf("Deploying %s to %s with rsync", cfg.GetAbsoluteOutputDir(), cfg.Deploy["rsync"])
+	case config.DeployBucket:
+		return fmt.Sprintf("Syncing %s with bucket %s", cfg.GetAbsoluteOutputDir(), cfg.Deploy["bucket"])
+	case config.DeployBranch:
+		return fmt.Sprintf("Committing %s to branch %s", cfg.GetAbsoluteOutputDir(), cfg.Deploy["branch"])
+	case config.DeployFTP:
+		return fmt.Sprintf("Uploading %s to %s over FTP", cfg.GetAbsoluteOutputDir(), cfg.Deploy["ftp"])
+	case config.DeploySFTP:
+		return fmt.Sprintf("Uploading %s to %s over SFTP", cfg.GetAbsoluteOutputDir(), cfg.Deploy["sftp"])
+	}
+	return "Deploying with: " + cfg.Deploy["command"]
+}
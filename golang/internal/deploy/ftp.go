@@ -0,0 +1,362 @@
+package deploy
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"sniplicity/internal/config"
+)
+
+// ftpTimeout is how long the FTP server may take to accept a connection or answer
+const ftpTimeout = 60 * time.Second
+
+// ftpDestination is where deploy's ftp uploads to
+type ftpDestination struct {
+	addr     string // Host and port
+	host     string
+	secure   bool   // Whether to use explicit TLS (FTPS)
+	dir      string // Folder relative to the login folder, "" for the login folder itself
+	user     string
+	password string
+}
+
+// uploadFTP uploads the outputs of the last build that changed since the last upload to
+// an FTP server, and deletes the ones the last upload put there that the build no longer
+// writes, all over one connection
+func uploadFTP(cfg config.Config, output func(string)) error {
+	dest, err := parseFTPDestination(cfg)
+	if err != nil {
+		return err
+	}
+	local, err := readManifest(cfg)
+	if err != nil {
+		return err
+	}
+
+	conn, err := dialFTP(dest)
+	if err != nil {
+		return err
+	}
+	defer conn.quit()
+
+	data, found, err := conn.retrieve(path.Join(dest.dir, remoteManifest))
+	if err != nil {
+		return err
+	}
+	uploads, deletes := changes(local, parseRecord(data, found, output), nil)
+
+	outputDir := cfg.GetAbsoluteOutputDir()
+	for _, rel := range uploads {
+		data, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(rel)))
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", rel, err)
+		}
+		if err := conn.store(path.Join(dest.dir, rel), data); err != nil {
+			return err
+		}
+		output("Uploaded " + rel)
+	}
+	for _, rel := range deletes {
+		if err := conn.delete(path.Join(dest.dir, rel)); err != nil {
+			return err
+		}
+		output("Deleted " + rel)
+	}
+
+	// Recorded last, so an interrupted upload sends the same files again next time
+	if data, err = encodeRecord(local); err != nil {
+		return err
+	}
+	if err := conn.store(path.Join(dest.dir, remoteManifest), data); err != nil {
+		return err
+	}
+	output(summary(local, uploads, deletes))
+	return nil
+}
+
+// parseFTPDestination reads deploy's ftp, such as ftp://user@example.com/public_html or
+// ftps:// for FTP over TLS, with the login from the environment
+func parseFTPDestination(cfg config.Config) (ftpDestination, error) {
+	raw := cfg.Deploy["ftp"]
+	if !strings.Contains(raw, "://") {
+		raw = "ftp://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return ftpDestination{}, fmt.Errorf("deploy ftp %q must look like ftp://user@example.com/folder", cfg.Deploy["ftp"])
+	}
+	if u.Scheme != "ftp" && u.Scheme != "ftps" {
+		return ftpDestination{}, fmt.Errorf("deploy ftp %q must start with ftp:// or ftps://", cfg.Deploy["ftp"])
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		return ftpDestination{}, fmt.Errorf("keep the FTP password out of sniplicity.yaml, in the environment variable password_env names")
+	}
+
+	userEnv, passwordEnv := cfg.DeployLoginEnvs()
+	dest := ftpDestination{
+		host:     u.Hostname(),
+		secure:   u.Scheme == "ftps",
+		dir:      strings.Trim(u.Path, "/"),
+		user:     u.User.Username(),
+		password: os.Getenv(passwordEnv),
+	}
+	port := u.Port()
+	if port == "" {
+		port = cfg.Deploy["port"]
+	}
+	if port == "" {
+		port = "21"
+	}
+	dest.addr = net.JoinHostPort(dest.host, port)
+	if dest.user == "" {
+		dest.user = os.Getenv(userEnv)
+	}
+	if dest.user == "" || dest.password == "" {
+		return ftpDestination{}, fmt.Errorf("deploy to FTP needs the user name in the ftp URL or %s, and the password in %s", userEnv, passwordEnv)
+	}
+	return dest, nil
+}
+
+// ftpConn is a logged in connection to an FTP server, transferring files in binary mode
+// over passive data connections
+type ftpConn struct {
+	text *textproto.Conn
+	host string
+	tls  *tls.Config     // Set with FTPS, which protects the data connections too
+	dirs map[string]bool // Folders made or found already
+}
+
+// dialFTP connects and logs in to the FTP server
+func dialFTP(dest ftpDestination) (*ftpConn, error) {
+	conn, err := net.DialTimeout("tcp", dest.addr, ftpTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", dest.addr, err)
+	}
+	c := &ftpConn{text: textproto.NewConn(conn), host: dest.host, dirs: map[string]bool{"": true, ".": true, "/": true}}
+	conn.SetDeadline(time.Now().Add(ftpTimeout))
+	if _, _, err := c.text.ReadResponse(2); err != nil {
+		c.text.Close()
+		return nil, fmt.Errorf("connecting to %s: %w", dest.addr, err)
+	}
+
+	if dest.secure {
+		if _, err := c.cmd(2, "AUTH TLS"); err != nil {
+			c.text.Close()
+			return nil, fmt.Errorf("%s doesn't support FTPS: %w", dest.addr, err)
+		}
+		c.tls = &tls.Config{ServerName: dest.host, ClientSessionCache: tls.NewLRUClientSessionCache(0)}
+		secure := tls.Client(conn, c.tls)
+		if err := secure.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("starting TLS with %s: %w", dest.addr, err)
+		}
+		c.text = textproto.NewConn(secure)
+	}
+	conn.SetDeadline(time.Time{})
+
+	if err := c.login(dest); err != nil {
+		c.text.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// login logs in and switches to binary transfers
+func (c *ftpConn) login(dest ftpDestination) error {
+	code, err := c.cmd(0, "USER %s", dest.user)
+	if err == nil && code == 331 {
+		_, err = c.cmd(2, "PASS %s", dest.password)
+	} else if err == nil && code/100 != 2 {
+		err = fmt.Errorf("server answered %d to USER", code)
+	}
+	if err != nil {
+		return fmt.Errorf("logging in to %s as %s: %w", dest.addr, dest.user, err)
+	}
+	if c.tls != nil {
+		if _, err := c.cmd(2, "PBSZ 0"); err != nil {
+			return fmt.Errorf("protecting data connections: %w", err)
+		}
+		if _, err := c.cmd(2, "PROT P"); err != nil {
+			return fmt.Errorf("protecting data connections: %w", err)
+		}
+	}
+	if _, err := c.cmd(2, "TYPE I"); err != nil {
+		return fmt.Errorf("switching to binary mode: %w", err)
+	}
+	return nil
+}
+
+// cmd sends a command and reads the answer, an error unless its code starts with expect
+// (any code when expect is 0)
+func (c *ftpConn) cmd(expect int, format string, args ...interface{}) (int, error) {
+	id, err := c.text.Cmd(format, args...)
+	if err != nil {
+		return 0, err
+	}
+	c.text.StartResponse(id)
+	defer c.text.EndResponse(id)
+	code, _, err := c.text.ReadResponse(expect)
+	return code, err
+}
+
+// transfer runs a command that sends or receives a file over a new data connection
+func (c *ftpConn) transfer(command string, use func(data net.Conn) error) error {
+	data, err := c.dataConn()
+	if err != nil {
+		return err
+	}
+	id, err := c.text.Cmd("%s", command)
+	if err != nil {
+		data.Close()
+		return err
+	}
+	c.text.StartResponse(id)
+	defer c.text.EndResponse(id)
+	if _, _, err := c.text.ReadResponse(1); err != nil {
+		data.Close()
+		return err
+	}
+	err = use(data)
+	if closeErr := data.Close(); err == nil {
+		err = closeErr
+	}
+	if _, _, respErr := c.text.ReadResponse(2); err == nil {
+		err = respErr
+	}
+	return err
+}
+
+// dataConn opens a passive data connection, with EPSV or else PASV
+func (c *ftpConn) dataConn() (net.Conn, error) {
+	port, err := c.passivePort()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(c.host, strconv.Itoa(port)), ftpTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("opening a data connection: %w", err)
+	}
+	if c.tls != nil {
+		// The handshake happens on first use, as servers only start it once the
+		// transfer command has been answered
+		return tls.Client(conn, c.tls), nil
+	}
+	return conn, nil
+}
+
+// passivePort asks the server for a port to open a data connection to. The address in
+// a PASV answer is ignored, as servers behind NAT often give their private one.
+func (c *ftpConn) passivePort() (int, error) {
+	id, err := c.text.Cmd("EPSV")
+	if err != nil {
+		return 0, err
+	}
+	c.text.StartResponse(id)
+	_, message, err := c.text.ReadResponse(229)
+	c.text.EndResponse(id)
+	if err == nil {
+		// 229 Entering Extended Passive Mode (|||6446|)
+		start, end := strings.Index(message, "(|||"), strings.LastIndex(message, "|)")
+		if start >= 0 && end > start+4 {
+			if port, err := strconv.Atoi(message[start+4 : end]); err == nil {
+				return port, nil
+			}
+		}
+	}
+
+	id, err = c.text.Cmd("PASV")
+	if err != nil {
+		return 0, err
+	}
+	c.text.StartResponse(id)
+	_, message, err = c.text.ReadResponse(227)
+	c.text.EndResponse(id)
+	if err != nil {
+		return 0, fmt.Errorf("asking for a data connection: %w", err)
+	}
+	// 227 Entering Passive Mode (h1,h2,h3,h4,p1,p2)
+	start, end := strings.Index(message, "("), strings.LastIndex(message, ")")
+	if start >= 0 && end > start {
+		parts := strings.Split(message[start+1:end], ",")
+		if len(parts) == 6 {
+			high, highErr := strconv.Atoi(strings.TrimSpace(parts[4]))
+			low, lowErr := strconv.Atoi(strings.TrimSpace(parts[5]))
+			if highErr == nil && lowErr == nil {
+				return high<<8 | low, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unexpected answer to PASV: %s", message)
+}
+
+// retrieve downloads a file, and false when there is no such file
+func (c *ftpConn) retrieve(name string) ([]byte, bool, error) {
+	var data []byte
+	err := c.transfer("RETR "+name, func(conn net.Conn) error {
+		var err error
+		data, err = io.ReadAll(conn)
+		return err
+	})
+	var ftpErr *textproto.Error
+	if errors.As(err, &ftpErr) && ftpErr.Code == 550 {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("downloading %s: %w", name, err)
+	}
+	return data, true, nil
+}
+
+// store uploads a file, making its folder first
+func (c *ftpConn) store(name string, data []byte) error {
+	c.makeDirs(path.Dir(name))
+	err := c.transfer("STOR "+name, func(conn net.Conn) error {
+		_, err := conn.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("uploading %s: %w", name, err)
+	}
+	return nil
+}
+
+// delete removes a file, unless it is gone already
+func (c *ftpConn) delete(name string) error {
+	_, err := c.cmd(2, "DELE %s", name)
+	var ftpErr *textproto.Error
+	if errors.As(err, &ftpErr) && ftpErr.Code == 550 {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("deleting %s: %w", name, err)
+	}
+	return nil
+}
+
+// makeDirs makes a folder and the ones above it. Errors are left for the upload to
+// report, as a folder that exists already is an error too.
+func (c *ftpConn) makeDirs(dir string) {
+	if c.dirs[dir] {
+		return
+	}
+	c.makeDirs(path.Dir(dir))
+	c.cmd(2, "MKD %s", dir)
+	c.dirs[dir] = true
+}
+
+// quit logs out and closes the connection
+func (c *ftpConn) quit() {
+	c.cmd(2, "QUIT")
+	c.text.Close()
+}
@@ -0,0 +1,165 @@
+package deploy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"sniplicity/internal/config"
+)
+
+// pushBranch commits the output directory to deploy's branch and pushes it. The commit is
+// made through a temporary index straight from the output directory, so the project's own
+// checkout, index and current branch are never touched. Each deploy adds a commit on top
+// of the branch as the remote has it, or starts the branch when there is none yet.
+func pushBranch(cfg config.Config, output func(string)) error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return fmt.Errorf("deploy to a branch needs git, which was not found on the PATH")
+	}
+	branch, remote := cfg.Deploy["branch"], cfg.DeployRemote()
+	outputDir := cfg.GetAbsoluteOutputDir()
+
+	repo := git{dir: cfg.ProjectDir}
+	gitDir, err := repo.read("rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return fmt.Errorf("%s is not in a git repository: %w", cfg.ProjectDir, err)
+	}
+	if _, err := repo.read("check-ref-format", "--branch", branch); err != nil {
+		return fmt.Errorf("deploy branch %q is not a valid branch name", branch)
+	}
+	repo.env = []string{"GIT_DIR=" + gitDir}
+
+	// Build on the branch as published, or as it is here when it can't be fetched
+	ref, remoteRef := "refs/heads/"+branch, "refs/remotes/"+remote+"/"+branch
+	if err := repo.stream(output, "fetch", "--quiet", remote, "+"+ref+":"+remoteRef); err != nil {
+		output(fmt.Sprintf("Could not fetch %s from %s, using the local branch if there is one", branch, remote))
+	}
+	parent := ""
+	for _, candidate := range []string{remoteRef, ref} {
+		if hash, err := repo.read("rev-parse", "--verify", "--quiet", candidate+"^{commit}"); err == nil {
+			parent = hash
+			break
+		}
+	}
+
+	tree, err := outputTree(repo, outputDir)
+	if err != nil {
+		return err
+	}
+	if parent != "" {
+		if parentTree, err := repo.read("rev-parse", parent+"^{tree}"); err == nil && parentTree == tree {
+			output(fmt.Sprintf("%s already holds this build", branch))
+			return repo.stream(output, "push", "--quiet", remote, parent+":"+ref)
+		}
+	}
+
+	args := []string{"commit-tree", tree, "-m", deployMessage(cfg)}
+	if parent != "" {
+		args = append(args, "-p", parent)
+	}
+	commit, err := repo.read(args...)
+	if err != nil {
+		return fmt.Errorf("committing the build: %w", err)
+	}
+	if _, err := repo.read("update-ref", ref, commit); err != nil {
+		return fmt.Errorf("updating %s: %w", branch, err)
+	}
+	output(fmt.Sprintf("Committed %s to %s", shortHash(commit), branch))
+	if err := repo.stream(output, "push", "--quiet", remote, ref+":"+ref); err != nil {
+		return fmt.Errorf("pushing %s to %s failed: %w", branch, remote, err)
+	}
+	output(fmt.Sprintf("Pushed %s to %s", branch, remote))
+	return nil
+}
+
+// outputTree writes every file in the output directory to the repository as a tree and
+// returns its hash. Files ignored by a .gitignore in the output directory are left out.
+// An empty .nojekyll is added unless the build wrote one, so GitHub Pages serves files
+// such as _redirects as they are.
+func outputTree(repo git, outputDir string) (string, error) {
+	index, err := os.CreateTemp("", "sniplicity-deploy-index-*")
+	if err != nil {
+		return "", fmt.Errorf("creating a temporary index: %w", err)
+	}
+	index.Close()
+	os.Remove(index.Name()) // git starts a missing index empty
+	defer os.Remove(index.Name())
+
+	files := git{dir: outputDir, env: append(repo.env, "GIT_INDEX_FILE="+index.Name(), "GIT_WORK_TREE="+outputDir)}
+	if _, err := files.read("add", "--all", "."); err != nil {
+		return "", fmt.Errorf("adding %s: %w", outputDir, err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, ".nojekyll")); err != nil {
+		blob, err := files.read("hash-object", "-w", "--stdin")
+		if err != nil {
+			return "", fmt.Errorf("adding .nojekyll: %w", err)
+		}
+		if _, err := files.read("update-index", "--add", "--cacheinfo", "100644,"+blob+",.nojekyll"); err != nil {
+			return "", fmt.Errorf("adding .nojekyll: %w", err)
+		}
+	}
+	tree, err := files.read("write-tree")
+	if err != nil {
+		return "", fmt.Errorf("writing the build to git: %w", err)
+	}
+	return tree, nil
+}
+
+// deployMessage returns the message of a deploy commit: deploy's message, or one naming
+// the commit the site was built from
+func deployMessage(cfg config.Config) string {
+	if message := cfg.Deploy["message"]; message != "" {
+		return message
+	}
+	if head, err := (git{dir: cfg.ProjectDir}).read("rev-parse", "--short", "HEAD"); err == nil {
+		return "Deploy " + head
+	}
+	return "Deploy site"
+}
+
+// shortHash shortens a commit hash for messages
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// git runs git commands in a directory with extra environment variables
+type git struct {
+	dir string
+	env []string
+}
+
+// command returns a git command ready to run
+func (g git) command(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.dir
+	cmd.Env = append(os.Environ(), g.env...)
+	return cmd
+}
+
+// read runs a git command with empty input and returns what it prints, trimmed. The
+// error holds what it printed to stderr.
+func (g git) read(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := g.command(args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if message := strings.TrimSpace(stderr.String()); message != "" && errors.As(err, &exitErr) {
+			return "", errors.New(message)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// stream runs a git command, passing each line it prints to output as it happens
+func (g git) stream(output func(string), args ...string) error {
+	return stream(g.command(args...), output)
+}
@@ -0,0 +1,95 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"sniplicity/internal/config"
+)
+
+// manifestPath is where the build keeps its manifest, relative to the project directory
+// (builder.ManifestPath)
+const manifestPath = ".sniplicity/manifest.json"
+
+// remoteManifest is the file that records what the last upload put on the server or in
+// the bucket, relative to the folder deployed to. Files are only deleted when it lists
+// them, so files sniplicity didn't upload are never touched.
+const remoteManifest = ".sniplicity-deploy.json"
+
+// readManifest returns the content hash of every file the last build wrote, by path
+// relative to the output directory
+func readManifest(cfg config.Config) (map[string]string, error) {
+	data, err := os.ReadFile(filepath.Join(cfg.ProjectDir, filepath.FromSlash(manifestPath)))
+	if err != nil {
+		return nil, fmt.Errorf("reading the build manifest: %w", err)
+	}
+	var manifest struct {
+		Outputs map[string]string `json:"outputs"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing the build manifest: %w", err)
+	}
+	return manifest.Outputs, nil
+}
+
+// parseRecord returns the hashes the last upload recorded, none when there was no record
+// or it can't be read, which uploads every file
+func parseRecord(data []byte, found bool, output func(string)) map[string]string {
+	remote := make(map[string]string)
+	if !found {
+		return remote
+	}
+	if err := json.Unmarshal(data, &remote); err != nil {
+		output(fmt.Sprintf("Ignoring unreadable %s, uploading every file", remoteManifest))
+		return make(map[string]string)
+	}
+	return remote
+}
+
+// encodeRecord returns the record of an upload of the local files
+func encodeRecord(local map[string]string) ([]byte, error) {
+	data, err := json.MarshalIndent(local, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding %s: %w", remoteManifest, err)
+	}
+	return data, nil
+}
+
+// changes returns the files to upload, those the last upload recorded with another hash
+// or not at all, and those to delete, the ones it uploaded that the build no longer
+// writes. present, when not nil, says whether a file is still there, so a file removed
+// by hand is uploaded again and one that is gone already isn't deleted.
+func changes(local, remote map[string]string, present func(rel string) bool) (uploads, deletes []string) {
+	if present == nil {
+		present = func(string) bool { return true }
+	}
+	for _, rel := range sortedPaths(local) {
+		if remote[rel] != local[rel] || !present(rel) {
+			uploads = append(uploads, rel)
+		}
+	}
+	for _, rel := range sortedPaths(remote) {
+		if _, ok := local[rel]; !ok && present(rel) {
+			deletes = append(deletes, rel)
+		}
+	}
+	return uploads, deletes
+}
+
+// summary says how many files an upload changed
+func summary(local map[string]string, uploads, deletes []string) string {
+	return fmt.Sprintf("Uploaded %d files, deleted %d, %d unchanged", len(uploads), len(deletes), len(local)-len(uploads))
+}
+
+// sortedPaths returns the keys of a manifest in order
+func sortedPaths(hashes map[string]string) []string {
+	paths := make([]string, 0, len(hashes))
+	for rel := range hashes {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,386 @@
+package deploy
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sniplicity/internal/config"
+)
+
+// errNotFound is returned for an object the bucket doesn't have
+var errNotFound = errors.New("no such object")
+
+// uploadWorkers is how many files are uploaded at once
+const uploadWorkers = 8
+
+// pageCacheControl is sent with pages, feeds and other files whose URLs don't change when
+// their content does, so visitors always get the latest version
+const pageCacheControl = "public, max-age=0, must-revalidate"
+
+// defaultCacheControl is sent with other files unless deploy sets cache_control
+const defaultCacheControl = "public, max-age=3600"
+
+// syncBucket uploads the outputs of the last build that the bucket doesn't have yet or
+// has an older version of, and deletes the ones an earlier sync uploaded that the build no
+// longer writes. What is in the bucket is known from the record the last sync left there.
+func syncBucket(cfg config.Config, output func(string)) error {
+	client, err := newS3Client(cfg)
+	if err != nil {
+		return err
+	}
+	local, err := readManifest(cfg)
+	if err != nil {
+		return err
+	}
+	prefix := strings.Trim(cfg.Deploy["prefix"], "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	existing, err := client.list(prefix)
+	if err != nil {
+		return err
+	}
+	data, found, err := client.get(prefix + remoteManifest)
+	if err != nil {
+		return err
+	}
+	remote := parseRecord(data, found, output)
+	uploads, deletes := changes(local, remote, func(rel string) bool { return existing[prefix+rel] })
+
+	var mu sync.Mutex
+	report := func(format string, args ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		output(fmt.Sprintf(format, args...))
+	}
+	outputDir := cfg.GetAbsoluteOutputDir()
+	err = forEach(uploads, func(rel string) error {
+		data, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(rel)))
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", rel, err)
+		}
+		if err := client.put(prefix+rel, data, contentType(rel), cacheControl(cfg, rel)); err != nil {
+			return err
+		}
+		report("Uploaded %s", rel)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = forEach(deletes, func(rel string) error {
+		if err := client.delete(prefix + rel); err != nil {
+			return err
+		}
+		report("Deleted %s", rel)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Recorded last, so an interrupted sync uploads the same files again next time
+	data, err = encodeRecord(local)
+	if err != nil {
+		return err
+	}
+	if err := client.put(prefix+remoteManifest, data, "application/json", pageCacheControl); err != nil {
+		return err
+	}
+	output(summary(local, uploads, deletes))
+	return nil
+}
+
+// forEach runs fn on every path, a few at a time, returning the first error
+func forEach(paths []string, fn func(string) error) error {
+	jobs := make(chan string)
+	errs := make(chan error, len(paths))
+	var wg sync.WaitGroup
+	for i := 0; i < uploadWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range jobs {
+				if err := fn(rel); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	for _, rel := range paths {
+		jobs <- rel
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// contentType returns the Content-Type a file is served with
+func contentType(rel string) string {
+	if t := mime.TypeByExtension(path.Ext(rel)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// cacheControl returns the Cache-Control header a file is served with
+func cacheControl(cfg config.Config, rel string) string {
+	switch strings.ToLower(path.Ext(rel)) {
+	case "", ".html", ".htm", ".xml", ".json", ".txt":
+		return pageCacheControl
+	}
+	if value := cfg.Deploy["cache_control"]; value != "" {
+		return value
+	}
+	return defaultCacheControl
+}
+
+// s3Client makes requests to an S3-compatible bucket, signed with AWS Signature Version 4
+type s3Client struct {
+	bucketURL *url.URL // Virtual-hosted on AWS, path-style on other endpoints
+	region    string
+	accessKey string
+	secretKey string
+	http      *http.Client
+	now       func() time.Time
+}
+
+// newS3Client returns a client for the bucket deploy names, with the credentials from
+// the environment variables it names
+func newS3Client(cfg config.Config) (*s3Client, error) {
+	accessKeyEnv, secretKeyEnv := cfg.DeployCredentialEnvs()
+	client := &s3Client{
+		region:    cfg.Deploy["region"],
+		accessKey: os.Getenv(accessKeyEnv),
+		secretKey: os.Getenv(secretKeyEnv),
+		http:      &http.Client{Timeout: 5 * time.Minute},
+		now:       time.Now,
+	}
+	if client.accessKey == "" || client.secretKey == "" {
+		return nil, fmt.Errorf("deploy to a bucket needs the access key in %s and the secret key in %s", accessKeyEnv, secretKeyEnv)
+	}
+	if client.region == "" {
+		client.region = "us-east-1"
+	}
+
+	bucket := cfg.Deploy["bucket"]
+	endpoint := cfg.Deploy["endpoint"]
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, client.region)
+	} else {
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "https://" + endpoint
+		}
+		endpoint = strings.TrimRight(endpoint, "/") + "/" + bucket
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deploy endpoint %q: %w", cfg.Deploy["endpoint"], err)
+	}
+	client.bucketURL = u
+	return client, nil
+}
+
+// list returns the keys in the bucket that start with prefix
+func (c *s3Client) list(prefix string) (map[string]bool, error) {
+	keys := make(map[string]bool)
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		resp, err := c.do("GET", "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("listing the bucket: %w", err)
+		}
+		for _, object := range result.Contents {
+			keys[object.Key] = true
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// get returns the content of an object, and false when there is no such object
+func (c *s3Client) get(key string) ([]byte, bool, error) {
+	resp, err := c.do("GET", key, nil, nil, nil)
+	if errors.Is(err, errNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("downloading %s: %w", key, err)
+	}
+	return data, true, nil
+}
+
+// put uploads an object
+func (c *s3Client) put(key string, data []byte, contentType, cacheControl string) error {
+	header := http.Header{"Content-Type": {contentType}, "Cache-Control": {cacheControl}}
+	resp, err := c.do("PUT", key, nil, header, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// delete removes an object
+func (c *s3Client) delete(key string) error {
+	resp, err := c.do("DELETE", key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends a signed request for an object, or for the bucket when key is empty, and
+// returns an error for any response but a success
+func (c *s3Client) do(method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := *c.bucketURL
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + key
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, key, err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	c.sign(req, body)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, key, err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && method == "GET" && key != "" {
+		return nil, fmt.Errorf("%s %s: %w", method, key, errNotFound)
+	}
+	var s3Error struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if xml.Unmarshal(data, &s3Error) == nil && s3Error.Code != "" {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, key, s3Error.Code, s3Error.Message)
+	}
+	return nil, fmt.Errorf("%s %s: %s", method, key, resp.Status)
+}
+
+// sign adds the AWS Signature Version 4 headers to req, whose URL is already encoded the
+// way the signature expects
+func (c *s3Client) sign(req *http.Request, body []byte) {
+	now := c.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + c.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := []byte("AWS4" + c.secretKey)
+	for _, part := range []string{day, c.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query sorted by name, as both the URL and the signature need it
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters, and slashes too when
+// encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~', ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,175 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sniplicity/internal/config"
+)
+
+// sftpSession runs batches of commands with the sftp program, logged in to deploy's server
+type sftpSession struct {
+	cfg      config.Config
+	host     string // user@host, or host for the user of the SSH configuration
+	password string // From the environment, given to sshpass; "" to log in with SSH keys
+}
+
+// uploadSFTP uploads the outputs of the last build that changed since the last upload to
+// an SFTP server, and deletes the ones the last upload put there that the build no longer
+// writes. It logs in with SSH keys, or with the password from the environment through
+// sshpass.
+func uploadSFTP(cfg config.Config, output func(string)) error {
+	if _, err := exec.LookPath("sftp"); err != nil {
+		return fmt.Errorf("deploy to SFTP needs sftp, which was not found on the PATH")
+	}
+	session, dir, err := parseSFTPDestination(cfg)
+	if err != nil {
+		return err
+	}
+	local, err := readManifest(cfg)
+	if err != nil {
+		return err
+	}
+
+	scratch, err := os.MkdirTemp("", "sniplicity-sftp-")
+	if err != nil {
+		return fmt.Errorf("creating a temporary folder: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	// A missing record fails the get, which the leading - lets pass
+	recordPath := filepath.Join(scratch, remoteManifest)
+	if out, err := session.run(fmt.Sprintf("-get %s %s\n", sftpQuote(path.Join(dir, remoteManifest)), sftpQuote(recordPath))); err != nil {
+		return fmt.Errorf("connecting to %s: %w\n%s", session.host, err, strings.TrimSpace(out))
+	}
+	data, err := os.ReadFile(recordPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("reading %s: %w", remoteManifest, err)
+	}
+	uploads, deletes := changes(local, parseRecord(data, err == nil, output), nil)
+
+	// Recorded last, so an interrupted upload sends the same files again next time
+	if data, err = encodeRecord(local); err != nil {
+		return err
+	}
+	if err := os.WriteFile(recordPath, data, 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", remoteManifest, err)
+	}
+
+	var batch strings.Builder
+	for _, folder := range sftpFolders(dir, uploads) {
+		fmt.Fprintf(&batch, "-mkdir %s\n", sftpQuote(folder))
+	}
+	outputDir := cfg.GetAbsoluteOutputDir()
+	for _, rel := range uploads {
+		fmt.Fprintf(&batch, "put %s %s\n", sftpQuote(filepath.Join(outputDir, filepath.FromSlash(rel))), sftpQuote(path.Join(dir, rel)))
+	}
+	for _, rel := range deletes {
+		fmt.Fprintf(&batch, "-rm %s\n", sftpQuote(path.Join(dir, rel)))
+	}
+	fmt.Fprintf(&batch, "put %s %s\n", sftpQuote(recordPath), sftpQuote(path.Join(dir, remoteManifest)))
+
+	if err := stream(session.command(batch.String()), output); err != nil {
+		return fmt.Errorf("sftp failed: %w", err)
+	}
+	output(summary(local, uploads, deletes))
+	return nil
+}
+
+// parseSFTPDestination reads deploy's sftp, such as user@example.com:/var/www, with the
+// user name from the environment when it has none and the password from the environment
+func parseSFTPDestination(cfg config.Config) (sftpSession, string, error) {
+	host, dir, ok := strings.Cut(cfg.Deploy["sftp"], ":")
+	if !ok || host == "" || strings.HasSuffix(host, "@") {
+		return sftpSession{}, "", fmt.Errorf("deploy sftp %q must look like user@example.com:/folder", cfg.Deploy["sftp"])
+	}
+	userEnv, passwordEnv := cfg.DeployLoginEnvs()
+	if !strings.Contains(host, "@") {
+		if user := os.Getenv(userEnv); user != "" {
+			host = user + "@" + host
+		}
+	}
+	session := sftpSession{cfg: cfg, host: host, password: os.Getenv(passwordEnv)}
+	if session.password != "" {
+		if _, err := exec.LookPath("sshpass"); err != nil {
+			return sftpSession{}, "", fmt.Errorf("logging in to SFTP with the password in %s needs sshpass, which was not found on the PATH; or unset it to use SSH keys", passwordEnv)
+		}
+	}
+
+	switch trimmed := strings.TrimRight(dir, "/"); {
+	case trimmed != "":
+		dir = trimmed
+	case dir != "":
+		dir = "/"
+	default:
+		dir = "." // The login folder
+	}
+	return session, dir, nil
+}
+
+// command returns the sftp command that runs a batch of commands, stopping at the first
+// one that fails unless it starts with -
+func (s sftpSession) command(batch string) *exec.Cmd {
+	args := []string{"sftp", "-b", "-"}
+	if port := s.cfg.Deploy["port"]; port != "" {
+		args = append(args, "-P", port)
+	}
+	if s.password != "" {
+		// sftp turns on BatchMode with -b, which would keep ssh from asking sshpass
+		args = append([]string{"sshpass", "-e", "sftp", "-o", "BatchMode=no"}, args[1:]...)
+	}
+	cmd := exec.Command(args[0], append(args[1:], s.host)...)
+	cmd.Dir = s.cfg.ProjectDir
+	cmd.Stdin = strings.NewReader(batch)
+	cmd.Env = os.Environ()
+	if s.password != "" {
+		cmd.Env = append(cmd.Env, "SSHPASS="+s.password)
+	}
+	return cmd
+}
+
+// run runs a batch of commands and returns what sftp printed
+func (s sftpSession) run(batch string) (string, error) {
+	out, err := s.command(batch).CombinedOutput()
+	return string(out), err
+}
+
+// sftpFolders returns the folders the uploads go into, each after the one above it, for
+// making them first
+func sftpFolders(dir string, uploads []string) []string {
+	seen := map[string]bool{}
+	for _, rel := range uploads {
+		for folder := path.Dir(rel); folder != "." && !seen[folder]; folder = path.Dir(folder) {
+			seen[folder] = true
+		}
+	}
+	folders := []string{dir}
+	for folder := range seen {
+		folders = append(folders, path.Join(dir, folder))
+	}
+	// A folder's path is a prefix of those below it, so it sorts first
+	sort.Strings(folders[1:])
+	return folders
+}
+
+// sftpQuote quotes a path for an sftp batch, escaping the characters sftp would otherwise
+// expand as a pattern
+func sftpQuote(name string) string {
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for _, r := range name {
+		if strings.ContainsRune(`"\*?[]`, r) {
+			quoted.WriteByte('\\')
+		}
+		quoted.WriteRune(r)
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
@@ -0,0 +1,129 @@
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/fatih/color"
+)
+
+// Comparison is what changed between the reports of two builds
+type Comparison struct {
+	New   []Diagnostic `json:"new"`   // In the current report but not the previous one
+	Fixed []Diagnostic `json:"fixed"` // In the previous report but not the current one
+	Sizes []SizeChange `json:"sizes"` // Pages that were added, removed or changed size
+}
+
+// SizeChange is a page whose size differs between two reports. A page only in one of
+// them has a size of -1 in the other.
+type SizeChange struct {
+	Page   string `json:"page"`
+	Before int64  `json:"before"`
+	After  int64  `json:"after"`
+}
+
+// ReadReport reads a report written by --diagnostics json. Watch mode writes a report per
+// build, one per line, and the last one is used.
+func ReadReport(path string) (Report, error) {
+	var report Report
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return report, err
+	}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if err := json.Unmarshal(lines[len(lines)-1], &report); err != nil {
+		return report, fmt.Errorf("reading report %s: %w", path, err)
+	}
+	return report, nil
+}
+
+// diagnosticKey identifies a diagnostic across builds. Lines move when a page is edited,
+// so they are left out.
+type diagnosticKey struct {
+	Level   Level
+	Rule    Rule
+	File    string
+	Message string
+}
+
+// Compare lists the diagnostics current has that previous didn't and the other way round,
+// and the pages whose size changed. A diagnostic found twice in current but once in
+// previous counts as new once.
+func Compare(previous, current Report) Comparison {
+	c := Comparison{Sizes: []SizeChange{}}
+	c.New = subtract(current.Diagnostics, previous.Diagnostics)
+	c.Fixed = subtract(previous.Diagnostics, current.Diagnostics)
+
+	for page, after := range current.Pages {
+		if before, ok := previous.Pages[page]; !ok {
+			c.Sizes = append(c.Sizes, SizeChange{Page: page, Before: -1, After: after})
+		} else if before != after {
+			c.Sizes = append(c.Sizes, SizeChange{Page: page, Before: before, After: after})
+		}
+	}
+	for page, before := range previous.Pages {
+		if _, ok := current.Pages[page]; !ok {
+			c.Sizes = append(c.Sizes, SizeChange{Page: page, Before: before, After: -1})
+		}
+	}
+	sort.Slice(c.Sizes, func(i, j int) bool { return c.Sizes[i].Page < c.Sizes[j].Page })
+	return c
+}
+
+// subtract returns the diagnostics of a that b doesn't have, in a's order
+func subtract(a, b []Diagnostic) []Diagnostic {
+	counts := make(map[diagnosticKey]int)
+	for _, d := range b {
+		counts[keyOf(d)]++
+	}
+	left := []Diagnostic{}
+	for _, d := range a {
+		if key := keyOf(d); counts[key] > 0 {
+			counts[key]--
+		} else {
+			left = append(left, d)
+		}
+	}
+	return left
+}
+
+// keyOf returns the key of a diagnostic
+func keyOf(d Diagnostic) diagnosticKey {
+	return diagnosticKey{Level: d.Level, Rule: d.Rule, File: d.File, Message: d.Message}
+}
+
+// WriteText lists the new and fixed diagnostics and the pages that changed size
+func (c Comparison) WriteText(w io.Writer) {
+	yellow := color.New(color.FgYellow)
+	red := color.New(color.FgRed)
+	green := color.New(color.FgGreen)
+
+	fmt.Fprintf(w, "New (%d):\n", len(c.New))
+	for _, d := range c.New {
+		if d.Level == Error {
+			fmt.Fprintf(w, "  %s %s\n", red.Sprint("Error:"), d)
+		} else {
+			fmt.Fprintf(w, "  %s %s\n", yellow.Sprint("Warning:"), d)
+		}
+	}
+	fmt.Fprintf(w, "Fixed (%d):\n", len(c.Fixed))
+	for _, d := range c.Fixed {
+		fmt.Fprintf(w, "  %s %s\n", green.Sprint("Fixed:"), d)
+	}
+
+	fmt.Fprintf(w, "Page sizes (%d changed):\n", len(c.Sizes))
+	for _, s := range c.Sizes {
+		switch {
+		case s.Before < 0:
+			fmt.Fprintf(w, "  %s: new, %d bytes\n", s.Page, s.After)
+		case s.After < 0:
+			fmt.Fprintf(w, "  %s: removed, was %d bytes\n", s.Page, s.Before)
+		default:
+			fmt.Fprintf(w, "  %s: %d -> %d bytes (%+d)\n", s.Page, s.Before, s.After, s.After-s.Before)
+		}
+	}
+}
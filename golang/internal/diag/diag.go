@@ -0,0 +1,163 @@
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// Level is how serious a diagnostic is
+type Level string
+
+const (
+	Warning Level = "warning" // Something looks wrong but the page was still written
+	Error   Level = "error"   // The build fails because of it
+)
+
+// Diagnostic is one problem found while building, with where it was found
+type Diagnostic struct {
+	Level   Level  `json:"level"`
+	Rule    Rule   `json:"rule,omitempty"` // The lint rule that found it, if any
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// String formats the diagnostic as file:line: message, followed by the lint rule in brackets
+func (d Diagnostic) String() string {
+	message := d.Message
+	if d.Rule != "" {
+		message += " [" + string(d.Rule) + "]"
+	}
+	switch {
+	case d.File == "":
+		return message
+	case d.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", d.File, d.Line, message)
+	}
+	return fmt.Sprintf("%s: %s", d.File, message)
+}
+
+// Collector gathers the diagnostics of a build. It is safe for concurrent use.
+type Collector struct {
+	mu    sync.Mutex
+	items []Diagnostic
+	seen  map[Diagnostic]bool
+	rules map[Rule]Level // Lint rule levels set by SetRules
+}
+
+// NewCollector creates an empty collector
+func NewCollector() *Collector {
+	return &Collector{seen: make(map[Diagnostic]bool)}
+}
+
+// Add records a diagnostic. Repeats of an identical diagnostic are dropped, so a problem
+// in a template used by a hundred pages is only reported once per page.
+func (c *Collector) Add(level Level, file string, line int, format string, args ...interface{}) {
+	c.add(Diagnostic{Level: level, File: file, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// add records a diagnostic unless an identical one was already recorded
+func (c *Collector) add(d Diagnostic) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seen[d] {
+		return
+	}
+	c.seen[d] = true
+	c.items = append(c.items, d)
+}
+
+// Warn records a warning
+func (c *Collector) Warn(file string, line int, format string, args ...interface{}) {
+	c.Add(Warning, file, line, format, args...)
+}
+
+// Error records an error
+func (c *Collector) Error(file string, line int, format string, args ...interface{}) {
+	c.Add(Error, file, line, format, args...)
+}
+
+// Reset forgets everything collected so far, ready for the next build
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = nil
+	c.seen = make(map[Diagnostic]bool)
+}
+
+// Items returns a copy of the diagnostics in the order they were found
+func (c *Collector) Items() []Diagnostic {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Diagnostic{}, c.items...)
+}
+
+// Count returns how many diagnostics of the given level were collected
+func (c *Collector) Count(level Level) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, d := range c.items {
+		if d.Level == level {
+			n++
+		}
+	}
+	return n
+}
+
+// Report is the JSON form of a build's diagnostics
+type Report struct {
+	Errors      int              `json:"errors"`
+	Warnings    int              `json:"warnings"`
+	Diagnostics []Diagnostic     `json:"diagnostics"`
+	Pages       map[string]int64 `json:"pages,omitempty"` // Size in bytes of each page written, by output path
+}
+
+// WriteJSON writes the diagnostics, with the sizes of the pages written, as a single line
+// of JSON, so the reports of successive builds in watch mode can be read as JSON Lines
+func (c *Collector) WriteJSON(w io.Writer, pages map[string]int64) error {
+	report := Report{
+		Errors:      c.Count(Error),
+		Warnings:    c.Count(Warning),
+		Diagnostics: c.Items(),
+		Pages:       pages,
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("encoding diagnostics: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// WriteText lists the diagnostics followed by a count, or writes nothing if there are none.
+// Without warnings only the errors are listed, for --quiet.
+func (c *Collector) WriteText(w io.Writer, warnings bool) {
+	items := c.Items()
+	if !warnings {
+		items = items[:0]
+		for _, d := range c.Items() {
+			if d.Level == Error {
+				items = append(items, d)
+			}
+		}
+	}
+	if len(items) == 0 {
+		return
+	}
+
+	yellow := color.New(color.FgYellow)
+	red := color.New(color.FgRed)
+	for _, d := range items {
+		if d.Level == Error {
+			fmt.Fprintf(w, "%s %s\n", red.Sprint("Error:"), d)
+		} else {
+			fmt.Fprintf(w, "%s %s\n", yellow.Sprint("Warning:"), d)
+		}
+	}
+	fmt.Fprintf(w, "%d warning(s), %d error(s)\n", c.Count(Warning), c.Count(Error))
+}
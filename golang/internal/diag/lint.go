@@ -0,0 +1,64 @@
+package diag
+
+import "fmt"
+
+// Off is the level of a lint rule that is switched off
+const Off Level = "off"
+
+// Rule is a lint check whose level can be set in the lint section of sniplicity.yaml
+type Rule string
+
+const (
+	MissingReference  Rule = "missing-reference"  // Pastes, templates and includes that don't exist
+	UndefinedVariable Rule = "undefined-variable" // {{variables}} left in a finished page
+	BrokenLink        Rule = "broken-link"        // Links to pages and files missing from the output
+	MissingAlt        Rule = "missing-alt"        // Images without an alt attribute
+	SnippetCollision  Rule = "snippet-collision"  // Snippets and templates defined more than once
+)
+
+// Rules are every lint rule, in the order they are documented
+var Rules = []Rule{MissingReference, UndefinedVariable, BrokenLink, MissingAlt, SnippetCollision}
+
+// DefaultRules are the levels rules have when nothing sets them
+var DefaultRules = map[Rule]Level{
+	MissingReference:  Warning,
+	UndefinedVariable: Off,
+	BrokenLink:        Off,
+	MissingAlt:        Off,
+	SnippetCollision:  Off,
+}
+
+// ParseRuleLevel returns the level with the given name, as written in sniplicity.yaml
+func ParseRuleLevel(name string) (Level, error) {
+	switch Level(name) {
+	case Off, Warning, Error:
+		return Level(name), nil
+	}
+	return Off, fmt.Errorf("unknown lint level %q (use off, warning or error)", name)
+}
+
+// SetRules sets the level of each lint rule. Rules it leaves out keep their default level.
+func (c *Collector) SetRules(levels map[Rule]Level) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rules = levels
+}
+
+// RuleLevel returns the level a rule's problems are recorded at, Off if it is switched off
+func (c *Collector) RuleLevel(rule Rule) Level {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if level, ok := c.rules[rule]; ok {
+		return level
+	}
+	return DefaultRules[rule]
+}
+
+// Check records a problem found by a lint rule at the rule's level, unless it is off
+func (c *Collector) Check(rule Rule, file string, line int, format string, args ...interface{}) {
+	level := c.RuleLevel(rule)
+	if level == Off {
+		return
+	}
+	c.add(Diagnostic{Level: level, Rule: rule, File: file, Line: line, Message: fmt.Sprintf(format, args...)})
+}
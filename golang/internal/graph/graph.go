@@ -0,0 +1,150 @@
+package graph
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Kind is the type of a node in the graph
+type Kind string
+
+const (
+	Page     Kind = "page"
+	Template Kind = "template"
+	Snippet  Kind = "snippet"
+	Include  Kind = "include"
+	Data     Kind = "data" // A file a page declares it depends on with depends
+)
+
+// Node is a page, template, snippet, included file or declared dependency
+type Node struct {
+	ID      string `json:"id"`
+	Kind    Kind   `json:"kind"`
+	Name    string `json:"name"`           // Snippet or template name, or path relative to the input directory
+	Path    string `json:"path,omitempty"` // Source file it is defined in, relative to the input directory when inside it
+	UsedBy  int    `json:"used_by"`        // How many nodes depend on it directly
+	Missing bool   `json:"missing,omitempty"`
+}
+
+// Edge records that From uses To
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Graph is a set of nodes and the dependencies between them
+type Graph struct {
+	Nodes []*Node `json:"nodes"`
+	Edges []Edge  `json:"edges"`
+	byID  map[string]*Node
+	edges map[Edge]bool
+}
+
+// New creates an empty graph
+func New() *Graph {
+	return &Graph{byID: make(map[string]*Node), edges: make(map[Edge]bool)}
+}
+
+// ID returns the ID of the node of the given kind and name
+func ID(kind Kind, name string) string {
+	return string(kind) + ":" + name
+}
+
+// Node returns the node of the given kind and name, adding it if it is new
+func (g *Graph) Node(kind Kind, name string) *Node {
+	id := ID(kind, name)
+	if node, ok := g.byID[id]; ok {
+		return node
+	}
+	node := &Node{ID: id, Kind: kind, Name: name}
+	g.byID[id] = node
+	g.Nodes = append(g.Nodes, node)
+	return node
+}
+
+// Depend records that from uses to, once however often it does
+func (g *Graph) Depend(from, to *Node) {
+	edge := Edge{From: from.ID, To: to.ID}
+	if g.edges[edge] {
+		return
+	}
+	g.edges[edge] = true
+	g.Edges = append(g.Edges, edge)
+	to.UsedBy++
+}
+
+// Sort orders the nodes by kind and name and the edges by their ends, so the output is
+// the same from one run to the next
+func (g *Graph) Sort() {
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		if g.Nodes[i].Kind != g.Nodes[j].Kind {
+			return kindOrder(g.Nodes[i].Kind) < kindOrder(g.Nodes[j].Kind)
+		}
+		return g.Nodes[i].Name < g.Nodes[j].Name
+	})
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+}
+
+// kindOrder lists pages first, then what they use
+func kindOrder(kind Kind) int {
+	switch kind {
+	case Page:
+		return 0
+	case Template:
+		return 1
+	case Snippet:
+		return 2
+	}
+	return 3
+}
+
+// WriteJSON writes the graph as JSON
+func (g *Graph) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(g)
+}
+
+// dotShapes are the Graphviz shapes for each kind of node
+var dotShapes = map[Kind]string{
+	Page:     "box",
+	Template: "component",
+	Snippet:  "ellipse",
+	Include:  "note",
+	Data:     "cylinder",
+}
+
+// WriteDOT writes the graph in Graphviz DOT format, with missing snippets, templates and
+// includes drawn dashed
+func (g *Graph) WriteDOT(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "digraph sniplicity {")
+	fmt.Fprintln(out, "  rankdir=LR;")
+	fmt.Fprintln(out, "  node [fontname=\"Helvetica\"];")
+	for _, node := range g.Nodes {
+		style := ""
+		if node.Missing {
+			style = ", style=dashed, color=red"
+		}
+		fmt.Fprintf(out, "  %s [label=%s, shape=%s%s];\n", dotQuote(node.ID), dotQuote(node.Name), dotShapes[node.Kind], style)
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(out, "  %s -> %s;\n", dotQuote(edge.From), dotQuote(edge.To))
+	}
+	fmt.Fprintln(out, "}")
+	return out.Flush()
+}
+
+// dotQuote returns s as a quoted DOT string
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
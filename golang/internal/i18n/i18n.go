@@ -0,0 +1,286 @@
+package i18n
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// LocalesDir is the project folder holding the translation catalogs, one per language
+const LocalesDir = "locales"
+
+// StringsDir is a project folder of string tables such as i18n/en.yaml, read along with
+// the catalogs in LocalesDir
+const StringsDir = "i18n"
+
+// GlobalsSuffix ends the name of the locales file holding one language's globals rather
+// than its translations, e.g. de.globals.yaml
+const GlobalsSuffix = ".globals.yaml"
+
+// TemplateName is the base name of the catalog extracted without a language, the
+// starting point for new translations
+const TemplateName = "messages"
+
+var (
+	// markerRegex matches a translatable string, {{t "Read more"}} or {{t 'Read more'}},
+	// or a key looked up in the catalogs: {{t nav.home}}
+	markerRegex = regexp.MustCompile(`\{\{\s*t\s+(?:"([^"]*)"|'([^']*)'|([A-Za-z0-9_][A-Za-z0-9_.\-]*))\s*\}\}`)
+
+	// protectedRegex matches a marker hidden from the markdown converter by Protect
+	protectedRegex = regexp.MustCompile(`\{\{t:([0-9a-f]*)\}\}`)
+)
+
+// Catalog maps source strings to their translations in one language
+type Catalog map[string]string
+
+// Message is a translatable string and where it is used, as path:line references
+type Message struct {
+	ID   string
+	Refs []string
+}
+
+// markerID returns the string or key inside a marker match
+func markerID(match []string) string {
+	for _, id := range match[1:] {
+		if id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// Translate replaces every marker in text with its translation from the catalog, or with
+// the source string itself when the catalog doesn't have it. A nil catalog is fine.
+func Translate(text string, catalog Catalog) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+	return markerRegex.ReplaceAllStringFunc(text, func(marker string) string {
+		id := markerID(markerRegex.FindStringSubmatch(marker))
+		if translation := catalog[id]; translation != "" {
+			return translation
+		}
+		return id
+	})
+}
+
+// Protect hides markers from the markdown converter, which would otherwise turn their
+// quotes into curly ones. Restore brings them back in the converted HTML.
+func Protect(text string) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+	return markerRegex.ReplaceAllStringFunc(text, func(marker string) string {
+		id := markerID(markerRegex.FindStringSubmatch(marker))
+		return "{{t:" + hex.EncodeToString([]byte(id)) + "}}"
+	})
+}
+
+// Restore turns markers hidden by Protect back into their usual form
+func Restore(text string) string {
+	if !strings.Contains(text, "{{t:") {
+		return text
+	}
+	return protectedRegex.ReplaceAllStringFunc(text, func(marker string) string {
+		id, err := hex.DecodeString(protectedRegex.FindStringSubmatch(marker)[1])
+		if err != nil {
+			return marker
+		}
+		quote := `"`
+		if strings.Contains(string(id), `"`) {
+			quote = "'"
+		}
+		return "{{t " + quote + string(id) + quote + "}}"
+	})
+}
+
+// ExtractDir collects the translatable strings from every page and template in a source
+// folder, in the order they first appear
+func ExtractDir(inputDir string) ([]Message, error) {
+	var messages []Message
+	index := make(map[string]int)
+
+	err := filepath.WalkDir(inputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != inputDir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".html", ".htm", ".md", ".mdown", ".markdown":
+		default:
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+		rel, err := filepath.Rel(inputDir, path)
+		if err != nil {
+			rel = path
+		}
+
+		for i, line := range strings.Split(string(data), "\n") {
+			for _, match := range markerRegex.FindAllStringSubmatch(line, -1) {
+				id := markerID(match)
+				if id == "" {
+					continue
+				}
+				ref := fmt.Sprintf("%s:%d", filepath.ToSlash(rel), i+1)
+				if n, seen := index[id]; seen {
+					messages[n].Refs = append(messages[n].Refs, ref)
+					continue
+				}
+				index[id] = len(messages)
+				messages = append(messages, Message{ID: id, Refs: []string{ref}})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// LoadCatalogs reads every <lang>.po, <lang>.json and <lang>.yaml catalog in the given
+// folders, keyed by language, with the strings of later ones winning. A missing folder
+// just means there are no translations in it.
+func LoadCatalogs(dirs ...string) (map[string]Catalog, error) {
+	catalogs := make(map[string]Catalog)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", dir, err)
+		}
+
+		for _, entry := range entries {
+			ext := filepath.Ext(entry.Name())
+			lang := strings.TrimSuffix(entry.Name(), ext)
+			if entry.IsDir() || lang == TemplateName || strings.HasSuffix(entry.Name(), GlobalsSuffix) {
+				continue
+			}
+			switch ext {
+			case ".po", ".json", ".yaml", ".yml":
+			default:
+				continue
+			}
+
+			catalog, err := LoadCatalog(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			if catalogs[lang] == nil {
+				catalogs[lang] = catalog
+				continue
+			}
+			for id, translation := range catalog {
+				catalogs[lang][id] = translation
+			}
+		}
+	}
+	return catalogs, nil
+}
+
+// WithFallback returns the catalogs with the strings each one lacks taken from the
+// fallback language's catalog, so an unfinished translation shows the fallback language
+// rather than the source strings or keys
+func WithFallback(catalogs map[string]Catalog, fallback string) map[string]Catalog {
+	base := catalogs[fallback]
+	if len(base) == 0 {
+		return catalogs
+	}
+	merged := make(map[string]Catalog, len(catalogs))
+	for lang, catalog := range catalogs {
+		if lang == fallback {
+			merged[lang] = catalog
+			continue
+		}
+		combined := make(Catalog, len(base)+len(catalog))
+		for id, translation := range base {
+			combined[id] = translation
+		}
+		for id, translation := range catalog {
+			if translation != "" {
+				combined[id] = translation
+			}
+		}
+		merged[lang] = combined
+	}
+	return merged
+}
+
+// LoadCatalog reads a .po, .json or .yaml catalog
+func LoadCatalog(path string) (Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading catalog: %w", err)
+	}
+
+	var catalog Catalog
+	switch filepath.Ext(path) {
+	case ".json":
+		catalog, err = parseJSON(data)
+	case ".yaml", ".yml":
+		catalog, err = parseYAML(data)
+	default:
+		catalog, err = parsePO(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return catalog, nil
+}
+
+// WriteCatalog writes a catalog for lang holding the extracted messages, keeping the
+// translations already in existing. Translations whose string is no longer used are kept
+// too (as obsolete entries in PO files), so moving a string around never loses its
+// translation. The format follows the file extension. It returns how many of the
+// messages are still untranslated.
+func WriteCatalog(path, lang string, messages []Message, existing Catalog) (int, error) {
+	untranslated := 0
+	used := make(map[string]bool)
+	for _, message := range messages {
+		used[message.ID] = true
+		if existing[message.ID] == "" {
+			untranslated++
+		}
+	}
+	var obsolete []string
+	for id, translation := range existing {
+		if !used[id] && translation != "" {
+			obsolete = append(obsolete, id)
+		}
+	}
+
+	var data []byte
+	var err error
+	if filepath.Ext(path) == ".json" {
+		data, err = formatJSON(messages, existing, obsolete)
+	} else {
+		data = formatPO(lang, messages, existing, obsolete)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, fmt.Errorf("cannot create directory %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return 0, fmt.Errorf("cannot write %s: %w", path, err)
+	}
+	return untranslated, nil
+}
@@ -0,0 +1,123 @@
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parsePO reads the msgid/msgstr pairs of a gettext PO file. Obsolete (#~) entries are
+// read like any other, so a string that comes back gets its old translation.
+func parsePO(data []byte) (Catalog, error) {
+	catalog := make(Catalog)
+
+	var id, translation *strings.Builder
+	var current *strings.Builder
+	flush := func() {
+		if id != nil && translation != nil && id.Len() > 0 {
+			catalog[id.String()] = translation.String()
+		}
+		id, translation, current = nil, nil, nil
+	}
+
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#~") {
+			line = strings.TrimSpace(line[2:])
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		keyword, rest := line, ""
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			keyword, rest = line[:i], strings.TrimSpace(line[i:])
+		}
+
+		switch {
+		case strings.HasPrefix(line, `"`):
+			rest = line
+		case keyword == "msgid":
+			flush()
+			id = &strings.Builder{}
+			current = id
+		case keyword == "msgstr" || keyword == "msgstr[0]":
+			translation = &strings.Builder{}
+			current = translation
+		default:
+			current = nil // msgctxt, plurals and anything else aren't used
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+		text, err := strconv.Unquote(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: malformed string %s", n+1, rest)
+		}
+		current.WriteString(text)
+	}
+	flush()
+
+	return catalog, nil
+}
+
+// formatPO writes messages as a gettext PO file, with their source references
+func formatPO(lang string, messages []Message, existing Catalog, obsolete []string) []byte {
+	var b strings.Builder
+	if lang == "" {
+		b.WriteString("# Translatable strings extracted by sniplicity\n")
+	} else {
+		fmt.Fprintf(&b, "# %s translations, extracted by sniplicity\n", lang)
+	}
+	b.WriteString("msgid \"\"\nmsgstr \"\"\n")
+	if lang != "" {
+		fmt.Fprintf(&b, "%s\n", poQuote("Language: "+lang+"\n"))
+	}
+	b.WriteString(poQuote("Content-Type: text/plain; charset=UTF-8\n") + "\n")
+
+	for _, message := range messages {
+		fmt.Fprintf(&b, "\n#: %s\n", strings.Join(message.Refs, " "))
+		fmt.Fprintf(&b, "msgid %s\nmsgstr %s\n", poQuote(message.ID), poQuote(existing[message.ID]))
+	}
+
+	sort.Strings(obsolete)
+	for _, id := range obsolete {
+		fmt.Fprintf(&b, "\n#~ msgid %s\n#~ msgstr %s\n", poQuote(id), poQuote(existing[id]))
+	}
+	return []byte(b.String())
+}
+
+// poQuote quotes a string the way PO files expect
+func poQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`).Replace(s) + `"`
+}
+
+// parseJSON reads a catalog written as a flat {"source": "translation"} object
+func parseJSON(data []byte) (Catalog, error) {
+	catalog := make(Catalog)
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("expected an object of \"source\": \"translation\" strings: %w", err)
+	}
+	return catalog, nil
+}
+
+// formatJSON writes messages as a flat JSON object, obsolete translations included
+func formatJSON(messages []Message, existing Catalog, obsolete []string) ([]byte, error) {
+	catalog := make(Catalog)
+	for _, message := range messages {
+		catalog[message.ID] = existing[message.ID]
+	}
+	for _, id := range obsolete {
+		catalog[id] = existing[id]
+	}
+
+	data, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding catalog: %w", err)
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,47 @@
+package i18n
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseYAML reads a YAML string table, where nested keys are joined with dots, so
+//
+//	nav:
+//	  home: Startseite
+//
+// gives the string nav.home
+func parseYAML(data []byte) (Catalog, error) {
+	var table map[string]interface{}
+	if err := yaml.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	catalog := make(Catalog)
+	if err := flattenTable(catalog, "", table); err != nil {
+		return nil, err
+	}
+	return catalog, nil
+}
+
+// flattenTable adds the strings of a YAML table to a catalog, with prefix before their keys
+func flattenTable(catalog Catalog, prefix string, table map[string]interface{}) error {
+	for key, value := range table {
+		id := prefix + key
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if err := flattenTable(catalog, id+".", value); err != nil {
+				return err
+			}
+		case string:
+			catalog[id] = value
+		case nil:
+			catalog[id] = ""
+		case []interface{}:
+			return fmt.Errorf("%s is a list, expected a string or a table of strings", id)
+		default:
+			catalog[id] = fmt.Sprint(value)
+		}
+	}
+	return nil
+}
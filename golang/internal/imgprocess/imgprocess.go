@@ -10,6 +10,8 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"sniplicity/internal/logging"
 )
 
 // ImageDimensions holds width and height of an image
@@ -38,7 +40,7 @@ func GetImageDimensions(imagePath string) (ImageDimensions, error) {
 }
 
 // ProcessHTMLForImages processes HTML content to add width and height attributes to img tags
-func ProcessHTMLForImages(htmlContent string, outputDir string, verbose bool) (string, error) {
+func ProcessHTMLForImages(htmlContent string, outputDir string) (string, error) {
 	lines := strings.Split(htmlContent, "\n")
 	
 	// Regex patterns for img tags and picture elements
@@ -60,7 +62,7 @@ func ProcessHTMLForImages(htmlContent string, outputDir string, verbose bool) (s
 		
 		// Process img tags in this line
 		processedLine := imgRegex.ReplaceAllStringFunc(line, func(match string) string {
-			return processImgTag(match, outputDir, insidePicture, verbose)
+			return processImgTag(match, outputDir, insidePicture)
 		})
 		
 		result = append(result, processedLine)
@@ -70,7 +72,7 @@ func ProcessHTMLForImages(htmlContent string, outputDir string, verbose bool) (s
 }
 
 // ProcessHTMLForMarkdownImages processes HTML content to add width and height attributes to img tags that came from markdown
-func ProcessHTMLForMarkdownImages(htmlContent string, outputDir string, htmlDir string, markdownImages map[string]bool, verbose bool) (string, error) {
+func ProcessHTMLForMarkdownImages(htmlContent string, outputDir string, htmlDir string, markdownImages map[string]bool) (string, error) {
 	lines := strings.Split(htmlContent, "\n")
 	
 	// Regex patterns for img tags and picture elements
@@ -92,7 +94,7 @@ func ProcessHTMLForMarkdownImages(htmlContent string, outputDir string, htmlDir
 		
 		// Process img tags in this line
 		processedLine := imgRegex.ReplaceAllStringFunc(line, func(match string) string {
-			return processMarkdownImgTag(match, outputDir, htmlDir, markdownImages, insidePicture, verbose)
+			return processMarkdownImgTag(match, outputDir, htmlDir, markdownImages, insidePicture)
 		})
 		
 		result = append(result, processedLine)
@@ -102,7 +104,7 @@ func ProcessHTMLForMarkdownImages(htmlContent string, outputDir string, htmlDir
 }
 
 // processImgTag processes a single img tag
-func processImgTag(imgTag string, outputDir string, insidePicture bool, verbose bool) string {
+func processImgTag(imgTag string, outputDir string, insidePicture bool) string {
 	// Check if width and height attributes already exist
 	hasWidth := regexp.MustCompile(`(?i)\swidth\s*=`).MatchString(imgTag)
 	hasHeight := regexp.MustCompile(`(?i)\sheight\s*=`).MatchString(imgTag)
@@ -154,15 +156,11 @@ func processImgTag(imgTag string, outputDir string, insidePicture bool, verbose
 	// Get image dimensions
 	dims, err := GetImageDimensions(imagePath)
 	if err != nil {
-		if verbose {
-			fmt.Printf("Warning: Cannot get dimensions for image %s: %v\n", imagePath, err)
-		}
+		logging.Debugf("  Cannot get dimensions for image %s: %v", imagePath, err)
 		return imgTag
 	}
 	
-	if verbose {
-		fmt.Printf("  Adding dimensions to %s: %dx%d\n", srcPath, dims.Width, dims.Height)
-	}
+	logging.Debugf("  Adding dimensions to %s: %dx%d", srcPath, dims.Width, dims.Height)
 	
 	// Add width and height attributes
 	result := imgTag
@@ -214,7 +212,7 @@ func addAttribute(imgTag, attrName, attrValue string) string {
 }
 
 // processMarkdownImgTag processes a single img tag, but only if it came from markdown
-func processMarkdownImgTag(imgTag string, outputDir string, htmlDir string, markdownImages map[string]bool, insidePicture bool, verbose bool) string {
+func processMarkdownImgTag(imgTag string, outputDir string, htmlDir string, markdownImages map[string]bool, insidePicture bool) string {
 	// Extract src attribute first to check if this image came from markdown
 	srcRegex := regexp.MustCompile(`(?i)\ssrc\s*=\s*["']([^"']+)["']`)
 	srcMatch := srcRegex.FindStringSubmatch(imgTag)
@@ -231,11 +229,11 @@ func processMarkdownImgTag(imgTag string, outputDir string, htmlDir string, mark
 	}
 	
 	// Use a modified version of processImgTag that uses htmlDir for relative paths
-	return processImgTagWithContext(imgTag, outputDir, htmlDir, insidePicture, verbose)
+	return processImgTagWithContext(imgTag, outputDir, htmlDir, insidePicture)
 }
 
 // processImgTagWithContext processes a single img tag with HTML directory context for relative paths
-func processImgTagWithContext(imgTag string, outputDir string, htmlDir string, insidePicture bool, verbose bool) string {
+func processImgTagWithContext(imgTag string, outputDir string, htmlDir string, insidePicture bool) string {
 	// Check if width and height attributes already exist
 	hasWidth := regexp.MustCompile(`(?i)\swidth\s*=`).MatchString(imgTag)
 	hasHeight := regexp.MustCompile(`(?i)\sheight\s*=`).MatchString(imgTag)
@@ -287,9 +285,7 @@ func processImgTagWithContext(imgTag string, outputDir string, htmlDir string, i
 	// Get image dimensions
 	dims, err := GetImageDimensions(imagePath)
 	if err != nil {
-		if verbose {
-			fmt.Printf("Warning: Cannot get dimensions for image %s: %v\n", imagePath, err)
-		}
+		logging.Debugf("  Cannot get dimensions for image %s: %v", imagePath, err)
 		return imgTag
 	}
 	
@@ -301,9 +297,7 @@ func processImgTagWithContext(imgTag string, outputDir string, htmlDir string, i
 		result = addAttribute(result, "height", fmt.Sprintf("%d", dims.Height))
 	}
 	
-	if verbose {
-		fmt.Printf("Adding dimensions to %s: %dx%d\n", srcPath, dims.Width, dims.Height)
-	}
+	logging.Debugf("  Adding dimensions to %s: %dx%d", srcPath, dims.Width, dims.Height)
 	
 	return result
 }
\ No newline at end of file
@@ -0,0 +1,245 @@
+package integrity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FileName is the integrity manifest written to the root of the output directory
+const FileName = "integrity.json"
+
+// File is the checksum and size of one output file
+type File struct {
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// Manifest lists every file of a built site by path relative to the output directory,
+// with forward slashes
+type Manifest struct {
+	Files map[string]File `json:"files"`
+}
+
+// Problem is a file of a deployed copy that doesn't match the manifest
+type Problem struct {
+	Path    string
+	Message string
+}
+
+// Build hashes every file in dir except hidden ones, like .git, and the manifest itself
+func Build(dir string) (*Manifest, error) {
+	m := &Manifest{Files: make(map[string]File)}
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == FileName {
+			return nil
+		}
+		file, err := hashFile(p)
+		if err != nil {
+			return err
+		}
+		m.Files[rel] = file
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("hashing %s: %w", dir, err)
+	}
+	return m, nil
+}
+
+// Encode returns the manifest as indented JSON, with the files in path order
+func (m *Manifest) Encode() ([]byte, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding integrity manifest: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// Load reads a manifest from a file, or from a URL when location starts with http:// or
+// https://
+func Load(location string) (*Manifest, error) {
+	var data []byte
+	var err error
+	if isURL(location) {
+		data, err = fetch(location)
+	} else {
+		data, err = os.ReadFile(location)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", location, err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", location, err)
+	}
+	if m.Files == nil {
+		return nil, fmt.Errorf("%s lists no files", location)
+	}
+	return &m, nil
+}
+
+// Locate returns where the manifest of a deployed copy is: FileName in the folder or
+// under the URL
+func Locate(target string) string {
+	if isURL(target) {
+		return strings.TrimSuffix(target, "/") + "/" + FileName
+	}
+	return filepath.Join(target, FileName)
+}
+
+// Verify checks a deployed copy of the site, a local folder or a base URL, against the
+// manifest and returns every file that is missing or differs, sorted by path. A folder
+// is also checked for files the manifest doesn't list; a URL can't be listed.
+func Verify(m *Manifest, target string) []Problem {
+	paths := make([]string, 0, len(m.Files))
+	for p := range m.Files {
+		paths = append(paths, p)
+	}
+
+	check := checkLocal
+	if isURL(target) {
+		check = checkURL
+	}
+
+	// Fetching one file at a time is slow for a site on a server
+	var mu sync.Mutex
+	var problems []Problem
+	var wg sync.WaitGroup
+	queue := make(chan string)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range queue {
+				if message := compare(check, target, p, m.Files[p]); message != "" {
+					mu.Lock()
+					problems = append(problems, Problem{Path: p, Message: message})
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, p := range paths {
+		queue <- p
+	}
+	close(queue)
+	wg.Wait()
+
+	if !isURL(target) {
+		if deployed, err := Build(target); err == nil {
+			for p := range deployed.Files {
+				if _, listed := m.Files[p]; !listed {
+					problems = append(problems, Problem{Path: p, Message: "not in the build"})
+				}
+			}
+		}
+	}
+
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Path < problems[j].Path })
+	return problems
+}
+
+// compare checks one deployed file against its entry, returning what's wrong or ""
+func compare(check func(target, p string) (File, error), target, p string, want File) string {
+	got, err := check(target, p)
+	switch {
+	case os.IsNotExist(err):
+		return "missing"
+	case err != nil:
+		return err.Error()
+	case got.Size != want.Size:
+		return fmt.Sprintf("size %d, expected %d", got.Size, want.Size)
+	case got.SHA256 != want.SHA256:
+		return "content differs"
+	}
+	return ""
+}
+
+// checkLocal hashes a file of a deployed folder
+func checkLocal(dir, p string) (File, error) {
+	return hashFile(filepath.Join(dir, filepath.FromSlash(p)))
+}
+
+// checkURL downloads and hashes a file of a deployed site
+func checkURL(base, p string) (File, error) {
+	u := strings.TrimSuffix(base, "/") + (&url.URL{Path: path.Join("/", p)}).EscapedPath()
+	resp, err := http.Get(u)
+	if err != nil {
+		return File{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return File{}, os.ErrNotExist
+	}
+	if resp.StatusCode != http.StatusOK {
+		return File{}, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return hashReader(resp.Body)
+}
+
+// hashFile returns the checksum and size of the file at p
+func hashFile(p string) (File, error) {
+	file, err := os.Open(p)
+	if err != nil {
+		return File{}, err
+	}
+	defer file.Close()
+	return hashReader(file)
+}
+
+// hashReader returns the checksum and size of everything r holds
+func hashReader(r io.Reader) (File, error) {
+	h := sha256.New()
+	size, err := io.Copy(h, r)
+	if err != nil {
+		return File{}, err
+	}
+	return File{SHA256: hex.EncodeToString(h.Sum(nil)), Size: size}, nil
+}
+
+// fetch downloads the body of a URL
+func fetch(u string) ([]byte, error) {
+	resp, err := http.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// isURL reports whether location is an http or https URL rather than a path
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
@@ -0,0 +1,78 @@
+package launcher
+
+import (
+	"os"
+	"runtime"
+	"strings"
+
+	"sniplicity/internal/logging"
+
+	"github.com/atotto/clipboard"
+	"github.com/fatih/color"
+	"github.com/skratchdot/open-golang/open"
+)
+
+// Options chooses what is done with the URL of a server that just started
+type Options struct {
+	Clipboard   bool // Copy the URL to the clipboard
+	OpenBrowser bool // Open the URL in the default browser
+}
+
+// copyURL and openURL copy a URL to the clipboard and open it in the default browser.
+// Tests replace them.
+var (
+	copyURL = clipboard.WriteAll
+	openURL = open.Run
+)
+
+// Headless reports whether there is no desktop session to copy to or open a browser in:
+// in CI, over SSH without X forwarding, or on Linux and the BSDs (including WSL) without
+// a display server
+func Headless() bool {
+	if os.Getenv("CI") != "" {
+		return true
+	}
+	display := os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+	if (os.Getenv("SSH_CONNECTION") != "" || os.Getenv("SSH_TTY") != "") && !display {
+		return true
+	}
+	switch runtime.GOOS {
+	case "darwin", "windows":
+		return false
+	}
+	return !display
+}
+
+// Launch copies url to the clipboard and opens it in the browser as opts allow, and prints
+// it when it wasn't copied. what names the page for messages, e.g. "project selector", and
+// may be "" for the site itself. Nothing is tried in a headless session, as it would only
+// fail.
+func Launch(url, what string, opts Options) {
+	if Headless() {
+		logging.Debugf("No desktop session found, so the URL isn't copied or opened")
+		opts = Options{}
+	}
+
+	label := "URL"
+	if what != "" {
+		label = strings.ToUpper(what[:1]) + what[1:] + " URL"
+	}
+	if opts.Clipboard && copyURL(url) == nil {
+		logging.Infof("✓ %s copied to clipboard - you can paste it anywhere!", label)
+	} else {
+		logging.Infof("ℹ Copy this URL: %s", color.New(color.FgCyan).Sprint(url))
+	}
+
+	if !opts.OpenBrowser {
+		return
+	}
+	page := ""
+	if what != "" {
+		page = what + " "
+	}
+	if err := openURL(url); err == nil {
+		logging.Infof("✓ Opening %sin your default browser...", page)
+	} else {
+		logging.Infof("ℹ Please open the URL above in your browser")
+	}
+}
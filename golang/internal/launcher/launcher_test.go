@@ -0,0 +1,134 @@
+package launcher
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"sniplicity/internal/logging"
+)
+
+// desktopEnv is the environment of a desktop session on Linux
+var desktopEnv = map[string]string{"CI": "", "SSH_CONNECTION": "", "SSH_TTY": "", "DISPLAY": ":0", "WAYLAND_DISPLAY": ""}
+
+func setEnv(t *testing.T, env map[string]string, changes map[string]string) {
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+	for name, value := range changes {
+		t.Setenv(name, value)
+	}
+}
+
+func TestHeadless(t *testing.T) {
+	desktopOS := runtime.GOOS == "darwin" || runtime.GOOS == "windows"
+	for _, test := range []struct {
+		name    string
+		changes map[string]string
+		want    bool
+	}{
+		{"desktop", nil, false},
+		{"wayland", map[string]string{"DISPLAY": "", "WAYLAND_DISPLAY": "wayland-0"}, false},
+		{"CI", map[string]string{"CI": "true"}, true},
+		{"SSH without forwarding", map[string]string{"SSH_CONNECTION": "10.0.0.1 22 10.0.0.2 22", "DISPLAY": ""}, true},
+		{"SSH terminal without forwarding", map[string]string{"SSH_TTY": "/dev/pts/0", "DISPLAY": ""}, true},
+		{"SSH with X forwarding", map[string]string{"SSH_CONNECTION": "10.0.0.1 22 10.0.0.2 22", "DISPLAY": "localhost:10.0"}, false},
+		{"no display server", map[string]string{"DISPLAY": ""}, !desktopOS},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			setEnv(t, desktopEnv, test.changes)
+			if got := Headless(); got != test.want {
+				t.Errorf("Headless() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLaunch(t *testing.T) {
+	const url = "http://localhost:3000"
+	failed := errors.New("no clipboard utility found")
+	for _, test := range []struct {
+		name       string
+		what       string
+		opts       Options
+		headless   bool
+		copyErr    error
+		openErr    error
+		wantCopied bool
+		wantOpened bool
+		wantOutput []string
+	}{
+		{
+			name: "copies and opens", opts: Options{Clipboard: true, OpenBrowser: true},
+			wantCopied: true, wantOpened: true,
+			wantOutput: []string{"URL copied to clipboard", "Opening in your default browser"},
+		},
+		{
+			name: "names the page", what: "project selector", opts: Options{Clipboard: true, OpenBrowser: true},
+			wantCopied: true, wantOpened: true,
+			wantOutput: []string{"Project selector URL copied", "Opening project selector in your default browser"},
+		},
+		{
+			name: "clipboard fails", opts: Options{Clipboard: true}, copyErr: failed,
+			wantCopied: true,
+			wantOutput: []string{"Copy this URL: " + url},
+		},
+		{
+			name: "browser fails", opts: Options{OpenBrowser: true}, openErr: errors.New("exec: xdg-open not found"),
+			wantOpened: true,
+			wantOutput: []string{"Copy this URL: " + url, "Please open the URL above in your browser"},
+		},
+		{
+			name: "both off", opts: Options{},
+			wantOutput: []string{"Copy this URL: " + url},
+		},
+		{
+			name: "headless", opts: Options{Clipboard: true, OpenBrowser: true}, headless: true,
+			wantOutput: []string{"Copy this URL: " + url},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if test.headless {
+				setEnv(t, desktopEnv, map[string]string{"CI": "true"})
+			} else {
+				setEnv(t, desktopEnv, nil)
+			}
+			copied, opened := false, false
+			defer func(copy, open func(string) error) { copyURL, openURL = copy, open }(copyURL, openURL)
+			copyURL = func(got string) error {
+				copied = true
+				if got != url {
+					t.Errorf("copied %q, want %q", got, url)
+				}
+				return test.copyErr
+			}
+			openURL = func(got string) error {
+				opened = true
+				if got != url {
+					t.Errorf("opened %q, want %q", got, url)
+				}
+				return test.openErr
+			}
+			var out bytes.Buffer
+			logging.SetOutput(&out, &out)
+			defer logging.SetOutput(os.Stdout, os.Stderr)
+			logging.SetColor(false)
+
+			Launch(url, test.what, test.opts)
+			if copied != test.wantCopied || opened != test.wantOpened {
+				t.Errorf("copied %v and opened %v, want %v and %v", copied, opened, test.wantCopied, test.wantOpened)
+			}
+			for _, want := range test.wantOutput {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output %q doesn't say %q", out.String(), want)
+				}
+			}
+			if test.copyErr == nil && test.wantCopied && strings.Contains(out.String(), "Copy this URL") {
+				t.Errorf("output %q shows the URL though it was copied", out.String())
+			}
+		})
+	}
+}
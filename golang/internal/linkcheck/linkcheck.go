@@ -0,0 +1,144 @@
+package linkcheck
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// BrokenLink is an internal href or src that points at a file missing from the output
+type BrokenLink struct {
+	File string `json:"file"` // Page containing the link, relative to the output directory
+	Line int    `json:"line"` // 1-based line number
+	URL  string `json:"url"`
+}
+
+// String formats the link as file:line: url for terminal output
+func (l BrokenLink) String() string {
+	return fmt.Sprintf("%s:%d: %s", l.File, l.Line, l.URL)
+}
+
+// linkAttrRegex matches href and src attributes with quoted values
+var linkAttrRegex = regexp.MustCompile(`(?i)\b(?:href|src)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+// schemeRegex matches URLs with a scheme such as https:, mailto: or data:
+var schemeRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+// Check scans every HTML file in outputDir for internal links to files that don't exist
+func Check(outputDir string) ([]BrokenLink, error) {
+	var broken []BrokenLink
+
+	err := filepath.Walk(outputDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip unreadable entries
+		}
+		if info.IsDir() {
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(filePath))
+		if ext != ".html" && ext != ".htm" {
+			return nil
+		}
+
+		links, err := checkFile(outputDir, filePath)
+		if err != nil {
+			return err
+		}
+		broken = append(broken, links...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("checking links in %s: %w", outputDir, err)
+	}
+
+	sort.SliceStable(broken, func(i, j int) bool {
+		if broken[i].File != broken[j].File {
+			return broken[i].File < broken[j].File
+		}
+		return broken[i].Line < broken[j].Line
+	})
+	return broken, nil
+}
+
+// checkFile returns the broken links in a single HTML file
+func checkFile(outputDir, filePath string) ([]BrokenLink, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", filePath, err)
+	}
+	defer file.Close()
+
+	relFile, err := filepath.Rel(outputDir, filePath)
+	if err != nil {
+		relFile = filePath
+	}
+	relFile = filepath.ToSlash(relFile)
+
+	var broken []BrokenLink
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		for _, match := range linkAttrRegex.FindAllStringSubmatch(scanner.Text(), -1) {
+			link := match[1] + match[2]
+			if !exists(outputDir, path.Dir(relFile), link) {
+				broken = append(broken, BrokenLink{File: relFile, Line: lineNum, URL: link})
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", filePath, err)
+	}
+	return broken, nil
+}
+
+// exists reports whether an internal link resolves to a file in the output directory.
+// External links, fragments and anything that isn't a plain path count as existing.
+func exists(outputDir, pageDir, link string) bool {
+	link = strings.TrimSpace(link)
+	if link == "" || strings.HasPrefix(link, "#") || strings.HasPrefix(link, "//") ||
+		schemeRegex.MatchString(link) || strings.Contains(link, "{{") {
+		return true
+	}
+
+	// Drop the query string and fragment
+	if idx := strings.IndexAny(link, "?#"); idx >= 0 {
+		link = link[:idx]
+		if link == "" {
+			return true
+		}
+	}
+	if unescaped, err := url.PathUnescape(link); err == nil {
+		link = unescaped
+	}
+
+	target := link
+	if !strings.HasPrefix(target, "/") {
+		target = path.Join("/", pageDir, target)
+	}
+	target = filepath.Join(outputDir, filepath.FromSlash(path.Clean(target)))
+
+	info, err := os.Stat(target)
+	if err == nil && !info.IsDir() {
+		return true
+	}
+	if err == nil && isFile(filepath.Join(target, "index.html")) {
+		return true
+	}
+
+	// Pretty URLs: /about is about.html, as the dev server and most static hosts serve it
+	return target != filepath.Clean(outputDir) && filepath.Ext(target) == "" && isFile(target+".html")
+}
+
+// isFile reports whether path is an existing file rather than a folder
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
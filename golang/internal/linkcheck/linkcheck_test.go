@@ -0,0 +1,62 @@
+package linkcheck
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"about.html":           "",
+		"blog/index.html":      "",
+		"blog/first-post.html": "",
+		"docs/guide.html":      "",
+		"docs/setup/.keep":     "",
+		"css/site.css":         "",
+		"index.html": `<a href="/about">About</a>
+<a href="/about/">About</a>
+<a href="about.html">About</a>
+<a href="/blog/">Blog</a>
+<a href="/blog/first-post?ref=home#top">Post</a>
+<a href="/docs/guide">Guide</a>
+<a href="/docs/setup">Setup</a>
+<a href="/contact">Contact</a>
+<a href="/about.htm">About</a>
+<a href="/css/site">Styles</a>
+<link href="/css/site.css">
+<a href="https://example.com/missing">External</a>`,
+		"blog/first-post/.keep": "",
+		"docs/index.html":       `<a href="guide">Guide</a> <a href="../about">About</a> <a href="missing">Missing</a>`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	broken, err := Check(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, link := range broken {
+		got = append(got, link.String())
+	}
+	want := []string{
+		"docs/index.html:1: missing",
+		"index.html:7: /docs/setup",
+		"index.html:8: /contact",
+		"index.html:9: /about.htm",
+		"index.html:10: /css/site",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Check() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,130 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// Level is how much sniplicity prints while it works
+type Level int
+
+const (
+	Error Level = iota // Only problems that stop a build or a server
+	Warn               // Problems worth knowing about that sniplicity works around
+	Info               // Progress messages such as "Success!" (the default)
+	Debug              // Every step of the build, for tracking down problems
+)
+
+// Levels are the level names accepted by ParseLevel, quietest first
+var Levels = []string{"error", "warn", "info", "debug"}
+
+// String returns the level's name
+func (l Level) String() string {
+	if l < Error || l > Debug {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return Levels[l]
+}
+
+// ParseLevel returns the level with the given name
+func ParseLevel(name string) (Level, error) {
+	for i, level := range Levels {
+		if strings.EqualFold(name, level) {
+			return Level(i), nil
+		}
+	}
+	return Info, fmt.Errorf("unknown log level %q (use %s)", name, strings.Join(Levels, ", "))
+}
+
+var (
+	mu     sync.Mutex
+	level            = Info
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+	hook   func(Level, string)
+)
+
+// SetLevel sets the quietest level that is still printed
+func SetLevel(l Level) {
+	mu.Lock()
+	defer mu.Unlock()
+	level = l
+}
+
+// CurrentLevel returns the level set by SetLevel
+func CurrentLevel() Level {
+	mu.Lock()
+	defer mu.Unlock()
+	return level
+}
+
+// Enabled reports whether messages at the given level are printed
+func Enabled(l Level) bool {
+	return l <= CurrentLevel()
+}
+
+// SetOutput redirects messages, for example to keep stdout clean for machine-readable output.
+// Info and debug messages go to out, warnings and errors to errOut.
+func SetOutput(out, errOut io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	stdout, stderr = out, errOut
+}
+
+// SetHook passes every printed message to fn as well, for example to show it in the web
+// interface. nil removes the hook. fn must not log itself.
+func SetHook(fn func(Level, string)) {
+	mu.Lock()
+	defer mu.Unlock()
+	hook = fn
+}
+
+// SetColor turns ANSI colours on or off. fatih/color already turns them off when stdout
+// isn't a terminal or NO_COLOR is set, so this is only needed to force them off.
+func SetColor(enabled bool) {
+	color.NoColor = !enabled
+}
+
+// Errorf prints an error message
+func Errorf(format string, args ...interface{}) {
+	write(Error, color.New(color.FgRed).Sprint("Error: ")+format, args...)
+}
+
+// Warnf prints a warning
+func Warnf(format string, args ...interface{}) {
+	write(Warn, color.New(color.FgYellow).Sprint("Warning: ")+format, args...)
+}
+
+// Infof prints a progress message
+func Infof(format string, args ...interface{}) {
+	write(Info, format, args...)
+}
+
+// Debugf prints a detail of what the build is doing
+func Debugf(format string, args ...interface{}) {
+	write(Debug, format, args...)
+}
+
+// write prints a message followed by a newline if its level is enabled
+func write(l Level, format string, args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	if l > level {
+		return
+	}
+
+	w := stdout
+	if l <= Warn {
+		w = stderr
+	}
+	message := fmt.Sprintf(format, args...)
+	fmt.Fprintln(w, message)
+	if hook != nil {
+		hook(l, message)
+	}
+}
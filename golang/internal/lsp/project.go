@@ -0,0 +1,143 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sniplicity/internal/parser"
+)
+
+// definition records where a snippet, template or global is defined
+type definition struct {
+	Path string
+	Line int
+}
+
+// projectIndex holds every snippet, template and global defined in a project's sources
+type projectIndex struct {
+	snippets  map[string]definition
+	templates map[string]definition
+	globals   map[string]definition
+}
+
+// frontmatterKeyRegex matches simple "key: value" frontmatter lines
+var frontmatterKeyRegex = regexp.MustCompile(`^([-\w.]+)\s*:\s*(.*)$`)
+
+// isSourceFile reports whether sniplicity processes the file as a page
+func isSourceFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".md" || ext == ".mdown" || ext == ".markdown" || ext == ".html" || ext == ".htm"
+}
+
+// directiveLines returns the lines of a document to look for directives in, leaving out
+// the code blocks of markdown, where directives are only examples
+func directiveLines(path string, lines []string) []string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".mdown", ".markdown":
+		return parser.HideCodeFences(lines)
+	}
+	return lines
+}
+
+// scanProject indexes all source files under inputDir. Open documents are read from
+// overrides instead of disk so unsaved edits are reflected.
+func scanProject(inputDir string, overrides map[string]string) *projectIndex {
+	index := &projectIndex{
+		snippets:  make(map[string]definition),
+		templates: make(map[string]definition),
+		globals:   make(map[string]definition),
+	}
+
+	filepath.Walk(inputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !isSourceFile(path) {
+			return nil
+		}
+
+		text, open := overrides[path]
+		if !open {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			text = string(data)
+		}
+
+		index.addFile(path, strings.Split(text, "\n"))
+		return nil
+	})
+
+	return index
+}
+
+// addFile records the definitions found in one file
+func (idx *projectIndex) addFile(path string, lines []string) {
+	for i, line := range directiveLines(path, lines) {
+		directive := parser.ParseLine(line, i)
+		if directive == nil {
+			continue
+		}
+		switch directive.Type {
+		case parser.DirectiveCopy, parser.DirectiveCut:
+			idx.snippets[directive.Name] = definition{Path: path, Line: i}
+		case parser.DirectiveTemplate:
+			idx.templates[directive.Name] = definition{Path: path, Line: i}
+		case parser.DirectiveGlobal:
+			idx.globals[directive.Name] = definition{Path: path, Line: i}
+		}
+	}
+}
+
+// sortedKeys returns the names in a definition map in alphabetical order
+func sortedKeys(defs map[string]definition) []string {
+	keys := make([]string, 0, len(defs))
+	for k := range defs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// localVariables returns the variables a single document defines itself: frontmatter
+// keys and set directives, mapped to the line they are defined on
+func localVariables(lines []string) map[string]int {
+	vars := make(map[string]int)
+
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == "---" {
+				break
+			}
+			if match := frontmatterKeyRegex.FindStringSubmatch(strings.TrimSpace(lines[i])); match != nil {
+				vars[match[1]] = i
+			}
+		}
+	}
+
+	for i, line := range lines {
+		if directive := parser.ParseLine(line, i); directive != nil && directive.Type == parser.DirectiveSet {
+			vars[directive.Name] = i
+		}
+	}
+
+	return vars
+}
+
+// frontmatterValue returns the value of a frontmatter key in a document, if present
+func frontmatterValue(lines []string, key string) (string, int, bool) {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return "", 0, false
+	}
+	for i := 1; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "---" {
+			break
+		}
+		if match := frontmatterKeyRegex.FindStringSubmatch(trimmed); match != nil && match[1] == key {
+			return strings.Trim(match[2], `"'`), i, true
+		}
+	}
+	return "", 0, false
+}
@@ -0,0 +1,454 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"sniplicity/internal/config"
+	"sniplicity/internal/parser"
+)
+
+// builtinVariables are always available in pages and templates
+var builtinVariables = []string{"content", "snippet_id", "uuid", "random", "counter"}
+
+// Completion contexts, matched against the text before the cursor
+var (
+	pasteContextRegex    = regexp.MustCompile(`<!--\s+paste\s+[-\w.]*$`)
+	templateContextRegex = regexp.MustCompile(`(<!--\s+set\s+template\s+|<!--\s+index\s+\S+\s+|^template:\s*)[-\w.]*$`)
+	variableContextRegex = regexp.MustCompile(`\{\{[-\w.]*$`)
+	keywordContextRegex  = regexp.MustCompile(`<!--\s+[a-z]*$`)
+	variableRefRegex     = regexp.MustCompile(`\{\{([-\w.]+)\}\}`)
+)
+
+// Server is a minimal Language Server Protocol implementation for sniplicity sources.
+// It offers directive, snippet, template and variable completion, go to definition
+// for snippets, templates and variables, and diagnostics for unresolved references.
+type Server struct {
+	conn     *conn
+	inputDir string
+	docs     map[string]string // Open documents by path
+	index    *projectIndex
+}
+
+// Serve runs the language server on the given streams until the client sends exit
+func Serve(in io.Reader, out io.Writer, inputDir string) error {
+	s := &Server{
+		conn:     &conn{reader: bufio.NewReader(in), writer: out},
+		inputDir: inputDir,
+		docs:     make(map[string]string),
+	}
+	s.index = scanProject(s.inputDir, s.docs)
+
+	for {
+		body, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading message: %w", err)
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			continue // Ignore malformed messages
+		}
+
+		if req.Method == "exit" {
+			return nil
+		}
+
+		result, rpcErr := s.handle(req)
+
+		// Notifications get no response
+		if req.ID == nil {
+			continue
+		}
+		if err := s.conn.write(response{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rpcErr}); err != nil {
+			return fmt.Errorf("writing response: %w", err)
+		}
+	}
+}
+
+// handle dispatches a single request or notification
+func (s *Server) handle(req request) (interface{}, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		return s.initialize(req.Params)
+	case "initialized", "$/cancelRequest", "$/setTrace":
+		return nil, nil
+	case "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		s.docs[uriToPath(params.TextDocument.URI)] = params.TextDocument.Text
+		s.refresh()
+		return nil, nil
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		// Full document sync: the last change holds the whole text
+		if n := len(params.ContentChanges); n > 0 {
+			s.docs[uriToPath(params.TextDocument.URI)] = params.ContentChanges[n-1].Text
+		}
+		s.refresh()
+		return nil, nil
+	case "textDocument/didSave":
+		s.refresh()
+		return nil, nil
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		delete(s.docs, uriToPath(params.TextDocument.URI))
+		s.publish(params.TextDocument.URI, []Diagnostic{})
+		s.refresh()
+		return nil, nil
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		return s.completion(params), nil
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		return s.definition(params), nil
+	}
+
+	return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+// initialize picks up the project from the client's root and reports capabilities
+func (s *Server) initialize(raw json.RawMessage) (interface{}, *rpcError) {
+	var params struct {
+		RootURI string `json:"rootUri"`
+	}
+	json.Unmarshal(raw, &params)
+
+	if params.RootURI != "" {
+		root := uriToPath(params.RootURI)
+		if cfg, err := config.LoadConfigFromFile(root); err == nil {
+			if cfg.ProjectDir == "" {
+				cfg.ProjectDir = root
+			}
+			s.inputDir = cfg.GetAbsoluteInputDir()
+			s.index = scanProject(s.inputDir, s.docs)
+		}
+	}
+
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync": map[string]interface{}{
+				"openClose": true,
+				"change":    1, // Full
+				"save":      true,
+			},
+			"completionProvider": map[string]interface{}{
+				"triggerCharacters": []string{" ", "{"},
+			},
+			"definitionProvider": true,
+		},
+		"serverInfo": map[string]string{"name": "sniplicity"},
+	}, nil
+}
+
+// refresh rescans the project and republishes diagnostics for every open document
+func (s *Server) refresh() {
+	s.index = scanProject(s.inputDir, s.docs)
+	for path, text := range s.docs {
+		s.publish(pathToURI(path), s.diagnose(path, strings.Split(text, "\n")))
+	}
+}
+
+// publish sends diagnostics for a document to the client
+func (s *Server) publish(uri string, diagnostics []Diagnostic) {
+	s.conn.write(notification{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params: map[string]interface{}{
+			"uri":         uri,
+			"diagnostics": diagnostics,
+		},
+	})
+}
+
+// lineRange returns a range covering a whole line
+func lineRange(lines []string, line int) Range {
+	return Range{
+		Start: Position{Line: line, Character: 0},
+		End:   Position{Line: line, Character: utf16Offset(lines[line], len(lines[line]))},
+	}
+}
+
+// diagnose reports unclosed blocks and references to snippets, templates and include
+// files that don't exist
+func (s *Server) diagnose(path string, lines []string) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	add := func(line, severity int, message string) {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    lineRange(lines, line),
+			Severity: severity,
+			Source:   "sniplicity",
+			Message:  message,
+		})
+	}
+
+	type openBlock struct {
+		keyword string
+		name    string
+		line    int
+	}
+	var blocks []openBlock
+
+	for i, line := range directiveLines(path, lines) {
+		if parser.IsBlockEnd(line) {
+			if len(blocks) > 0 {
+				blocks = blocks[:len(blocks)-1]
+			}
+			continue
+		}
+
+		directive := parser.ParseLine(line, i)
+		if directive == nil {
+			continue
+		}
+
+		switch directive.Type {
+		case parser.DirectiveCopy:
+			blocks = append(blocks, openBlock{"copy", directive.Name, i})
+		case parser.DirectiveCut:
+			blocks = append(blocks, openBlock{"cut", directive.Name, i})
+		case parser.DirectiveTemplate:
+			blocks = append(blocks, openBlock{"template", directive.Name, i})
+		case parser.DirectivePaste:
+			if _, exists := s.index.snippets[directive.Name]; !exists {
+				add(i, severityWarning, fmt.Sprintf("Snippet '%s' is not defined anywhere in the project", directive.Name))
+			}
+		case parser.DirectiveSet:
+			if directive.Name == "template" {
+				if _, exists := s.index.templates[directive.Args[0]]; !exists {
+					add(i, severityWarning, fmt.Sprintf("Template '%s' is not defined anywhere in the project", directive.Args[0]))
+				}
+			}
+		case parser.DirectiveIndex:
+			if len(directive.Args) < 2 {
+				add(i, severityError, "index needs a file pattern and a template name")
+			} else if _, exists := s.index.templates[directive.Args[1]]; !exists {
+				add(i, severityWarning, fmt.Sprintf("Template '%s' is not defined anywhere in the project", directive.Args[1]))
+			}
+		case parser.DirectiveSitemap:
+			if len(directive.Args) > 0 {
+				if _, exists := s.index.templates[directive.Args[0]]; !exists {
+					add(i, severityWarning, fmt.Sprintf("Template '%s' is not defined anywhere in the project", directive.Args[0]))
+				}
+			}
+		case parser.DirectiveRedirect:
+			if !parser.IsRedirectStatus(directive.Args[1]) {
+				add(i, severityError, fmt.Sprintf("Redirect status '%s' must be 301, 302, 303, 307 or 308", directive.Args[1]))
+			}
+		case parser.DirectiveInclude:
+			includePath := directive.Args[0]
+			if !strings.Contains(includePath, "{{") && !s.includeExists(path, includePath) {
+				add(i, severityWarning, fmt.Sprintf("Include file '%s' not found", includePath))
+			}
+		case parser.DirectiveDepends:
+			dependency := directive.Args[0]
+			if !strings.Contains(dependency, "{{") && !s.includeExists(path, dependency) {
+				add(i, severityWarning, fmt.Sprintf("Dependency '%s' not found", dependency))
+			}
+		}
+	}
+
+	for _, block := range blocks {
+		add(block.line, severityError, fmt.Sprintf("%s '%s' is never closed with <!-- end -->", block.keyword, block.name))
+	}
+
+	if name, line, ok := frontmatterValue(lines, "template"); ok && name != "" {
+		if _, exists := s.index.templates[name]; !exists {
+			add(line, severityWarning, fmt.Sprintf("Template '%s' is not defined anywhere in the project", name))
+		}
+	}
+
+	return diagnostics
+}
+
+// includeExists resolves an include path the same way the processor does
+func (s *Server) includeExists(fromPath, includePath string) bool {
+	candidates := []string{filepath.Join(s.inputDir, includePath)}
+	if !strings.HasPrefix(includePath, "/") {
+		candidates = append(candidates, filepath.Join(filepath.Dir(fromPath), includePath))
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// documentLines returns the lines of an open document, or of the file on disk
+func (s *Server) documentLines(path string) []string {
+	if text, open := s.docs[path]; open {
+		return strings.Split(text, "\n")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(string(data), "\n")
+}
+
+// completion suggests directives, snippet names, template names or variables
+// depending on what precedes the cursor
+func (s *Server) completion(params textDocumentPositionParams) []CompletionItem {
+	path := uriToPath(params.TextDocument.URI)
+	lines := s.documentLines(path)
+	if params.Position.Line >= len(lines) {
+		return []CompletionItem{}
+	}
+	line := lines[params.Position.Line]
+	prefix := line[:byteOffset(line, params.Position.Character)]
+
+	items := []CompletionItem{}
+	switch {
+	case pasteContextRegex.MatchString(prefix):
+		for _, name := range sortedKeys(s.index.snippets) {
+			items = append(items, CompletionItem{Label: name, Kind: kindSnippet, Detail: s.relative(s.index.snippets[name].Path)})
+		}
+	case templateContextRegex.MatchString(prefix):
+		for _, name := range sortedKeys(s.index.templates) {
+			items = append(items, CompletionItem{Label: name, Kind: kindModule, Detail: s.relative(s.index.templates[name].Path)})
+		}
+	case variableContextRegex.MatchString(prefix):
+		seen := make(map[string]bool)
+		addVar := func(name, detail string) {
+			if !seen[name] {
+				seen[name] = true
+				items = append(items, CompletionItem{Label: name, Kind: kindVariable, Detail: detail})
+			}
+		}
+		for name := range localVariables(directiveLines(path, lines)) {
+			addVar(name, "page variable")
+		}
+		for _, name := range sortedKeys(s.index.globals) {
+			addVar(name, "global")
+		}
+		for _, name := range builtinVariables {
+			addVar(name, "built-in")
+		}
+	case keywordContextRegex.MatchString(prefix):
+		for _, keyword := range parser.Keywords {
+			items = append(items, CompletionItem{Label: keyword.Name, Kind: kindKeyword, Detail: keyword.Description})
+		}
+	}
+
+	return items
+}
+
+// definition jumps from a paste, template reference or variable to where it is defined
+func (s *Server) definition(params textDocumentPositionParams) interface{} {
+	path := uriToPath(params.TextDocument.URI)
+	lines := s.documentLines(path)
+	if params.Position.Line >= len(lines) {
+		return nil
+	}
+	line := lines[params.Position.Line]
+	cursor := byteOffset(line, params.Position.Character)
+
+	location := func(def definition) interface{} {
+		defLines := s.documentLines(def.Path)
+		if def.Line >= len(defLines) {
+			return nil
+		}
+		return Location{URI: pathToURI(def.Path), Range: lineRange(defLines, def.Line)}
+	}
+
+	// A {{variable}} under the cursor
+	for _, match := range variableRefRegex.FindAllStringSubmatchIndex(line, -1) {
+		if cursor >= match[0] && cursor <= match[1] {
+			name := line[match[2]:match[3]]
+			if defLine, exists := localVariables(directiveLines(path, lines))[name]; exists {
+				return Location{URI: params.TextDocument.URI, Range: lineRange(lines, defLine)}
+			}
+			if def, exists := s.index.globals[name]; exists {
+				return location(def)
+			}
+			return nil
+		}
+	}
+
+	directive := parser.ParseLine(line, params.Position.Line)
+	if directive == nil {
+		if name, defLine, ok := frontmatterValue(lines, "template"); ok && defLine == params.Position.Line {
+			if def, exists := s.index.templates[name]; exists {
+				return location(def)
+			}
+		}
+		return nil
+	}
+
+	switch directive.Type {
+	case parser.DirectivePaste:
+		if def, exists := s.index.snippets[directive.Name]; exists {
+			return location(def)
+		}
+	case parser.DirectiveSet:
+		if directive.Name == "template" {
+			if def, exists := s.index.templates[directive.Args[0]]; exists {
+				return location(def)
+			}
+		}
+	case parser.DirectiveIndex:
+		if len(directive.Args) >= 2 {
+			if def, exists := s.index.templates[directive.Args[1]]; exists {
+				return location(def)
+			}
+		}
+	case parser.DirectiveSitemap:
+		if len(directive.Args) > 0 {
+			if def, exists := s.index.templates[directive.Args[0]]; exists {
+				return location(def)
+			}
+		}
+	}
+
+	return nil
+}
+
+// relative shortens a path for display relative to the input directory
+func (s *Server) relative(path string) string {
+	if rel, err := filepath.Rel(s.inputDir, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Delimiters are the marks around variable references, helpers and translatable strings.
+// The zero value stands for the default {{ and }}.
+type Delimiters struct {
+	Open, Close string
+}
+
+// ParseDelimiters reads delimiters written as the opening and closing marks separated by
+// a space, e.g. "[[ ]]". An empty string gives the default.
+func ParseDelimiters(value string) (Delimiters, error) {
+	fields := strings.Fields(value)
+	switch {
+	case len(fields) == 0:
+		return Delimiters{}, nil
+	case len(fields) != 2:
+		return Delimiters{}, fmt.Errorf("delimiters %q must be an opening and a closing mark separated by a space, e.g. \"[[ ]]\"", value)
+	case fields[0] == fields[1]:
+		return Delimiters{}, fmt.Errorf("delimiters %q must open and close with different marks", value)
+	case strings.Contains(value, "<!--") || strings.Contains(value, "-->"):
+		return Delimiters{}, fmt.Errorf("delimiters %q can't use comment marks", value)
+	}
+	if fields[0] == "{{" && fields[1] == "}}" {
+		return Delimiters{}, nil
+	}
+	return Delimiters{Open: fields[0], Close: fields[1]}, nil
+}
+
+// IsDefault reports whether d are the default {{ and }}
+func (d Delimiters) IsDefault() bool {
+	return d.Open == "" && d.Close == ""
+}
+
+// String returns the delimiters the way ParseDelimiters reads them
+func (d Delimiters) String() string {
+	if d.IsDefault() {
+		return "{{ }}"
+	}
+	return d.Open + " " + d.Close
+}
+
+// ApplyDelimiters rewrites content written with custom delimiters into the default ones
+// the rest of the build understands. Literal {{ and }} in it, e.g. from a client-side
+// template, are protected like raw content so they reach the output unchanged, as are
+// references escaped with a backslash, e.g. \[[name]]. Raw blocks are left alone, so
+// their contents stay exactly as written.
+func ApplyDelimiters(lines []string, d Delimiters) []string {
+	if d.IsDefault() {
+		return lines
+	}
+	text := strings.Join(lines, "\n")
+	if !strings.Contains(text, d.Open) && !strings.Contains(text, "{{") && !strings.Contains(text, "}}") {
+		return lines
+	}
+
+	convert := strings.NewReplacer(
+		"{{", rawOpenVar,
+		"}}", rawCloseVar,
+		d.Open, "{{",
+		d.Close, "}}",
+	)
+	literal := strings.NewReplacer("{{", rawOpenVar, "}}", rawCloseVar)
+	escaped := regexp.MustCompile(`\\` + regexp.QuoteMeta(d.Open) + `.*?` + regexp.QuoteMeta(d.Close))
+
+	var out strings.Builder
+	convertText := func(text string) {
+		last := 0
+		for _, match := range escaped.FindAllStringIndex(text, -1) {
+			out.WriteString(convert.Replace(text[last:match[0]]))
+			out.WriteString(literal.Replace(text[match[0]+1 : match[1]]))
+			last = match[1]
+		}
+		out.WriteString(convert.Replace(text[last:]))
+	}
+	last := 0
+	for _, block := range rawBlockRegex.FindAllStringIndex(text, -1) {
+		convertText(text[last:block[0]])
+		out.WriteString(text[block[0]:block[1]])
+		last = block[1]
+	}
+	convertText(text[last:])
+	return strings.Split(out.String(), "\n")
+}
@@ -17,8 +17,14 @@ const (
 	DirectiveTemplate
 	DirectiveInclude
 	DirectiveIndex
+	DirectiveSitemap
 	DirectiveIf
 	DirectiveEndif
+	DirectiveRedirect
+	DirectiveSlot
+	DirectiveEndslot
+	DirectiveSEO
+	DirectiveDepends
 	DirectiveUnknown
 )
 
@@ -34,6 +40,25 @@ type Directive struct {
 var (
 	// Regex patterns matching Python version exactly - simple <!-- command --> format
 	directiveRegex = regexp.MustCompile(`^\s*\<\!\-\-\s+(.*?)\s+\-\-\>`)
+
+	// Snippet, template and variable names: alphanumeric, underscore, dash, dot
+	identifierRegex = regexp.MustCompile(`^[-\w.]+$`)
+
+	// Variable references like {{name}}
+	varRegex = regexp.MustCompile(`\{\{([-\w.]+)\}\}`)
+
+	// Variable references through a filter, like {{date | dateformat "%Y"}}
+	filteredVarRegex = regexp.MustCompile(`\{\{([-\w.]+)\s*\|[^}]*\}\}`)
+
+	// redirectStatuses are the HTTP statuses a redirect directive can ask for
+	redirectStatuses = map[string]bool{"301": true, "302": true, "303": true, "307": true, "308": true}
+
+	// idCommands are the directives that take an identifier
+	idCommands = map[string]bool{
+		"copy": true, "cut": true, "paste": true,
+		"set": true, "global": true, "template": true,
+		"slot": true,
+	}
 )
 
 // ParseLine parses a line for sniplicity directives matching Python's exact logic
@@ -53,12 +78,11 @@ func ParseLine(line string, lineIndex int) *Directive {
 	}
 	
 	command := parts[0]
-	idCommands := map[string]bool{
-		"copy": true, "cut": true, "paste": true, 
-		"set": true, "global": true, "template": true,
-	}
 	
 	// Handle special end markers
+	if command == "endslot" {
+		return &Directive{Type: DirectiveEndslot, LineIndex: lineIndex}
+	}
 	if command == "end" || command == "endif" {
 		if command == "endif" {
 			return &Directive{Type: DirectiveEndif, LineIndex: lineIndex}
@@ -87,7 +111,6 @@ func ParseLine(line string, lineIndex int) *Directive {
 		
 		identifier := parts[1]
 		// Validate identifier pattern (alphanumeric, underscore, dash, dot)
-		identifierRegex := regexp.MustCompile(`^[-\w.]+$`)
 		if !identifierRegex.MatchString(identifier) {
 			return nil
 		}
@@ -120,6 +143,12 @@ func ParseLine(line string, lineIndex int) *Directive {
 				LineIndex: lineIndex,
 				Content:   make([]string, 0),
 			}
+		case "slot":
+			return &Directive{
+				Type:      DirectiveSlot,
+				Name:      identifier,
+				LineIndex: lineIndex,
+			}
 		case "set":
 			value := ""
 			if len(parts) >= 3 {
@@ -186,11 +215,54 @@ func ParseLine(line string, lineIndex int) *Directive {
 			Args:      parts[1:], // Keep all arguments separate
 			LineIndex: lineIndex,
 		}
+	case "depends":
+		if len(parts) < 2 {
+			return nil
+		}
+		return &Directive{
+			Type:      DirectiveDepends,
+			Args:      []string{strings.Join(parts[1:], " ")},
+			LineIndex: lineIndex,
+		}
+	case "redirect":
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil
+		}
+		status := "301"
+		if len(parts) == 3 {
+			status = parts[2]
+		}
+		return &Directive{
+			Type:      DirectiveRedirect,
+			Args:      []string{parts[1], status}, // Target URL and HTTP status
+			LineIndex: lineIndex,
+		}
+	case "sitemap-page":
+		// The template name is optional
+		return &Directive{
+			Type:      DirectiveSitemap,
+			Args:      parts[1:],
+			LineIndex: lineIndex,
+		}
+	case "seo":
+		if len(parts) > 1 {
+			return nil
+		}
+		return &Directive{
+			Type:      DirectiveSEO,
+			LineIndex: lineIndex,
+		}
 	}
 	
 	return nil
 }
 
+// IsRedirectStatus reports whether status is an HTTP redirect status a redirect
+// directive can use
+func IsRedirectStatus(status string) bool {
+	return redirectStatuses[status]
+}
+
 // IsBlockEnd checks if a line ends a copy/cut/template block
 func IsBlockEnd(line string) bool {
 	directive := ParseLine(line, 0)
@@ -242,7 +314,6 @@ func ExpandVariables(text string, variables map[string]string) string {
 	result := text
 	
 	// Replace variables in the format {{variable_name}} (allows letters, numbers, hyphens, underscores, and dots)
-	varRegex := regexp.MustCompile(`\{\{([-\w.]+)\}\}`)
 	result = varRegex.ReplaceAllStringFunc(result, func(match string) string {
 		// Extract variable name (remove {{ and }})
 		varName := match[2 : len(match)-2]
@@ -253,4 +324,28 @@ func ExpandVariables(text string, variables map[string]string) string {
 	})
 	
 	return result
-}
\ No newline at end of file
+}
+// VariableNames returns the names of the variables text uses, in {{name}} references,
+// filters such as {{name | dateformat}} and if conditions, each once
+func VariableNames(text string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, match := range varRegex.FindAllStringSubmatch(text, -1) {
+		add(match[1])
+	}
+	for _, match := range filteredVarRegex.FindAllStringSubmatch(text, -1) {
+		add(match[1])
+	}
+	for i, line := range strings.Split(text, "\n") {
+		if directive := ParseLine(line, i); directive != nil && directive.Type == DirectiveIf {
+			add(strings.TrimSpace(strings.TrimPrefix(directive.Name, "!")))
+		}
+	}
+	return names
+}
@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func FuzzParseLine(f *testing.F) {
+	for _, seed := range []string{
+		"<!-- set title Hello -->",
+		"<!-- global site Example -->",
+		"<!-- copy nav -->",
+		"<!-- cut footer -->",
+		"<!-- end -->",
+		"<!-- paste nav -->",
+		"<!-- if draft -->",
+		"<!-- if !draft -->",
+		"<!-- endif -->",
+		"<!-- include ../header.html -->",
+		"<!-- index blog/*.md sort=date:desc limit=5 -->",
+		"<!-- template post -->",
+		"<!-- slot main -->",
+		"<!-- endslot -->",
+		"<!-- depends data/prices.json -->",
+		"<!--  -->",
+		"<!-- set -->",
+		"<!-- if -->",
+		"<!-- <!-- --> -->",
+		"plain text with {{variable}}",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		directive := ParseLine(line, 0)
+		if directive != nil && directive.LineIndex != 0 {
+			t.Errorf("ParseLine(%q) has line index %d", line, directive.LineIndex)
+		}
+		ParseDirectives(strings.Split(line, "\n"))
+	})
+}
@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+)
+
+// CodeFenceLines reports which lines of markdown belong to a fenced code block, the
+// fences included. A fence is a line starting with three or more backticks or tildes,
+// and the block runs to a fence of the same character at least as long, or to the end.
+func CodeFenceLines(lines []string) []bool {
+	fenced := make([]bool, len(lines))
+	open := "" // Fence of the block we are in, "" outside one
+	for i, line := range lines {
+		marker := fenceMarker(line)
+		switch {
+		case open == "" && marker != "":
+			open = marker
+			fenced[i] = true
+		case open != "":
+			fenced[i] = true
+			rest := strings.TrimSpace(line)
+			if marker != "" && marker[0] == open[0] && len(marker) >= len(open) && strings.Trim(rest, marker[:1]) == "" {
+				open = ""
+			}
+		}
+	}
+	return fenced
+}
+
+// HideCodeFences returns markdown lines with their fenced code blocks blanked out, so
+// directives shown there as examples aren't taken for real ones. Line numbers stay the
+// same. The build doesn't need this, as markdown is converted before directives are read.
+func HideCodeFences(lines []string) []string {
+	var hidden []string
+	for i, fenced := range CodeFenceLines(lines) {
+		if !fenced {
+			continue
+		}
+		if hidden == nil {
+			hidden = append([]string(nil), lines...)
+		}
+		hidden[i] = ""
+	}
+	if hidden == nil {
+		return lines
+	}
+	return hidden
+}
+
+// fenceMarker returns the run of backticks or tildes a fence line starts with, or ""
+func fenceMarker(line string) string {
+	trimmed := strings.TrimLeft(line, " \t")
+	if !strings.HasPrefix(trimmed, "```") && !strings.HasPrefix(trimmed, "~~~") {
+		return ""
+	}
+	n := 0
+	for n < len(trimmed) && trimmed[n] == trimmed[0] {
+		n++
+	}
+	if trimmed[0] == '`' && strings.Contains(trimmed[n:], "`") {
+		return "" // Backtick fences can't have backticks in their info string
+	}
+	return trimmed[:n]
+}
+
+// ApplyMarkdownDelimiters is ApplyDelimiters for markdown, whose fenced code blocks are
+// code samples: variable references in them, in either delimiters, are protected like
+// raw content so they show as written instead of being expanded
+func ApplyMarkdownDelimiters(lines []string, d Delimiters) []string {
+	fenced := CodeFenceLines(lines)
+	if !slices.Contains(fenced, true) {
+		return ApplyDelimiters(lines, d)
+	}
+
+	literal := strings.NewReplacer("{{", rawOpenVar, "}}", rawCloseVar)
+	out := make([]string, 0, len(lines))
+	start := 0 // First line of the run of lines outside fences not yet added
+	for i, line := range lines {
+		if !fenced[i] {
+			continue
+		}
+		out = append(out, ApplyDelimiters(lines[start:i], d)...)
+		out = append(out, literal.Replace(line))
+		start = i + 1
+	}
+	return append(out, ApplyDelimiters(lines[start:], d)...)
+}
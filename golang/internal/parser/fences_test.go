@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestApplyMarkdownDelimiters(t *testing.T) {
+	custom, err := ParseDelimiters("[[ ]]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name       string
+		delimiters Delimiters
+		lines      string
+		want       string
+	}{
+		{
+			name:  "no fences",
+			lines: "Hello {{name}}",
+			want:  "Hello {{name}}",
+		},
+		{
+			name:  "backtick fence",
+			lines: "{{a}}\n```html\n<p>{{a}}</p>\n```\n{{a}}",
+			want:  "{{a}}\n```html\n<p>" + rawOpenVar + "a" + rawCloseVar + "</p>\n```\n{{a}}",
+		},
+		{
+			name:  "tilde fence",
+			lines: "~~~\n{{a}}\n~~~",
+			want:  "~~~\n" + rawOpenVar + "a" + rawCloseVar + "\n~~~",
+		},
+		{
+			name:  "unclosed fence runs to the end",
+			lines: "````\n{{a}}\n```\n{{b}}",
+			want:  "````\n" + rawOpenVar + "a" + rawCloseVar + "\n```\n" + rawOpenVar + "b" + rawCloseVar,
+		},
+		{
+			name:       "custom delimiters outside fences",
+			delimiters: custom,
+			lines:      "[[a]] {{b}}\n```\n[[a]] {{b}}\n```",
+			want:       "{{a}} " + rawOpenVar + "b" + rawCloseVar + "\n```\n[[a]] " + rawOpenVar + "b" + rawCloseVar + "\n```",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := ApplyMarkdownDelimiters(strings.Split(test.lines, "\n"), test.delimiters)
+			if want := strings.Split(test.want, "\n"); !slices.Equal(got, want) {
+				t.Errorf("ApplyMarkdownDelimiters(%q) = %q, want %q", test.lines, got, want)
+			}
+			if restored := RestoreRaw(strings.Join(got, "\n")); test.delimiters.IsDefault() && strings.Count(restored, "{{") != strings.Count(test.lines, "{{") {
+				t.Errorf("RestoreRaw(%q) = %q, want the variables back as written", got, restored)
+			}
+		})
+	}
+}
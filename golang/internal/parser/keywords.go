@@ -0,0 +1,32 @@
+package parser
+
+// Keyword describes a directive keyword for tooling such as editor completion
+type Keyword struct {
+	Name        string // The word following <!--
+	Arguments   string // Human readable argument summary, empty if none
+	Block       bool   // Whether the directive opens a block closed by <!-- end -->
+	Description string
+}
+
+// Keywords lists every directive ParseLine understands, plus the raw block markers
+var Keywords = []Keyword{
+	{Name: "copy", Arguments: "name", Block: true, Description: "Define a snippet and keep its content in place"},
+	{Name: "cut", Arguments: "name", Block: true, Description: "Define a snippet and remove its content from the page"},
+	{Name: "paste", Arguments: "name", Description: "Insert a snippet"},
+	{Name: "set", Arguments: "name [value]", Description: "Set a page variable"},
+	{Name: "global", Arguments: "name [value]", Description: "Set a site-wide variable"},
+	{Name: "template", Arguments: "name", Block: true, Description: "Define a template"},
+	{Name: "include", Arguments: "path", Description: "Insert the contents of another file"},
+	{Name: "depends", Arguments: "path", Description: "Rebuild this page in watch mode when the file changes, for data the engine can't see it use"},
+	{Name: "index", Arguments: "pattern template [sort]", Description: "List matching files using a template"},
+	{Name: "sitemap-page", Arguments: "[template]", Description: "List every generated page, grouped by directory"},
+	{Name: "redirect", Arguments: "url [status]", Description: "Publish a redirect to url in place of this page (status 301 unless given)"},
+	{Name: "seo", Description: "Write the canonical link, Open Graph and Twitter card tags of the page"},
+	{Name: "slot", Arguments: "name", Description: "Fill the template's {{slot name}} with the following content"},
+	{Name: "endslot", Description: "End a slot block"},
+	{Name: "if", Arguments: "[!]variable", Description: "Only output the following content if the variable is set"},
+	{Name: "endif", Description: "End an if block"},
+	{Name: "end", Description: "End a copy, cut or template block"},
+	{Name: "raw", Description: "Output the following content literally"},
+	{Name: "endraw", Description: "End a raw block"},
+}
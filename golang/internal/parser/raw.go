@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Placeholders used to carry literal directive and variable syntax through the
+// build. They live in the Unicode private use area so no directive or variable
+// pattern can match them, and RestoreRaw turns them back into the original text.
+const (
+	rawOpenVar      = "\uE000" // {{
+	rawCloseVar     = "\uE001" // }}
+	rawOpenComment  = "\uE002" // <!--
+	rawCloseComment = "\uE003" // -->
+)
+
+var (
+	// <!-- raw --> ... <!-- endraw --> blocks, either inline or spanning lines
+	rawBlockRegex = regexp.MustCompile(`(?s)<!--\s*raw\s*-->(.*?)<!--\s*endraw\s*-->`)
+
+	// \{{name}} escapes a single variable reference
+	escapedVarRegex = regexp.MustCompile(`\\\{\{(.*?)\}\}`)
+
+	rawRestorer = strings.NewReplacer(
+		rawOpenVar, "{{",
+		rawCloseVar, "}}",
+		rawOpenComment, "<!--",
+		rawCloseComment, "-->",
+	)
+)
+
+// ProtectRaw hides the contents of raw blocks and escaped variables from all later
+// processing stages. Call RestoreRaw on the final output to get the literal text back.
+func ProtectRaw(lines []string) []string {
+	text := strings.Join(lines, "\n")
+	if !strings.Contains(text, "raw") && !strings.Contains(text, `\{{`) {
+		return lines
+	}
+
+	text = rawBlockRegex.ReplaceAllStringFunc(text, func(match string) string {
+		inner := rawBlockRegex.FindStringSubmatch(match)[1]
+
+		// Drop the newline that follows <!-- raw --> and precedes <!-- endraw --> so
+		// block markers on their own lines don't leave empty lines behind
+		inner = strings.TrimPrefix(inner, "\n")
+		inner = strings.TrimSuffix(inner, "\n")
+
+		return encodeRaw(inner)
+	})
+
+	text = escapedVarRegex.ReplaceAllStringFunc(text, func(match string) string {
+		return encodeRaw(match[1:])
+	})
+
+	return strings.Split(text, "\n")
+}
+
+// RestoreRaw converts protected raw content back into its literal form
+func RestoreRaw(text string) string {
+	return rawRestorer.Replace(text)
+}
+
+// Literal protects finished text, such as content already rendered for another page, from
+// the processing of the page it is added to. RestoreRaw turns it back.
+func Literal(text string) string {
+	return encodeRaw(text)
+}
+
+// encodeRaw replaces directive and variable delimiters with placeholders
+func encodeRaw(text string) string {
+	text = strings.ReplaceAll(text, "{{", rawOpenVar)
+	text = strings.ReplaceAll(text, "}}", rawCloseVar)
+	text = strings.ReplaceAll(text, "<!--", rawOpenComment)
+	text = strings.ReplaceAll(text, "-->", rawCloseComment)
+	return text
+}
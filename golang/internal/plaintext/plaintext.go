@@ -0,0 +1,135 @@
+package plaintext
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// droppedRegex matches elements whose content never belongs in the text, and comments
+	droppedRegex = regexp.MustCompile(`(?is)<(script|style|head|template)\b.*?</(?:script|style|head|template)\s*>|<!--.*?-->`)
+
+	// preRegex matches preformatted blocks, whose line breaks and indentation are kept
+	preRegex = regexp.MustCompile(`(?is)<pre\b[^>]*>(.*?)</pre\s*>`)
+
+	// preTokenRegex matches the placeholder a preformatted block is swapped for meanwhile
+	preTokenRegex = regexp.MustCompile("\x00pre([0-9]+)\x00")
+
+	// linkRegex matches a link with its href and text
+	linkRegex = regexp.MustCompile(`(?is)<a\b[^>]*?\bhref\s*=\s*["']([^"']*)["'][^>]*>(.*?)</a\s*>`)
+
+	// imageRegex matches an image with its alt text
+	imageRegex = regexp.MustCompile(`(?is)<img\b[^>]*?\balt\s*=\s*["']([^"']*)["'][^>]*>`)
+
+	// breakRegex matches tags that end a line
+	breakRegex = regexp.MustCompile(`(?i)<br\s*/?>|</t[dh]\s*>\s*</tr\s*>`)
+
+	// itemRegex matches the start of a list item
+	itemRegex = regexp.MustCompile(`(?i)<li\b[^>]*>`)
+
+	// itemEndRegex matches the end of a list item
+	itemEndRegex = regexp.MustCompile(`(?i)</li\s*>`)
+
+	// cellRegex matches the end of a table cell that isn't the last in its row
+	cellRegex = regexp.MustCompile(`(?i)</t[dh]\s*>`)
+
+	// blockRegex matches the start or end of a block element, which becomes a paragraph break
+	blockRegex = regexp.MustCompile(`(?i)</?(?:p|div|h[1-6]|ul|ol|dl|dt|dd|table|blockquote|section|article|header|footer|nav|aside|figure|figcaption|hr)\b[^>]*>`)
+
+	// tagRegex matches any remaining tag
+	tagRegex = regexp.MustCompile(`(?s)<[^>]*>`)
+
+	// spaceRegex matches runs of horizontal whitespace
+	spaceRegex = regexp.MustCompile(`[ \t\r\f\v]+`)
+
+	// blankLinesRegex matches more than one blank line
+	blankLinesRegex = regexp.MustCompile(`\n{3,}`)
+
+	// paragraphRegex matches a paragraph with its content
+	paragraphRegex = regexp.MustCompile(`(?is)<p\b[^>]*>(.*?)</p\s*>`)
+)
+
+// FromHTML converts an HTML fragment to readable plain text: paragraphs separated by
+// blank lines, list items as "- " lines and links followed by their URL
+func FromHTML(source string) string {
+	text := droppedRegex.ReplaceAllString(source, "")
+
+	var blocks []string
+	text = preRegex.ReplaceAllStringFunc(text, func(block string) string {
+		inner := preRegex.FindStringSubmatch(block)[1]
+		blocks = append(blocks, strings.Trim(html.UnescapeString(tagRegex.ReplaceAllString(inner, "")), "\n"))
+		return fmt.Sprintf("<p>\x00pre%d\x00</p>", len(blocks)-1)
+	})
+
+	text = linkRegex.ReplaceAllStringFunc(text, func(link string) string {
+		match := linkRegex.FindStringSubmatch(link)
+		href, label := match[1], strings.TrimSpace(tagRegex.ReplaceAllString(match[2], ""))
+		if href == "" || strings.HasPrefix(href, "#") || html.UnescapeString(label) == html.UnescapeString(href) {
+			return label
+		}
+		return label + " (" + href + ")"
+	})
+	text = imageRegex.ReplaceAllString(text, "$1")
+
+	// Whitespace in the source is only layout, the tags decide where lines end
+	text = strings.Join(strings.Fields(text), " ")
+	text = breakRegex.ReplaceAllString(text, "\n")
+	text = itemRegex.ReplaceAllString(text, "\n- ")
+	text = itemEndRegex.ReplaceAllString(text, "")
+	text = cellRegex.ReplaceAllString(text, " | ")
+	text = blockRegex.ReplaceAllString(text, "\n\n")
+	text = tagRegex.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(spaceRegex.ReplaceAllString(line, " "))
+	}
+	text = blankLinesRegex.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	text = preTokenRegex.ReplaceAllStringFunc(text, func(token string) string {
+		i, _ := strconv.Atoi(preTokenRegex.FindStringSubmatch(token)[1])
+		return blocks[i]
+	})
+
+	return strings.TrimSpace(text) + "\n"
+}
+
+// Excerpt returns the text of the first paragraph of an HTML fragment, or of the whole
+// fragment when it has none, on one line and cut at a word before limit characters
+func Excerpt(source string, limit int) string {
+	text := firstParagraph(source)
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	cut := string(runes[:limit-1])
+	if space := strings.LastIndex(cut, " "); space > 0 {
+		cut = cut[:space]
+	}
+	return strings.TrimRight(cut, " ,.;:") + "…"
+}
+
+// Summary returns the text of the first paragraph of an HTML fragment, or of the whole
+// fragment when it has none, on one line and cut after the given number of words
+func Summary(source string, words int) string {
+	fields := strings.Fields(firstParagraph(source))
+	if len(fields) <= words {
+		return strings.Join(fields, " ")
+	}
+	return strings.TrimRight(strings.Join(fields[:words], " "), ",.;:") + "…"
+}
+
+// firstParagraph returns the text of the first paragraph of an HTML fragment, or of the
+// whole fragment when it has none, on one line
+func firstParagraph(source string) string {
+	source = droppedRegex.ReplaceAllString(source, "")
+	if match := paragraphRegex.FindStringSubmatch(source); match != nil {
+		source = match[1]
+	}
+	source = breakRegex.ReplaceAllString(source, " ")
+	source = blockRegex.ReplaceAllString(source, " ")
+	return strings.Join(strings.Fields(html.UnescapeString(tagRegex.ReplaceAllString(source, ""))), " ")
+}
@@ -0,0 +1,342 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// namedColors are the CSS named colors
+var namedColors = map[string][3]int{
+	"aliceblue":            {240, 248, 255},
+	"antiquewhite":         {250, 235, 215},
+	"aqua":                 {0, 255, 255},
+	"aquamarine":           {127, 255, 212},
+	"azure":                {240, 255, 255},
+	"beige":                {245, 245, 220},
+	"bisque":               {255, 228, 196},
+	"black":                {0, 0, 0},
+	"blanchedalmond":       {255, 235, 205},
+	"blue":                 {0, 0, 255},
+	"blueviolet":           {138, 43, 226},
+	"brown":                {165, 42, 42},
+	"burlywood":            {222, 184, 135},
+	"cadetblue":            {95, 158, 160},
+	"chartreuse":           {127, 255, 0},
+	"chocolate":            {210, 105, 30},
+	"coral":                {255, 127, 80},
+	"cornflowerblue":       {100, 149, 237},
+	"cornsilk":             {255, 248, 220},
+	"crimson":              {220, 20, 60},
+	"cyan":                 {0, 255, 255},
+	"darkblue":             {0, 0, 139},
+	"darkcyan":             {0, 139, 139},
+	"darkgoldenrod":        {184, 134, 11},
+	"darkgray":             {169, 169, 169},
+	"darkgreen":            {0, 100, 0},
+	"darkgrey":             {169, 169, 169},
+	"darkkhaki":            {189, 183, 107},
+	"darkmagenta":          {139, 0, 139},
+	"darkolivegreen":       {85, 107, 47},
+	"darkorange":           {255, 140, 0},
+	"darkorchid":           {153, 50, 204},
+	"darkred":              {139, 0, 0},
+	"darksalmon":           {233, 150, 122},
+	"darkseagreen":         {143, 188, 143},
+	"darkslateblue":        {72, 61, 139},
+	"darkslategray":        {47, 79, 79},
+	"darkslategrey":        {47, 79, 79},
+	"darkturquoise":        {0, 206, 209},
+	"darkviolet":           {148, 0, 211},
+	"deeppink":             {255, 20, 147},
+	"deepskyblue":          {0, 191, 255},
+	"dimgray":              {105, 105, 105},
+	"dimgrey":              {105, 105, 105},
+	"dodgerblue":           {30, 144, 255},
+	"firebrick":            {178, 34, 34},
+	"floralwhite":          {255, 250, 240},
+	"forestgreen":          {34, 139, 34},
+	"fuchsia":              {255, 0, 255},
+	"gainsboro":            {220, 220, 220},
+	"ghostwhite":           {248, 248, 255},
+	"gold":                 {255, 215, 0},
+	"goldenrod":            {218, 165, 32},
+	"gray":                 {128, 128, 128},
+	"green":                {0, 128, 0},
+	"greenyellow":          {173, 255, 47},
+	"grey":                 {128, 128, 128},
+	"honeydew":             {240, 255, 240},
+	"hotpink":              {255, 105, 180},
+	"indianred":            {205, 92, 92},
+	"indigo":               {75, 0, 130},
+	"ivory":                {255, 255, 240},
+	"khaki":                {240, 230, 140},
+	"lavender":             {230, 230, 250},
+	"lavenderblush":        {255, 240, 245},
+	"lawngreen":            {124, 252, 0},
+	"lemonchiffon":         {255, 250, 205},
+	"lightblue":            {173, 216, 230},
+	"lightcoral":           {240, 128, 128},
+	"lightcyan":            {224, 255, 255},
+	"lightgoldenrodyellow": {250, 250, 210},
+	"lightgray":            {211, 211, 211},
+	"lightgreen":           {144, 238, 144},
+	"lightgrey":            {211, 211, 211},
+	"lightpink":            {255, 182, 193},
+	"lightsalmon":          {255, 160, 122},
+	"lightseagreen":        {32, 178, 170},
+	"lightskyblue":         {135, 206, 250},
+	"lightslategray":       {119, 136, 153},
+	"lightslategrey":       {119, 136, 153},
+	"lightsteelblue":       {176, 196, 222},
+	"lightyellow":          {255, 255, 224},
+	"lime":                 {0, 255, 0},
+	"limegreen":            {50, 205, 50},
+	"linen":                {250, 240, 230},
+	"magenta":              {255, 0, 255},
+	"maroon":               {128, 0, 0},
+	"mediumaquamarine":     {102, 205, 170},
+	"mediumblue":           {0, 0, 205},
+	"mediumorchid":         {186, 85, 211},
+	"mediumpurple":         {147, 112, 219},
+	"mediumseagreen":       {60, 179, 113},
+	"mediumslateblue":      {123, 104, 238},
+	"mediumspringgreen":    {0, 250, 154},
+	"mediumturquoise":      {72, 209, 204},
+	"mediumvioletred":      {199, 21, 133},
+	"midnightblue":         {25, 25, 112},
+	"mintcream":            {245, 255, 250},
+	"mistyrose":            {255, 228, 225},
+	"moccasin":             {255, 228, 181},
+	"navajowhite":          {255, 222, 173},
+	"navy":                 {0, 0, 128},
+	"oldlace":              {253, 245, 230},
+	"olive":                {128, 128, 0},
+	"olivedrab":            {107, 142, 35},
+	"orange":               {255, 165, 0},
+	"orangered":            {255, 69, 0},
+	"orchid":               {218, 112, 214},
+	"palegoldenrod":        {238, 232, 170},
+	"palegreen":            {152, 251, 152},
+	"paleturquoise":        {175, 238, 238},
+	"palevioletred":        {219, 112, 147},
+	"papayawhip":           {255, 239, 213},
+	"peachpuff":            {255, 218, 185},
+	"peru":                 {205, 133, 63},
+	"pink":                 {255, 192, 203},
+	"plum":                 {221, 160, 221},
+	"powderblue":           {176, 224, 230},
+	"purple":               {128, 0, 128},
+	"rebeccapurple":        {102, 51, 153},
+	"red":                  {255, 0, 0},
+	"rosybrown":            {188, 143, 143},
+	"royalblue":            {65, 105, 225},
+	"saddlebrown":          {139, 69, 19},
+	"salmon":               {250, 128, 114},
+	"sandybrown":           {244, 164, 96},
+	"seagreen":             {46, 139, 87},
+	"seashell":             {255, 245, 238},
+	"sienna":               {160, 82, 45},
+	"silver":               {192, 192, 192},
+	"skyblue":              {135, 206, 235},
+	"slateblue":            {106, 90, 205},
+	"slategray":            {112, 128, 144},
+	"slategrey":            {112, 128, 144},
+	"snow":                 {255, 250, 250},
+	"springgreen":          {0, 255, 127},
+	"steelblue":            {70, 130, 180},
+	"tan":                  {210, 180, 140},
+	"teal":                 {0, 128, 128},
+	"thistle":              {216, 191, 216},
+	"tomato":               {255, 99, 71},
+	"turquoise":            {64, 224, 208},
+	"violet":               {238, 130, 238},
+	"wheat":                {245, 222, 179},
+	"white":                {255, 255, 255},
+	"whitesmoke":           {245, 245, 245},
+	"yellow":               {255, 255, 0},
+	"yellowgreen":          {154, 205, 50},
+}
+
+// parseColor parses a CSS color: hex with or without alpha, a named color, rgb()/rgba()
+// or hsl()/hsla(), in either the comma or the space separated syntax. It returns RGB
+// values (0-255) and the alpha (0-1).
+func parseColor(color string) (int, int, int, float64, error) {
+	color = strings.ToLower(strings.TrimSpace(color))
+
+	if strings.HasPrefix(color, "#") {
+		return parseHexColor(color)
+	}
+	if rgb, exists := namedColors[color]; exists {
+		return rgb[0], rgb[1], rgb[2], 1, nil
+	}
+
+	open := strings.Index(color, "(")
+	if open < 0 || !strings.HasSuffix(color, ")") {
+		return 0, 0, 0, 0, fmt.Errorf("unsupported color format: %s", color)
+	}
+	name := strings.TrimSpace(color[:open])
+	args, alpha, err := colorArguments(color[open+1 : len(color)-1])
+	if err != nil {
+		return 0, 0, 0, 0, fmt.Errorf("invalid color %s: %w", color, err)
+	}
+
+	switch name {
+	case "rgb", "rgba":
+		var rgb [3]int
+		for i, arg := range args {
+			value, err := parseColorNumber(arg, 255)
+			if err != nil {
+				return 0, 0, 0, 0, fmt.Errorf("invalid color %s: %w", color, err)
+			}
+			rgb[i] = clamp(int(math.Round(value)))
+		}
+		return rgb[0], rgb[1], rgb[2], alpha, nil
+
+	case "hsl", "hsla":
+		hue, err := parseHue(args[0])
+		if err != nil {
+			return 0, 0, 0, 0, fmt.Errorf("invalid color %s: %w", color, err)
+		}
+		saturation, err := parseColorNumber(args[1], 100)
+		if err != nil {
+			return 0, 0, 0, 0, fmt.Errorf("invalid color %s: %w", color, err)
+		}
+		lightness, err := parseColorNumber(args[2], 100)
+		if err != nil {
+			return 0, 0, 0, 0, fmt.Errorf("invalid color %s: %w", color, err)
+		}
+		r, g, b := hslToRgb(hue, clampUnit(saturation/100), clampUnit(lightness/100))
+		return r, g, b, alpha, nil
+	}
+
+	return 0, 0, 0, 0, fmt.Errorf("unsupported color format: %s", color)
+}
+
+// parseHexColor parses #rgb, #rgba, #rrggbb and #rrggbbaa
+func parseHexColor(color string) (int, int, int, float64, error) {
+	hex := strings.TrimPrefix(color, "#")
+	if len(hex) == 3 || len(hex) == 4 {
+		var long strings.Builder
+		for _, digit := range hex {
+			long.WriteRune(digit)
+			long.WriteRune(digit)
+		}
+		hex = long.String()
+	}
+	if len(hex) != 6 && len(hex) != 8 {
+		return 0, 0, 0, 0, fmt.Errorf("invalid hex color: %s", color)
+	}
+
+	var channels [4]int
+	channels[3] = 255
+	for i := 0; i < len(hex)/2; i++ {
+		value, err := strconv.ParseUint(hex[i*2:i*2+2], 16, 8)
+		if err != nil {
+			return 0, 0, 0, 0, fmt.Errorf("invalid hex color: %s", color)
+		}
+		channels[i] = int(value)
+	}
+	return channels[0], channels[1], channels[2], float64(channels[3]) / 255, nil
+}
+
+// colorArguments splits the arguments of a color function into its three components and
+// the alpha, which defaults to 1
+func colorArguments(value string) ([]string, float64, error) {
+	var args []string
+	alphaArg := ""
+	if strings.Contains(value, ",") {
+		for _, arg := range strings.Split(value, ",") {
+			args = append(args, strings.TrimSpace(arg))
+		}
+		if len(args) == 4 {
+			args, alphaArg = args[:3], args[3]
+		}
+	} else {
+		components, alphaPart, hasAlpha := strings.Cut(value, "/")
+		args = strings.Fields(components)
+		if hasAlpha {
+			alphaArg = strings.TrimSpace(alphaPart)
+		}
+	}
+	if len(args) != 3 {
+		return nil, 0, fmt.Errorf("expected 3 components and an optional alpha")
+	}
+
+	alpha := 1.0
+	if alphaArg != "" {
+		value, err := parseColorNumber(alphaArg, 1)
+		if err != nil {
+			return nil, 0, err
+		}
+		alpha = clampUnit(value)
+	}
+	return args, alpha, nil
+}
+
+// parseColorNumber parses a number, or a percentage of full
+func parseColorNumber(value string, full float64) (float64, error) {
+	if percent, ok := strings.CutSuffix(value, "%"); ok {
+		number, err := strconv.ParseFloat(percent, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid percentage %q", value)
+		}
+		return number / 100 * full, nil
+	}
+	if value == "none" {
+		return 0, nil
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", value)
+	}
+	return number, nil
+}
+
+// parseHue parses a hue angle, in degrees unless it has a unit, and returns it as a
+// fraction of a turn (0-1)
+func parseHue(value string) (float64, error) {
+	units := []struct {
+		suffix string
+		turn   float64
+	}{
+		{"deg", 360},
+		{"grad", 400},
+		{"rad", 2 * math.Pi},
+		{"turn", 1},
+	}
+	turn := 360.0
+	for _, unit := range units {
+		if number, ok := strings.CutSuffix(value, unit.suffix); ok {
+			value, turn = number, unit.turn
+			break
+		}
+	}
+	if value == "none" {
+		return 0, nil
+	}
+	angle, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hue %q", value)
+	}
+	hue := math.Mod(angle/turn, 1)
+	if hue < 0 {
+		hue++
+	}
+	return hue, nil
+}
+
+// formatColor writes a color back as hex, or as rgba() when it is translucent so the
+// alpha survives
+func formatColor(r, g, b int, alpha float64) string {
+	if alpha >= 1 {
+		return fmt.Sprintf("#%02x%02x%02x", clamp(r), clamp(g), clamp(b))
+	}
+	return fmt.Sprintf("rgba(%d, %d, %d, %s)", clamp(r), clamp(g), clamp(b), strconv.FormatFloat(math.Round(alpha*1000)/1000, 'f', -1, 64))
+}
+
+// clampUnit ensures a value is between 0 and 1
+func clampUnit(value float64) float64 {
+	return math.Max(0, math.Min(1, value))
+}
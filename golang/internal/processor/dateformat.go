@@ -0,0 +1,46 @@
+package processor
+
+import (
+	"regexp"
+
+	"sniplicity/internal/types"
+)
+
+// dateFilterRegex matches {{name | dateformat}} and {{name | dateformat "%d %B %Y"}},
+// whose format may also be in single quotes
+var dateFilterRegex = regexp.MustCompile(`\{\{([-\w.]+)\s*\|\s*dateformat(?:\s+(?:"([^"]*)"|'([^']*)'))?\s*\}\}`)
+
+// processContent runs ProcessContentWithDirectives within the page's limits and then
+// formats the dates of the dateformat filters in the result with the same variables
+func (p *Processor) processContent(fileInfo *types.FileInfo, content string, localVars, metaVars map[string]string) (string, error) {
+	processed, err := processDirectives(content, localVars, metaVars, fileInfo.Limits)
+	if err != nil {
+		return "", err
+	}
+	return p.formatDates(fileInfo, processed, localVars, metaVars), nil
+}
+
+// formatDates replaces each {{name | dateformat "format"}} whose variable is set with its
+// date in the format, written like strftime, or in the project's dateformat when the
+// filter gives none. A value that isn't a date is reported and shown as it is. Filters
+// of unset variables are left, like other undefined variables.
+func (p *Processor) formatDates(fileInfo *types.FileInfo, text string, localVars, metaVars map[string]string) string {
+	return dateFilterRegex.ReplaceAllStringFunc(text, func(match string) string {
+		parts := dateFilterRegex.FindStringSubmatch(match)
+		value, ok := localVars[parts[1]]
+		if !ok {
+			if value, ok = metaVars[parts[1]]; !ok {
+				return match
+			}
+		}
+		if value == "" {
+			return ""
+		}
+		formatted, err := p.dates.Format(value, parts[2]+parts[3])
+		if err != nil {
+			p.warn(fileInfo, regexp.QuoteMeta(match), "cannot format %s with dateformat: %v", parts[1], err)
+			return value
+		}
+		return formatted
+	})
+}
@@ -1,36 +1,49 @@
 package processor
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
 
 	"sniplicity/internal/parser"
+	"sniplicity/internal/types"
 )
 
-// ProcessContentWithDirectives handles conditionals, directive removal, and variable replacement like Python
-func ProcessContentWithDirectives(content string, localVars, metaVars map[string]string) string {
+// varRegex matches variable references like {{name}}
+var varRegex = regexp.MustCompile(`\{\{([-\w.]+)\}\}`)
+
+// ProcessContentWithDirectives handles conditionals, directive removal, and variable replacement like Python.
+// It fails on inline conditionals whose if and endif markers don't pair up.
+func ProcessContentWithDirectives(content string, localVars, metaVars map[string]string) (string, error) {
+	return processDirectives(content, localVars, metaVars, nil)
+}
+
+// processDirectives is ProcessContentWithDirectives for a page, stopping as soon as the
+// page runs past its limits
+func processDirectives(content string, localVars, metaVars map[string]string, limits *types.PageLimits) (string, error) {
 	// First process inline conditionals (for mixed content lines)
-	content = processInlineConditionals(content, localVars, metaVars)
+	content, err := processInlineConditionals(content, localVars, metaVars)
+	if err != nil {
+		return "", err
+	}
 	
 	// Then process block-level conditionals and other directives
 	lines := strings.Split(content, "\n")
 	var processedLines []string
+	size := 0
 	write := true
 	cutting := false
 	
 	for _, line := range lines {
+		if err := limits.Check(size); err != nil {
+			return "", err
+		}
 		directive := parser.ParseLine(line, 0)
 		
 		if directive != nil {
 			switch directive.Type {
 			case parser.DirectiveIf:
-				condition := directive.Name
-				if strings.HasPrefix(condition, "!") {
-					varName := strings.TrimSpace(condition[1:])
-					write = isFalse(localVars, metaVars, varName)
-				} else {
-					write = isTrue(localVars, metaVars, condition)
-				}
+				write = conditionHolds(directive.Name, localVars, metaVars)
 				continue // Skip adding the if directive to output
 			case parser.DirectiveEndif:
 				write = true
@@ -47,54 +60,128 @@ func ProcessContentWithDirectives(content string, localVars, metaVars map[string
 				}
 				continue // Skip adding end directive to output
 			case parser.DirectiveSet, parser.DirectiveCopy, parser.DirectivePaste, 
-				 parser.DirectiveGlobal, parser.DirectiveTemplate, parser.DirectiveInclude, parser.DirectiveIndex:
+				 parser.DirectiveGlobal, parser.DirectiveTemplate, parser.DirectiveInclude, parser.DirectiveIndex, parser.DirectiveSitemap, parser.DirectiveRedirect, parser.DirectiveDepends,
+				 parser.DirectiveSlot, parser.DirectiveEndslot:
 				continue // Skip other directive commands that shouldn't appear in output
 			}
 		}
 		
 		if write {
 			processedLines = append(processedLines, line)
+			size += len(line) + 1
 		}
 	}
 	
 	// Finally do variable replacements on the processed text
-	processedText := strings.Join(processedLines, "\n")
-	return doReplacements(processedText, localVars, metaVars)
+	processedText := doReplacements(strings.Join(processedLines, "\n"), localVars, metaVars)
+	if err := limits.Check(len(processedText)); err != nil {
+		return "", err
+	}
+	return processedText, nil
 }
 
-// processInlineConditionals processes inline conditional directives within a line of text
-func processInlineConditionals(text string, localVars, metaVars map[string]string) string {
-	// Pattern to match inline conditionals like <!-- if var --> content <!-- endif -->
-	inlineIfRegex := regexp.MustCompile(`<!--\s*if\s+([^>]+)\s*-->(.*?)<!--\s*endif\s*-->`)
-	
-	for {
-		matches := inlineIfRegex.FindStringSubmatch(text)
-		if matches == nil {
-			break
+// inlineMarkerRegex matches the markers of an inline conditional, <!-- if var --> and
+// <!-- endif -->. The first group is set for an endif, the second holds an if's condition,
+// a variable name with an optional !, so ordinary comments such as <!-- if you change
+// this, ... --> are left as they are.
+var inlineMarkerRegex = regexp.MustCompile(`<!--\s*(?:(endif)|if\s+(!?\s*[-\w.]+))\s*-->`)
+
+// processInlineConditionals processes inline conditional directives, which open and close
+// on the same line and may be nested. Lines that start with an unmatched if or endif are
+// block conditionals and are left for ProcessContentWithDirectives; any other unmatched
+// marker is an error.
+func processInlineConditionals(text string, localVars, metaVars map[string]string) (string, error) {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, "<!--") {
+			continue
 		}
-		
-		condition := strings.TrimSpace(matches[1])
-		content := matches[2]
-		
-		// Handle negation
-		var showContent bool
-		if strings.HasPrefix(condition, "!") {
-			varName := strings.TrimSpace(condition[1:])
-			showContent = isFalse(localVars, metaVars, varName)
-		} else {
-			showContent = isTrue(localVars, metaVars, condition)
+		resolved, err := resolveInlineConditionals(line, localVars, metaVars)
+		if err != nil {
+			return "", err
 		}
-		
-		replacement := ""
-		if showContent {
-			replacement = content
+		lines[i] = resolved
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// inlineFrame is an inline conditional that has been opened but not yet closed
+type inlineFrame struct {
+	marker string // The if marker itself, kept in case this turns out to be a block conditional
+	start  int    // Where the marker starts in the line
+	at     int    // Length of the output when the marker was found
+	show   bool   // Whether the condition holds
+}
+
+// resolveInlineConditionals resolves the inline conditionals in one line in a single pass.
+// Content is written straight to the output and cut back off when its condition turns out
+// false, so deep nesting costs no more than flat text.
+func resolveInlineConditionals(line string, localVars, metaVars map[string]string) (string, error) {
+	markers := inlineMarkerRegex.FindAllStringSubmatchIndex(line, -1)
+	if len(markers) == 0 {
+		return line, nil
+	}
+	lineStart := len(line) - len(strings.TrimLeft(line, " \t"))
+
+	out := make([]byte, 0, len(line))
+	var stack []inlineFrame
+	pos := 0
+	for _, m := range markers {
+		out = append(out, line[pos:m[0]]...)
+		pos = m[1]
+
+		if m[2] >= 0 { // endif
+			if len(stack) == 0 {
+				if m[0] == lineStart {
+					out = append(out, line[m[0]:m[1]]...) // Closes a block conditional
+					continue
+				}
+				return "", fmt.Errorf("malformed conditional in %q: endif without a matching if", excerpt(line))
+			}
+			frame := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if !frame.show {
+				out = out[:frame.at]
+			}
+			continue
 		}
-		
-		// Replace the first match
-		text = strings.Replace(text, matches[0], replacement, 1)
+
+		condition := strings.TrimSpace(line[m[4]:m[5]])
+		stack = append(stack, inlineFrame{
+			marker: line[m[0]:m[1]],
+			start:  m[0],
+			at:     len(out),
+			show:   conditionHolds(condition, localVars, metaVars),
+		})
 	}
-	
-	return text
+	out = append(out, line[pos:]...)
+
+	switch {
+	case len(stack) == 0:
+		return string(out), nil
+	case len(stack) == 1 && stack[0].start == lineStart:
+		// Opens a block conditional; anything nested inside it on this line is resolved
+		return string(out[:stack[0].at]) + stack[0].marker + string(out[stack[0].at:]), nil
+	}
+	return "", fmt.Errorf("malformed conditional in %q: if without a matching endif", excerpt(line))
+}
+
+// excerpt shortens a line of content for an error message
+func excerpt(line string) string {
+	line = strings.TrimSpace(line)
+	if len(line) > 80 {
+		return line[:77] + "..."
+	}
+	return line
+}
+
+// conditionHolds evaluates an if condition: a variable name, true when it is set to
+// something other than "", "false" or "0", or ! and a variable name
+func conditionHolds(condition string, localVars, metaVars map[string]string) bool {
+	if strings.HasPrefix(condition, "!") {
+		return isFalse(localVars, metaVars, strings.TrimSpace(condition[1:]))
+	}
+	return isTrue(localVars, metaVars, condition)
 }
 
 // isTrue checks if a variable is true (exists and not empty/false)
@@ -128,14 +215,15 @@ func doReplacements(text string, localVars, metaVars map[string]string) string {
 	
 	// Replace all variables using the same pattern as Python: {{variable}} where variable can contain letters, numbers, hyphens, underscores, and dots
 	result := text
-	varRegex := regexp.MustCompile(`\{\{([-\w.]+)\}\}`)
 	result = varRegex.ReplaceAllStringFunc(result, func(match string) string {
 		// Extract variable name (remove {{ and }})
 		varName := match[2 : len(match)-2]
 		if value, exists := allVars[varName]; exists {
 			return value
 		}
-		return "" // Remove undefined variables (like Python)
+		// Helpers like {{uuid}} are expanded when the page is written. Undefined variables
+		// are kept until then too, so they can be reported before they are removed.
+		return match
 	})
 	
 	return result
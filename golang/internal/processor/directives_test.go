@@ -0,0 +1,31 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+)
+
+func FuzzResolveInlineConditionals(f *testing.F) {
+	for _, seed := range []string{
+		"<p><!-- if draft -->Draft<!-- endif --></p>",
+		"<p><!-- if !draft -->Live<!-- endif --></p>",
+		"<!-- if a --><!-- if b -->ab<!-- endif --><!-- endif -->",
+		"<!-- if draft -->",
+		"<!-- endif -->",
+		"text <!-- endif -->",
+		"text <!-- if draft -->",
+		"<!-- if you remember, update this -->",
+		strings.Repeat("<!-- if a -->", 50) + strings.Repeat("<!-- endif -->", 50),
+		"<!-- if a --> <!-- if",
+	} {
+		f.Add(seed)
+	}
+	vars := map[string]string{"a": "true", "b": "false", "draft": "yes"}
+	f.Fuzz(func(t *testing.T, line string) {
+		resolved, err := resolveInlineConditionals(line, vars, nil)
+		if err == nil && len(resolved) > len(line) {
+			t.Errorf("resolveInlineConditionals(%q) grew the line to %q", line, resolved)
+		}
+		processInlineConditionals(line, vars, nil)
+	})
+}
@@ -0,0 +1,102 @@
+package processor
+
+import (
+	"html"
+	"net/url"
+	"strings"
+
+	"sniplicity/internal/diag"
+	"sniplicity/internal/parser"
+	"sniplicity/internal/plaintext"
+	"sniplicity/internal/types"
+)
+
+// excerptLength is about how many characters {{excerpt}} holds at most
+const excerptLength = 300
+
+// summaryWords is how many words {{summary}} holds at most when it comes from a page's
+// first paragraph
+const summaryWords = 50
+
+// SetEntryRenderer sets how index directives with render=true get the rendered content of
+// a page they list, from its path
+func (p *Processor) SetEntryRenderer(render func(inputPath string) (string, error)) {
+	p.renderEntry = render
+}
+
+// Quiet returns a processor set up like p that reports to a collector of its own, for
+// rendering pages again without repeating what their own build reports
+func (p *Processor) Quiet() *Processor {
+	return &Processor{
+		defaults:            p.defaults,
+		permalinks:          p.permalinks,
+		languages:           p.languages,
+		languageGlobals:     p.languageGlobals,
+		dates:               p.dates,
+		drafts:              p.drafts,
+		catalogs:            p.catalogs,
+		fallbackLanguage:    p.fallbackLanguage,
+		siteURL:             p.siteURL,
+		autoSEO:             p.autoSEO,
+		normalizeWhitespace: p.normalizeWhitespace,
+		builtin:             p.builtin,
+		write:               p.write,
+		metadata:            p.metadata,
+		diagnostics:         diag.NewCollector(),
+	}
+}
+
+// addEntryContent renders a page an index lists and adds its excerpt, first image and
+// content to its metadata, unless its frontmatter already sets them. The content is
+// protected from further processing, having been rendered for its own page already.
+func (p *Processor) addEntryContent(fileInfo *types.FileInfo, pattern, filePath string, metadata map[string]interface{}) {
+	if p.renderEntry == nil {
+		return
+	}
+	body, err := p.renderEntry(filePath)
+	if err != nil {
+		p.warn(fileInfo, directivePattern("index", pattern), "cannot render %s for its index entry: %v", filePath, err)
+		return
+	}
+
+	fields := map[string]string{
+		"excerpt": html.EscapeString(plaintext.Excerpt(body, excerptLength)),
+		"content": body,
+	}
+	if src := firstImage(body); src != "" {
+		fields["first_image"] = entryURL(metadata, src)
+	}
+	for name, value := range fields {
+		if _, exists := metadata[name]; !exists {
+			metadata[name] = parser.Literal(value)
+		}
+	}
+}
+
+// firstImage returns the src of the first image in HTML, or ""
+func firstImage(html string) string {
+	tag := imgTagRegex.FindString(html)
+	if tag == "" {
+		return ""
+	}
+	match := srcAttrRegex.FindStringSubmatch(tag)
+	if match == nil {
+		return ""
+	}
+	return match[1] + match[2]
+}
+
+// entryURL resolves a URL in a listed page against where the page is published, so it
+// works from the index page too. Local URLs come back root-relative.
+func entryURL(metadata map[string]interface{}, ref string) string {
+	page, _ := metadata["filepath"].(string)
+	base, err := url.Parse("/" + strings.TrimPrefix(page, "/"))
+	if err != nil {
+		return ref
+	}
+	target, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(target).String()
+}
@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"sniplicity/internal/types"
+)
+
+// Groupings of the index directive's group option besides a frontmatter field
+const (
+	GroupYear  = "year"  // By the year of the page's date
+	GroupMonth = "month" // By the month and year of the page's date
+)
+
+// defaultGroupHeading is the heading between groups when the index names no group-heading snippet
+var defaultGroupHeading = []string{"<h2>{{group_label}}</h2>"}
+
+// groupOf returns the group an index entry falls in, as a key that stays the same across
+// the group and a label to show: 2024 for year, 2024-05 and May 2024 for month, and the
+// field's value otherwise. Year and month read the sort field when it is a date field,
+// and the date field otherwise. An entry without the value is in the group "".
+func (p *Processor) groupOf(metadata map[string]interface{}, group, sortField string) (key, label string) {
+	if group != GroupYear && group != GroupMonth {
+		value := metadata[group]
+		if value == nil {
+			return "", ""
+		}
+		key = fmt.Sprintf("%v", value)
+		return key, key
+	}
+
+	field := "date"
+	if dateFields[strings.ToLower(sortField)] {
+		field = sortField
+	}
+	value, ok := metadata[field]
+	if !ok {
+		return "", ""
+	}
+	t, ok := p.dates.Parse(fmt.Sprintf("%v", value))
+	if !ok {
+		return "", ""
+	}
+	if group == GroupYear {
+		key = t.Format("2006")
+		return key, key
+	}
+	return t.Format("2006-01"), t.Format("January 2006")
+}
+
+// groupHeading renders the heading an index puts before each group, from its
+// group-heading snippet with {{group}} and {{group_label}} set. The entries without a
+// value, which sort last, get none.
+func (p *Processor) groupHeading(fileInfo *types.FileInfo, options indexOptions, key, label string, snippets map[string][]string, globals map[string]string) ([]string, error) {
+	if key == "" {
+		return nil, nil
+	}
+	lines := defaultGroupHeading
+	if options.groupHeading != "" {
+		snippet, exists := snippets[options.groupHeading]
+		if !exists {
+			p.report(fileInfo, directivePattern("index"), "index group-heading snippet '%s' not found", options.groupHeading)
+			return nil, nil
+		}
+		lines = scopeSnippet(fileInfo, options.groupHeading, snippet)
+	}
+	heading, err := p.processContent(fileInfo, strings.Join(lines, "\n"), map[string]string{"group": key, "group_label": label}, globals)
+	if err != nil {
+		return nil, fmt.Errorf("group heading: %w", err)
+	}
+	return strings.Split(heading, "\n"), nil
+}
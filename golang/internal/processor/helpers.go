@@ -0,0 +1,169 @@
+package processor
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"math"
+	mathrand "math/rand/v2"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"sniplicity/internal/types"
+)
+
+// helperRegex matches helper expressions like {{uuid}}, {{random 1 100}} and {{counter tabs}}
+var helperRegex = regexp.MustCompile(`\{\{(uuid|random|counter)((?:\s+[-\w.]+)*)\s*\}\}`)
+
+// anchorRegex matches {{anchor text}}, whose text may be anything up to the closing }}
+var anchorRegex = regexp.MustCompile(`\{\{anchor\s+((?:[^}]|\}[^}])+?)\s*\}\}`)
+
+// anchorTagRegex matches an HTML tag in an anchor's text
+var anchorTagRegex = regexp.MustCompile(`<[^>]*>`)
+
+// headingIDRegex matches a heading with an id attribute, capturing the ID and the text
+var headingIDRegex = regexp.MustCompile(`(?is)<h[1-6]\s[^>]*?\bid="([^"]+)"[^>]*>(.*?)</h[1-6]>`)
+
+// helperState holds the per-page state for helper expressions
+type helperState struct {
+	counters map[string]int
+	anchors  map[string]string // Text of each {{anchor}} and heading ID, by ID
+}
+
+// newHelperState creates helper state for a single output page
+func newHelperState() *helperState {
+	return &helperState{counters: make(map[string]int), anchors: make(map[string]string)}
+}
+
+// expand replaces every helper expression in text, in document order
+func (h *helperState) expand(text string) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+
+	text = anchorRegex.ReplaceAllStringFunc(text, func(match string) string {
+		return h.anchor(anchorRegex.FindStringSubmatch(match)[1])
+	})
+	return helperRegex.ReplaceAllStringFunc(text, func(match string) string {
+		parts := helperRegex.FindStringSubmatch(match)
+		name := parts[1]
+		args := strings.Fields(parts[2])
+
+		switch name {
+		case "uuid":
+			return newUUID()
+		case "random":
+			return randomInRange(args)
+		case "counter":
+			// Each group counts independently starting at 1, so repeated pastes of
+			// the same snippet on a page get distinct numbers
+			group := ""
+			if len(args) > 0 {
+				group = args[0]
+			}
+			h.counters[group]++
+			return strconv.Itoa(h.counters[group])
+		}
+
+		return match
+	})
+}
+
+// anchor returns the ID {{anchor text}} gives: a hash of the text's words, ignoring case,
+// punctuation and markup, so small edits such as a fixed typo in capitals or a changed
+// question mark keep it. The same text twice on a page gets -2, -3 and so on.
+func (h *helperState) anchor(text string) string {
+	words := anchorWords(text)
+	sum := sha256.Sum256([]byte(strings.Join(words, " ")))
+	base := "a-" + hex.EncodeToString(sum[:4])
+	id := base
+	for n := 2; ; n++ {
+		if _, taken := h.anchors[id]; !taken {
+			break
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	h.anchors[id] = strings.Join(words, " ")
+	return id
+}
+
+// addHeadings notes the ID and text of each heading on the finished page with an id,
+// so a heading whose ID changes with its text redirects like an {{anchor}} does
+func (h *helperState) addHeadings(page string) {
+	for _, match := range headingIDRegex.FindAllStringSubmatch(page, -1) {
+		if _, taken := h.anchors[match[1]]; !taken {
+			h.anchors[match[1]] = strings.Join(anchorWords(match[2]), " ")
+		}
+	}
+}
+
+// anchorWords returns the words of an anchor's text in lower case, without markup
+func anchorWords(text string) []string {
+	text = html.UnescapeString(anchorTagRegex.ReplaceAllString(text, " "))
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// randomInRange returns a random integer for {{random}}, {{random max}} or {{random min max}}
+func randomInRange(args []string) string {
+	min, max := 0, 1000000
+
+	switch len(args) {
+	case 0:
+	case 1:
+		if v, err := strconv.Atoi(args[0]); err == nil {
+			min, max = 1, v
+		}
+	default:
+		lo, errLo := strconv.Atoi(args[0])
+		hi, errHi := strconv.Atoi(args[1])
+		if errLo == nil && errHi == nil {
+			min, max = lo, hi
+		}
+	}
+
+	if max < min {
+		min, max = max, min
+	}
+
+	// Work out the span unsigned so extreme ranges can't overflow
+	span := uint64(max) - uint64(min)
+	if span == math.MaxUint64 {
+		return strconv.Itoa(int(mathrand.Uint64()))
+	}
+	return strconv.Itoa(min + int(mathrand.Uint64N(span+1)))
+}
+
+// newUUID returns a random (version 4) UUID string
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// Fall back to the non-cryptographic generator; uniqueness is all we need
+		for i := range b {
+			b[i] = byte(mathrand.IntN(256))
+		}
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// scopeSnippet returns a copy of a snippet's lines with {{snippet_id}} replaced by an ID
+// unique to this paste, e.g. "tabs-1", "tabs-2" for two pastes of "tabs" on one page
+func scopeSnippet(fileInfo *types.FileInfo, name string, lines []string) []string {
+	fileInfo.PasteCounts[name]++
+	id := fmt.Sprintf("%s-%d", strings.ReplaceAll(name, ".", "-"), fileInfo.PasteCounts[name])
+
+	scoped := make([]string, len(lines))
+	for i, line := range lines {
+		scoped[i] = strings.ReplaceAll(line, "{{snippet_id}}", id)
+	}
+	return scoped
+}
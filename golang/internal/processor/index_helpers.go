@@ -2,82 +2,14 @@ package processor
 
 import (
 	"fmt"
-	"strconv"
 	"strings"
-	"time"
 
 	"sniplicity/internal/parser"
+	"sniplicity/internal/types"
 )
 
-// getSortKey gets the sort key for a file metadata matching Python's logic exactly
-func (p *Processor) getSortKey(metadata map[string]interface{}, sortField string) float64 {
-	value, exists := metadata[sortField]
-	if !exists {
-		return 0.0
-	}
-	
-	// Handle date sorting with comprehensive date format support like Python
-	if strings.ToLower(sortField) == "date" || 
-	   strings.ToLower(sortField) == "created" || 
-	   strings.ToLower(sortField) == "modified" || 
-	   strings.ToLower(sortField) == "published" {
-		return p.parseDateToTimestamp(fmt.Sprintf("%v", value))
-	}
-	
-	// Handle numeric sorting
-	if f, ok := value.(float64); ok {
-		return f
-	}
-	if i, ok := value.(int); ok {
-		return float64(i)
-	}
-	
-	// Try to convert string to number
-	if str := fmt.Sprintf("%v", value); str != "" {
-		if f, err := strconv.ParseFloat(str, 64); err == nil {
-			return f
-		}
-	}
-	
-	// String sorting (case-insensitive) - convert to hash for numeric comparison
-	str := strings.ToLower(fmt.Sprintf("%v", value))
-	hash := 0.0
-	for _, char := range str {
-		hash = hash*31 + float64(char)
-	}
-	return hash
-}
-
-// parseDateToTimestamp parses date string using all Python-supported formats
-func (p *Processor) parseDateToTimestamp(dateStr string) float64 {
-	// Common date formats - try most specific first (matching Python exactly)
-	dateFormats := []string{
-		"2006-01-02",           // 2024-09-23
-		"2006/01/02",           // 2024/09/23
-		"01/02/2006",           // 09/23/2024
-		"02/01/2006",           // 23/09/2024
-		"Jan 02 2006",          // Sep 23 2024
-		"January 02 2006",      // September 23 2024
-		"Jan 02, 2006",         // Sep 23, 2024
-		"January 02, 2006",     // September 23, 2024
-		"02 Jan 2006",          // 23 Sep 2024
-		"02 January 2006",      // 23 September 2006
-		"2006-01-02 15:04:05",  // 2024-09-23 14:30:00
-		"2006-01-02 15:04",     // 2024-09-23 14:30
-	}
-	
-	for _, format := range dateFormats {
-		if t, err := time.Parse(format, dateStr); err == nil {
-			return float64(t.Unix())
-		}
-	}
-	
-	// If no format matches, return epoch (sorts to bottom)
-	return 0.0
-}
-
 // processIndexTemplate processes template for a single file in the index like Python's process_index_template
-func (p *Processor) processIndexTemplate(templateContent []string, fileMetadata map[string]interface{}, snippets map[string][]string, globals map[string]string) string {
+func (p *Processor) processIndexTemplate(fileInfo *types.FileInfo, templateContent []string, fileMetadata map[string]interface{}, snippets map[string][]string, globals map[string]string) (string, error) {
 	// Work with a fresh copy of the template
 	templateLines := make([]string, len(templateContent))
 	copy(templateLines, templateContent)
@@ -89,18 +21,19 @@ func (p *Processor) processIndexTemplate(templateContent []string, fileMetadata
 		if directive != nil && directive.Type == parser.DirectivePaste {
 			if snippetContent, exists := snippets[directive.Name]; exists {
 				// Get snippet content and process with file metadata
-				snippetText := strings.Join(snippetContent, "\n")
+				snippetText := strings.Join(scopeSnippet(fileInfo, directive.Name, snippetContent), "\n")
 				// Convert metadata to string map for processing
 				fileVars := make(map[string]string)
 				for k, v := range fileMetadata {
 					fileVars[k] = fmt.Sprintf("%v", v)
 				}
-				processedSnippet := ProcessContentWithDirectives(snippetText, fileVars, globals)
+				processedSnippet, err := p.processContent(fileInfo, snippetText, fileVars, globals)
+				if err != nil {
+					return "", fmt.Errorf("snippet '%s': %w", directive.Name, err)
+				}
 				processedLines = append(processedLines, strings.Split(processedSnippet, "\n")...)
 			} else {
-				if p.verbose {
-					fmt.Printf("Warning: Index template references unknown snippet '%s'\n", directive.Name)
-				}
+				p.report(fileInfo, directivePattern("paste", directive.Name), "index template pastes unknown snippet '%s'", directive.Name)
 				processedLines = append(processedLines, line)
 			}
 		} else {
@@ -118,9 +51,7 @@ func (p *Processor) processIndexTemplate(templateContent []string, fileMetadata
 	}
 	
 	// Process all variables and directives
-	result := ProcessContentWithDirectives(templateStr, fileVars, globals)
-	
-	return result
+	return p.processContent(fileInfo, templateStr, fileVars, globals)
 }
 
 // parseFrontmatter is moved here from types package to be accessible
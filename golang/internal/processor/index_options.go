@@ -0,0 +1,177 @@
+package processor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sniplicity/internal/types"
+)
+
+// indexOptions are the name=value options of an index directive
+type indexOptions struct {
+	sortType     string           // One of SortTypes, or "" to choose by the sort field
+	sortKeys     []sortKey        // The sort option's fields, which replace the sort field
+	render       bool             // Whether entries are rendered for {{excerpt}}, {{first_image}} and {{content}}
+	limit        int              // How many entries to list at most, or 0 for all of them
+	offset       int              // How many entries to skip before listing
+	where        []indexCondition // Conditions every entry must meet
+	group        string           // GroupYear, GroupMonth or a frontmatter field to put a heading before each run of entries by, or "" for none
+	groupHeading string           // Snippet the headings are rendered with, or "" for an h2
+}
+
+// indexCondition is a where option: a frontmatter field and the value it must, or with
+// negate mustn't, have. A missing field has the value "".
+type indexCondition struct {
+	field  string
+	value  string
+	negate bool
+}
+
+// matches reports whether a page's metadata meets the condition
+func (c indexCondition) matches(metadata map[string]interface{}) bool {
+	value := ""
+	if v, ok := metadata[c.field]; ok {
+		value = fmt.Sprintf("%v", v)
+	}
+	return (value == c.value) != c.negate
+}
+
+// matchesAll reports whether a page's metadata meets every where condition
+func (options indexOptions) matchesAll(metadata map[string]interface{}) bool {
+	for _, condition := range options.where {
+		if !condition.matches(metadata) {
+			return false
+		}
+	}
+	return true
+}
+
+// page returns the entries the limit and offset options leave
+func (options indexOptions) page(fileData []map[string]interface{}) []map[string]interface{} {
+	if options.offset >= len(fileData) {
+		return nil
+	}
+	fileData = fileData[options.offset:]
+	if options.limit > 0 && options.limit < len(fileData) {
+		fileData = fileData[:options.limit]
+	}
+	return fileData
+}
+
+// parseIndexOptions splits an index directive's arguments into the positional ones and
+// its options, reporting options it doesn't know or whose values it can't use
+func (p *Processor) parseIndexOptions(fileInfo *types.FileInfo, args []string) ([]string, indexOptions) {
+	var positional []string
+	var options indexOptions
+	for _, arg := range args {
+		name, value, isOption := strings.Cut(arg, "=")
+		if !isOption {
+			positional = append(positional, arg)
+			continue
+		}
+		switch name {
+		case "sort-type":
+			if validSortType(value) {
+				options.sortType = value
+			} else {
+				p.warn(fileInfo, directivePattern("index"), "index sort-type must be one of %s, not '%s'", strings.Join(SortTypes, ", "), value)
+			}
+		case "sort":
+			keys, err := parseSortKeys(value)
+			if err != nil {
+				p.warn(fileInfo, directivePattern("index"), "index sort %v", err)
+			} else {
+				options.sortKeys = keys
+			}
+		case "render":
+			switch value {
+			case "true":
+				options.render = true
+			case "false":
+				options.render = false
+			default:
+				p.warn(fileInfo, directivePattern("index"), "index render must be true or false, not '%s'", value)
+			}
+		case "limit", "offset":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				p.warn(fileInfo, directivePattern("index"), "index %s must be a whole number, not '%s'", name, value)
+			} else if name == "limit" {
+				options.limit = n
+			} else {
+				options.offset = n
+			}
+		case "where":
+			if condition, ok := parseIndexCondition(value); ok {
+				options.where = append(options.where, condition)
+			} else {
+				p.warn(fileInfo, directivePattern("index"), "index where must look like field=value or field!=value, not '%s'", value)
+			}
+		case "group":
+			options.group = value
+		case "group-heading":
+			options.groupHeading = value
+		default:
+			p.warn(fileInfo, directivePattern("index"), "index has an unknown option '%s'", name)
+		}
+	}
+	return positional, options
+}
+
+// parseIndexCondition reads a where option's value, such as "category=news" or
+// category!=news, with or without quotes
+func parseIndexCondition(value string) (indexCondition, bool) {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	field, want, found := strings.Cut(value, "=")
+	condition := indexCondition{field: strings.TrimSpace(field), value: strings.TrimSpace(want)}
+	if strings.HasSuffix(condition.field, "!") {
+		condition.field = strings.TrimSpace(strings.TrimSuffix(condition.field, "!"))
+		condition.negate = true
+	}
+	return condition, found && condition.field != ""
+}
+
+// parseSortKeys reads a sort option's value, fields separated by commas, each with an
+// optional direction and sort type: date:desc,title:asc or price:asc:numeric
+func parseSortKeys(value string) ([]sortKey, error) {
+	var keys []sortKey
+	for _, item := range strings.Split(strings.Trim(value, `"'`), ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if parts[0] == "" || len(parts) > 3 {
+			return nil, fmt.Errorf("must look like field:asc or field:desc:type, not '%s'", item)
+		}
+		sortType := ""
+		if len(parts) == 3 {
+			if !validSortType(parts[2]) {
+				return nil, fmt.Errorf("type must be one of %s, not '%s'", strings.Join(SortTypes, ", "), parts[2])
+			}
+			sortType = parts[2]
+		}
+		key := defaultSortKey(parts[0], sortType)
+		if len(parts) > 1 {
+			switch parts[1] {
+			case "asc":
+				key.descending = false
+			case "desc":
+				key.descending = true
+			default:
+				return nil, fmt.Errorf("direction must be asc or desc, not '%s'", parts[1])
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// validSortType reports whether sortType is one of SortTypes
+func validSortType(sortType string) bool {
+	for _, known := range SortTypes {
+		if sortType == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,17 @@
+package processor
+
+import "strings"
+
+// addAlternateLinks puts the hreflang links of a page that has translations before its
+// </head>, unless the page has them already through {{hreflang}}
+func addAlternateLinks(page string, metadata map[string]interface{}) string {
+	links, _ := metadata["hreflang"].(string)
+	if links == "" || strings.Contains(page, "hreflang=") {
+		return page
+	}
+	end := strings.Index(strings.ToLower(page), "</head>")
+	if end < 0 {
+		return page
+	}
+	return page[:end] + links + "\n" + page[end:]
+}
@@ -0,0 +1,35 @@
+package processor
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// LastModifiedFormat is how {{lastmod}} prints the date, the W3C date sitemaps use
+const LastModifiedFormat = "2006-01-02"
+
+// lastModifiedRegex matches the placeholder {{lastmod}} is swapped for until the page it
+// refers to has been written
+var lastModifiedRegex = regexp.MustCompile("\x00lastmod:([^\x00]*)\x00")
+
+// lastModifiedToken returns the placeholder for the {{lastmod}} of an output file,
+// relative to the output directory
+func lastModifiedToken(file string) string {
+	return "\x00lastmod:" + file + "\x00"
+}
+
+// HasLastModified reports whether a rendered page refers to when other pages last changed,
+// so it has to be written after them
+func HasLastModified(text string) bool {
+	return strings.Contains(text, "\x00lastmod:")
+}
+
+// ResolveLastModified fills in the {{lastmod}} dates of a rendered page, from when each
+// output file it refers to last changed
+func ResolveLastModified(text string, lastModified func(file string) time.Time) string {
+	return lastModifiedRegex.ReplaceAllStringFunc(text, func(token string) string {
+		file := lastModifiedRegex.FindStringSubmatch(token)[1]
+		return lastModified(file).Format(LastModifiedFormat)
+	})
+}
@@ -0,0 +1,98 @@
+package processor
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"sniplicity/internal/parser"
+	"sniplicity/internal/plaintext"
+	"sniplicity/internal/types"
+)
+
+// pageMetadata is what reading a page for an index gives, before the directory defaults,
+// permalink and other fields that depend on the configuration are added. Its frontmatter
+// has the page's word_count and reading_time.
+type pageMetadata struct {
+	modTime     time.Time
+	size        int64
+	frontmatter map[string]interface{}
+	heading     string // Text of the first # heading, "" when there is none
+	summary     string // Text of the first paragraph of the converted content, cut at summaryWords
+}
+
+// metadataCache keeps the metadata of the pages indexes list by path, so a page is read
+// and parsed once for every index directive and every rebuild until it changes
+type metadataCache struct {
+	mu    sync.Mutex
+	pages map[string]*pageMetadata
+}
+
+// newMetadataCache creates an empty metadata cache
+func newMetadataCache() *metadataCache {
+	return &metadataCache{pages: make(map[string]*pageMetadata)}
+}
+
+// load returns a page's frontmatter, first heading and summary, read again only when the
+// file's modification time or size has changed since they were cached. The frontmatter is
+// a copy the caller may add to.
+func (c *metadataCache) load(filePath string) (map[string]interface{}, string, string, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		c.forget(filePath)
+		return nil, "", "", err
+	}
+
+	c.mu.Lock()
+	page, cached := c.pages[filePath]
+	c.mu.Unlock()
+	if !cached || !page.modTime.Equal(info.ModTime()) || page.size != info.Size() {
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			c.forget(filePath)
+			return nil, "", "", err
+		}
+		lines := strings.Split(string(content), "\n")
+		body, frontmatter := parseFrontmatter(lines)
+		types.AddReadingTime(body, frontmatter)
+		page = &pageMetadata{modTime: info.ModTime(), size: info.Size(), frontmatter: frontmatter, heading: firstHeading(lines), summary: pageSummary(filePath, body)}
+
+		c.mu.Lock()
+		c.pages[filePath] = page
+		c.mu.Unlock()
+	}
+
+	metadata := make(map[string]interface{}, len(page.frontmatter))
+	for key, value := range page.frontmatter {
+		metadata[key] = value
+	}
+	return metadata, page.heading, page.summary, nil
+}
+
+// forget drops a page that can no longer be read
+func (c *metadataCache) forget(filePath string) {
+	c.mu.Lock()
+	delete(c.pages, filePath)
+	c.mu.Unlock()
+}
+
+// firstHeading returns the text of the first # heading, or ""
+func firstHeading(lines []string) string {
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "# ") {
+			return strings.TrimSpace(trimmed[2:])
+		}
+	}
+	return ""
+}
+
+// pageSummary returns the first paragraph of a page's content without its frontmatter,
+// converted from markdown first for markdown pages, cut at summaryWords
+func pageSummary(filePath string, body []string) string {
+	if types.IsMarkdownFile(filePath) {
+		body = types.RenderMarkdownFragment(body, parser.Delimiters{}, types.HeadingIDs{})
+	}
+	return plaintext.Summary(strings.Join(body, "\n"), summaryWords)
+}
@@ -2,13 +2,23 @@ package processor
 
 import (
 	"fmt"
+	"html"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
+	"sync"
 
+	"sniplicity/internal/builtin"
+	"sniplicity/internal/config"
+	"sniplicity/internal/diag"
+	"sniplicity/internal/i18n"
 	"sniplicity/internal/imgprocess"
+	"sniplicity/internal/logging"
 	"sniplicity/internal/parser"
+	"sniplicity/internal/plaintext"
 	"sniplicity/internal/types"
 
 	"github.com/fatih/color"
@@ -16,16 +26,108 @@ import (
 
 // Processor handles file processing operations
 type Processor struct {
-	verbose bool
+	defaults    *types.DirectoryDefaults // Per-directory default frontmatter, may be nil
+	permalinks  types.Permalinks         // Output URL patterns by source directory, may be nil
+	languages   types.Languages          // Languages of a multilingual site, none for a site in one
+	languageGlobals map[string]map[string]string // Globals by language, which override the site's for pages in it
+	dates       types.Dates              // Reads frontmatter dates for sorting and permalinks
+	gitHistory  types.GitHistory         // Last commit of each source file, for git_info, may be nil
+	drafts      bool                     // Whether pages marked draft: true are listed in indexes
+	catalogs    map[string]i18n.Catalog  // Translations by language, may be nil
+	fallbackLanguage string              // Whose translations pages in a language without a catalog get
+	siteURL     string                   // Address the site is published at, without a trailing slash, may be empty
+	autoSEO     bool                     // Whether pages without a seo directive get its tags before their </head>
+	normalizeWhitespace bool             // Whether written pages get their line endings and trailing whitespace tidied
+	sitemap     []SitemapPage            // Every generated page, for sitemap-page directives
+	builtin     *builtin.Templates       // Markup of the lists sniplicity generates itself
+	write       func(path string, data []byte) error // How output files are written
+	diagnostics *diag.Collector          // Where warnings and errors are reported
+	renderEntry func(inputPath string) (string, error) // Renders pages listed by index directives with render=true, may be nil
+	metadata    *metadataCache           // Frontmatter of the pages indexes list, kept between builds
+	externalMu  sync.Mutex
+	external    map[string]bool          // Included files from outside the input directory
 }
 
 // New creates a new Processor instance
-func New(verbose bool) *Processor {
-	return &Processor{verbose: verbose}
+func New() *Processor {
+	return &Processor{diagnostics: diag.NewCollector(), write: writeFile, builtin: builtin.New(""), metadata: newMetadataCache()}
+}
+
+// SetBuiltinTemplates sets the templates of the markup sniplicity generates itself, such
+// as the list around a site map
+func (p *Processor) SetBuiltinTemplates(templates *builtin.Templates) {
+	p.builtin = templates
+}
+
+// SetWriter sets how output files are written, so a dry run can note them instead
+func (p *Processor) SetWriter(write func(path string, data []byte) error) {
+	p.write = write
+}
+
+// writeFile writes an output file, creating its folder first
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("cannot create output directory %s: %w", filepath.Dir(path), err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// SetDiagnostics sets the collector that warnings and errors are reported to
+func (p *Processor) SetDiagnostics(diagnostics *diag.Collector) {
+	p.diagnostics = diagnostics
+}
+
+// SetDrafts sets whether pages marked draft: true are listed in indexes
+func (p *Processor) SetDrafts(drafts bool) {
+	p.drafts = drafts
+}
+
+// SetCatalogs sets the translations used for {{t "..."}} strings, keyed by language
+func (p *Processor) SetCatalogs(catalogs map[string]i18n.Catalog) {
+	p.catalogs = catalogs
+}
+
+// SetFallbackLanguage sets the language whose translations are used for pages in a
+// language that has no catalog
+func (p *Processor) SetFallbackLanguage(lang string) {
+	p.fallbackLanguage = lang
+}
+
+// SetDirectoryDefaults sets the _defaults.yaml loader used for index metadata
+func (p *Processor) SetDirectoryDefaults(defaults *types.DirectoryDefaults) {
+	p.defaults = defaults
+}
+
+// SetPermalinks sets the permalink rules used for index metadata
+func (p *Processor) SetPermalinks(permalinks types.Permalinks) {
+	p.permalinks = permalinks
+}
+
+// SetLanguages sets the languages of a multilingual site, which index metadata gets the
+// lang and permalink of pages from
+func (p *Processor) SetLanguages(languages types.Languages) {
+	p.languages = languages
+}
+
+// SetLanguageGlobals sets the globals of each language, which pages in that language
+// get instead of the site's globals of the same name
+func (p *Processor) SetLanguageGlobals(globals map[string]map[string]string) {
+	p.languageGlobals = globals
+}
+
+// SetDates sets how frontmatter dates are read for sorting indexes and permalinks
+func (p *Processor) SetDates(dates types.Dates) {
+	p.dates = dates
+}
+
+// SetGitHistory sets the last commits the git.lastmod and git.author of listed pages
+// come from, or nil without git_info
+func (p *Processor) SetGitHistory(history types.GitHistory) {
+	p.gitHistory = history
 }
 
 // CollectSnippetsFromFile extracts snippets and templates from a file using stack-based processing like Python
-func (p *Processor) CollectSnippetsFromFile(fileInfo *types.FileInfo, snippets, templates map[string][]string, verbose bool) error {
+func (p *Processor) CollectSnippetsFromFile(fileInfo *types.FileInfo, snippets, templates map[string][]string) error {
 	// Stack to handle nested snippets/templates: (name, block, type, nesting_level, start_line)
 	type stackItem struct {
 		name         string
@@ -60,9 +162,7 @@ func (p *Processor) CollectSnippetsFromFile(fileInfo *types.FileInfo, snippets,
 					startLine:    i,
 				})
 				
-				if verbose {
-					fmt.Printf("  Start %s '%s' at level %d in %s\n", itemType, directive.Name, nestingLevel, fileInfo.Filename)
-				}
+				logging.Debugf("  Start %s '%s' at level %d in %s", itemType, directive.Name, nestingLevel, fileInfo.Filename)
 				
 			default:
 				// Check for end directive (Python uses "end" but our parser uses block end detection)
@@ -71,24 +171,25 @@ func (p *Processor) CollectSnippetsFromFile(fileInfo *types.FileInfo, snippets,
 					item := contentStack[len(contentStack)-1]
 					contentStack = contentStack[:len(contentStack)-1]
 					
-					if verbose {
-						fmt.Printf("  End %s '%s' from level %d in %s\n", item.itemType, item.name, item.nestingLevel, fileInfo.Filename)
-					}
+					logging.Debugf("  End %s '%s' from level %d in %s", item.itemType, item.name, item.nestingLevel, fileInfo.Filename)
 					
 					// Store the item based on type
+					defined, kind := snippets, "snippet"
+					if item.itemType == "template" {
+						defined, kind = templates, "template"
+					}
+					if _, exists := defined[item.name]; exists {
+						line := sourceLine(fileInfo.InputPath, directivePattern(item.itemType, item.name))
+						p.diagnostics.Check(diag.SnippetCollision, fileInfo.InputPath, line, "%s '%s' is already defined, this definition replaces it", kind, item.name)
+					}
 					if item.itemType == "template" {
 						templates[item.name] = make([]string, len(item.block))
 						copy(templates[item.name], item.block)
-						if verbose {
-							fmt.Printf("  Stored template '%s' with %d lines\n", item.name, len(item.block))
-						}
+						logging.Debugf("  Stored template '%s' with %d lines", item.name, len(item.block))
 					} else {
 						snippets[item.name] = make([]string, len(item.block))
 						copy(snippets[item.name], item.block)
-						if verbose {
-							green := color.New(color.FgGreen)
-							fmt.Printf("  Found %s: %s\n", green.Sprint("snippet"), item.name)
-						}
+						logging.Debugf("  Found %s: %s", color.GreenString("snippet"), item.name)
 					}
 				} else {
 					// Add the line to all active blocks
@@ -108,96 +209,206 @@ func (p *Processor) CollectSnippetsFromFile(fileInfo *types.FileInfo, snippets,
 	return nil
 }
 
-// CollectGlobalsFromFile extracts global variables from a file
-func (p *Processor) CollectGlobalsFromFile(fileInfo *types.FileInfo, globals map[string]string, verbose bool) error {
+// CollectGlobalsFromFile extracts global variables from a file, expanding the environment
+// variables in their values
+func (p *Processor) CollectGlobalsFromFile(fileInfo *types.FileInfo, globals map[string]string) error {
 	directives := parser.ParseDirectives(fileInfo.Content)
 	
 	for _, directive := range directives {
 		if directive.Type == parser.DirectiveGlobal {
-			globals[directive.Name] = directive.Args[0]
-			if verbose {
-				fmt.Printf("  Found global: %s = %s\n", directive.Name, directive.Args[0])
+			value, unset := config.ExpandEnvVars(directive.Args[0])
+			for _, name := range unset {
+				p.warn(fileInfo, regexp.QuoteMeta("${"+name), "global %s uses ${%s}, which is not set", directive.Name, name)
 			}
+			globals[directive.Name] = value
+			logging.Debugf("  Found global: %s = %s", directive.Name, value)
 		}
 	}
 	
 	return nil
 }
 
-// ProcessIncludes processes include directives in a file
-func (p *Processor) ProcessIncludes(fileInfo *types.FileInfo, inputDir string) error {
+// maxIncludeDepth limits how deeply included files may include other files
+const maxIncludeDepth = 10
+
+// ProcessIncludes processes include directives in a file. Include paths may contain
+// {{variables}} and are resolved relative to the including file first, then the input root.
+func (p *Processor) ProcessIncludes(fileInfo *types.FileInfo, inputDir string, globals map[string]string) error {
+	// Variables available to include paths: globals, then frontmatter, then set directives
+	vars := make(map[string]string)
+	for k, v := range globals {
+		vars[k] = v
+	}
+	for k, v := range fileInfo.Metadata {
+		if str, ok := v.(string); ok {
+			vars[k] = str
+		}
+	}
+	for _, directive := range parser.ParseDirectives(fileInfo.Content) {
+		if directive.Type == parser.DirectiveSet {
+			vars[directive.Name] = directive.Args[0]
+		}
+	}
+	
+	stack := []string{fileInfo.InputPath}
+	content, err := p.expandIncludes(fileInfo, fileInfo.Content, filepath.Dir(fileInfo.InputPath), inputDir, vars, stack)
+	if err != nil {
+		return err
+	}
+	fileInfo.Content = content
+	return nil
+}
+
+// expandIncludes replaces include directives in lines with the included content, recursively.
+// It stops as soon as the page runs past its limits.
+func (p *Processor) expandIncludes(fileInfo *types.FileInfo, lines []string, baseDir, inputDir string, vars map[string]string, stack []string) ([]string, error) {
 	var newContent []string
-	directives := parser.ParseDirectives(fileInfo.Content)
+	size := 0
 	
-	// Process content line by line
-	for i, line := range fileInfo.Content {
-		// Check if this line has an include directive
-		hasInclude := false
-		for _, directive := range directives {
-			if directive.Type == parser.DirectiveInclude && directive.LineIndex == i {
-				// Process include
-				includePath := directive.Args[0]
-				fullPath := filepath.Join(inputDir, includePath)
-				
-				// Read included file
-				includeContent, err := os.ReadFile(fullPath)
-				if err != nil {
-					if p.verbose {
-						fmt.Printf("Warning: Cannot read include file %s\n", fullPath)
-					}
-					newContent = append(newContent, line) // Keep original line
-				} else {
-					// Add included content
-					includeLines := strings.Split(strings.TrimRight(string(includeContent), "\n"), "\n")
-					newContent = append(newContent, includeLines...)
-				}
-				hasInclude = true
-				break
-			}
+	for i, line := range lines {
+		directive := parser.ParseLine(line, i)
+		if directive == nil || directive.Type != parser.DirectiveInclude {
+			newContent = append(newContent, line)
+			size += len(line) + 1
+			continue
 		}
 		
-		if !hasInclude {
+		includePath := parser.ExpandVariables(directive.Args[0], vars)
+		fullPath := ResolveIncludePath(includePath, baseDir, inputDir)
+		p.noteExternalInclude(fullPath, inputDir)
+		
+		if len(stack) > maxIncludeDepth {
+			p.report(fileInfo, directivePattern("include", directive.Args[0]), "include depth limit (%d) reached for %s", maxIncludeDepth, includePath)
 			newContent = append(newContent, line)
+			continue
+		}
+		
+		if containsPath(stack, fullPath) {
+			p.report(fileInfo, directivePattern("include", directive.Args[0]), "circular include of %s", includePath)
+			newContent = append(newContent, line)
+			continue
+		}
+		
+		// Read included file
+		includeContent, err := os.ReadFile(fullPath)
+		if err != nil {
+			p.report(fileInfo, directivePattern("include", directive.Args[0]), "include file %s not found", includePath)
+			newContent = append(newContent, line) // Keep original line
+			continue
+		}
+		
+		// Add included content, resolving its own includes relative to where it lives.
+		// Pages are already HTML by now, so markdown partials are converted to match.
+		// Partials use the delimiters of the page they are included in.
+		includeLines := strings.Split(strings.TrimRight(string(includeContent), "\n"), "\n")
+		if types.IsMarkdownFile(fullPath) {
+			includeLines = types.RenderMarkdownFragment(includeLines, fileInfo.Delimiters, fileInfo.HeadingIDs)
+		} else {
+			includeLines = parser.ProtectRaw(parser.ApplyDelimiters(includeLines, fileInfo.Delimiters))
+		}
+		includeLines, err = p.expandIncludes(fileInfo, includeLines, filepath.Dir(fullPath), inputDir, vars, append(stack, fullPath))
+		if err != nil {
+			return nil, err
+		}
+		newContent = append(newContent, includeLines...)
+		size += types.ContentSize(includeLines)
+		if err := fileInfo.Limits.Check(size); err != nil {
+			return nil, err
 		}
 	}
 	
-	fileInfo.Content = newContent
-	return nil
+	return newContent, nil
+}
+
+// ResolveIncludePath finds the file an include refers to. Paths starting with / are
+// relative to the input root; others are tried next to the including file first and
+// then relative to the input root, which is how includes always used to resolve.
+func ResolveIncludePath(includePath, baseDir, inputDir string) string {
+	if strings.HasPrefix(includePath, "/") {
+		return filepath.Join(inputDir, includePath)
+	}
+	
+	relative := filepath.Join(baseDir, includePath)
+	if _, err := os.Stat(relative); err == nil {
+		return relative
+	}
+	
+	return filepath.Join(inputDir, includePath)
+}
+
+// noteExternalInclude remembers an included file that lives outside the input directory,
+// so watch mode can rebuild when it changes
+func (p *Processor) noteExternalInclude(fullPath, inputDir string) {
+	rel, err := filepath.Rel(inputDir, fullPath)
+	if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return
+	}
+	p.externalMu.Lock()
+	defer p.externalMu.Unlock()
+	if p.external == nil {
+		p.external = make(map[string]bool)
+	}
+	p.external[filepath.Clean(fullPath)] = true
+}
+
+// ResetExternalIncludes forgets the external includes found by a previous build
+func (p *Processor) ResetExternalIncludes() {
+	p.externalMu.Lock()
+	defer p.externalMu.Unlock()
+	p.external = nil
+}
+
+// ExternalIncludes returns the included files found outside the input directory, sorted
+func (p *Processor) ExternalIncludes() []string {
+	p.externalMu.Lock()
+	defer p.externalMu.Unlock()
+	paths := make([]string, 0, len(p.external))
+	for path := range p.external {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// containsPath reports whether path is already in the include stack
+func containsPath(stack []string, path string) bool {
+	for _, item := range stack {
+		if item == path {
+			return true
+		}
+	}
+	return false
 }
 
 // ProcessIndexCommands processes index directives in a file exactly like Python
 func (p *Processor) ProcessIndexCommands(fileInfo *types.FileInfo, inputDir string, templates map[string][]string, snippets map[string][]string, globals map[string]string) error {
 	var newContent []string
+	size := types.ContentSize(fileInfo.Content) // The page with every entry generated so far
 	
 	for i, line := range fileInfo.Content {
 		directive := parser.ParseLine(line, i)
 		
 		if directive != nil && directive.Type == parser.DirectiveIndex {
-			// Parse index directive: <!-- index pattern template [sort_field] -->
-			if len(directive.Args) < 2 {
-				if p.verbose {
-					fmt.Printf("Warning: Index command requires at least pattern and template: %s in %s:%d\n", line, fileInfo.Filename, i+1)
-				}
+			// Parse index directive: <!-- index pattern template [sort_field] [sort-type=natural] [sort=date:desc,title:asc] [render=true] [limit=5] [offset=0] [where=field=value] [group=year] [group-heading=snippet] -->
+			args, options := p.parseIndexOptions(fileInfo, directive.Args)
+			if len(args) < 2 {
+				p.report(fileInfo, directivePattern("index"), "index needs a file pattern and a template name")
 				newContent = append(newContent, line)
 				continue
 			}
 			
-			pattern := directive.Args[0]    // e.g., "blog/*.md"
-			templateName := directive.Args[1] // e.g., "blog-item"
+			pattern := args[0]      // e.g., "blog/*.md"
+			templateName := args[1] // e.g., "blog-item"
 			var sortField string
-			if len(directive.Args) > 2 {
-				sortField = directive.Args[2] // e.g., "date"
+			if len(args) > 2 {
+				sortField = args[2] // e.g., "date"
 			}
 			
-			if p.verbose {
-				fmt.Printf("  Processing index: pattern='%s' template='%s' sort='%s'\n", pattern, templateName, sortField)
-			}
+			logging.Debugf("  Processing index: pattern='%s' template='%s' sort='%s' type='%s'", pattern, templateName, sortField, options.sortType)
 			
 			// Check if template exists
 			if _, exists := templates[templateName]; !exists {
-				if p.verbose {
-					fmt.Printf("Warning: Index template '%s' not found in %s:%d\n", templateName, fileInfo.Filename, i+1)
-				}
+				p.report(fileInfo, directivePattern("index", pattern, templateName), "index template '%s' not found", templateName)
 				newContent = append(newContent, line)
 				continue
 			}
@@ -205,41 +416,67 @@ func (p *Processor) ProcessIndexCommands(fileInfo *types.FileInfo, inputDir stri
 			// Find matching files using glob pattern
 			matchingFiles, err := p.findMatchingFiles(pattern, inputDir)
 			if err != nil {
-				if p.verbose {
-					fmt.Printf("Warning: Error finding files for pattern '%s': %v\n", pattern, err)
-				}
+				p.warn(fileInfo, directivePattern("index", pattern), "cannot find files for pattern '%s': %v", pattern, err)
 				newContent = append(newContent, line)
 				continue
 			}
 			
-			if p.verbose {
-				fmt.Printf("  Found %d matching files\n", len(matchingFiles))
-			}
+			logging.Debugf("  Found %d matching files", len(matchingFiles))
 			
 			// Load metadata from matching files
 			var fileData []map[string]interface{}
+			sources := make(map[string]string) // Source file of each entry, by its filepath
 			for _, filePath := range matchingFiles {
 				metadata, err := p.loadFileMetadata(filePath, inputDir)
 				if err != nil {
-					if p.verbose {
-						fmt.Printf("Warning: Cannot load metadata from %s: %v\n", filePath, err)
-					}
+					p.warn(fileInfo, directivePattern("index", pattern), "cannot load metadata from %s: %v", filePath, err)
 					continue
 				}
-				if metadata != nil {
+				if metadata != nil && (p.drafts || !types.IsDraft(metadata)) && options.matchesAll(metadata) {
+					sources[metadata["filepath"].(string)] = filePath
 					fileData = append(fileData, metadata)
 				}
 			}
 			
-			// Sort files if sort field is specified
-			if sortField != "" && len(fileData) > 0 {
-				fileData = p.sortFileData(fileData, sortField)
+			// Sort files by the sort option, or else the sort field if one is specified
+			if len(options.sortKeys) > 0 {
+				fileData = p.sortFileData(fileData, options.sortKeys)
+				sortField = options.sortKeys[0].field
+			} else if sortField != "" {
+				fileData = p.sortFileData(fileData, []sortKey{defaultSortKey(sortField, options.sortType)})
 			}
+			fileData = options.page(fileData)
 			
-			// Generate HTML for each file using the template
+			// Only the entries listed are rendered
+			if options.render {
+				for _, metadata := range fileData {
+					p.addEntryContent(fileInfo, pattern, sources[metadata["filepath"].(string)], metadata)
+				}
+			}
+			
+			// Generate HTML for each file using the template, with a heading before each group
+			group := "\x00"
 			for _, fileMeta := range fileData {
-				indexHTML := p.processIndexTemplate(templates[templateName], fileMeta, snippets, globals)
+				if options.group != "" {
+					if key, label := p.groupOf(fileMeta, options.group, sortField); key != group {
+						heading, err := p.groupHeading(fileInfo, options, key, label, snippets, globals)
+						if err != nil {
+							return fmt.Errorf("index template '%s': %w", templateName, err)
+						}
+						newContent = append(newContent, heading...)
+						size += types.ContentSize(heading)
+						group = key
+					}
+				}
+				indexHTML, err := p.processIndexTemplate(fileInfo, templates[templateName], fileMeta, snippets, globals)
+				if err != nil {
+					return fmt.Errorf("index template '%s': %w", templateName, err)
+				}
 				newContent = append(newContent, strings.Split(indexHTML, "\n")...)
+				size += len(indexHTML) + 1
+				if err := fileInfo.Limits.Check(size); err != nil {
+					return err
+				}
 			}
 		} else {
 			newContent = append(newContent, line)
@@ -310,10 +547,17 @@ func (p *Processor) generateIndex(dirPath string, templates map[string][]string,
 	return indexLines, nil
 }
 
-// findMatchingFiles finds files matching the glob pattern like Python's find_matching_files
+// findMatchingFiles finds files matching the glob pattern like Python's find_matching_files.
+// A ** folder in the pattern matches any number of folders, so blog/**/*.md finds posts
+// in nested year and month folders.
 func (p *Processor) findMatchingFiles(pattern, sourceDir string) ([]string, error) {
-	fullPattern := filepath.Join(sourceDir, pattern)
-	matches, err := filepath.Glob(fullPattern)
+	var matches []string
+	var err error
+	if strings.Contains(pattern, "**") {
+		matches, err = globRecursive(pattern, sourceDir)
+	} else {
+		matches, err = filepath.Glob(filepath.Join(sourceDir, pattern))
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -335,25 +579,67 @@ func (p *Processor) findMatchingFiles(pattern, sourceDir string) ([]string, erro
 	return filteredMatches, nil
 }
 
+// globRecursive returns the files under sourceDir matching a pattern with ** in it, in
+// lexical order. Only the folder the pattern starts with, before any wildcard, is
+// searched, and hidden folders are skipped.
+func globRecursive(pattern, sourceDir string) ([]string, error) {
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "/")
+	if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+		return nil, err
+	}
+
+	// Start at the folders the pattern names before its first wildcard
+	parts := strings.Split(pattern, "/")
+	start := 0
+	for start < len(parts)-1 && !strings.ContainsAny(parts[start], "*?[") {
+		start++
+	}
+	root := filepath.Join(sourceDir, filepath.FromSlash(strings.Join(parts[:start], "/")))
+
+	var matches []string
+	err := filepath.WalkDir(root, func(filePath string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && filePath == root {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if entry.IsDir() {
+			if filePath != root && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(sourceDir, filePath)
+		if err == nil && config.MatchPattern(pattern, filepath.ToSlash(rel)) {
+			matches = append(matches, filePath)
+		}
+		return nil
+	})
+	return matches, err
+}
+
 // loadFileMetadata loads metadata from a file (frontmatter + computed fields) like Python's load_file_metadata
 func (p *Processor) loadFileMetadata(filePath, sourceDir string) (map[string]interface{}, error) {
-	// Read file content
-	content, err := os.ReadFile(filePath)
+	// Parsed once per change of the file, across index directives and rebuilds
+	metadata, heading, summary, err := p.metadata.load(filePath)
 	if err != nil {
 		return nil, err
 	}
 	
-	lines := strings.Split(string(content), "\n")
-	
-	// Parse frontmatter for metadata
-	_, metadata := parseFrontmatter(lines)
-	
 	// Add computed fields like Python does
 	relPath, err := filepath.Rel(sourceDir, filePath)
 	if err != nil {
 		relPath = filePath
 	}
 	
+	// Fill in directory defaults and the language the same way pages get them
+	if p.defaults != nil {
+		p.defaults.Apply(metadata, filepath.Dir(relPath))
+	}
+	p.languages.Localize(relPath, metadata)
+	p.languages.Section(relPath, metadata)
+	
 	// Convert to output path (change .md to .html)
 	outputPath := relPath
 	if strings.HasSuffix(strings.ToLower(outputPath), ".md") || 
@@ -363,20 +649,20 @@ func (p *Processor) loadFileMetadata(filePath, sourceDir string) (map[string]int
 		outputPath = outputPath[:len(outputPath)-len(ext)] + ".html"
 	}
 	
-	metadata["filepath"] = outputPath
+	// Always use forward slashes - this ends up in URLs, even on Windows
+	metadata["filepath"] = filepath.ToSlash(outputPath)
+	if permalink := p.languages.Permalink(p.permalinks, relPath, metadata, p.dates); permalink != "" {
+		metadata["filepath"] = permalink
+	}
 	metadata["filename"] = filepath.Base(filePath)
+	types.AddFileVariables(metadata, filePath, relPath, "/"+metadata["filepath"].(string), p.gitHistory)
 	
 	// Add title if not present
 	if _, exists := metadata["title"]; !exists {
-		// Try to extract title from first heading or use filename
-		for _, line := range lines {
-			trimmed := strings.TrimSpace(line)
-			if strings.HasPrefix(trimmed, "# ") {
-				metadata["title"] = strings.TrimSpace(trimmed[2:])
-				break
-			}
-		}
-		if _, exists := metadata["title"]; !exists {
+		// Use the first heading or else the filename
+		if heading != "" {
+			metadata["title"] = heading
+		} else {
 			// Use filename without extension as fallback
 			name := filepath.Base(filePath)
 			ext := filepath.Ext(name)
@@ -387,9 +673,20 @@ func (p *Processor) loadFileMetadata(filePath, sourceDir string) (map[string]int
 		}
 	}
 	
+	// Add a summary for index templates, the description when there is one
+	if _, exists := metadata["summary"]; !exists {
+		if description, ok := metadata["description"].(string); ok && description != "" {
+			summary = description
+		}
+		metadata["summary"] = parser.Literal(html.EscapeString(summary))
+	}
+	
 	return metadata, nil
 }
 
+// maxSnippetLines caps how large pasting can make a page
+const maxSnippetLines = 100000
+
 // ProcessSnippets processes snippet directives using iterative processing like Python
 func (p *Processor) ProcessSnippets(fileInfo *types.FileInfo, snippets map[string][]string) error {
 	// First find any local snippets in this file and track cut regions
@@ -492,9 +789,11 @@ func (p *Processor) ProcessSnippets(fileInfo *types.FileInfo, snippets map[strin
 	// Keep processing until no more paste directives are found
 	maxIterations := 10 // Prevent infinite loops
 	iteration := 0
+	tooLarge := false
 	
 	for iteration < maxIterations {
 		var newFile []string
+		size := 0
 		foundPaste := false
 		
 		for _, line := range currentData {
@@ -502,23 +801,33 @@ func (p *Processor) ProcessSnippets(fileInfo *types.FileInfo, snippets map[strin
 			
 			if directive != nil && directive.Type == parser.DirectivePaste {
 				foundPaste = true
-				// First try local snippets, then fall back to global
-				if snippetContent, exists := localSnippets[directive.Name]; exists {
-					newFile = append(newFile, snippetContent...)
+				var pasted []string
+				if len(newFile) > maxSnippetLines {
+					// A snippet pasting itself grows exponentially; stop before it eats all memory
+					if !tooLarge {
+						tooLarge = true
+						p.report(fileInfo, directivePattern("paste", directive.Name), "snippet '%s' makes the page too large (recursive paste?)", directive.Name)
+					}
+				} else if snippetContent, exists := localSnippets[directive.Name]; exists { // Local snippets first, then global
+					pasted = scopeSnippet(fileInfo, directive.Name, snippetContent)
 					fileInfo.UsedSnippets[directive.Name] = true
 				} else if snippetContent, exists := snippets[directive.Name]; exists {
-					newFile = append(newFile, snippetContent...)
+					pasted = scopeSnippet(fileInfo, directive.Name, snippetContent)
 					fileInfo.UsedSnippets[directive.Name] = true
 				} else {
-					if p.verbose {
-						fmt.Printf("Warning: Unable to insert %s because snippet doesn't exist in %s\n", directive.Name, fileInfo.Filename)
-					}
+					p.report(fileInfo, directivePattern("paste", directive.Name), "snippet '%s' doesn't exist", directive.Name)
 					// Don't add the paste directive to output - remove it even if snippet doesn't exist
 				}
+				newFile = append(newFile, pasted...)
+				size += types.ContentSize(pasted)
+				if err := fileInfo.Limits.Check(size); err != nil {
+					return err
+				}
 			} else if directive != nil && (directive.Type == parser.DirectiveCopy || directive.Type == parser.DirectiveCut || directive.Type == parser.DirectiveTemplate || parser.IsBlockEnd(line)) {
 				// Remove directive markers from output - they should not appear in final content
 			} else {
 				newFile = append(newFile, line)
+				size += len(line) + 1
 			}
 		}
 		
@@ -531,8 +840,8 @@ func (p *Processor) ProcessSnippets(fileInfo *types.FileInfo, snippets map[strin
 		}
 	}
 	
-	if iteration >= maxIterations && p.verbose {
-		fmt.Printf("Warning: Maximum snippet processing iterations reached in %s\n", fileInfo.Filename)
+	if iteration >= maxIterations && !tooLarge {
+		p.warn(fileInfo, "", "snippets nested more than %d deep were not pasted", maxIterations)
 	}
 	
 	fileInfo.Content = currentData
@@ -540,7 +849,71 @@ func (p *Processor) ProcessSnippets(fileInfo *types.FileInfo, snippets map[strin
 }
 
 // ProcessVariables processes variable substitution and writes the file
-func (p *Processor) ProcessVariables(fileInfo *types.FileInfo, outputDir string, templates map[string][]string, snippets map[string][]string, globals map[string]string, imgSize bool, verbose bool) error {
+func (p *Processor) ProcessVariables(fileInfo *types.FileInfo, outputDir string, templates map[string][]string, snippets map[string][]string, globals map[string]string, imgSize bool) error {
+	finalContentStr, err := p.RenderPage(fileInfo, outputDir, templates, snippets, globals, imgSize)
+	if err != nil {
+		return err
+	}
+	return p.WritePage(fileInfo, outputDir, finalContentStr)
+}
+
+// FinishPage applies the changes made to a rendered page on its way out, so a page looks
+// the same whether it is written by the build or rendered on its own
+func (p *Processor) FinishPage(page string) string {
+	if p.normalizeWhitespace {
+		page = normalizeWhitespace(page)
+	}
+	return page
+}
+
+// WritePage writes a rendered page to its output path
+func (p *Processor) WritePage(fileInfo *types.FileInfo, outputDir, finalContentStr string) error {
+	// Write output file
+	outputPath := fileInfo.GetOutputPath(outputDir)
+	finalContentStr = p.FinishPage(finalContentStr)
+	
+	if err := p.write(outputPath, []byte(finalContentStr)); err != nil {
+		return fmt.Errorf("cannot write file %s: %w", outputPath, err)
+	}
+	
+	logging.Debugf("  Wrote %s", outputPath)
+	
+	return nil
+}
+
+// WritePlainText writes a plain text version of a page's body next to its output page,
+// with the extension swapped for .txt. The title usually comes from the template, so
+// it is added from the frontmatter unless the body already starts with it.
+func (p *Processor) WritePlainText(fileInfo *types.FileInfo, outputDir, body string) error {
+	outputPath := fileInfo.GetPlainTextPath(outputDir)
+	
+	text := plaintext.FromHTML(body)
+	if title, ok := fileInfo.Metadata["title"].(string); ok && title != "" && !strings.HasPrefix(text, title) {
+		text = title + "\n\n" + text
+	}
+	if p.normalizeWhitespace {
+		text = normalizeWhitespace(text)
+	}
+	
+	if err := p.write(outputPath, []byte(text)); err != nil {
+		return fmt.Errorf("cannot write file %s: %w", outputPath, err)
+	}
+	
+	logging.Debugf("  Wrote %s", outputPath)
+	
+	return nil
+}
+
+// RenderPage expands variables, applies the page's template and returns the finished
+// page content without writing it. outputDir is used to resolve images for sizing.
+func (p *Processor) RenderPage(fileInfo *types.FileInfo, outputDir string, templates map[string][]string, snippets map[string][]string, globals map[string]string, imgSize bool) (string, error) {
+	page, _, err := p.RenderPageWithBody(fileInfo, outputDir, templates, snippets, globals, imgSize)
+	return page, err
+}
+
+// RenderPageWithBody is RenderPage that also returns the page's own content, processed
+// the same way but without its template around it
+func (p *Processor) RenderPageWithBody(fileInfo *types.FileInfo, outputDir string, templates map[string][]string, snippets map[string][]string, globals map[string]string, imgSize bool) (string, string, error) {
 	// Collect local variables from set directives
 	localVars := make(map[string]string)
 	directives := parser.ParseDirectives(fileInfo.Content)
@@ -567,8 +940,25 @@ func (p *Processor) ProcessVariables(fileInfo *types.FileInfo, outputDir string,
 		}
 	}
 	
-	// Remove directive lines and expand variables
+	// Translatable strings and language globals follow the page's lang, which may come
+	// from its path, frontmatter, _defaults.yaml, a set directive or a global
+	lang := allVars["lang"]
+	catalog, found := p.catalogs[lang]
+	if !found {
+		catalog = p.catalogs[p.fallbackLanguage]
+	}
+	for k, v := range p.languageGlobals[lang] {
+		if _, local := localVars[k]; local {
+			continue
+		}
+		if _, meta := fileInfo.Metadata[k].(string); !meta {
+			allVars[k] = v
+		}
+	}
+	
+	// Remove directive lines, translate strings and expand variables
 	var finalContent []string
+	size := 0
 	for i, line := range fileInfo.Content {
 		// Check if this line is a directive that should be removed
 		isDirective := false
@@ -579,7 +969,10 @@ func (p *Processor) ProcessVariables(fileInfo *types.FileInfo, outputDir string,
 				   directive.Type == parser.DirectiveGlobal ||
 				   directive.Type == parser.DirectivePaste ||
 				   directive.Type == parser.DirectiveInclude ||
-				   directive.Type == parser.DirectiveIndex {
+				   directive.Type == parser.DirectiveIndex ||
+				   directive.Type == parser.DirectiveSitemap ||
+				   directive.Type == parser.DirectiveRedirect ||
+				   directive.Type == parser.DirectiveDepends {
 					isDirective = true
 					break
 				}
@@ -588,8 +981,12 @@ func (p *Processor) ProcessVariables(fileInfo *types.FileInfo, outputDir string,
 		
 		if !isDirective {
 			// Expand variables in the line
-			expandedLine := parser.ExpandVariables(line, allVars)
+			expandedLine := parser.ExpandVariables(i18n.Translate(line, catalog), allVars)
 			finalContent = append(finalContent, expandedLine)
+			size += len(expandedLine) + 1
+			if err := fileInfo.Limits.Check(size); err != nil {
+				return "", "", err
+			}
 		}
 	}
 	
@@ -601,11 +998,10 @@ func (p *Processor) ProcessVariables(fileInfo *types.FileInfo, outputDir string,
 		templateName = metaTemplate
 	}
 	
+	var body string
 	if templateName != "" {
 		if templateContent, templateExists := templates[templateName]; templateExists {
-			if verbose {
-				fmt.Printf("  Using template '%s' for %s\n", templateName, fileInfo.Filename)
-			}
+			logging.Debugf("  Using template '%s' for %s", templateName, fileInfo.Filename)
 			
 			// Get the template content and process snippets in it (like Python)
 			var processedTemplate []string
@@ -616,13 +1012,14 @@ func (p *Processor) ProcessVariables(fileInfo *types.FileInfo, outputDir string,
 				if directive != nil && directive.Type == parser.DirectivePaste {
 					if snippetContent, exists := snippets[directive.Name]; exists {
 						// Process the snippet content with directives
-						snippetText := strings.Join(snippetContent, "\n")
-						processedSnippet := ProcessContentWithDirectives(snippetText, localVars, allVars)
+						snippetText := strings.Join(scopeSnippet(fileInfo, directive.Name, snippetContent), "\n")
+						processedSnippet, err := p.processContent(fileInfo, snippetText, localVars, allVars)
+						if err != nil {
+							return "", "", fmt.Errorf("snippet '%s': %w", directive.Name, err)
+						}
 						processedTemplate = append(processedTemplate, strings.Split(processedSnippet, "\n")...)
 					} else {
-						if verbose {
-							fmt.Printf("Warning: Template references unknown snippet '%s'\n", directive.Name)
-						}
+						p.report(fileInfo, directivePattern("paste", directive.Name), "template '%s' pastes unknown snippet '%s'", templateName, directive.Name)
 						processedTemplate = append(processedTemplate, line)
 					}
 				} else {
@@ -631,85 +1028,81 @@ func (p *Processor) ProcessVariables(fileInfo *types.FileInfo, outputDir string,
 			}
 			
 			// Convert template to string
-			templateContentStr := strings.Join(processedTemplate, "\n")
+			templateContentStr := i18n.Translate(strings.Join(processedTemplate, "\n"), catalog)
+			
+			// Fill the template's slots from the page's slot blocks, which templates can
+			// test for with <!-- if slot.name -->
+			pageContent, slots := p.extractSlots(fileInfo, finalContent)
+			for name := range slots {
+				allVars["slot."+name] = "true"
+			}
+			templateContentStr, err := fillSlots(templateContentStr, slots, localVars, allVars)
+			if err != nil {
+				return "", "", fmt.Errorf("template '%s': %w", templateName, err)
+			}
 			
 			// Replace {{content}} in template with the file content (processed)
-			fileContentStr := strings.Join(finalContent, "\n")
-			processedFileContent := ProcessContentWithDirectives(fileContentStr, localVars, allVars)
+			fileContentStr := strings.Join(pageContent, "\n")
+			processedFileContent, err := p.processContent(fileInfo, fileContentStr, localVars, allVars)
+			if err != nil {
+				return "", "", err
+			}
+			body = processedFileContent
 			templateWithContent := strings.ReplaceAll(templateContentStr, "{{content}}", processedFileContent)
 			
 			// Process conditionals and variables in the complete template
-			finalTemplateContent := ProcessContentWithDirectives(templateWithContent, localVars, allVars)
+			finalTemplateContent, err := p.processContent(fileInfo, templateWithContent, localVars, allVars)
+			if err != nil {
+				return "", "", fmt.Errorf("template '%s': %w", templateName, err)
+			}
 			finalContent = strings.Split(finalTemplateContent, "\n")
-		} else if verbose {
-			fmt.Printf("Warning: Template '%s' not found for file %s\n", templateName, fileInfo.Filename)
+		} else {
+			p.report(fileInfo, templatePattern(templateName), "template '%s' not found", templateName)
+			body = strings.Join(finalContent, "\n")
 		}
 	} else {
-		if verbose {
-			fmt.Printf("  Processing file without template: %s\n", fileInfo.Filename)
-		}
+		logging.Debugf("  Processing file without template: %s", fileInfo.Filename)
 		// Process all directives and variables in content without template
 		contentText := strings.Join(finalContent, "\n")
-		processedContent := ProcessContentWithDirectives(contentText, localVars, allVars)
+		processedContent, err := p.processContent(fileInfo, contentText, localVars, allVars)
+		if err != nil {
+			return "", "", err
+		}
+		body = processedContent
 		finalContent = strings.Split(processedContent, "\n")
-	}	// Write output file
-	outputPath := fileInfo.GetOutputPath(outputDir)
-	
-	// Create output directory if needed
-	outputDirPath := filepath.Dir(outputPath)
-	if err := os.MkdirAll(outputDirPath, 0755); err != nil {
-		return fmt.Errorf("cannot create output directory %s: %w", outputDirPath, err)
 	}
 	
-	// Write file
-	finalContentStr := strings.Join(finalContent, "\n")
+	outputPath := fileInfo.GetOutputPath(outputDir)
+	
+	// Expand helpers ({{uuid}}, {{random}}, {{counter}}) once over the finished page so
+	// counters run in document order, then give raw blocks and escaped variables their
+	// literal text back
+	helpers := newHelperState()
+	finalContentStr := helpers.expand(strings.Join(finalContent, "\n"))
+	helpers.addHeadings(finalContentStr)
+	fileInfo.Anchors = helpers.anchors
+	p.checkUnresolvedVariables(fileInfo, finalContentStr)
+	p.checkMissingAlt(fileInfo, finalContentStr)
+	finalContentStr = parser.RestoreRaw(removeUnresolvedVariables(finalContentStr))
+	body = parser.RestoreRaw(removeUnresolvedVariables(newHelperState().expand(body)))
+	finalContentStr = p.addSEOTags(fileInfo, outputDir, finalContentStr, allVars)
+	body = removeSEODirectives(body)
+	finalContentStr = addAlternateLinks(finalContentStr, fileInfo.Metadata)
 	
 	// Process images if enabled and this file has markdown images to process
 	if imgSize && len(fileInfo.MarkdownImages) > 0 && (strings.HasSuffix(strings.ToLower(outputPath), ".html") || strings.HasSuffix(strings.ToLower(outputPath), ".htm")) {
-		if verbose {
-			fmt.Printf("  Processing markdown images for %s\n", outputPath)
-		}
+		logging.Debugf("  Processing markdown images for %s", outputPath)
 		// Get the directory of the HTML file for resolving relative image paths
 		htmlDir := filepath.Dir(outputPath)
 		// Process only images that came from markdown
-		processedContent, err := imgprocess.ProcessHTMLForMarkdownImages(finalContentStr, outputDir, htmlDir, fileInfo.MarkdownImages, verbose)
+		processedContent, err := imgprocess.ProcessHTMLForMarkdownImages(finalContentStr, outputDir, htmlDir, fileInfo.MarkdownImages)
 		if err != nil {
-			if verbose {
-				fmt.Printf("  Warning: Image processing failed for %s: %v\n", outputPath, err)
-			}
+			p.warn(fileInfo, "", "image processing failed for %s: %v", outputPath, err)
 			// Continue with unprocessed content if image processing fails
 		} else {
 			finalContentStr = processedContent
 		}
 	}
 	
-	if err := os.WriteFile(outputPath, []byte(finalContentStr), 0644); err != nil {
-		return fmt.Errorf("cannot write file %s: %w", outputPath, err)
-	}
-	
-	if verbose {
-		fmt.Printf("  Wrote %s\n", outputPath)
-	}
-	
-	return nil
-}
-// sortFileData sorts file data by the specified field like Python's sort_file_data
-func (p *Processor) sortFileData(fileData []map[string]interface{}, sortField string) []map[string]interface{} {
-// Sort by date in descending order (most recent first), others ascending
-reverse := strings.ToLower(sortField) == "date" || 
-   strings.ToLower(sortField) == "created" || 
-   strings.ToLower(sortField) == "modified" || 
-   strings.ToLower(sortField) == "published"
-
-sort.Slice(fileData, func(i, j int) bool {
-valueI := p.getSortKey(fileData[i], sortField)
-valueJ := p.getSortKey(fileData[j], sortField)
-
-if reverse {
-return valueI > valueJ
-}
-return valueI < valueJ
-})
-
-return fileData
+	return finalContentStr, body, nil
 }
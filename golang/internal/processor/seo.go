@@ -0,0 +1,132 @@
+package processor
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"sniplicity/internal/parser"
+	"sniplicity/internal/types"
+)
+
+// SetSEO sets the address the site is published at, which canonical and Open Graph URLs
+// start with, and whether pages without a seo directive get its tags before their </head>
+func (p *Processor) SetSEO(siteURL string, auto bool) {
+	p.siteURL = strings.TrimRight(siteURL, "/")
+	p.autoSEO = auto
+}
+
+// addSEOTags replaces the seo directives of a rendered page with its canonical link, Open
+// Graph and Twitter card tags, or puts them before its </head> with seo: true, unless the
+// page has an og:title of its own
+func (p *Processor) addSEOTags(fileInfo *types.FileInfo, outputDir, page string, vars map[string]string) string {
+	lines := strings.Split(page, "\n")
+	found := false
+	for i, line := range lines {
+		if directive := parser.ParseLine(line, i); directive != nil && directive.Type == parser.DirectiveSEO {
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			lines[i] = indent + strings.Join(p.seoTags(fileInfo, outputDir, vars), "\n"+indent)
+			found = true
+		}
+	}
+	if found {
+		return strings.Join(lines, "\n")
+	}
+
+	if !p.autoSEO || strings.Contains(page, `property="og:title"`) {
+		return page
+	}
+	end := strings.Index(strings.ToLower(page), "</head>")
+	if end < 0 {
+		return page
+	}
+	return page[:end] + strings.Join(p.seoTags(fileInfo, outputDir, vars), "\n") + "\n" + page[end:]
+}
+
+// removeSEODirectives drops the seo directives from a page's content rendered without its
+// template, where there is no head to put tags in
+func removeSEODirectives(body string) string {
+	if !strings.Contains(body, "seo") {
+		return body
+	}
+	var kept []string
+	for i, line := range strings.Split(body, "\n") {
+		if directive := parser.ParseLine(line, i); directive == nil || directive.Type != parser.DirectiveSEO {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// seoTags returns the meta tags describing a page from its title, description and image,
+// the site's name in the site global and its Twitter account in the twitter global. The
+// canonical URL is the page's canonical variable, or its address under site_url.
+func (p *Processor) seoTags(fileInfo *types.FileInfo, outputDir string, vars map[string]string) []string {
+	var tags []string
+	meta := func(attr, name, value string) {
+		if value != "" {
+			tags = append(tags, fmt.Sprintf(`<meta %s="%s" content="%s">`, attr, name, html.EscapeString(value)))
+		}
+	}
+
+	canonical := vars["canonical"]
+	if canonical == "" && p.siteURL != "" {
+		canonical = p.siteURL + pageURL(fileInfo, outputDir)
+	}
+	image := vars["image"]
+	if image != "" && canonical != "" {
+		if base, err := url.Parse(canonical); err == nil {
+			if ref, err := url.Parse(image); err == nil {
+				image = base.ResolveReference(ref).String()
+			}
+		}
+	}
+
+	if canonical != "" {
+		tags = append(tags, fmt.Sprintf(`<link rel="canonical" href="%s">`, html.EscapeString(canonical)))
+	}
+	meta("name", "description", vars["description"])
+
+	pageType := "website"
+	if fileInfo.Metadata["date"] != nil {
+		pageType = "article"
+	}
+	meta("property", "og:type", pageType)
+	meta("property", "og:title", vars["title"])
+	meta("property", "og:description", vars["description"])
+	meta("property", "og:url", canonical)
+	meta("property", "og:site_name", vars["site"])
+	meta("property", "og:image", image)
+	meta("property", "og:locale", strings.ReplaceAll(vars["lang"], "-", "_"))
+
+	card := "summary"
+	if image != "" {
+		card = "summary_large_image"
+	}
+	meta("name", "twitter:card", card)
+	handle := vars["twitter"]
+	if handle != "" && !strings.HasPrefix(handle, "@") {
+		handle = "@" + handle
+	}
+	meta("name", "twitter:site", handle)
+	return tags
+}
+
+// pageURL returns the address of a page relative to the site root, ending in its folder
+// rather than index.html
+func pageURL(fileInfo *types.FileInfo, outputDir string) string {
+	if fileInfo.Permalink != "" {
+		return "/" + fileInfo.Permalink
+	}
+	rel, err := filepath.Rel(outputDir, fileInfo.GetOutputPath(outputDir))
+	if err != nil {
+		return ""
+	}
+	address := "/" + filepath.ToSlash(rel)
+	if strings.HasSuffix(address, "/index.html") {
+		address = strings.TrimSuffix(address, "index.html")
+	}
+	return address
+}
@@ -0,0 +1,235 @@
+package processor
+
+import (
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sniplicity/internal/builtin"
+	"sniplicity/internal/logging"
+	"sniplicity/internal/parser"
+	"sniplicity/internal/types"
+)
+
+// SitemapPage is one generated page listed by sitemap-page directives
+type SitemapPage struct {
+	URL      string                 // Output URL relative to the output directory, e.g. "blog/post.html" or "blog/post/"
+	File     string                 // Output file relative to the output directory, with forward slashes
+	Metadata map[string]interface{} // The page's frontmatter
+}
+
+// sitemapSection is a directory of the site with the pages directly inside it
+type sitemapSection struct {
+	name     string
+	index    *SitemapPage // The directory's own index page, if it has one
+	pages    []*SitemapPage
+	sections map[string]*sitemapSection
+}
+
+// SetSitemapPages sets the generated pages listed by sitemap-page directives
+func (p *Processor) SetSitemapPages(pages []SitemapPage) {
+	p.sitemap = pages
+}
+
+// ProcessSitemapCommands replaces sitemap-page directives with a nested list of every
+// generated page, grouped by directory
+func (p *Processor) ProcessSitemapCommands(fileInfo *types.FileInfo, templates map[string][]string, snippets map[string][]string, globals map[string]string) error {
+	var newContent []string
+
+	for i, line := range fileInfo.Content {
+		directive := parser.ParseLine(line, i)
+		if directive == nil || directive.Type != parser.DirectiveSitemap {
+			newContent = append(newContent, line)
+			continue
+		}
+
+		var template []string
+		if len(directive.Args) > 0 {
+			templateName := directive.Args[0]
+			content, exists := templates[templateName]
+			if !exists {
+				p.report(fileInfo, directivePattern("sitemap-page", templateName), "sitemap-page template '%s' not found", templateName)
+				newContent = append(newContent, line)
+				continue
+			}
+			template = content
+		}
+
+		logging.Debugf("  Processing sitemap-page: %d pages", len(p.sitemap))
+
+		root := buildSitemapTree(p.sitemap)
+		lines, err := p.renderSitemapSection(fileInfo, root, template, snippets, globals)
+		if err != nil {
+			return fmt.Errorf("sitemap-page template '%s': %w", directive.Args[0], err)
+		}
+		list, err := p.builtin.Render(builtin.SitemapList, builtin.Sitemap{Items: htmltemplate.HTML(strings.Join(lines, "\n"))})
+		if err != nil {
+			return fmt.Errorf("sitemap-page: %w", err)
+		}
+		newContent = append(newContent, strings.Split(strings.TrimRight(string(list), "\n"), "\n")...)
+	}
+
+	fileInfo.Content = newContent
+	return nil
+}
+
+// buildSitemapTree sorts pages into sections by their URL's directory. A page named
+// index.html, or a pretty URL ending in a slash, becomes its directory's index page.
+func buildSitemapTree(pages []SitemapPage) *sitemapSection {
+	root := &sitemapSection{sections: make(map[string]*sitemapSection)}
+
+	for i := range pages {
+		page := &pages[i]
+		url := strings.TrimPrefix(page.URL, "/")
+
+		dir, isIndex := "", false
+		switch {
+		case strings.HasSuffix(url, "/"):
+			dir, isIndex = strings.TrimSuffix(url, "/"), true
+		case path.Base(url) == "index.html":
+			dir, isIndex = path.Dir(url), true
+		default:
+			dir = path.Dir(url)
+		}
+		if dir == "." {
+			dir = ""
+		}
+
+		section := root
+		if dir != "" {
+			for _, part := range strings.Split(dir, "/") {
+				child, exists := section.sections[part]
+				if !exists {
+					child = &sitemapSection{name: part, sections: make(map[string]*sitemapSection)}
+					section.sections[part] = child
+				}
+				section = child
+			}
+		}
+
+		if isIndex && section.index == nil {
+			section.index = page
+		} else {
+			section.pages = append(section.pages, page)
+		}
+	}
+
+	return root
+}
+
+// renderSitemapSection renders the list items of a section: its pages sorted by title,
+// then its subdirectories sorted by name, each with a nested list
+func (p *Processor) renderSitemapSection(fileInfo *types.FileInfo, section *sitemapSection, template []string, snippets map[string][]string, globals map[string]string) ([]string, error) {
+	var lines []string
+
+	pages := append([]*SitemapPage{}, section.pages...)
+	sort.SliceStable(pages, func(i, j int) bool {
+		return strings.ToLower(sitemapTitle(pages[i])) < strings.ToLower(sitemapTitle(pages[j]))
+	})
+	if section.name == "" && section.index != nil {
+		pages = append([]*SitemapPage{section.index}, pages...) // The home page comes first
+	}
+
+	for _, page := range pages {
+		item, err := p.renderSitemapItem(fileInfo, page, template, snippets, globals)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, "<li>"+item+"</li>")
+	}
+
+	names := make([]string, 0, len(section.sections))
+	for name := range section.sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		child := section.sections[name]
+		label := html.EscapeString(name)
+		if child.index != nil {
+			item, err := p.renderSitemapItem(fileInfo, child.index, template, snippets, globals)
+			if err != nil {
+				return nil, err
+			}
+			label = item
+		}
+
+		childLines, err := p.renderSitemapSection(fileInfo, child, template, snippets, globals)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, "<li>"+label)
+		if len(childLines) > 0 {
+			lines = append(lines, "<ul>")
+			lines = append(lines, childLines...)
+			lines = append(lines, "</ul>")
+		}
+		lines = append(lines, "</li>")
+	}
+
+	return lines, nil
+}
+
+// renderSitemapItem renders one page as a link, or with the directive's template when it has one
+func (p *Processor) renderSitemapItem(fileInfo *types.FileInfo, page *SitemapPage, template []string, snippets map[string][]string, globals map[string]string) (string, error) {
+	href := sitemapHref(fileInfo, page.URL)
+	title := sitemapTitle(page)
+
+	if template == nil {
+		return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(href), html.EscapeString(title)), nil
+	}
+
+	metadata := make(map[string]interface{}, len(page.Metadata)+4)
+	for k, v := range page.Metadata {
+		metadata[k] = v
+	}
+	metadata["filepath"] = page.URL
+	metadata["href"] = href
+	metadata["title"] = title
+	if page.File != "" {
+		metadata["lastmod"] = lastModifiedToken(page.File)
+	}
+
+	item, err := p.processIndexTemplate(fileInfo, template, metadata, snippets, globals)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(item), nil
+}
+
+// sitemapTitle returns a page's title, falling back to its file or directory name
+func sitemapTitle(page *SitemapPage) string {
+	if title, ok := page.Metadata["title"].(string); ok && strings.TrimSpace(title) != "" {
+		return title
+	}
+	name := path.Base(strings.TrimSuffix(page.URL, "/"))
+	if name == "index.html" {
+		name = path.Base(path.Dir(page.URL))
+	}
+	if name == "." || name == "/" || name == "" {
+		return "Home"
+	}
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// sitemapHref returns the link to a page's URL from the page holding the sitemap
+func sitemapHref(fileInfo *types.FileInfo, url string) string {
+	from := fileInfo.Permalink
+	if from != "" {
+		from = types.PermalinkFile(from)
+	} else {
+		from = path.Join(filepath.ToSlash(fileInfo.OutputRelPath), fileInfo.Filename)
+	}
+	fromDir := path.Dir(from)
+	if fromDir == "." {
+		return url
+	}
+
+	depth := len(strings.Split(fromDir, "/"))
+	return strings.Repeat("../", depth) + url
+}
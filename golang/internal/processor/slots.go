@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"regexp"
+	"strings"
+
+	"sniplicity/internal/parser"
+	"sniplicity/internal/types"
+)
+
+// slotRegex matches a slot in a template, {{slot sidebar}}
+var slotRegex = regexp.MustCompile(`\{\{slot\s+([-\w.]+)\s*\}\}`)
+
+// extractSlots takes the slot blocks out of a page's lines, <!-- slot name --> up to
+// <!-- endslot -->, and returns the rest of the page and the lines of each slot. Blocks
+// filling the same slot are joined.
+func (p *Processor) extractSlots(fileInfo *types.FileInfo, lines []string) ([]string, map[string][]string) {
+	var content []string
+	slots := make(map[string][]string)
+	current := ""
+	for _, line := range lines {
+		directive := parser.ParseLine(line, 0)
+		switch {
+		case directive != nil && directive.Type == parser.DirectiveSlot:
+			if current != "" {
+				p.warn(fileInfo, directivePattern("slot", directive.Name), "slot '%s' starts inside slot '%s', which has no endslot", directive.Name, current)
+			}
+			current = directive.Name
+			if _, exists := slots[current]; !exists {
+				slots[current] = []string{}
+			}
+		case directive != nil && directive.Type == parser.DirectiveEndslot:
+			if current == "" {
+				p.warn(fileInfo, directivePattern("endslot"), "endslot without a slot")
+			}
+			current = ""
+		case current != "":
+			slots[current] = append(slots[current], line)
+		default:
+			content = append(content, line)
+		}
+	}
+	if current != "" {
+		p.warn(fileInfo, directivePattern("slot", current), "slot '%s' has no endslot", current)
+	}
+	return content, slots
+}
+
+// fillSlots replaces each {{slot name}} in a template with the processed content of the
+// page's slot, or nothing when the page doesn't fill it
+func fillSlots(template string, slots map[string][]string, localVars, allVars map[string]string) (string, error) {
+	if !strings.Contains(template, "{{slot") {
+		return template, nil
+	}
+	filled := make(map[string]string, len(slots))
+	for name, lines := range slots {
+		content, err := ProcessContentWithDirectives(strings.Join(lines, "\n"), localVars, allVars)
+		if err != nil {
+			return "", err
+		}
+		filled[name] = content
+	}
+	return slotRegex.ReplaceAllStringFunc(template, func(slot string) string {
+		return filled[slotRegex.FindStringSubmatch(slot)[1]]
+	}), nil
+}
@@ -0,0 +1,188 @@
+package processor
+
+import (
+	"cmp"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sort types the index directive's sort-type option chooses from
+const (
+	SortDate    = "date"    // Newest first, by the moment a date names
+	SortNumeric = "numeric" // Smallest number first
+	SortString  = "string"  // Alphabetical, ignoring case
+	SortNatural = "natural" // Alphabetical with runs of digits compared as numbers, so "Chapter 2" comes before "Chapter 10"
+)
+
+// SortTypes lists the sort types in the order they are documented
+var SortTypes = []string{SortNatural, SortNumeric, SortString, SortDate}
+
+// dateFields are the fields sorted as dates, newest first, unless sort-type or sort says otherwise
+var dateFields = map[string]bool{"date": true, "created": true, "modified": true, "published": true}
+
+// sortKey is one field of an index's sort order
+type sortKey struct {
+	field      string
+	sortType   string // One of SortTypes, or "" to choose by the field and its values
+	descending bool
+}
+
+// defaultSortKey returns the sort key of a field without a direction: date fields, and
+// fields sort-type says are dates, newest first and other fields ascending
+func defaultSortKey(field, sortType string) sortKey {
+	if sortType == "" && dateFields[strings.ToLower(field)] {
+		sortType = SortDate
+	}
+	return sortKey{field: field, sortType: sortType, descending: sortType == SortDate}
+}
+
+// sortFileData sorts file data by each key in turn, the next key deciding between pages
+// the one before finds equal. Without a sort type, date fields are sorted as dates,
+// fields holding numbers by value and other fields naturally. Pages without a field, or
+// with a date that can't be read, come after the ones with it whatever the direction,
+// and pages that compare equal on every key keep their order.
+func (p *Processor) sortFileData(fileData []map[string]interface{}, keys []sortKey) []map[string]interface{} {
+	for i, key := range keys {
+		if key.sortType == "" && dateFields[strings.ToLower(key.field)] {
+			keys[i].sortType = SortDate
+		}
+	}
+
+	sort.SliceStable(fileData, func(i, j int) bool {
+		for _, key := range keys {
+			a, aOK := p.sortValue(fileData[i], key)
+			b, bOK := p.sortValue(fileData[j], key)
+			if !aOK || !bOK {
+				if aOK != bOK {
+					return aOK
+				}
+				continue
+			}
+			c := p.compareSortValues(a, b, key.sortType)
+			if key.descending {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return fileData
+}
+
+// sortValue returns a page's value for a sort key, and false when it has none to sort
+// by: the field is missing, or it is sorted as a date and isn't one
+func (p *Processor) sortValue(metadata map[string]interface{}, key sortKey) (string, bool) {
+	value, ok := metadata[key.field]
+	if !ok {
+		return "", false
+	}
+	text := fmt.Sprintf("%v", value)
+	if key.sortType == SortDate {
+		if _, ok := p.dates.Parse(text); !ok {
+			return "", false
+		}
+	}
+	return text, true
+}
+
+// compareSortValues returns how a sorts against b for the sort type, ascending: negative
+// when a comes first, positive when b does and zero when they are equal. Dates compare
+// by the moment they name, to the nanosecond.
+func (p *Processor) compareSortValues(a, b, sortType string) int {
+	switch sortType {
+	case SortDate:
+		x, _ := p.dates.Parse(a)
+		y, _ := p.dates.Parse(b)
+		return x.Compare(y)
+	case SortNumeric:
+		return compareNumbers(a, b)
+	case SortString:
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	case SortNatural:
+		return naturalCompare(a, b)
+	}
+	if _, aOK := parseNumber(a); aOK {
+		if _, bOK := parseNumber(b); bOK {
+			return compareNumbers(a, b)
+		}
+	}
+	return naturalCompare(a, b)
+}
+
+// compareNumbers compares values as numbers, exactly however long they are, putting
+// ones that aren't numbers last
+func compareNumbers(a, b string) int {
+	x, xOK := parseNumber(a)
+	y, yOK := parseNumber(b)
+	switch {
+	case !xOK && !yOK:
+		return naturalCompare(a, b)
+	case !xOK:
+		return 1
+	case !yOK:
+		return -1
+	}
+	return x.Cmp(y)
+}
+
+// maxExponent is the largest exponent parseNumber reads, as big.Rat works out the whole value
+const maxExponent = 1000
+
+// parseNumber reads a decimal number such as 42, -1.5 or 2e10 exactly
+func parseNumber(s string) (*big.Rat, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.ContainsAny(s, "/xXpP_") {
+		return nil, false // Fractions and hex, which big.Rat would read, aren't sort values
+	}
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		if exp, err := strconv.Atoi(s[i+1:]); err != nil || exp > maxExponent || exp < -maxExponent {
+			return nil, false
+		}
+	}
+	return new(big.Rat).SetString(s)
+}
+
+// naturalCompare compares strings ignoring case, with each run of digits compared by its
+// numeric value, so "file9" sorts before "file10"
+func naturalCompare(a, b string) int {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	for a != "" && b != "" {
+		aDigits, bDigits := digitPrefix(a), digitPrefix(b)
+		if aDigits == "" || bDigits == "" {
+			if a[0] != b[0] {
+				return strings.Compare(a[:1], b[:1])
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		// Compare the numbers without their leading zeros: a longer one is bigger
+		aNumber, bNumber := strings.TrimLeft(aDigits, "0"), strings.TrimLeft(bDigits, "0")
+		if len(aNumber) != len(bNumber) {
+			return cmp.Compare(len(aNumber), len(bNumber))
+		}
+		if c := strings.Compare(aNumber, bNumber); c != 0 {
+			return c
+		}
+		// Equal numbers: fewer leading zeros first, so the order is still total
+		if len(aDigits) != len(bDigits) {
+			return cmp.Compare(len(aDigits), len(bDigits))
+		}
+		a, b = a[len(aDigits):], b[len(bDigits):]
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// digitPrefix returns the run of ASCII digits s starts with
+func digitPrefix(s string) string {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	return s[:end]
+}
@@ -0,0 +1,152 @@
+package processor
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"sniplicity/internal/diag"
+	"sniplicity/internal/parser"
+	"sniplicity/internal/types"
+)
+
+// unresolvedVarRegex matches variables still left in a finished page, with or without a
+// dateformat filter
+var unresolvedVarRegex = regexp.MustCompile(`\{\{([-\w.]+)(?:\s*\|\s*dateformat\b[^}]*)?\}\}`)
+
+// imgTagRegex matches img tags
+var imgTagRegex = regexp.MustCompile(`(?i)<img\b[^>]*>`)
+
+// altAttrRegex matches an alt attribute, which may be empty for decorative images
+var altAttrRegex = regexp.MustCompile(`(?i)\salt\s*(=|\s|/?>)`)
+
+// srcAttrRegex matches an src attribute's quoted value
+var srcAttrRegex = regexp.MustCompile(`(?i)\ssrc\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+// report records a broken reference at the missing-reference rule's level, which strict
+// mode makes an error
+func (p *Processor) report(fileInfo *types.FileInfo, pattern, format string, args ...interface{}) {
+	p.diagnostics.Check(diag.MissingReference, fileInfo.InputPath, sourceLine(fileInfo.InputPath, pattern), format, args...)
+}
+
+// warn records a warning about a page. The line is found by searching the page's source
+// for pattern; it is left out if the problem came from somewhere else, such as a
+// template or snippet, or pattern is "".
+func (p *Processor) warn(fileInfo *types.FileInfo, pattern, format string, args ...interface{}) {
+	p.diagnostics.Warn(fileInfo.InputPath, sourceLine(fileInfo.InputPath, pattern), format, args...)
+}
+
+// checkUnresolvedVariables records every {{variable}} left in a finished page. Raw
+// blocks and escaped variables are still protected at this point, so they don't count.
+func (p *Processor) checkUnresolvedVariables(fileInfo *types.FileInfo, content string) {
+	if p.diagnostics.RuleLevel(diag.UndefinedVariable) == diag.Off {
+		return
+	}
+
+	seen := templateVariables(fileInfo.InputPath)
+	for _, match := range unresolvedVarRegex.FindAllStringSubmatch(content, -1) {
+		if seen[match[1]] {
+			continue
+		}
+		seen[match[1]] = true
+		pattern := `\{\{` + regexp.QuoteMeta(match[1]) + `[\s|}]`
+		p.diagnostics.Check(diag.UndefinedVariable, fileInfo.InputPath, sourceLine(fileInfo.InputPath, pattern), "variable '%s' is not defined", match[1])
+	}
+}
+
+// templateVariables returns the variables used inside the template blocks a page defines.
+// Those blocks are written out with the page, but their variables are only meant to be
+// filled in by the pages that use the template, so they aren't reported.
+func templateVariables(path string) map[string]bool {
+	vars := make(map[string]bool)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return vars
+	}
+
+	depth := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		if directive := parser.ParseLine(line, 0); directive != nil {
+			switch {
+			case directive.Type == parser.DirectiveTemplate:
+				depth++
+			case depth > 0 && parser.IsBlockEnd(line):
+				depth--
+			}
+			continue
+		}
+		if depth > 0 {
+			for _, match := range unresolvedVarRegex.FindAllStringSubmatch(line, -1) {
+				vars[match[1]] = true
+			}
+		}
+	}
+	return vars
+}
+
+// removeUnresolvedVariables removes the variables left in a finished page, like Python
+func removeUnresolvedVariables(content string) string {
+	return unresolvedVarRegex.ReplaceAllString(content, "")
+}
+
+// checkMissingAlt records every image in a finished page without an alt attribute
+func (p *Processor) checkMissingAlt(fileInfo *types.FileInfo, content string) {
+	if p.diagnostics.RuleLevel(diag.MissingAlt) == diag.Off {
+		return
+	}
+
+	for _, tag := range imgTagRegex.FindAllString(content, -1) {
+		if altAttrRegex.MatchString(tag) {
+			continue
+		}
+		src, pattern := "", ""
+		if match := srcAttrRegex.FindStringSubmatch(tag); match != nil {
+			src = match[1] + match[2]
+			pattern = regexp.QuoteMeta(src)
+		}
+		p.diagnostics.Check(diag.MissingAlt, fileInfo.InputPath, sourceLine(fileInfo.InputPath, pattern), "image %s has no alt text", src)
+	}
+}
+
+// directivePattern matches a directive with the given keyword and leading arguments
+func directivePattern(keyword string, args ...string) string {
+	pattern := `<!--\s+` + regexp.QuoteMeta(keyword)
+	for _, arg := range args {
+		pattern += `\s+` + regexp.QuoteMeta(arg)
+	}
+	return pattern + `(\s|$)`
+}
+
+// templatePattern matches a page choosing a template, in frontmatter or a set directive
+func templatePattern(name string) string {
+	return `template\b[:\s]+["']?` + regexp.QuoteMeta(name) + `\b`
+}
+
+// sourceLine returns the 1-based number of the first line in a file matching pattern, or 0.
+// In markdown, a match outside code blocks, where directives are only examples, wins.
+func sourceLine(path, pattern string) int {
+	if pattern == "" {
+		return 0
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return 0
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	lines := strings.Split(string(data), "\n")
+	fenced := make([]bool, len(lines))
+	if types.IsMarkdownFile(path) {
+		fenced = parser.CodeFenceLines(lines)
+	}
+	for _, inFence := range []bool{false, true} {
+		for i, line := range lines {
+			if fenced[i] == inFence && re.MatchString(line) {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
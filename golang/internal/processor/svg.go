@@ -6,59 +6,51 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"sniplicity/internal/logging"
 )
 
 // ProcessSVGFilters processes CSS filters in SVG content and bakes them into the SVG colors
 func ProcessSVGFilters(content string) (string, error) {
-	fmt.Printf("DEBUG: ProcessSVGFilters called with content length: %d\n", len(content))
 	modifiedContent := content
 	
 	// Process inline filter attributes
 	inlineProcessed, err := processInlineFilters(modifiedContent)
 	if err != nil {
-		fmt.Printf("DEBUG: Error in processInlineFilters: %v\n", err)
 		return "", fmt.Errorf("processing inline filters: %w", err)
 	}
-	fmt.Printf("DEBUG: After inline processing, content length: %d\n", len(inlineProcessed))
 	modifiedContent = inlineProcessed
 	
 	// Process CSS filters in style blocks
-	fmt.Printf("DEBUG: About to call processStyleBlockFilters with content length: %d\n", len(modifiedContent))
 	styleProcessed, err := processStyleBlockFilters(modifiedContent)
 	if err != nil {
 		return "", fmt.Errorf("processing style block filters: %w", err)
 	}
-	fmt.Printf("DEBUG: processStyleBlockFilters returned content length: %d\n", len(styleProcessed))
-	fmt.Printf("DEBUG: After style block processing, content length: %d\n", len(styleProcessed))
 	modifiedContent = styleProcessed
 	
-	fmt.Printf("DEBUG: Final ProcessSVGFilters result, content length: %d\n", len(modifiedContent))
 	return modifiedContent, nil
 }
 
 // processInlineFilters processes filter attributes on SVG elements and bakes colors
 func processInlineFilters(content string) (string, error) {
-	fmt.Printf("DEBUG: processInlineFilters called with content: %.100s...\n", content)
 	// Regex to find filter attributes on any element
 	filterAttrRegex := regexp.MustCompile(`(<[^>]+?\s)filter="([^"]+)"([^>]*>)`)
 	matches := filterAttrRegex.FindAllStringSubmatch(content, -1)
 	
-	fmt.Printf("DEBUG: Found %d filter matches\n", len(matches))
 	if len(matches) == 0 {
 		return content, nil
 	}
+	logging.Debugf("  Found %d SVG filter attributes", len(matches))
 	
 	modifiedContent := content
 	
-	for i, match := range matches {
-		fmt.Printf("DEBUG: Processing match %d: %s\n", i, match[0])
+	for _, match := range matches {
 		filterValue := match[2]
 		
-		fmt.Printf("DEBUG: Filter value: %s\n", filterValue)
+		logging.Debugf("  Baking SVG filter: %s", filterValue)
 		
 		// Parse the filter functions
 		functions := parseFilterFunctions(filterValue)
-		fmt.Printf("DEBUG: Parsed %d filter functions\n", len(functions))
 		
 		// Apply filters to all colors in the entire SVG
 		var err error
@@ -66,13 +58,11 @@ func processInlineFilters(content string) (string, error) {
 		if err != nil {
 			return "", fmt.Errorf("applying filters to colors: %w", err)
 		}
-		fmt.Printf("DEBUG: After applying filters, content length: %d\n", len(modifiedContent))
 		
 		// Remove the filter attribute from the element
 		// We need to find the updated element in the modified content and remove the filter attribute
 		filterAttrPattern := regexp.MustCompile(`(\s)filter="[^"]*"`)
 		modifiedContent = filterAttrPattern.ReplaceAllString(modifiedContent, "")
-		fmt.Printf("DEBUG: After removing filter attribute, content length: %d\n", len(modifiedContent))
 	}
 	
 	return modifiedContent, nil
@@ -80,14 +70,11 @@ func processInlineFilters(content string) (string, error) {
 
 // processStyleBlockFilters processes CSS filters in style blocks and bakes colors
 func processStyleBlockFilters(content string) (string, error) {
-	fmt.Printf("DEBUG: processStyleBlockFilters called\n")
 	// Check if the SVG has any CSS filters
 	styleRegex := regexp.MustCompile(`<style[^>]*>(.*?)</style>`)
 	styleMatches := styleRegex.FindAllStringSubmatch(content, -1)
 	
-	fmt.Printf("DEBUG: Found %d style blocks\n", len(styleMatches))
 	if len(styleMatches) == 0 {
-		fmt.Printf("DEBUG: No style blocks found, returning unchanged\n")
 		return content, nil // No style blocks found
 	}
 	
@@ -154,18 +141,18 @@ func applyFiltersToColors(content string, functions []filterFunction) (string, e
 		}
 		
 		// Parse the color
-		r, g, b, err := parseColor(colorValue)
+		r, g, b, alpha, err := parseColor(colorValue)
 		if err != nil {
 			return match // Return unchanged if we can't parse the color
 		}
 		
 		// Apply each filter function in sequence
 		for _, function := range functions {
-			r, g, b = applyFilterFunction(r, g, b, function)
+			r, g, b, alpha = applyFilterFunction(r, g, b, alpha, function)
 		}
 		
-		// Convert back to hex color
-		newColor := fmt.Sprintf("#%02x%02x%02x", clamp(r), clamp(g), clamp(b))
+		// Convert back to hex color, keeping any alpha
+		newColor := formatColor(r, g, b, alpha)
 		return fmt.Sprintf(`%s="%s"`, attribute, newColor)
 	}), nil
 }
@@ -215,18 +202,18 @@ func applyFiltersToClassElements(content string, functions []filterFunction, sty
 				}
 				
 				// Parse the color
-				r, g, b, err := parseColor(colorValue)
+				r, g, b, alpha, err := parseColor(colorValue)
 				if err != nil {
 					return match // Return unchanged if we can't parse the color
 				}
 				
 				// Apply each filter function in sequence
 				for _, function := range functions {
-					r, g, b = applyFilterFunction(r, g, b, function)
+					r, g, b, alpha = applyFilterFunction(r, g, b, alpha, function)
 				}
 				
-				// Convert back to hex color
-				newColor := fmt.Sprintf("#%02x%02x%02x", clamp(r), clamp(g), clamp(b))
+				// Convert back to hex color, keeping any alpha
+				newColor := formatColor(r, g, b, alpha)
 				return fmt.Sprintf(`%s="%s"`, attribute, newColor)
 			})
 		})
@@ -235,64 +222,8 @@ func applyFiltersToClassElements(content string, functions []filterFunction, sty
 	return modifiedContent, nil
 }
 
-// parseColor parses a color string and returns RGB values (0-255)
-func parseColor(color string) (int, int, int, error) {
-	color = strings.TrimSpace(color)
-	
-	// Handle hex colors
-	if strings.HasPrefix(color, "#") {
-		hex := strings.TrimPrefix(color, "#")
-		
-		// Handle 3-digit hex
-		if len(hex) == 3 {
-			hex = string(hex[0]) + string(hex[0]) + string(hex[1]) + string(hex[1]) + string(hex[2]) + string(hex[2])
-		}
-		
-		if len(hex) != 6 {
-			return 0, 0, 0, fmt.Errorf("invalid hex color: %s", color)
-		}
-		
-		r, err := strconv.ParseInt(hex[0:2], 16, 64)
-		if err != nil {
-			return 0, 0, 0, err
-		}
-		g, err := strconv.ParseInt(hex[2:4], 16, 64)
-		if err != nil {
-			return 0, 0, 0, err
-		}
-		b, err := strconv.ParseInt(hex[4:6], 16, 64)
-		if err != nil {
-			return 0, 0, 0, err
-		}
-		
-		return int(r), int(g), int(b), nil
-	}
-	
-	// Handle named colors (basic set)
-	namedColors := map[string][3]int{
-		"red":     {255, 0, 0},
-		"green":   {0, 128, 0},
-		"blue":    {0, 0, 255},
-		"white":   {255, 255, 255},
-		"black":   {0, 0, 0},
-		"yellow":  {255, 255, 0},
-		"cyan":    {0, 255, 255},
-		"magenta": {255, 0, 255},
-		"gray":    {128, 128, 128},
-		"grey":    {128, 128, 128},
-		"orange":  {255, 165, 0},
-		"purple":  {128, 0, 128},
-	}
-	
-	if rgb, exists := namedColors[strings.ToLower(color)]; exists {
-		return rgb[0], rgb[1], rgb[2], nil
-	}
-	
-	return 0, 0, 0, fmt.Errorf("unsupported color format: %s", color)
-}
-
-// applyFilterFunction applies a single filter function to RGB values
-func applyFilterFunction(r, g, b int, function filterFunction) (int, int, int) {
+// applyFilterFunction applies a single filter function to RGB values and alpha
+func applyFilterFunction(r, g, b int, alpha float64, function filterFunction) (int, int, int, float64) {
 	switch function.name {
 	case "invert":
 		amount := 1.0 // default to 100%
@@ -309,8 +240,127 @@ func applyFilterFunction(r, g, b int, function filterFunction) (int, int, int) {
 		}
 		
 		// Correct CSS invert formula: output = input * (1 - amount) + (255 - input) * amount
-		newR := float64(r) * (1.0 - amount) + float64(255 - r) * amount
-		func applyInvert(r, g, b int, amount float64) (int, int, int) {
+		newR, newG, newB := applyInvert(r, g, b, amount)
+		
+		return clamp(newR), clamp(newG), clamp(newB), alpha
+		
+	case "hue-rotate":
+		angle := 0.0
+		if function.value != "" {
+			if strings.HasSuffix(function.value, "deg") {
+				if val, err := strconv.ParseFloat(strings.TrimSuffix(function.value, "deg"), 64); err == nil {
+					angle = val
+				}
+			} else {
+				if val, err := strconv.ParseFloat(function.value, 64); err == nil {
+					angle = val
+				}
+			}
+		}
+		
+		// Convert to HSL, rotate hue, convert back to RGB
+		h, s, l := rgbToHsl(r, g, b)
+		h = math.Mod(h+angle/360.0, 1.0)
+		if h < 0 {
+			h += 1.0
+		}
+		resultR, resultG, resultB := hslToRgb(h, s, l)
+		return resultR, resultG, resultB, alpha
+
+	case "grayscale", "sepia", "saturate", "brightness", "contrast", "opacity":
+		amount, ok := filterAmount(function.value)
+		if !ok {
+			logging.Debugf("  Skipping %s(%s): not a valid amount", function.name, function.value)
+			return r, g, b, alpha
+		}
+		return applyAmountFilter(r, g, b, alpha, function.name, amount)
+	}
+	
+	return r, g, b, alpha
+}
+
+// applyAmountFilter applies one of the filter functions that take an amount, per the
+// Filter Effects spec. Grayscale, sepia and opacity stop at 100%.
+func applyAmountFilter(r, g, b int, alpha float64, name string, amount float64) (int, int, int, float64) {
+	switch name {
+	case "grayscale":
+		r, g, b = applyColorMatrix(r, g, b, grayscaleMatrix(math.Min(amount, 1)))
+	case "sepia":
+		r, g, b = applyColorMatrix(r, g, b, sepiaMatrix(math.Min(amount, 1)))
+	case "saturate":
+		r, g, b = applyColorMatrix(r, g, b, saturateMatrix(amount))
+	case "brightness":
+		r, g, b = applyLinearTransfer(r, g, b, amount, 0)
+	case "contrast":
+		r, g, b = applyLinearTransfer(r, g, b, amount, 0.5-amount/2)
+	case "opacity":
+		alpha *= math.Min(amount, 1)
+	}
+	return r, g, b, alpha
+}
+
+// filterAmount parses a filter function's amount, a number or a percentage, which is 1
+// when left out. Negative amounts are invalid in CSS.
+func filterAmount(value string) (float64, bool) {
+	if value == "" {
+		return 1, true
+	}
+	amount, err := parseColorNumber(value, 1)
+	if err != nil || amount < 0 {
+		return 0, false
+	}
+	return amount, true
+}
+
+// grayscaleMatrix returns the color matrix of grayscale(amount), as the Filter Effects spec
+// defines it
+func grayscaleMatrix(amount float64) [3][3]float64 {
+	a := 1 - amount
+	return [3][3]float64{
+		{0.2126 + 0.7874*a, 0.7152 - 0.7152*a, 0.0722 - 0.0722*a},
+		{0.2126 - 0.2126*a, 0.7152 + 0.2848*a, 0.0722 - 0.0722*a},
+		{0.2126 - 0.2126*a, 0.7152 - 0.7152*a, 0.0722 + 0.9278*a},
+	}
+}
+
+// sepiaMatrix returns the color matrix of sepia(amount), as the Filter Effects spec defines it
+func sepiaMatrix(amount float64) [3][3]float64 {
+	a := 1 - amount
+	return [3][3]float64{
+		{0.393 + 0.607*a, 0.769 - 0.769*a, 0.189 - 0.189*a},
+		{0.349 - 0.349*a, 0.686 + 0.314*a, 0.168 - 0.168*a},
+		{0.272 - 0.272*a, 0.534 - 0.534*a, 0.131 + 0.869*a},
+	}
+}
+
+// saturateMatrix returns the color matrix of saturate(amount), the feColorMatrix saturate type
+func saturateMatrix(s float64) [3][3]float64 {
+	return [3][3]float64{
+		{0.213 + 0.787*s, 0.715 - 0.715*s, 0.072 - 0.072*s},
+		{0.213 - 0.213*s, 0.715 + 0.285*s, 0.072 - 0.072*s},
+		{0.213 - 0.213*s, 0.715 - 0.715*s, 0.072 + 0.928*s},
+	}
+}
+
+// applyColorMatrix multiplies RGB values by a color matrix
+func applyColorMatrix(r, g, b int, m [3][3]float64) (int, int, int) {
+	rf, gf, bf := float64(r), float64(g), float64(b)
+	channel := func(row [3]float64) int {
+		return clamp(int(math.Round(row[0]*rf + row[1]*gf + row[2]*bf)))
+	}
+	return channel(m[0]), channel(m[1]), channel(m[2])
+}
+
+// applyLinearTransfer applies a feComponentTransfer type="linear" function to each channel
+func applyLinearTransfer(r, g, b int, slope, intercept float64) (int, int, int) {
+	channel := func(value int) int {
+		return clamp(int(math.Round((float64(value)/255*slope + intercept) * 255)))
+	}
+	return channel(r), channel(g), channel(b)
+}
+
+// applyInvert applies invert() using the W3C feComponentTransfer table definition
+func applyInvert(r, g, b int, amount float64) (int, int, int) {
 	// W3C spec: feComponentTransfer with type="table" tableValues="[amount] (1 - [amount])"
 	tableValues := []float64{amount, 1.0 - amount}
 	
@@ -323,9 +373,10 @@ func applyFilterFunction(r, g, b int, function filterFunction) (int, int, int) {
 	newG := applyTableTransfer(gNorm, tableValues)
 	newB := applyTableTransfer(bNorm, tableValues)
 	
-	return int(newR * 255), int(newG * 255), int(newB * 255)
+	return int(math.Round(newR * 255)), int(math.Round(newG * 255)), int(math.Round(newB * 255))
 }
 
+// applyTableTransfer evaluates a feComponentTransfer type="table" function for one channel
 func applyTableTransfer(input float64, tableValues []float64) float64 {
 	if len(tableValues) == 0 {
 		return input
@@ -352,36 +403,6 @@ func applyTableTransfer(input float64, tableValues []float64) float64 {
 	}
 	
 	return tableValues[index]*(1.0-fraction) + tableValues[index+1]*fraction
-}  
-		newB := float64(b) * (1.0 - amount) + float64(255 - b) * amount
-		
-		return clamp(int(newR)), clamp(int(newG)), clamp(int(newB))
-		
-	case "hue-rotate":
-		angle := 0.0
-		if function.value != "" {
-			if strings.HasSuffix(function.value, "deg") {
-				if val, err := strconv.ParseFloat(strings.TrimSuffix(function.value, "deg"), 64); err == nil {
-					angle = val
-				}
-			} else {
-				if val, err := strconv.ParseFloat(function.value, 64); err == nil {
-					angle = val
-				}
-			}
-		}
-		
-		// Convert to HSL, rotate hue, convert back to RGB
-		h, s, l := rgbToHsl(r, g, b)
-		h = math.Mod(h+angle/360.0, 1.0)
-		if h < 0 {
-			h += 1.0
-		}
-		resultR, resultG, resultB := hslToRgb(h, s, l)
-		return resultR, resultG, resultB
-	}
-	
-	return r, g, b
 }
 
 // rgbToHsl converts RGB values (0-255) to HSL values (0-1)
@@ -460,7 +481,7 @@ func hslToRgb(h, s, l float64) (int, int, int) {
 		b = hue2rgb(p, q, h-1.0/3.0)
 	}
 	
-	return int(r * 255), int(g * 255), int(b * 255)
+	return int(math.Round(r * 255)), int(math.Round(g * 255)), int(math.Round(b * 255))
 }
 
 // clamp ensures a value is between 0 and 255
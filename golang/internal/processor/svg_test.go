@@ -0,0 +1,77 @@
+package processor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestApplyFilterFunction(t *testing.T) {
+	type rgba struct {
+		r, g, b int
+		alpha   float64
+	}
+	for _, test := range []struct {
+		function filterFunction
+		in       rgba
+		want     rgba
+	}{
+		// invert
+		{filterFunction{"invert", ""}, rgba{255, 0, 128, 1}, rgba{0, 255, 127, 1}},
+		{filterFunction{"invert", "100%"}, rgba{0, 0, 0, 1}, rgba{255, 255, 255, 1}},
+		{filterFunction{"invert", "0"}, rgba{12, 128, 200, 1}, rgba{12, 128, 200, 1}},
+		{filterFunction{"invert", "50%"}, rgba{0, 100, 255, 1}, rgba{128, 128, 128, 1}},
+		{filterFunction{"invert", "25%"}, rgba{0, 0, 255, 1}, rgba{64, 64, 191, 1}},
+
+		// hue-rotate turns the hue in HSL
+		{filterFunction{"hue-rotate", "120deg"}, rgba{255, 0, 0, 1}, rgba{0, 255, 0, 1}},
+		{filterFunction{"hue-rotate", "180deg"}, rgba{255, 0, 0, 1}, rgba{0, 255, 255, 1}},
+		{filterFunction{"hue-rotate", "240"}, rgba{255, 0, 0, 1}, rgba{0, 0, 255, 1}},
+		{filterFunction{"hue-rotate", "-120deg"}, rgba{255, 0, 0, 1}, rgba{0, 0, 255, 1}},
+		{filterFunction{"hue-rotate", "360deg"}, rgba{51, 102, 153, 1}, rgba{51, 102, 153, 1}},
+		{filterFunction{"hue-rotate", "90deg"}, rgba{128, 128, 128, 1}, rgba{128, 128, 128, 1}},
+
+		// grayscale
+		{filterFunction{"grayscale", ""}, rgba{255, 0, 0, 1}, rgba{54, 54, 54, 1}},
+		{filterFunction{"grayscale", "100%"}, rgba{0, 255, 0, 1}, rgba{182, 182, 182, 1}},
+		{filterFunction{"grayscale", "0%"}, rgba{255, 0, 0, 1}, rgba{255, 0, 0, 1}},
+		{filterFunction{"grayscale", "200%"}, rgba{255, 0, 0, 1}, rgba{54, 54, 54, 1}},
+		{filterFunction{"grayscale", "50%"}, rgba{255, 0, 0, 1}, rgba{155, 27, 27, 1}},
+
+		// sepia
+		{filterFunction{"sepia", "1"}, rgba{255, 255, 255, 1}, rgba{255, 255, 239, 1}},
+		{filterFunction{"sepia", "100%"}, rgba{0, 0, 0, 1}, rgba{0, 0, 0, 1}},
+		{filterFunction{"sepia", "0"}, rgba{10, 20, 30, 1}, rgba{10, 20, 30, 1}},
+
+		// saturate
+		{filterFunction{"saturate", "0"}, rgba{255, 0, 0, 1}, rgba{54, 54, 54, 1}},
+		{filterFunction{"saturate", "100%"}, rgba{128, 64, 32, 1}, rgba{128, 64, 32, 1}},
+		{filterFunction{"saturate", "200%"}, rgba{128, 64, 32, 1}, rgba{181, 53, 0, 1}},
+
+		// brightness lightens above 100% and darkens below it
+		{filterFunction{"brightness", "50%"}, rgba{200, 100, 50, 1}, rgba{100, 50, 25, 1}},
+		{filterFunction{"brightness", "150%"}, rgba{100, 100, 100, 1}, rgba{150, 150, 150, 1}},
+		{filterFunction{"brightness", "2"}, rgba{200, 100, 0, 1}, rgba{255, 200, 0, 1}},
+		{filterFunction{"brightness", "0"}, rgba{200, 100, 50, 1}, rgba{0, 0, 0, 1}},
+
+		// contrast moves channels away from or towards the middle
+		{filterFunction{"contrast", "50%"}, rgba{255, 0, 128, 1}, rgba{191, 64, 128, 1}},
+		{filterFunction{"contrast", "100%"}, rgba{255, 0, 128, 1}, rgba{255, 0, 128, 1}},
+		{filterFunction{"contrast", "300%"}, rgba{255, 0, 100, 1}, rgba{255, 0, 45, 1}},
+
+		// opacity only changes alpha and stops at 100%
+		{filterFunction{"opacity", "50%"}, rgba{10, 20, 30, 1}, rgba{10, 20, 30, 0.5}},
+		{filterFunction{"opacity", "0.5"}, rgba{10, 20, 30, 0.8}, rgba{10, 20, 30, 0.4}},
+		{filterFunction{"opacity", "150%"}, rgba{10, 20, 30, 0.8}, rgba{10, 20, 30, 0.8}},
+
+		// Invalid amounts and unknown functions leave the color alone
+		{filterFunction{"brightness", "-50%"}, rgba{10, 20, 30, 1}, rgba{10, 20, 30, 1}},
+		{filterFunction{"sepia", "lots"}, rgba{10, 20, 30, 1}, rgba{10, 20, 30, 1}},
+		{filterFunction{"blur", "2px"}, rgba{10, 20, 30, 1}, rgba{10, 20, 30, 1}},
+	} {
+		r, g, b, alpha := applyFilterFunction(test.in.r, test.in.g, test.in.b, test.in.alpha, test.function)
+		got := rgba{r, g, b, alpha}
+		if got.r != test.want.r || got.g != test.want.g || got.b != test.want.b || math.Abs(got.alpha-test.want.alpha) > 1e-9 {
+			t.Errorf("%s(%s) of %v = %v, want %v", test.function.name, test.function.value, test.in, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package processor
+
+import (
+	"regexp"
+	"strings"
+)
+
+// preservedTagRegex matches the opening and closing tags of elements whose whitespace is
+// content, with the closing slash in the first group
+var preservedTagRegex = regexp.MustCompile(`(?i)<(/?)(?:pre|textarea)\b[^>]*>`)
+
+// SetNormalizeWhitespace sets whether pages are written with LF line endings, trailing
+// whitespace trimmed and exactly one final newline
+func (p *Processor) SetNormalizeWhitespace(normalize bool) {
+	p.normalizeWhitespace = normalize
+}
+
+// normalizeWhitespace returns text with CRLF line endings turned into LF, the spaces and
+// tabs at the ends of lines removed and exactly one newline at the end. Lines inside pre
+// and textarea elements keep their trailing whitespace, which shows there.
+func normalizeWhitespace(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(strings.TrimRight(text, " \t\n"), "\n")
+	depth := 0 // How many pre or textarea elements are open at the end of the line
+	for i, line := range lines {
+		for _, match := range preservedTagRegex.FindAllStringSubmatch(line, -1) {
+			if match[1] == "" {
+				depth++
+			} else if depth > 0 {
+				depth--
+			}
+		}
+		// The end of a line inside one of them is part of its content
+		if depth == 0 {
+			lines[i] = strings.TrimRight(line, " \t")
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,100 @@
+package scaffold
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sniplicity/internal/config"
+	"sniplicity/internal/types"
+)
+
+// ArchetypesDir is the project folder holding the templates new content files start from
+const ArchetypesDir = "archetypes"
+
+// Tokens replaced in an archetype when a content file is created from it
+const (
+	titleToken = "%TITLE%" // Title made from the filename, e.g. "My First Post"
+	dateToken  = "%DATE%"  // Today's date as 2006-01-02
+	slugToken  = "%SLUG%"  // The filename as a URL slug, e.g. "my-first-post"
+)
+
+// defaultArchetype is used when the project has no archetype for the new file
+const defaultArchetype = "---\ntitle: " + titleToken + "\ndate: " + dateToken + "\ndraft: true\n---\n\n"
+
+// NewContent creates a content file from an archetype and returns its path. The target is
+// relative to the input directory (a leading input directory name is allowed too) and
+// gets a .md extension if it has none. The archetype is archetypes/<kind><ext>, falling
+// back to archetypes/default<ext> and then a built-in draft; kind defaults to the target's
+// top folder, so blog/my-post.md starts from archetypes/blog.md.
+func NewContent(cfg config.Config, target, kind string, now time.Time) (string, error) {
+	inputDir := cfg.GetAbsoluteInputDir()
+	rel := filepath.Clean(target)
+	if filepath.IsAbs(rel) {
+		var err error
+		if rel, err = filepath.Rel(inputDir, rel); err != nil {
+			return "", fmt.Errorf("resolving %s: %w", target, err)
+		}
+	} else {
+		rel = strings.TrimPrefix(rel, filepath.Clean(cfg.InputDir)+string(filepath.Separator))
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the input directory %s", target, inputDir)
+	}
+	if filepath.Ext(rel) == "" {
+		rel += ".md"
+	}
+
+	path := filepath.Join(inputDir, rel)
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("%s already exists", path)
+	}
+
+	if kind == "" {
+		kind = strings.Split(filepath.ToSlash(rel), "/")[0]
+	}
+	archetype, err := loadArchetype(cfg.ProjectDir, kind, filepath.Ext(rel))
+	if err != nil {
+		return "", err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(rel), filepath.Ext(rel))
+	content := strings.NewReplacer(
+		titleToken, titleFromName(name),
+		dateToken, now.Format("2006-01-02"),
+		slugToken, types.Slugify(name),
+	).Replace(archetype)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("cannot create directory %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("cannot write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// loadArchetype reads the project's archetype for a kind of content, or the built-in default
+func loadArchetype(projectDir, kind, ext string) (string, error) {
+	for _, name := range []string{kind + ext, "default" + ext} {
+		data, err := os.ReadFile(filepath.Join(projectDir, ArchetypesDir, name))
+		if err == nil {
+			return string(data), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("reading archetype: %w", err)
+		}
+	}
+	return defaultArchetype, nil
+}
+
+// titleFromName turns a filename like my-first_post into My First Post
+func titleFromName(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' || r == ' ' })
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
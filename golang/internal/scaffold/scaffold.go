@@ -0,0 +1,120 @@
+package scaffold
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"sniplicity/internal/config"
+)
+
+// themes holds the starter files, one folder per theme
+//
+//go:embed themes
+var themes embed.FS
+
+// Themes lists the starters a project can be created from
+var Themes = []string{"basic", "blog"}
+
+// nameToken is replaced with the project name in the starter files
+const nameToken = "%NAME%"
+
+// Create writes a starter project into projectDir: a sniplicity.yaml, a snip/ source folder
+// from the given theme and an empty www/ output folder. Files that already exist are left
+// alone, but a directory that already has a sniplicity.yaml is refused. It returns the
+// files it created, relative to projectDir.
+func Create(projectDir, theme string) ([]string, error) {
+	return CreateNamed(projectDir, theme, "")
+}
+
+// CreateNamed is Create with the project's name, used in sniplicity.yaml and the starter
+// pages. An empty name stands for the project folder's name.
+func CreateNamed(projectDir, theme, name string) ([]string, error) {
+	if !isTheme(theme) {
+		return nil, fmt.Errorf("unknown theme %q (available: %s)", theme, strings.Join(Themes, ", "))
+	}
+
+	absDir, err := filepath.Abs(projectDir)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s: %w", projectDir, err)
+	}
+	if _, err := os.Stat(filepath.Join(absDir, "sniplicity.yaml")); err == nil {
+		return nil, fmt.Errorf("%s already has a sniplicity.yaml", absDir)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.ProjectDir = absDir
+	cfg.Name = strings.TrimSpace(name)
+	if cfg.Name == "" {
+		cfg.Name = filepath.Base(absDir)
+	}
+	if err := os.MkdirAll(filepath.Join(absDir, cfg.OutputDir), 0755); err != nil {
+		return nil, fmt.Errorf("cannot create output directory: %w", err)
+	}
+	if err := cfg.SaveConfigToFile(); err != nil {
+		return nil, err
+	}
+	created := []string{"sniplicity.yaml"}
+
+	// Every theme builds on the basic one, replacing and adding files
+	layers := []string{"basic"}
+	if theme != "basic" {
+		layers = append(layers, theme)
+	}
+	files := make(map[string]string) // Relative path -> embedded path
+	var order []string
+	for _, layer := range layers {
+		root := path.Join("themes", layer)
+		err := fs.WalkDir(themes, root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel := strings.TrimPrefix(p, root+"/")
+			if _, seen := files[rel]; !seen {
+				order = append(order, rel)
+			}
+			files[rel] = p
+			return nil
+		})
+		if err != nil {
+			return created, fmt.Errorf("reading theme %s: %w", layer, err)
+		}
+	}
+
+	for _, rel := range order {
+		target := filepath.Join(absDir, filepath.FromSlash(rel))
+		if _, err := os.Stat(target); err == nil {
+			continue // Never overwrite the user's work
+		}
+
+		data, err := themes.ReadFile(files[rel])
+		if err != nil {
+			return created, fmt.Errorf("reading %s: %w", files[rel], err)
+		}
+		data = []byte(strings.ReplaceAll(string(data), nameToken, cfg.Name))
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return created, fmt.Errorf("cannot create directory %s: %w", filepath.Dir(target), err)
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return created, fmt.Errorf("cannot write %s: %w", target, err)
+		}
+		created = append(created, rel)
+	}
+
+	return created, nil
+}
+
+// isTheme reports whether name is one of the available themes
+func isTheme(name string) bool {
+	for _, theme := range Themes {
+		if theme == name {
+			return true
+		}
+	}
+	return false
+}
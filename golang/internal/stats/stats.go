@@ -0,0 +1,147 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/kirsle/configdir"
+)
+
+// Version is the sniplicity version recorded with each build, set by main
+var Version = "dev"
+
+// Stats is the local, opt-in usage summary. It is only ever written to disk, never
+// sent anywhere, so users can look at it and attach it to bug reports.
+type Stats struct {
+	Version      string         `json:"version"`
+	OS           string         `json:"os"`
+	Arch         string         `json:"arch"`
+	FirstBuild   time.Time      `json:"first_build"`
+	LastBuild    time.Time      `json:"last_build"`
+	Builds       int            `json:"builds"`
+	FailedBuilds int            `json:"failed_builds"`
+	TotalMs      int64          `json:"total_ms"`
+	AverageMs    int64          `json:"average_ms"`
+	LongestMs    int64          `json:"longest_ms"`
+	LastPages    int            `json:"last_pages"` // Pages written by the most recent build
+	MostPages    int            `json:"most_pages"` // Largest number of pages in one build
+	Features     map[string]int `json:"features"`   // Number of builds that used each feature
+}
+
+// Build describes a single build to add to the stats
+type Build struct {
+	Duration time.Duration
+	Failed   bool
+	Pages    int
+	Features []string
+}
+
+// Path returns where the stats file is kept
+func Path() string {
+	return filepath.Join(configdir.LocalConfig("sniplicity"), "stats.json")
+}
+
+// Load reads the stats file, returning empty stats if there isn't one yet
+func Load() (*Stats, error) {
+	s := &Stats{Features: make(map[string]int)}
+
+	data, err := os.ReadFile(Path())
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading stats: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("parsing stats: %w", err)
+	}
+	if s.Features == nil {
+		s.Features = make(map[string]int)
+	}
+	return s, nil
+}
+
+// Record adds a build to the stats file
+func Record(build Build) error {
+	s, err := Load()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if s.Builds == 0 {
+		s.FirstBuild = now
+	}
+	s.LastBuild = now
+	s.Version = Version
+	s.OS = runtime.GOOS
+	s.Arch = runtime.GOARCH
+
+	s.Builds++
+	if build.Failed {
+		s.FailedBuilds++
+	}
+
+	ms := build.Duration.Milliseconds()
+	s.TotalMs += ms
+	s.AverageMs = s.TotalMs / int64(s.Builds)
+	if ms > s.LongestMs {
+		s.LongestMs = ms
+	}
+
+	s.LastPages = build.Pages
+	if build.Pages > s.MostPages {
+		s.MostPages = build.Pages
+	}
+
+	for _, feature := range build.Features {
+		s.Features[feature]++
+	}
+
+	return s.save()
+}
+
+// Reset deletes the stats file
+func Reset() error {
+	if err := os.Remove(Path()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing stats: %w", err)
+	}
+	return nil
+}
+
+// FeatureNames returns the recorded features, most used first
+func (s *Stats) FeatureNames() []string {
+	names := make([]string, 0, len(s.Features))
+	for name := range s.Features {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if s.Features[names[i]] != s.Features[names[j]] {
+			return s.Features[names[i]] > s.Features[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// save writes the stats file
+func (s *Stats) save() error {
+	path := Path()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling stats: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing stats: %w", err)
+	}
+	return nil
+}
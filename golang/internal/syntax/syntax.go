@@ -0,0 +1,112 @@
+package syntax
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sniplicity/internal/parser"
+)
+
+// Formats lists the editor grammar formats Generate supports
+var Formats = []string{"textmate", "vim"}
+
+// Generate returns a grammar in the given format that highlights sniplicity directives
+// and {{variables}} inside HTML and markdown
+func Generate(format string) ([]byte, error) {
+	switch format {
+	case "textmate":
+		return TextMate()
+	case "vim":
+		return []byte(Vim()), nil
+	}
+	return nil, fmt.Errorf("unknown syntax format %q (expected %s)", format, strings.Join(Formats, " or "))
+}
+
+// keywordNames returns the directive keywords, longest first so alternations never
+// stop at a shorter prefix such as "end" in "endif"
+func keywordNames() []string {
+	names := make([]string, 0, len(parser.Keywords))
+	for _, keyword := range parser.Keywords {
+		names = append(names, keyword.Name)
+	}
+	sort.SliceStable(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	return names
+}
+
+// TextMate returns a TextMate injection grammar (also usable in VS Code, Sublime Text
+// and other editors that read .tmLanguage.json files)
+func TextMate() ([]byte, error) {
+	alternation := make([]string, 0, len(parser.Keywords))
+	for _, name := range keywordNames() {
+		alternation = append(alternation, regexp.QuoteMeta(name))
+	}
+
+	grammar := map[string]interface{}{
+		"$schema":           "https://raw.githubusercontent.com/martinring/tmlanguage/master/tmlanguage.json",
+		"name":              "Sniplicity",
+		"scopeName":         "text.sniplicity.injection",
+		"injectionSelector": "L:text.html, L:text.html.markdown",
+		"patterns": []map[string]string{
+			{"include": "#directive"},
+			{"include": "#escape"},
+			{"include": "#variable"},
+		},
+		"repository": map[string]interface{}{
+			"directive": map[string]interface{}{
+				"match": `(<!--)\s+(` + strings.Join(alternation, "|") + `)\b(.*?)\s*(-->)`,
+				"captures": map[string]interface{}{
+					"1": map[string]string{"name": "punctuation.definition.tag.begin.sniplicity"},
+					"2": map[string]string{"name": "keyword.control.directive.sniplicity"},
+					"3": map[string]interface{}{
+						"name":     "entity.name.function.sniplicity",
+						"patterns": []map[string]string{{"include": "#variable"}},
+					},
+					"4": map[string]string{"name": "punctuation.definition.tag.end.sniplicity"},
+				},
+			},
+			"escape": map[string]string{
+				"match": `\\\{\{[^}]*\}\}`,
+				"name":  "constant.character.escape.sniplicity",
+			},
+			"variable": map[string]interface{}{
+				"match": `(\{\{)([-\w.]+)(\([^)]*\))?(\}\})`,
+				"captures": map[string]interface{}{
+					"1": map[string]string{"name": "punctuation.definition.variable.begin.sniplicity"},
+					"2": map[string]string{"name": "variable.other.sniplicity"},
+					"3": map[string]string{"name": "variable.parameter.sniplicity"},
+					"4": map[string]string{"name": "punctuation.definition.variable.end.sniplicity"},
+				},
+			},
+		},
+	}
+
+	data, err := json.MarshalIndent(grammar, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding grammar: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// Vim returns a syntax file that adds sniplicity highlighting on top of Vim's html and
+// markdown syntax, meant for after/syntax/html.vim and after/syntax/markdown.vim
+func Vim() string {
+	names := keywordNames()
+
+	var b strings.Builder
+	b.WriteString("\" Sniplicity directives and variables\n")
+	b.WriteString("\" Generated by `sniplicity syntax --format vim`. Save as after/syntax/html.vim\n")
+	b.WriteString("\" and after/syntax/markdown.vim in your Vim runtime path.\n\n")
+	fmt.Fprintf(&b, "syn match sniplicityDirective /<!--\\s\\+\\%%(%s\\)\\>.\\{-}-->/ contains=sniplicityKeyword,sniplicityVariable containedin=ALL\n",
+		strings.Join(names, "\\|"))
+	fmt.Fprintf(&b, "syn keyword sniplicityKeyword contained %s\n", strings.Join(names, " "))
+	b.WriteString("syn match sniplicityEscape /\\\\{{[^}]*}}/ containedin=ALL\n")
+	b.WriteString("syn match sniplicityVariable /\\\\\\@<!{{[-[:alnum:]_.]\\+\\%(([^)]*)\\)\\=}}/ containedin=ALL\n\n")
+	b.WriteString("hi def link sniplicityDirective PreProc\n")
+	b.WriteString("hi def link sniplicityKeyword Keyword\n")
+	b.WriteString("hi def link sniplicityEscape SpecialChar\n")
+	b.WriteString("hi def link sniplicityVariable Identifier\n")
+	return b.String()
+}
@@ -0,0 +1,182 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // Time zones by name work on systems without a zone database, like Windows
+)
+
+// dateFormats are the frontmatter date formats sniplicity understands, most specific first
+var dateFormats = []string{
+	time.RFC3339Nano,            // 2024-09-23T14:30:00.5+02:00
+	"2006-01-02T15:04Z07:00",    // 2024-09-23T14:30+02:00
+	"2006-01-02 15:04:05Z07:00", // 2024-09-23 14:30:00+02:00
+	"2006-01-02 15:04:05 -0700", // 2024-09-23 14:30:00 +0200
+	"2006-01-02T15:04:05",       // 2024-09-23T14:30:00
+	"2006-01-02T15:04",          // 2024-09-23T14:30
+	"2006-01-02",                // 2024-09-23
+	"2006/01/02",                // 2024/09/23
+	"01/02/2006",                // 09/23/2024
+	"02/01/2006",                // 23/09/2024
+	"Jan 02 2006",               // Sep 23 2024
+	"January 02 2006",           // September 23 2024
+	"Jan 02, 2006",              // Sep 23, 2024
+	"January 02, 2006",          // September 23, 2024
+	"02 Jan 2006",               // 23 Sep 2024
+	"02 January 2006",           // 23 September 2006
+	"2006-01-02 15:04:05",       // 2024-09-23 14:30:00
+	"2006-01-02 15:04",          // 2024-09-23 14:30
+}
+
+// strftimeLayouts maps the strftime directives date_formats may use to Go layout parts
+var strftimeLayouts = map[byte]string{
+	'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'e': "_2",
+	'b': "Jan", 'B': "January", 'a': "Mon", 'A': "Monday",
+	'H': "15", 'I': "03", 'M': "04", 'S': "05", 'p': "PM",
+	'z': "-0700", 'Z': "MST", '%': "%",
+}
+
+// unpaddedLayouts maps the strftime directives that may follow %- to Go layout parts
+// without their leading zero
+var unpaddedLayouts = map[byte]string{'d': "2", 'm': "1", 'I': "3"}
+
+// DefaultDateFormat is how {{name | dateformat}} shows a date when dateformat is not set
+const DefaultDateFormat = "%B %-d, %Y"
+
+// Dates reads frontmatter dates in the built-in formats and the project's own. Dates
+// without an offset, such as date-only values, are taken to be in the project's time
+// zone. The zero value reads the built-in formats in UTC and shows dates in
+// DefaultDateFormat.
+type Dates struct {
+	layouts  []string       // Go layouts of the project's formats, tried after the built-in ones
+	location *time.Location // Time zone of dates without an offset, nil for UTC
+	display  string         // strftime format dates are shown in, "" for DefaultDateFormat
+}
+
+// NewDates returns the date reader for a project's date_formats, written like strftime
+// (e.g. "%d.%m.%Y"), timezone, an IANA name such as "Europe/Berlin" or "" for UTC, and
+// dateformat, the strftime format dates are shown in, or "" for DefaultDateFormat
+func NewDates(formats []string, timezone, dateformat string) (Dates, error) {
+	var dates Dates
+	if dateformat != "" {
+		if _, err := strftimeParts(dateformat); err != nil {
+			return Dates{}, fmt.Errorf("dateformat: %w", err)
+		}
+		dates.display = dateformat
+	}
+	for _, format := range formats {
+		layout, err := strftimeLayout(format)
+		if err != nil {
+			return Dates{}, err
+		}
+		dates.layouts = append(dates.layouts, layout)
+	}
+	if timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return Dates{}, fmt.Errorf("unknown timezone %q, use a name like Europe/Berlin or America/New_York", timezone)
+		}
+		dates.location = location
+	}
+	return dates, nil
+}
+
+// Parse reads a date in any of the formats. A date with an offset keeps it unless the
+// project has a time zone, which it is then shown in.
+func (d Dates) Parse(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	location := d.location
+	if location == nil {
+		location = time.UTC
+	}
+	for _, layouts := range [][]string{dateFormats, d.layouts} {
+		for _, layout := range layouts {
+			if t, err := time.ParseInLocation(layout, value, location); err == nil {
+				if d.location != nil {
+					t = t.In(d.location)
+				}
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// Format shows value, a date in any of the formats Parse reads, in format, written like
+// strftime, or in the project's dateformat when format is "". Text between the
+// directives is kept as it is.
+func (d Dates) Format(value, format string) (string, error) {
+	if format == "" {
+		format = d.display
+	}
+	if format == "" {
+		format = DefaultDateFormat
+	}
+	parts, err := strftimeParts(format)
+	if err != nil {
+		return "", err
+	}
+	t, ok := d.Parse(value)
+	if !ok {
+		return "", fmt.Errorf("%q is not a date", value)
+	}
+	var shown strings.Builder
+	for _, part := range parts {
+		if part.layout {
+			shown.WriteString(t.Format(part.text))
+		} else {
+			shown.WriteString(part.text)
+		}
+	}
+	return shown.String(), nil
+}
+
+// strftimePart is literal text of a strftime format, or the Go layout of a directive
+type strftimePart struct {
+	text   string
+	layout bool
+}
+
+// strftimeParts splits a strftime format into its text and directives
+func strftimeParts(format string) ([]strftimePart, error) {
+	var parts []strftimePart
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			if n := len(parts); n > 0 && !parts[n-1].layout {
+				parts[n-1].text += format[i : i+1]
+			} else {
+				parts = append(parts, strftimePart{text: format[i : i+1]})
+			}
+			continue
+		}
+		if i+1 == len(format) {
+			return nil, fmt.Errorf("date format %q ends in a lone %%", format)
+		}
+		i++
+		layouts, directive := strftimeLayouts, format[i:i+1]
+		if format[i] == '-' && i+1 < len(format) {
+			i++
+			layouts, directive = unpaddedLayouts, format[i-1:i+1]
+		}
+		part, ok := layouts[format[i]]
+		if !ok {
+			return nil, fmt.Errorf("date format %q uses %%%s, which sniplicity doesn't support", format, directive)
+		}
+		parts = append(parts, strftimePart{text: part, layout: true})
+	}
+	return parts, nil
+}
+
+// strftimeLayout turns a strftime format into a Go time layout
+func strftimeLayout(format string) (string, error) {
+	parts, err := strftimeParts(format)
+	if err != nil {
+		return "", err
+	}
+	var layout strings.Builder
+	for _, part := range parts {
+		layout.WriteString(part.text)
+	}
+	return layout.String(), nil
+}
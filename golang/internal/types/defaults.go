@@ -0,0 +1,102 @@
+package types
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultsFilename is the per-directory file whose keys become default frontmatter for
+// every page in that directory and below it
+const DefaultsFilename = "_defaults.yaml"
+
+// DirectoryDefaults loads and merges _defaults.yaml files down a source tree. Each
+// directory's values override its parents', and a page's own frontmatter overrides both.
+type DirectoryDefaults struct {
+	inputDir string
+	mu       sync.Mutex                        // Pages may be processed concurrently
+	cache    map[string]map[string]interface{} // merged defaults per relative directory
+}
+
+// NewDirectoryDefaults creates a defaults loader for the given input directory
+func NewDirectoryDefaults(inputDir string) *DirectoryDefaults {
+	return &DirectoryDefaults{
+		inputDir: inputDir,
+		cache:    make(map[string]map[string]interface{}),
+	}
+}
+
+// For returns the merged defaults that apply to pages in relDir (relative to the input directory)
+func (d *DirectoryDefaults) For(relDir string) map[string]interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lookup(relDir)
+}
+
+// lookup does the work of For, with the lock held
+func (d *DirectoryDefaults) lookup(relDir string) map[string]interface{} {
+	relDir = filepath.ToSlash(filepath.Clean(relDir))
+	if relDir == "." || relDir == "/" {
+		relDir = ""
+	}
+
+	if merged, exists := d.cache[relDir]; exists {
+		return merged
+	}
+
+	merged := make(map[string]interface{})
+
+	// Start from the parent directory's defaults
+	if relDir != "" {
+		parent := ""
+		if idx := strings.LastIndex(relDir, "/"); idx >= 0 {
+			parent = relDir[:idx]
+		}
+		for k, v := range d.lookup(parent) {
+			merged[k] = v
+		}
+	}
+
+	// Then apply this directory's own file
+	defaultsPath := filepath.Join(d.inputDir, filepath.FromSlash(relDir), DefaultsFilename)
+	if data, err := os.ReadFile(defaultsPath); err == nil {
+		for k, v := range parseSimpleYAML(string(data)) {
+			merged[k] = v
+		}
+	}
+
+	d.cache[relDir] = merged
+	return merged
+}
+
+// Source returns the path, relative to the input directory, of the _defaults.yaml that
+// gives pages in relDir their default for key, or "" when none does
+func (d *DirectoryDefaults) Source(relDir, key string) string {
+	relDir = filepath.ToSlash(filepath.Clean(relDir))
+	for {
+		if relDir == "." || relDir == "/" {
+			relDir = ""
+		}
+		defaultsPath := filepath.Join(d.inputDir, filepath.FromSlash(relDir), DefaultsFilename)
+		if data, err := os.ReadFile(defaultsPath); err == nil {
+			if _, exists := parseSimpleYAML(string(data))[key]; exists {
+				return path.Join(relDir, DefaultsFilename)
+			}
+		}
+		if relDir == "" {
+			return ""
+		}
+		relDir = path.Dir(relDir)
+	}
+}
+
+// Apply fills in any keys missing from metadata with the defaults for relDir
+func (d *DirectoryDefaults) Apply(metadata map[string]interface{}, relDir string) {
+	for k, v := range d.For(relDir) {
+		if _, exists := metadata[k]; !exists {
+			metadata[k] = v
+		}
+	}
+}
@@ -3,11 +3,17 @@ package types
 import (
 	"bufio"
 	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 
+	"sniplicity/internal/i18n"
+	sniparser "sniplicity/internal/parser"
+
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
@@ -20,11 +26,17 @@ type FileInfo struct {
 	InputPath       string
 	Filename        string
 	OutputRelPath   string
+	Permalink       string // Output path from a permalink rule, relative to the output directory
 	IsMarkdown      bool
 	Content         []string
 	Metadata        map[string]interface{}
 	UsedSnippets    map[string]bool
+	PasteCounts     map[string]int   // Number of times each snippet has been pasted into this page
 	MarkdownImages  map[string]bool  // Track image URLs that came from markdown
+	Anchors         map[string]string // Text of each {{anchor}} and heading ID on the rendered page, by ID
+	Delimiters      sniparser.Delimiters // Variable delimiters the page is written with, set before loading; its frontmatter may override them
+	HeadingIDs      HeadingIDs       // How markdown headings get their IDs, set before loading
+	Limits          *PageLimits      // Limits of the build step processing the page, nil outside a build
 }
 
 // NewFileInfoRaw creates a new FileInfo instance for raw content loading
@@ -36,6 +48,7 @@ func NewFileInfoRaw(inputPath, filename string, isMarkdown bool) *FileInfo {
 		Content:        make([]string, 0),
 		Metadata:       make(map[string]interface{}),
 		UsedSnippets:   make(map[string]bool),
+		PasteCounts:    make(map[string]int),
 		MarkdownImages: make(map[string]bool),
 	}
 }
@@ -49,6 +62,7 @@ func NewFileInfo(inputPath, filename string, isMarkdown bool) *FileInfo {
 		Content:        make([]string, 0),
 		Metadata:       make(map[string]interface{}),
 		UsedSnippets:   make(map[string]bool),
+		PasteCounts:    make(map[string]int),
 		MarkdownImages: make(map[string]bool),
 	}
 }
@@ -72,8 +86,13 @@ func (f *FileInfo) LoadRaw() error {
 
 	// Parse metadata and content - YAML frontmatter should be processed for ALL file types
 	content, metadata := parseFrontmatter(lines)
-	f.Content = content
 	f.Metadata = metadata
+	AddReadingTime(content, f.Metadata)
+	content, err = f.applyDelimiters(content)
+	if err != nil {
+		return err
+	}
+	f.Content = sniparser.ProtectRaw(content)
 	
 	// Convert markdown to HTML if this is a markdown file (matches Python exactly)
 	if f.IsMarkdown {
@@ -91,8 +110,14 @@ func (f *FileInfo) LoadWithTemplates(templates map[string][]string, globals map[
 	}
 	defer file.Close()
 
+	return f.LoadFromReader(file)
+}
+
+// LoadFromReader loads content from r instead of InputPath (e.g. a page piped in on stdin),
+// parsing frontmatter and converting markdown exactly like a file loaded from disk
+func (f *FileInfo) LoadFromReader(r io.Reader) error {
 	var lines []string
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		lines = append(lines, scanner.Text())
 	}
@@ -102,8 +127,13 @@ func (f *FileInfo) LoadWithTemplates(templates map[string][]string, globals map[
 
 	// Parse metadata and content - YAML frontmatter should be processed for ALL file types
 	content, metadata := parseFrontmatter(lines)
-	f.Content = content
 	f.Metadata = metadata
+	AddReadingTime(content, f.Metadata)
+	content, err := f.applyDelimiters(content)
+	if err != nil {
+		return err
+	}
+	f.Content = sniparser.ProtectRaw(content)
 	
 	// Convert markdown to HTML if this is a markdown file (same as LoadRaw - ensures consistency)
 	if f.IsMarkdown {
@@ -113,6 +143,23 @@ func (f *FileInfo) LoadWithTemplates(templates map[string][]string, globals map[
 	return nil
 }
 
+// applyDelimiters settles which delimiters the page uses, its frontmatter's delimiters
+// taking precedence, and rewrites content written with custom ones. Variables in the
+// code blocks of markdown are left as written.
+func (f *FileInfo) applyDelimiters(content []string) ([]string, error) {
+	if value, ok := f.Metadata["delimiters"].(string); ok {
+		d, err := sniparser.ParseDelimiters(value)
+		if err != nil {
+			return nil, err
+		}
+		f.Delimiters = d
+	}
+	if f.IsMarkdown {
+		return sniparser.ApplyMarkdownDelimiters(content, f.Delimiters), nil
+	}
+	return sniparser.ApplyDelimiters(content, f.Delimiters), nil
+}
+
 // Load reads the file content and parses metadata (original method for compatibility)
 func (f *FileInfo) Load() error {
 	return f.LoadWithTemplates(nil, nil)
@@ -126,6 +173,42 @@ func (f *FileInfo) convertMarkdownToHTML() {
 	// Extract image URLs from markdown before conversion
 	f.extractMarkdownImages(markdownText)
 	
+	if htmlContent, ok := markdownToHTML(markdownText, f.HeadingIDs); ok {
+		f.Content = htmlContent
+	}
+	
+	// Change filename extension and mark as no longer markdown
+	if strings.HasSuffix(f.Filename, ".md") {
+		f.Filename = strings.TrimSuffix(f.Filename, ".md") + ".html"
+	} else if strings.HasSuffix(f.Filename, ".markdown") {
+		f.Filename = strings.TrimSuffix(f.Filename, ".markdown") + ".html"
+	} else if strings.HasSuffix(f.Filename, ".mdown") {
+		f.Filename = strings.TrimSuffix(f.Filename, ".mdown") + ".html"
+	}
+	f.IsMarkdown = false
+}
+
+// IsMarkdownFile reports whether a path has one of the markdown extensions
+func IsMarkdownFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".md" || ext == ".mdown" || ext == ".markdown"
+}
+
+// RenderMarkdownFragment converts a markdown file's lines (e.g. an included partial) to
+// HTML lines the same way pages are converted, dropping any frontmatter. Variables in it
+// use the given delimiters, and its headings get IDs headingIDs' way.
+func RenderMarkdownFragment(lines []string, delimiters sniparser.Delimiters, headingIDs HeadingIDs) []string {
+	content, _ := parseFrontmatter(lines)
+	content = sniparser.ProtectRaw(sniparser.ApplyMarkdownDelimiters(content, delimiters))
+	
+	if htmlContent, ok := markdownToHTML(strings.Join(content, "\n"), headingIDs); ok {
+		return htmlContent
+	}
+	return content
+}
+
+// markdownToHTML converts markdown text to HTML lines, reporting false if conversion failed
+func markdownToHTML(markdownText string, headingIDs HeadingIDs) ([]string, bool) {
 	// Configure goldmark to match Python's markdown extensions
 	md := goldmark.New(
 		goldmark.WithExtensions(
@@ -148,30 +231,46 @@ func (f *FileInfo) convertMarkdownToHTML() {
 		),
 	)
 	
-	// Convert markdown to HTML
+	// Convert markdown to HTML, keeping the typographer away from translatable strings
 	var buf bytes.Buffer
-	if err := md.Convert([]byte(markdownText), &buf); err != nil {
+	if err := md.Convert([]byte(protectFilters(i18n.Protect(markdownText))), &buf, parser.WithContext(headingIDs.context())); err != nil {
 		// If conversion fails, keep original content but still change filename
 		// This matches Python behavior where markdown processing errors don't stop the build
-	} else {
-		// Replace content with HTML
-		htmlContent := buf.String()
-		
-		// Remove markdown attributes from HTML tags (matches Python's md_in_html extension)
-		htmlContent = removeMarkdownAttributes(htmlContent)
-		
-		f.Content = strings.Split(strings.TrimRight(htmlContent, "\n"), "\n")
+		return nil, false
 	}
 	
-	// Change filename extension and mark as no longer markdown
-	if strings.HasSuffix(f.Filename, ".md") {
-		f.Filename = strings.TrimSuffix(f.Filename, ".md") + ".html"
-	} else if strings.HasSuffix(f.Filename, ".markdown") {
-		f.Filename = strings.TrimSuffix(f.Filename, ".markdown") + ".html"
-	} else if strings.HasSuffix(f.Filename, ".mdown") {
-		f.Filename = strings.TrimSuffix(f.Filename, ".mdown") + ".html"
-	}
-	f.IsMarkdown = false
+	// Replace content with HTML
+	htmlContent := restoreFilters(i18n.Restore(buf.String()))
+	
+	// Remove markdown attributes from HTML tags (matches Python's md_in_html extension)
+	htmlContent = removeMarkdownAttributes(htmlContent)
+	
+	return strings.Split(strings.TrimRight(htmlContent, "\n"), "\n"), true
+}
+
+// filterRegex matches a variable passed through a filter, such as {{date | dateformat "%Y"}}
+var filterRegex = regexp.MustCompile(`\{\{[-\w.]+\s*\|[^}]*\}\}`)
+
+// protectedFilterRegex matches a filter hidden from the markdown converter by protectFilters
+var protectedFilterRegex = regexp.MustCompile(`\{\{f:([0-9a-f]*)\}\}`)
+
+// protectFilters hides filters from the markdown converter, which would otherwise turn
+// the quotes around their arguments into curly ones
+func protectFilters(text string) string {
+	return filterRegex.ReplaceAllStringFunc(text, func(filter string) string {
+		return "{{f:" + hex.EncodeToString([]byte(filter)) + "}}"
+	})
+}
+
+// restoreFilters turns filters hidden by protectFilters back into their usual form
+func restoreFilters(text string) string {
+	return protectedFilterRegex.ReplaceAllStringFunc(text, func(marker string) string {
+		filter, err := hex.DecodeString(protectedFilterRegex.FindStringSubmatch(marker)[1])
+		if err != nil {
+			return marker
+		}
+		return string(filter)
+	})
 }
 
 // removeMarkdownAttributes removes markdown attributes from HTML tags to match Python's md_in_html extension
@@ -181,8 +280,18 @@ func removeMarkdownAttributes(html string) string {
 	return re.ReplaceAllString(html, "")
 }
 
+// GetPlainTextPath returns the output path of this file's plain text version
+func (f *FileInfo) GetPlainTextPath(outputDir string) string {
+	outputPath := f.GetOutputPath(outputDir)
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".txt"
+}
+
 // GetOutputPath returns the full output path for this file
 func (f *FileInfo) GetOutputPath(outputDir string) string {
+	if f.Permalink != "" {
+		return filepath.Join(outputDir, filepath.FromSlash(PermalinkFile(f.Permalink)))
+	}
+	
 	outputPath := filepath.Join(outputDir, f.OutputRelPath, f.Filename)
 	
 	// Convert .md files to .html
@@ -272,6 +381,44 @@ func parseSimpleYAML(yamlContent string) map[string]interface{} {
 	return metadata
 }
 
+// IsDraft reports whether frontmatter marks a page as a draft
+func IsDraft(metadata map[string]interface{}) bool {
+	switch v := metadata["draft"].(type) {
+	case bool:
+		return v
+	case string:
+		v = strings.ToLower(strings.TrimSpace(v))
+		return v == "true" || v == "yes"
+	}
+	return false
+}
+
+// ParseList reads a frontmatter list, written inline as [a, b] or as a comma separated string
+func ParseList(value interface{}) []string {
+	var items []string
+	switch v := value.(type) {
+	case []string:
+		items = v
+	case []interface{}:
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+	case string:
+		v = strings.TrimSpace(v)
+		v = strings.TrimSuffix(strings.TrimPrefix(v, "["), "]")
+		items = strings.Split(v, ",")
+	}
+	
+	var list []string
+	for _, item := range items {
+		item = strings.Trim(strings.TrimSpace(item), `"'`)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 // extractMarkdownImages extracts image URLs from markdown content
 func (f *FileInfo) extractMarkdownImages(markdownText string) {
 	// Match markdown image syntax: ![alt](url) and ![alt](url "title")
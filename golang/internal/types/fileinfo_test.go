@@ -0,0 +1,32 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func FuzzParseFrontmatter(f *testing.F) {
+	for _, seed := range []string{
+		"---\ntitle: Hello\ndate: 2024-09-03\n---\n# Hello",
+		"---\ntags: [a, b, c]\n---\n",
+		"---\nauthors:\n  - Ann\n  - Bob\n---\ntext",
+		"---\ntitle: \"quoted: colon\"\n---",
+		"---\n---\n",
+		"---\nno closing marker",
+		"---\n: no key\nkey:\n---",
+		"no frontmatter\n---\n",
+		"",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, text string) {
+		lines := strings.Split(text, "\n")
+		content, metadata := parseFrontmatter(lines)
+		if metadata == nil {
+			t.Fatalf("parseFrontmatter(%q) returned nil metadata", text)
+		}
+		if len(content) > len(lines) {
+			t.Errorf("parseFrontmatter(%q) returned %d lines from %d", text, len(content), len(lines))
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package types
+
+import (
+	"html"
+	"os"
+	"path/filepath"
+	"time"
+
+	sniparser "sniplicity/internal/parser"
+)
+
+// GitHistory holds the last commit of each file of a git repository, by its path
+// relative to the input directory, with forward slashes
+type GitHistory map[string]GitCommit
+
+// GitCommit is the date and author of a commit
+type GitCommit struct {
+	Date   time.Time
+	Author string
+}
+
+// AddFileVariables sets a page's file.relpath, its source path relative to the input
+// directory; file.url, the root-relative URL it is published at; and file.mtime, when
+// its source last changed, in RFC 3339 for the dateformat filter. With a git history it
+// also sets git.lastmod and git.author, from the last commit of the source. Frontmatter
+// that sets any of them wins.
+func AddFileVariables(metadata map[string]interface{}, inputPath, relPath, url string, history GitHistory) {
+	relPath = filepath.ToSlash(relPath)
+	set := func(name, value string) {
+		if _, exists := metadata[name]; !exists {
+			metadata[name] = value
+		}
+	}
+	set("file.relpath", relPath)
+	set("file.url", url)
+	if info, err := os.Stat(inputPath); err == nil {
+		set("file.mtime", info.ModTime().UTC().Format(time.RFC3339))
+	}
+	if commit, ok := history[relPath]; ok {
+		set("git.lastmod", commit.Date.Format(time.RFC3339))
+		set("git.author", sniparser.Literal(html.EscapeString(commit.Author)))
+	}
+}
@@ -0,0 +1,123 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+)
+
+// Ways heading_ids can tell apart headings with the same text
+const (
+	DuplicatesNumber     = "number"     // intro, intro-1, intro-2, like GitHub
+	DuplicatesOrdinal    = "ordinal"    // intro, intro-2, intro-3: the heading's place among those with its text
+	DuplicatesUnderscore = "underscore" // intro, intro_1, intro_2, like Python-Markdown
+)
+
+// transliterations spells accented and other non-ASCII Latin letters in ASCII
+var transliterations = func() map[rune]string {
+	letters := map[string]string{
+		"a": "àáâãäåāăą", "c": "çćĉċč", "d": "ďđð", "e": "èéêëēĕėęě", "g": "ĝğġģ",
+		"h": "ĥħ", "i": "ìíîïĩīĭįı", "j": "ĵ", "k": "ķ", "l": "ĺļľŀł", "n": "ñńņň",
+		"o": "òóôõöøōŏő", "r": "ŕŗř", "s": "śŝşšș", "t": "ţťŧț", "u": "ùúûüũūŭůűų",
+		"w": "ŵ", "y": "ýÿŷ", "z": "źżž", "ss": "ß", "ae": "æ", "oe": "œ", "th": "þ",
+	}
+	m := make(map[rune]string)
+	for ascii, runes := range letters {
+		for _, r := range runes {
+			m[r] = ascii
+		}
+	}
+	return m
+}()
+
+// HeadingIDs sets how the IDs of markdown headings are made from their text. The zero
+// value makes goldmark's own IDs: lower case ASCII letters and digits, dashes for spaces,
+// other characters left out, and -1, -2 after repeats.
+type HeadingIDs struct {
+	Prefix        string // Put before every ID, e.g. "section-"
+	Transliterate bool   // Whether accented letters are kept as their ASCII letters instead of left out
+	Duplicates    string // One of the Duplicates constants, empty for DuplicatesNumber
+}
+
+// NewHeadingIDs returns the heading ID settings of a project's heading_ids: prefix,
+// transliterate (true or false) and duplicates
+func NewHeadingIDs(settings map[string]string) (HeadingIDs, error) {
+	h := HeadingIDs{Prefix: settings["prefix"], Duplicates: settings["duplicates"]}
+	if strings.ContainsAny(h.Prefix, " \t\"'<>&#") {
+		return HeadingIDs{}, fmt.Errorf("heading_ids prefix %q can't have spaces, quotes, <, >, & or #", h.Prefix)
+	}
+	switch settings["transliterate"] {
+	case "", "false":
+	case "true":
+		h.Transliterate = true
+	default:
+		return HeadingIDs{}, fmt.Errorf("heading_ids transliterate is %q, use true or false", settings["transliterate"])
+	}
+	switch h.Duplicates {
+	case "", DuplicatesNumber, DuplicatesOrdinal, DuplicatesUnderscore:
+	default:
+		return HeadingIDs{}, fmt.Errorf("heading_ids duplicates is %q, use %s, %s or %s", h.Duplicates, DuplicatesNumber, DuplicatesOrdinal, DuplicatesUnderscore)
+	}
+	return h, nil
+}
+
+// context returns a goldmark parser context that makes IDs these settings' way
+func (h HeadingIDs) context() parser.Context {
+	return parser.NewContext(parser.WithIDs(&headingIDs{settings: h, used: make(map[string]bool)}))
+}
+
+// headingIDs is the goldmark IDs of one markdown conversion
+type headingIDs struct {
+	settings HeadingIDs
+	used     map[string]bool
+}
+
+// Generate returns the ID of a heading with the given text, unique in the document
+func (s *headingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	var id strings.Builder
+	for _, r := range strings.TrimSpace(string(value)) {
+		r = unicode.ToLower(r)
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			id.WriteRune(r)
+		case r == ' ' || r == '\t' || r == '\n' || r == '-' || r == '_':
+			id.WriteByte('-')
+		case s.settings.Transliterate && transliterations[r] != "":
+			id.WriteString(transliterations[r])
+		}
+	}
+	if id.Len() == 0 {
+		if kind == ast.KindHeading {
+			id.WriteString("heading")
+		} else {
+			id.WriteString("id")
+		}
+	}
+	base := s.settings.Prefix + id.String()
+	if !s.used[base] {
+		s.used[base] = true
+		return []byte(base)
+	}
+
+	format, first := "%s-%d", 1
+	switch s.settings.Duplicates {
+	case DuplicatesOrdinal:
+		first = 2
+	case DuplicatesUnderscore:
+		format = "%s_%d"
+	}
+	for i := first; ; i++ {
+		if candidate := fmt.Sprintf(format, base, i); !s.used[candidate] {
+			s.used[candidate] = true
+			return []byte(candidate)
+		}
+	}
+}
+
+// Put notes an ID a heading was given by hand, so no generated one repeats it
+func (s *headingIDs) Put(value []byte) {
+	s.used[string(value)] = true
+}
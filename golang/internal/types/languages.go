@@ -0,0 +1,141 @@
+package types
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// languageCodeRegex matches a language code such as en, de or pt-BR
+var languageCodeRegex = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// Languages lists the languages of a multilingual site, the default one first. A page is
+// in a language by its lang frontmatter, a suffix before its extension (about.de.md) or
+// a top folder named after the language (de/about.md), and in the default one otherwise.
+// The zero value is a site with no languages, where paths say nothing about them.
+type Languages []string
+
+// NewLanguages returns the languages of a project's languages setting
+func NewLanguages(codes []string) (Languages, error) {
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if !languageCodeRegex.MatchString(code) {
+			return nil, fmt.Errorf("languages has %q, which is not a language code like en or pt-BR", code)
+		}
+		if seen[code] {
+			return nil, fmt.Errorf("languages has %s twice", code)
+		}
+		seen[code] = true
+	}
+	return Languages(codes), nil
+}
+
+// Default returns the default language, or "" when there are no languages
+func (l Languages) Default() string {
+	if len(l) == 0 {
+		return ""
+	}
+	return l[0]
+}
+
+// Has reports whether lang is one of the languages
+func (l Languages) Has(lang string) bool {
+	for _, known := range l {
+		if known == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// Split returns the language a page's path (relative to the input directory) puts it in,
+// "" for none, and the path without the language: about.de.md and de/about.md both give
+// about.md. suffixed says whether the language came from the file name.
+func (l Languages) Split(relPath string) (lang, neutral string, suffixed bool) {
+	neutral = filepath.ToSlash(relPath)
+	if len(l) == 0 {
+		return "", neutral, false
+	}
+
+	dir, name := path.Split(neutral)
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	if dot := strings.LastIndex(stem, "."); dot > 0 && l.Has(stem[dot+1:]) {
+		lang, suffixed = stem[dot+1:], true
+		neutral = dir + stem[:dot] + ext
+	}
+	if top, rest, found := strings.Cut(neutral, "/"); found && l.Has(top) {
+		if lang == "" {
+			lang = top
+		}
+		neutral = rest
+	}
+	return lang, neutral, suffixed
+}
+
+// Localize sets a page's lang from its path, or to the default language, and its
+// translation_key to its path without the language, so the versions of a page in
+// different languages share one. Frontmatter and directory defaults that set either win.
+func (l Languages) Localize(relPath string, metadata map[string]interface{}) {
+	if len(l) == 0 {
+		return
+	}
+	lang, neutral, _ := l.Split(relPath)
+	if lang == "" {
+		lang = l.Default()
+	}
+	if value, _ := metadata["lang"].(string); value == "" {
+		metadata["lang"] = lang
+	}
+	if value, _ := metadata["translation_key"].(string); value == "" {
+		metadata["translation_key"] = strings.TrimSuffix(neutral, path.Ext(neutral))
+	}
+}
+
+// Permalink returns a page's output path relative to the output directory, or "" for its
+// source path. Permalink rules are matched against the path without the language first,
+// and their :lang placeholder is the page's lang, empty for the default language. Pages
+// with a language suffix that no rule covers are moved into their language's folder, so
+// about.de.md is written to de/about.html and about.en.md, in the default language, to
+// about.html.
+func (l Languages) Permalink(permalinks Permalinks, relPath string, metadata map[string]interface{}, dates Dates) string {
+	relPath = filepath.ToSlash(relPath)
+	prefix, _ := metadata["lang"].(string)
+	if prefix == l.Default() {
+		prefix = ""
+	}
+	permalinks = permalinks.withLang(prefix)
+
+	_, neutral, suffixed := l.Split(relPath)
+	if permalink := permalinks.Resolve(neutral, metadata, dates); permalink != "" {
+		return permalink
+	}
+	if neutral != relPath {
+		if permalink := permalinks.Resolve(relPath, metadata, dates); permalink != "" {
+			return permalink
+		}
+	}
+	if !suffixed {
+		return ""
+	}
+	if IsMarkdownFile(neutral) {
+		neutral = strings.TrimSuffix(neutral, path.Ext(neutral)) + ".html"
+	}
+	return path.Join(prefix, neutral)
+}
+
+// withLang returns the permalink rules with their :lang placeholders replaced by lang
+func (p Permalinks) withLang(lang string) Permalinks {
+	replaced := make(Permalinks, len(p))
+	for dir, pattern := range p {
+		replaced[dir] = permalinkTokenRegex.ReplaceAllStringFunc(pattern, func(token string) string {
+			if token == ":lang" {
+				return lang
+			}
+			return token
+		})
+	}
+	return replaced
+}
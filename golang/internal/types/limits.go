@@ -0,0 +1,42 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrPageLimit marks a page that ran past page_timeout or max_page_size
+var ErrPageLimit = errors.New("page limit exceeded")
+
+// PageLimits bound the processing of one page. The processing steps check them as the
+// page grows, so a page that runs away stops at the next check instead of at the end.
+type PageLimits struct {
+	Deadline time.Time     // When the page must be done by, zero for no page_timeout
+	Timeout  time.Duration // The page_timeout the deadline was set from
+	MaxSize  int           // max_page_size in MB, 0 for no limit
+}
+
+// Check fails once the deadline has passed or a page of size bytes is over MaxSize.
+// Nil limits never fail, so pages processed outside a build aren't limited.
+func (l *PageLimits) Check(size int) error {
+	if l == nil {
+		return nil
+	}
+	if l.MaxSize > 0 && size > l.MaxSize<<20 {
+		return fmt.Errorf("%w: page grew to %.1f MB (max_page_size is %d MB)", ErrPageLimit, float64(size)/(1<<20), l.MaxSize)
+	}
+	if !l.Deadline.IsZero() && time.Now().After(l.Deadline) {
+		return fmt.Errorf("%w: processing took longer than %s (page_timeout)", ErrPageLimit, l.Timeout)
+	}
+	return nil
+}
+
+// ContentSize returns the size of a page's content lines in bytes
+func ContentSize(lines []string) int {
+	size := 0
+	for _, line := range lines {
+		size += len(line) + 1
+	}
+	return size
+}
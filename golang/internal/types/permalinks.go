@@ -0,0 +1,126 @@
+package types
+
+import (
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// permalinkTokenRegex matches :name placeholders in a permalink pattern
+var permalinkTokenRegex = regexp.MustCompile(`:([a-z_]+)`)
+
+// slugUnsafeRegex matches runs of characters that don't belong in a URL slug
+var slugUnsafeRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Slugify lowercases text and joins its words with dashes
+func Slugify(text string) string {
+	return strings.Trim(slugUnsafeRegex.ReplaceAllString(strings.ToLower(text), "-"), "-")
+}
+
+// Permalinks maps source directories (relative to the input directory) to output URL
+// patterns such as "/:year/:month/:slug/". Supported placeholders are :year, :month,
+// :day, :slug, :title, :name and :path, and :lang through Languages.Permalink. A pattern
+// ending in a slash produces a pretty URL directory holding an index.html.
+type Permalinks map[string]string
+
+// Resolve returns the permalink for the page at relPath (relative to the input
+// directory, including the filename), without a leading slash. It returns "" when no
+// rule covers the page or the page lacks a date the pattern needs, in which case the
+// page keeps its source path. The date is read with dates, in the project's time zone.
+func (p Permalinks) Resolve(relPath string, metadata map[string]interface{}, dates Dates) string {
+	relPath = filepath.ToSlash(relPath)
+
+	// Find the most specific directory rule for this page
+	dir := path.Dir(relPath)
+	prefix, pattern := "", ""
+	matched := false
+	for key, value := range p {
+		key = strings.Trim(filepath.ToSlash(key), "/")
+		if key != "" && dir != key && !strings.HasPrefix(dir, key+"/") {
+			continue
+		}
+		if !matched || len(key) > len(prefix) {
+			prefix, pattern, matched = key, value, true
+		}
+	}
+	if !matched {
+		return ""
+	}
+
+	name := path.Base(relPath)
+	name = strings.TrimSuffix(name, path.Ext(name))
+
+	subdir := strings.TrimPrefix(strings.TrimPrefix(dir, prefix), "/")
+	if subdir == "." {
+		subdir = ""
+	}
+
+	slug := Slugify(name)
+	if value, ok := metadata["slug"].(string); ok && value != "" {
+		slug = value
+	}
+	title := slug
+	if value, ok := metadata["title"].(string); ok && Slugify(value) != "" {
+		title = Slugify(value)
+	}
+
+	var date time.Time
+	hasDate := false
+	if value, ok := metadata["date"].(string); ok {
+		date, hasDate = dates.Parse(value)
+	}
+
+	missingDate := false
+	result := permalinkTokenRegex.ReplaceAllStringFunc(pattern, func(token string) string {
+		switch token[1:] {
+		case "year", "month", "day":
+			if !hasDate {
+				missingDate = true
+				return ""
+			}
+			switch token[1:] {
+			case "year":
+				return date.Format("2006")
+			case "month":
+				return date.Format("01")
+			}
+			return date.Format("02")
+		case "slug":
+			return slug
+		case "title":
+			return title
+		case "name":
+			return name
+		case "path":
+			return subdir
+		}
+		return token
+	})
+	if missingDate {
+		return ""
+	}
+
+	// Tidy up slashes left behind by empty placeholders
+	prettyURL := strings.HasSuffix(result, "/")
+	result = strings.Trim(path.Clean("/"+result), "/")
+	if result == "" {
+		return ""
+	}
+	if prettyURL {
+		return result + "/"
+	}
+	if path.Ext(result) == "" {
+		result += ".html"
+	}
+	return result
+}
+
+// PermalinkFile returns the output file for a permalink, adding index.html to pretty URL directories
+func PermalinkFile(permalink string) string {
+	if strings.HasSuffix(permalink, "/") {
+		return permalink + "index.html"
+	}
+	return permalink
+}
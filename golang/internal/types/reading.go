@@ -0,0 +1,53 @@
+package types
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// WordsPerMinute is the reading speed {{reading_time}} assumes
+const WordsPerMinute = 200
+
+var (
+	// commentRegex matches HTML comments, which is where directives live
+	commentRegex = regexp.MustCompile(`(?s)<!--.*?-->`)
+
+	// tagRegex matches an HTML tag
+	tagRegex = regexp.MustCompile(`(?s)<[^>]*>`)
+
+	// variableRegex matches a variable reference, whose value isn't known yet
+	variableRegex = regexp.MustCompile(`\{\{[^}]*\}\}`)
+)
+
+// CountWords returns how many words a page's source has, leaving out directives, HTML
+// tags, variables and markdown markup such as # and -
+func CountWords(lines []string) int {
+	text := strings.Join(lines, "\n")
+	text = commentRegex.ReplaceAllString(text, " ")
+	text = tagRegex.ReplaceAllString(text, " ")
+	text = variableRegex.ReplaceAllString(text, " ")
+
+	count := 0
+	for _, field := range strings.Fields(text) {
+		if strings.IndexFunc(field, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) >= 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// AddReadingTime sets a page's word_count from its source, without frontmatter, and its
+// reading_time in whole minutes at WordsPerMinute, at least 1 for a page with any words.
+// Frontmatter that sets either wins.
+func AddReadingTime(lines []string, metadata map[string]interface{}) {
+	words := CountWords(lines)
+	minutes := (words + WordsPerMinute - 1) / WordsPerMinute
+	if _, exists := metadata["word_count"]; !exists {
+		metadata["word_count"] = strconv.Itoa(words)
+	}
+	if _, exists := metadata["reading_time"]; !exists {
+		metadata["reading_time"] = strconv.Itoa(minutes)
+	}
+}
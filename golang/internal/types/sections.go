@@ -0,0 +1,32 @@
+package types
+
+import (
+	"path"
+	"strings"
+)
+
+// Section sets a page's section to the top folder of its path (relative to the input
+// directory) and its dir to the whole folder, both without a language folder and empty
+// for pages at the root. section.<name> is set to "true" as well, so templates can use
+// conditionals like <!-- if section.blog -->. Frontmatter and directory defaults that set
+// section or dir win.
+func (l Languages) Section(relPath string, metadata map[string]interface{}) {
+	_, neutral, _ := l.Split(relPath)
+	dir := path.Dir(neutral)
+	if dir == "." {
+		dir = ""
+	}
+	section, _, _ := strings.Cut(dir, "/")
+
+	if value, _ := metadata["dir"].(string); value == "" {
+		metadata["dir"] = dir
+	}
+	if value, _ := metadata["section"].(string); value == "" {
+		metadata["section"] = section
+	} else {
+		section = value
+	}
+	if section != "" {
+		metadata["section."+section] = "true"
+	}
+}
@@ -1,26 +1,37 @@
 package watcher
 
 import (
-	"log"
 	"sync"
+	"time"
+
+	"sniplicity/internal/logging"
 )
 
+// fileWatcher is a running watcher, driven by file system events or by polling
+type fileWatcher interface {
+	SetFiles(files []string)
+	Close() error
+}
+
 // Manager handles starting, stopping, and switching file watchers
 type Manager struct {
 	mu      sync.Mutex
-	watcher *Watcher
-	callback func()
+	watcher fileWatcher
+	callback func(removed []string)
+	files   []string // Single files watched along with the directory
+	pollInterval time.Duration // How often to scan instead of waiting for events, 0 for events
 }
 
 // NewManager creates a new watcher manager
-func NewManager(callback func()) *Manager {
+func NewManager(callback func(removed []string)) *Manager {
 	return &Manager{
 		callback: callback,
 	}
 }
 
-// Start starts watching the given directory
-func (m *Manager) Start(watchDir string) error {
+// Start starts watching the given directory, leaving out files and folders that ignore
+// reports
+func (m *Manager) Start(watchDir string, ignore func(path string) bool) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	
@@ -36,19 +47,51 @@ func (m *Manager) Start(watchDir string) error {
 	}
 	
 	// Create new watcher
-	w, err := New(watchDir, m.callback)
+	var w fileWatcher
+	var err error
+	if m.pollInterval > 0 {
+		w, err = NewPoller(watchDir, m.pollInterval, ignore, m.callback)
+	} else {
+		w, err = New(watchDir, ignore, m.callback)
+	}
 	if err != nil {
 		return err
 	}
 	
+	w.SetFiles(m.files)
 	m.watcher = w
-	log.Printf("File watcher started for: %s", watchDir)
+	if m.pollInterval > 0 {
+		logging.Infof("File watcher started for: %s (polling every %s)", watchDir, m.pollInterval)
+	} else {
+		logging.Infof("File watcher started for: %s", watchDir)
+	}
 	return nil
 }
 
+// SetPollInterval makes watchers started from now on scan the directory at this interval
+// instead of relying on file system events, or go back to events with 0
+func (m *Manager) SetPollInterval(interval time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pollInterval = interval
+}
+
 // Switch switches to watching a new directory
-func (m *Manager) Switch(newDir string) error {
-	return m.Start(newDir) // Start() already handles stopping the old one
+func (m *Manager) Switch(newDir string, ignore func(path string) bool) error {
+	return m.Start(newDir, ignore) // Start() already handles stopping the old one
+}
+
+// SetFiles sets the single files outside the watched directory whose changes also trigger
+// a rebuild, such as the project config and includes from elsewhere. They are kept for any
+// watcher started later.
+func (m *Manager) SetFiles(files []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.files = files
+	if m.watcher != nil {
+		m.watcher.SetFiles(files)
+	}
 }
 
 // Stop stops the current watcher
@@ -59,7 +102,7 @@ func (m *Manager) Stop() {
 	if m.watcher != nil {
 		m.watcher.Close()
 		m.watcher = nil
-		log.Printf("File watcher stopped")
+		logging.Infof("File watcher stopped")
 	}
 }
 
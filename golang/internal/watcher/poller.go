@@ -0,0 +1,177 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"sniplicity/internal/logging"
+)
+
+// fileState is what a Poller compares between scans to spot a change
+type fileState struct {
+	modTime time.Time
+	size    int64
+	dir     bool
+}
+
+// Poller watches a folder by scanning it at a fixed interval instead of waiting for file
+// system events, which some network drives and container bind mounts never deliver
+type Poller struct {
+	root     string
+	ignore   func(path string) bool
+	callback func(removed []string)
+	interval time.Duration
+	mu       sync.Mutex
+	files    []string             // Single files watched outside root, like the project config
+	state    map[string]fileState // Everything seen by the last scan, by path, guarded by mu
+	stop     chan struct{}
+}
+
+// NewPoller creates a watcher that scans watchDir every interval. Like New, files and
+// folders that ignore reports never trigger the callback, and the callback gets the
+// files and folders that disappeared since it was last called.
+func NewPoller(watchDir string, interval time.Duration, ignore func(path string) bool, callback func(removed []string)) (*Poller, error) {
+	if _, err := os.Stat(watchDir); err != nil {
+		return nil, fmt.Errorf("cannot add watch directory: %w", err)
+	}
+
+	p := &Poller{
+		root:     filepath.Clean(watchDir),
+		ignore:   ignore,
+		callback: callback,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+	p.state = p.scan()
+
+	go p.pollLoop()
+
+	return p, nil
+}
+
+// SetFiles sets the single files outside the watched folder whose changes also trigger
+// the callback. Files new to the list are recorded as they are now, so adding them isn't
+// taken for a change.
+func (p *Poller) SetFiles(files []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	listed := make(map[string]bool)
+	for _, file := range files {
+		file = filepath.Clean(file)
+		listed[file] = true
+		if _, ok := p.state[file]; ok || within(file, p.root) {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil {
+			p.state[file] = fileState{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	for _, file := range p.files {
+		if file = filepath.Clean(file); !listed[file] {
+			delete(p.state, file)
+		}
+	}
+	p.files = files
+}
+
+// Close stops the poller. A callback already running is left to finish.
+func (p *Poller) Close() error {
+	close(p.stop)
+	return nil
+}
+
+// scan records the state of every file and folder under root that isn't ignored, and of
+// the single files
+func (p *Poller) scan() map[string]fileState {
+	state := make(map[string]fileState)
+	filepath.Walk(p.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Gone since the folder was read, or unreadable: treat it as missing
+		}
+		if path != p.root && p.ignore != nil && p.ignore(path) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		state[path] = fileState{modTime: info.ModTime(), size: info.Size(), dir: info.IsDir()}
+		return nil
+	})
+
+	p.mu.Lock()
+	files := p.files
+	p.mu.Unlock()
+	for _, file := range files {
+		file = filepath.Clean(file)
+		if within(file, p.root) {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil {
+			state[file] = fileState{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return state
+}
+
+// pollLoop scans at every interval and runs the callback when anything changed
+func (p *Poller) pollLoop() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+
+		state := p.scan()
+		p.mu.Lock()
+		changed, removed := compareStates(p.state, state)
+		p.state = state
+		p.mu.Unlock()
+		select {
+		case <-p.stop:
+			return // Closed while scanning
+		default:
+		}
+		if changed {
+			logging.Debugf("Polling found changes in %s", p.root)
+			p.callback(removed)
+		}
+	}
+}
+
+// compareStates reports whether anything was added, changed or removed between two
+// scans, along with the removed paths. A removed folder stands for everything in it.
+func compareStates(before, after map[string]fileState) (bool, []string) {
+	changed := false
+	for path, state := range after {
+		previous, ok := before[path]
+		if !ok || !previous.modTime.Equal(state.modTime) || previous.size != state.size || previous.dir != state.dir {
+			changed = true
+			break
+		}
+	}
+
+	var removed []string
+	for path := range before {
+		if _, ok := after[path]; ok {
+			continue
+		}
+		parent := filepath.Dir(path)
+		if _, wasThere := before[parent]; wasThere {
+			if _, still := after[parent]; !still {
+				continue // Its folder went too and is listed instead
+			}
+		}
+		removed = append(removed, path)
+	}
+	sort.Strings(removed)
+	return changed || len(removed) > 0, removed
+}
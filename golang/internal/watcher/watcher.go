@@ -2,24 +2,35 @@ package watcher
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"sniplicity/internal/logging"
+
 	"github.com/fsnotify/fsnotify"
 )
 
 // Watcher handles file system watching
 type Watcher struct {
 	watcher  *fsnotify.Watcher
-	callback func()
+	root     string                 // The folder watched with everything inside it
+	ignore   func(path string) bool // Reports files and folders whose changes are ignored
+	callback func(removed []string)
 	debounce time.Duration
 	timer    *time.Timer
+	mu       sync.Mutex
+	removed  []string        // Paths deleted or renamed away since the last callback
+	files    map[string]bool // Single files watched outside root, like the project config
+	fileDirs map[string]bool // The folders watched for those files
 }
 
-// New creates a new file watcher
-func New(watchDir string, callback func()) (*Watcher, error) {
+// New creates a new file watcher. Files and folders that ignore reports, which may be nil,
+// never trigger the callback. The callback gets the files and folders that were deleted or
+// renamed since it was last called, so their outputs can be removed.
+func New(watchDir string, ignore func(path string) bool, callback func(removed []string)) (*Watcher, error) {
 	fsWatcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, fmt.Errorf("cannot create file watcher: %w", err)
@@ -27,21 +38,14 @@ func New(watchDir string, callback func()) (*Watcher, error) {
 
 	w := &Watcher{
 		watcher:  fsWatcher,
+		root:     filepath.Clean(watchDir),
+		ignore:   ignore,
 		callback: callback,
 		debounce: 500 * time.Millisecond, // Debounce multiple events
 	}
 
 	// Add the directory to watch
-	err = filepath.Walk(watchDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			return fsWatcher.Add(path)
-		}
-		return nil
-	})
-	if err != nil {
+	if err := w.addTree(watchDir); err != nil {
 		fsWatcher.Close()
 		return nil, fmt.Errorf("cannot add watch directory: %w", err)
 	}
@@ -52,14 +56,103 @@ func New(watchDir string, callback func()) (*Watcher, error) {
 	return w, nil
 }
 
+// addTree watches dir and every folder inside it that isn't ignored
+func (w *Watcher) addTree(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != dir && w.ignored(path) {
+			return filepath.SkipDir
+		}
+		return w.watcher.Add(path)
+	})
+}
+
+// SetFiles sets the single files outside the watched folder whose changes also trigger the
+// callback. Their folders are watched rather than the files themselves, as many editors
+// save by replacing a file, which would drop a watch on the file.
+func (w *Watcher) SetFiles(files []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.files = make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, file := range files {
+		file = filepath.Clean(file)
+		if within(file, w.root) {
+			continue
+		}
+		w.files[file] = true
+		dirs[filepath.Dir(file)] = true
+	}
+
+	for dir := range w.fileDirs {
+		if !dirs[dir] {
+			w.watcher.Remove(dir)
+		}
+	}
+	for dir := range dirs {
+		if w.fileDirs[dir] {
+			continue
+		}
+		if err := w.watcher.Add(dir); err != nil {
+			logging.Debugf("Cannot watch %s: %v", dir, err)
+			delete(dirs, dir)
+		}
+	}
+	w.fileDirs = dirs
+}
+
+// ignored reports whether changes to path should be ignored
+func (w *Watcher) ignored(path string) bool {
+	if !within(path, w.root) {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return !w.files[filepath.Clean(path)] // Only the single files count out there
+	}
+	return w.ignore != nil && w.ignore(path)
+}
+
+// within reports whether path is dir or somewhere inside it
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
 // Close stops the watcher
 func (w *Watcher) Close() error {
+	w.mu.Lock()
 	if w.timer != nil {
 		w.timer.Stop()
 	}
+	w.mu.Unlock()
 	return w.watcher.Close()
 }
 
+// schedule runs the callback once events have stopped arriving for the debounce period
+func (w *Watcher) schedule(removed string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if removed != "" {
+		w.removed = append(w.removed, removed)
+	}
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.timer = time.AfterFunc(w.debounce, func() {
+		w.mu.Lock()
+		removed := w.removed
+		w.removed = nil
+		w.mu.Unlock()
+		w.callback(removed)
+	})
+}
+
 func (w *Watcher) watchLoop() {
 	for {
 		select {
@@ -67,21 +160,36 @@ func (w *Watcher) watchLoop() {
 			if !ok {
 				return
 			}
-			
-			// Only trigger on write and create events
-			if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create {
-				// Debounce: reset timer on each event
-				if w.timer != nil {
-					w.timer.Stop()
+			if w.ignored(event.Name) {
+				continue
+			}
+			if !within(event.Name, w.root) {
+				w.schedule("") // A single file such as the config, which has no outputs to remove
+				continue
+			}
+
+			switch {
+			case event.Has(fsnotify.Create):
+				// Watch new folders, including any made inside them before the watch was added
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := w.addTree(event.Name); err != nil {
+						logging.Warnf("Cannot watch %s: %v", event.Name, err)
+					}
 				}
-				w.timer = time.AfterFunc(w.debounce, w.callback)
+				w.schedule("")
+			case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+				// A renamed file shows up again as a Create under its new name
+				w.watcher.Remove(event.Name)
+				w.schedule(event.Name)
+			case event.Has(fsnotify.Write):
+				w.schedule("")
 			}
 
 		case err, ok := <-w.watcher.Errors:
 			if !ok {
 				return
 			}
-			log.Printf("Watch error: %v", err)
+			logging.Warnf("Watch error: %v", err)
 		}
 	}
 }
\ No newline at end of file
@@ -0,0 +1,90 @@
+package web
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// authCookie remembers an access token given in the URL, so links and reloads keep working
+const authCookie = "sniplicity_token"
+
+// requireAuth asks for the credentials set by auth before passing requests on to next.
+// A token may be sent as a bearer token, as the password of basic auth, in the token
+// query parameter or in the cookie that parameter sets.
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.config.RequiresAuth() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if token := r.URL.Query().Get("token"); token != "" && s.validToken(token) {
+			// Swap the token in the URL for a cookie, so it doesn't stay in the address bar
+			http.SetCookie(w, &http.Cookie{
+				Name:     authCookie,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   s.config.UsesTLS(),
+				SameSite: http.SameSiteLaxMode,
+			})
+			query := r.URL.Query()
+			query.Del("token")
+			// A single leading slash keeps //host paths from leaving the server
+			target := url.URL{Path: "/" + strings.TrimLeft(r.URL.Path, "/"), RawQuery: query.Encode()}
+			http.Redirect(w, r, target.String(), http.StatusSeeOther)
+			return
+		}
+
+		if s.authorized(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("WWW-Authenticate", `Basic realm="sniplicity", charset="UTF-8"`)
+		http.Error(w, "Sign in to see this preview", http.StatusUnauthorized)
+	})
+}
+
+// authorized reports whether r carries the credentials set by auth
+func (s *Server) authorized(r *http.Request) bool {
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && s.validToken(bearer) {
+		return true
+	}
+	if cookie, err := r.Cookie(authCookie); err == nil && s.validToken(cookie.Value) {
+		return true
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	if s.validToken(password) {
+		return true
+	}
+	wantUsername, wantPassword := s.config.AuthCredentials()
+	if wantUsername == "" || wantPassword == "" {
+		return false
+	}
+	// Compare both, so the time taken doesn't tell which one was wrong
+	usernameOK := subtle.ConstantTimeCompare([]byte(username), []byte(wantUsername)) == 1
+	passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(wantPassword)) == 1
+	return usernameOK && passwordOK
+}
+
+// validToken reports whether token is the access token set by auth
+func (s *Server) validToken(token string) bool {
+	want := s.config.AuthToken()
+	return want != "" && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1
+}
+
+// signInURL adds the access token to a URL of the web server, so opening it signs in
+func (s *Server) signInURL(serverURL string) string {
+	token := s.config.AuthToken()
+	if token == "" {
+		return serverURL
+	}
+	return serverURL + "?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,176 @@
+package web
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// assetMaxAge is how long browsers may reuse assets from the preview server without
+// asking again. Pages are always checked, so edits show up on reload.
+const assetMaxAge = 60
+
+// minCompressSize is the smallest response worth compressing
+const minCompressSize = 1024
+
+// compressibleTypes are the content types compressed on the fly, besides text/*
+var compressibleTypes = []string{
+	"application/javascript", "application/json", "application/manifest+json",
+	"application/xml", "application/rss+xml", "application/atom+xml",
+	"image/svg+xml", "application/wasm",
+}
+
+// compressWriter sets the preview server's cache headers on a response and gzips it when
+// the browser accepts that and the content is worth compressing
+type compressWriter struct {
+	http.ResponseWriter
+	request     *http.Request
+	gzip        *gzip.Writer
+	wroteHeader bool
+}
+
+// newCompressWriter wraps w for r. Close must be called once the response is written.
+func newCompressWriter(w http.ResponseWriter, r *http.Request) *compressWriter {
+	return &compressWriter{ResponseWriter: w, request: r}
+}
+
+// WriteHeader decides on caching and compression, once the content type is known
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	header := cw.Header()
+	contentType := header.Get("Content-Type")
+
+	if status != http.StatusOK && status != http.StatusPartialContent || strings.HasPrefix(contentType, "text/html") {
+		header.Set("Cache-Control", "no-cache")
+	} else {
+		header.Set("Cache-Control", "public, max-age="+strconv.Itoa(assetMaxAge))
+	}
+
+	if cw.shouldCompress(status, contentType) {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", "gzip")
+		header.Add("Vary", "Accept-Encoding")
+		if cw.request.Method != http.MethodHead {
+			cw.gzip = gzip.NewWriter(cw.ResponseWriter)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+// shouldCompress reports whether a response with the given status and type gets gzipped
+func (cw *compressWriter) shouldCompress(status int, contentType string) bool {
+	header := cw.Header()
+	if status != http.StatusOK && status != http.StatusNotFound {
+		return false // Ranges, redirects and not-modified responses go out as they are
+	}
+	if header.Get("Content-Encoding") != "" || !acceptsEncoding(cw.request, "gzip") {
+		return false
+	}
+	if size, err := strconv.Atoi(header.Get("Content-Length")); err == nil && size < minCompressSize {
+		return false
+	}
+	return compressible(contentType)
+}
+
+// Write writes the body, through gzip when compressing
+func (cw *compressWriter) Write(data []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(data))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.gzip != nil {
+		return cw.gzip.Write(data)
+	}
+	return cw.ResponseWriter.Write(data)
+}
+
+// Close finishes the gzip stream, if there is one
+func (cw *compressWriter) Close() error {
+	if cw.gzip == nil {
+		return nil
+	}
+	return cw.gzip.Close()
+}
+
+// compressible reports whether content of the given type shrinks when compressed
+func compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	for _, t := range compressibleTypes {
+		if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsEncoding reports whether the request's Accept-Encoding allows encoding
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(name) != encoding {
+			continue
+		}
+		return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+	}
+	return false
+}
+
+// precompressed are the encodings served from a sibling file the site's own build tools
+// made, such as page.html.br, in order of preference
+var precompressed = []struct{ encoding, ext string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// servePrecompressed serves a .br or .gz sibling of path when the browser accepts its
+// encoding, and reports whether it did
+func servePrecompressed(w http.ResponseWriter, r *http.Request, path string) bool {
+	if r.Header.Get("Range") != "" {
+		return false
+	}
+	for _, p := range precompressed {
+		if !acceptsEncoding(r, p.encoding) {
+			continue
+		}
+		file, err := os.Open(path + p.ext)
+		if err != nil {
+			continue
+		}
+		info, err := file.Stat()
+		if err != nil || info.IsDir() {
+			file.Close()
+			continue
+		}
+		defer file.Close()
+
+		contentType := mime.TypeByExtension(filepath.Ext(path))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Encoding", p.encoding)
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		// Last-Modified follows the page, not when it was compressed
+		modified := info.ModTime()
+		if original, err := os.Stat(path); err == nil {
+			modified = original.ModTime()
+		}
+		http.ServeContent(w, r, path, modified, file)
+		return true
+	}
+	return false
+}
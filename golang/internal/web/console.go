@@ -0,0 +1,118 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sniplicity/internal/buildlog"
+)
+
+// keepAlive is how often an idle build log stream sends a comment, so proxies and
+// browsers don't close it
+const keepAlive = 25 * time.Second
+
+// BuildLogResponse holds the output of one build
+type BuildLogResponse struct {
+	Build int             `json:"build"`
+	Lines []buildlog.Line `json:"lines"`
+}
+
+// SetBuildLog sets the log the build status and log API read from
+func (h *Handler) SetBuildLog(log *buildlog.Log) {
+	h.buildLog = log
+}
+
+// getBuildStatus reports whether a build is running and how the last one went
+func (h *Handler) getBuildStatus(w http.ResponseWriter, r *http.Request) {
+	if h.buildLog == nil {
+		http.Error(w, `{"error": "No project is open"}`, http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.buildLog.Status())
+}
+
+// getBuildLog returns the output of the current build, or of the one the build query
+// parameter asks for. A request that accepts text/event-stream gets the output as
+// server-sent events instead, followed by the output and status of every later build.
+func (h *Handler) getBuildLog(w http.ResponseWriter, r *http.Request) {
+	if h.buildLog == nil {
+		http.Error(w, `{"error": "No project is open"}`, http.StatusServiceUnavailable)
+		return
+	}
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		h.streamBuildLog(w, r)
+		return
+	}
+
+	build := 0
+	if value := r.URL.Query().Get("build"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			http.Error(w, `{"error": "build must be a build number"}`, http.StatusBadRequest)
+			return
+		}
+		build = n
+	}
+	if build == 0 {
+		build = h.buildLog.Status().Build
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BuildLogResponse{Build: build, Lines: h.buildLog.Lines(build)})
+}
+
+// streamBuildLog sends the build status as "status" events and each line of output as a
+// "line" event, until the client goes away
+func (h *Handler) streamBuildLog(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, `{"error": "Streaming is not supported"}`, http.StatusInternalServerError)
+		return
+	}
+	status, lines, events, cancel := h.buildLog.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	writeEvent(w, "status", status)
+	for _, line := range lines {
+		writeEvent(w, "line", line)
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Status != nil {
+				writeEvent(w, "status", event.Status)
+			}
+			if event.Line != nil {
+				writeEvent(w, "line", event.Line)
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes one server-sent event with data as JSON
+func writeEvent(w http.ResponseWriter, name string, data interface{}) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, encoded)
+}
@@ -0,0 +1,42 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// DeployResponse reports the outcome of a deploy API request
+type DeployResponse struct {
+	Success    bool   `json:"success"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// OnDeploy sets the function the deploy API builds and deploys the site with
+func (h *Handler) OnDeploy(deploy func() error) {
+	h.onDeploy = deploy
+}
+
+// deploy builds the site and runs the project's deploy step. Its output goes to the
+// build log, so the Build Console shows it as it happens. As the step may run a shell
+// command, only the web interface itself can ask for it, not curl or another site.
+func (h *Handler) deploy(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r, true) {
+		http.Error(w, `{"error": "Deploy only runs from the web interface"}`, http.StatusForbidden)
+		return
+	}
+	if h.onDeploy == nil || h.config.ProjectDir == "" {
+		http.Error(w, `{"error": "No project is open"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	start := time.Now()
+	err := h.onDeploy()
+	response := DeployResponse{Success: err == nil, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		response.Error = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,274 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+//go:embed files.html
+var filesHTML string
+
+// MaxEditSize is the largest file the editor opens, in bytes
+const MaxEditSize = 2 << 20
+
+// FileEntry is a file or folder in the input directory
+type FileEntry struct {
+	Name     string      `json:"name"`
+	Path     string      `json:"path"` // Relative to the input directory, with forward slashes
+	Dir      bool        `json:"dir"`
+	Size     int64       `json:"size,omitempty"`
+	Children []FileEntry `json:"children,omitempty"`
+}
+
+// FileContent is a source file opened in the editor. Hash identifies the version that was
+// read, so saving can tell when the file changed on disk in the meantime.
+type FileContent struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+	Hash    string `json:"hash"`
+}
+
+// SaveFileRequest writes a source file. Base is the hash it was opened with, or "" for a
+// new file or to overwrite whatever is there.
+type SaveFileRequest struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+	Base    string `json:"base"`
+}
+
+// SaveFileResponse reports a saved file and the rebuild it caused
+type SaveFileResponse struct {
+	Path    string `json:"path"`
+	Hash    string `json:"hash"`
+	Rebuild string `json:"rebuild"` // "watch" when the file watcher rebuilds, "build" when the save did, "" for neither
+	Success bool   `json:"success"` // Whether the rebuild the save ran succeeded
+	Error   string `json:"error,omitempty"`
+}
+
+// serveFiles serves the file browser and editor
+func (h *Handler) serveFiles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(filesHTML))
+}
+
+// listFiles returns the tree of files in the input directory, leaving out hidden ones
+func (h *Handler) listFiles(w http.ResponseWriter, r *http.Request) {
+	inputDir := h.config.GetAbsoluteInputDir()
+	if h.config.ProjectDir == "" {
+		http.Error(w, `{"error": "No project is open"}`, http.StatusServiceUnavailable)
+		return
+	}
+	tree, err := readTree(inputDir, "")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Cannot list files: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tree)
+}
+
+// readTree lists the folder rel inside root, folders first
+func readTree(root, rel string) ([]FileEntry, error) {
+	entries, err := os.ReadDir(filepath.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil, err
+	}
+	tree := []FileEntry{}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		item := FileEntry{Name: entry.Name(), Path: strings.TrimPrefix(rel+"/"+entry.Name(), "/"), Dir: entry.IsDir()}
+		if item.Dir {
+			if item.Children, err = readTree(root, item.Path); err != nil {
+				return nil, err
+			}
+		} else if info, err := entry.Info(); err == nil {
+			item.Size = info.Size()
+		}
+		tree = append(tree, item)
+	}
+	sort.SliceStable(tree, func(i, j int) bool { return tree[i].Dir && !tree[j].Dir })
+	return tree, nil
+}
+
+// sourcePath returns the absolute path of a file given relative to the input directory,
+// refusing paths that lead outside it or to hidden files. Symlinks are followed, so one
+// in the input directory can't be used to read or write a file elsewhere.
+func (h *Handler) sourcePath(rel string) (string, error) {
+	if h.config.ProjectDir == "" {
+		return "", fmt.Errorf("no project is open")
+	}
+	inputDir := h.config.GetAbsoluteInputDir()
+	full := filepath.Join(inputDir, filepath.FromSlash(rel))
+	inside, err := filepath.Rel(inputDir, full)
+	if rel == "" || err != nil || inside == "." || inside == ".." || strings.HasPrefix(inside, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%q is not a file in the input directory", rel)
+	}
+	for _, part := range strings.Split(inside, string(filepath.Separator)) {
+		if strings.HasPrefix(part, ".") {
+			return "", fmt.Errorf("%q is hidden", rel)
+		}
+	}
+
+	resolvedDir, err := filepath.EvalSymlinks(inputDir)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve the input directory: %w", err)
+	}
+	resolved, err := resolveExisting(full)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve %q: %w", rel, err)
+	}
+	inside, err = filepath.Rel(resolvedDir, resolved)
+	if err != nil || inside == "." || inside == ".." || strings.HasPrefix(inside, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%q is not a file in the input directory", rel)
+	}
+	return full, nil
+}
+
+// resolveExisting resolves the symlinks in path as far as it exists, so a file about to be
+// created is resolved through the folders it will be created in
+func resolveExisting(path string) (string, error) {
+	rest := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(path), rest)
+		path = parent
+	}
+}
+
+// getFile returns a source file's content for the editor
+func (h *Handler) getFile(w http.ResponseWriter, r *http.Request) {
+	rel := r.URL.Query().Get("path")
+	path, err := h.sourcePath(rel)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		writeError(w, http.StatusNotFound, "%s not found", rel)
+		return
+	}
+	if info.Size() > MaxEditSize {
+		writeError(w, http.StatusRequestEntityTooLarge, "%s is too large to edit here", rel)
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Cannot read %s: %v", rel, err)
+		return
+	}
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		writeError(w, http.StatusUnsupportedMediaType, "%s is not a text file", rel)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(FileContent{Path: rel, Content: string(data), Hash: contentHash(data)})
+}
+
+// saveFile writes a source file from the editor, creating it and its folders if needed,
+// and rebuilds unless the file watcher is going to
+func (h *Handler) saveFile(w http.ResponseWriter, r *http.Request) {
+	var req SaveFileRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxEditSize+4096)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON: %v", err)
+		return
+	}
+	path, err := h.sourcePath(req.Path)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	if req.Base != "" {
+		current, err := os.ReadFile(path)
+		if err == nil && contentHash(current) != req.Base {
+			writeError(w, http.StatusConflict, "%s changed on disk since it was opened", req.Path)
+			return
+		}
+	}
+	if err := writeFileAtomic(path, []byte(req.Content)); err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+
+	response := SaveFileResponse{Path: req.Path, Hash: contentHash([]byte(req.Content)), Success: true}
+	switch {
+	case h.config.Watch:
+		response.Rebuild = "watch"
+	case h.onBuild != nil:
+		response.Rebuild = "build"
+		if _, err := h.onBuild(BuildRequest{Full: true}); err != nil {
+			response.Success, response.Error = false, err.Error()
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeFileAtomic replaces path with data through a temporary file, so the watcher and
+// the build never see it half written
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating folder: %w", err)
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), ".sniplicity-*")
+	if err != nil {
+		return fmt.Errorf("saving %s: %w", filepath.Base(path), err)
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return fmt.Errorf("saving %s: %w", filepath.Base(path), err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("saving %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Chmod(temp.Name(), mode); err != nil {
+		return fmt.Errorf("saving %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return fmt.Errorf("saving %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// writeError sends an error response with its message encoded as JSON, as the message
+// can hold a path from the request
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	body, _ := json.Marshal(map[string]string{"error": fmt.Sprintf(format, args...)})
+	http.Error(w, string(body), status)
+}
+
+// contentHash identifies a version of a file's content
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,112 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sniplicity/internal/config"
+)
+
+// newFilesHandler returns a handler for a project in a temporary folder with an input
+// folder holding index.html, and a secret file beside the project
+func newFilesHandler(t *testing.T) (*Handler, string) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"project/src/index.html": "<p>Hello</p>",
+		"secret.txt":             "password",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := config.DefaultConfig()
+	cfg.ProjectDir = filepath.Join(dir, "project")
+	cfg.InputDir = "src"
+	cfg.OutputDir = "out"
+	return &Handler{config: &cfg}, dir
+}
+
+func TestSourcePathSymlinks(t *testing.T) {
+	h, dir := newFilesHandler(t)
+	inputDir := h.config.GetAbsoluteInputDir()
+	links := map[string]string{
+		"outside.txt": filepath.Join(dir, "secret.txt"),
+		"elsewhere":   dir,
+		"inside.html": filepath.Join(inputDir, "index.html"),
+		"pages":       inputDir,
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(inputDir, name)); err != nil {
+			t.Skipf("cannot create symlinks: %v", err)
+		}
+	}
+
+	for _, test := range []struct {
+		rel string
+		ok  bool
+	}{
+		{"index.html", true},
+		{"new/page.html", true},
+		{"inside.html", true},
+		{"pages/index.html", true},
+		{"pages/new.html", true},
+		{"../secret.txt", false},
+		{"outside.txt", false},
+		{"elsewhere/secret.txt", false},
+		{"elsewhere/new.txt", false},
+		{"elsewhere/new/deeper.txt", false},
+	} {
+		_, err := h.sourcePath(test.rel)
+		if (err == nil) != test.ok {
+			t.Errorf("sourcePath(%q) error = %v, want allowed %v", test.rel, err, test.ok)
+		}
+	}
+
+	// Neither reading nor saving goes through a symlink out of the input directory
+	w := httptest.NewRecorder()
+	h.getFile(w, httptest.NewRequest(http.MethodGet, "/sniplicity/api/files/content?path=outside.txt", nil))
+	if w.Code != http.StatusBadRequest || strings.Contains(w.Body.String(), "password") {
+		t.Errorf("reading through a symlink out of the input directory gave %d %q", w.Code, w.Body.String())
+	}
+	body := `{"path": "elsewhere/secret.txt", "content": "overwritten"}`
+	w = httptest.NewRecorder()
+	h.saveFile(w, httptest.NewRequest(http.MethodPost, "/sniplicity/api/files/content", strings.NewReader(body)))
+	if data, _ := os.ReadFile(filepath.Join(dir, "secret.txt")); w.Code != http.StatusBadRequest || string(data) != "password" {
+		t.Errorf("saving through a symlink out of the input directory gave %d and left %q", w.Code, data)
+	}
+}
+
+func TestFileErrorsAreJSON(t *testing.T) {
+	h, _ := newFilesHandler(t)
+	for _, rel := range []string{`missing "quoted".html`, `back\slash.html`, "../outside.html"} {
+		w := httptest.NewRecorder()
+		h.getFile(w, httptest.NewRequest(http.MethodGet, "/sniplicity/api/files/content?path="+url.QueryEscape(rel), nil))
+		var response struct{ Error string }
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Error == "" {
+			t.Errorf("getFile(%q) answered %q, not a JSON error: %v", rel, w.Body.String(), err)
+		}
+	}
+
+	// A conflicting save names the file in its error
+	name := `say "hi".html`
+	if err := os.WriteFile(filepath.Join(h.config.GetAbsoluteInputDir(), name), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(SaveFileRequest{Path: name, Content: "new", Base: "stale"})
+	w := httptest.NewRecorder()
+	h.saveFile(w, httptest.NewRequest(http.MethodPost, "/sniplicity/api/files/content", strings.NewReader(string(body))))
+	var response struct{ Error string }
+	if err := json.Unmarshal(w.Body.Bytes(), &response); w.Code != http.StatusConflict || err != nil || !strings.Contains(response.Error, name) {
+		t.Errorf("saveFile answered %d %q, want a JSON conflict error naming %s: %v", w.Code, w.Body.String(), name, err)
+	}
+}
@@ -4,14 +4,20 @@ import (
 	_ "embed"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"sniplicity/internal/buildlog"
 	"sniplicity/internal/config"
+	"sniplicity/internal/diag"
+	"sniplicity/internal/linkcheck"
 	"sniplicity/internal/projects"
+	"sniplicity/internal/scaffold"
 )
 
 //go:embed ui.html
@@ -33,6 +39,10 @@ type Handler struct {
 	appDir         string                         // Directory where the app executable is located
 	onConfigSave   func(*config.Config) error     // Callback for when config is saved
 	onProjectSwitch func(string) error            // Callback for when project is switched
+	onBuild        func(BuildRequest) ([]diag.Diagnostic, error) // Runs a build for the build API, may be nil
+	buildLog       *buildlog.Log                  // Status and output of the builds, may be nil
+	inventory      func() (Inventory, error)      // Lists the site's snippets, templates and globals, may be nil
+	onDeploy       func() error                   // Builds and deploys the site, may be nil
 }
 
 // NewHandler creates a new web interface handler
@@ -60,12 +70,19 @@ func NewHandler(cfg *config.Config, onConfigSave func(*config.Config) error, onP
 // ServeHTTP handles all /sniplicity routes
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/sniplicity")
+	if r.Method == http.MethodPost && !checkPost(w, r) {
+		return
+	}
 	
 	switch {
 	case path == "" || path == "/":
 		h.serveProjectSelector(w, r)
 	case path == "-project" || path == "-project/":
 		h.serveUI(w, r)
+	case path == "/inventory" || path == "/inventory/":
+		h.serveInventory(w, r)
+	case path == "/files" || path == "/files/":
+		h.serveFiles(w, r)
 	case path == "/css":
 		h.serveCSS(w, r)
 	case path == "/custom.css":
@@ -76,12 +93,32 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.getConfig(w, r)
 	case path == "/api/config" && r.Method == "POST":
 		h.saveConfig(w, r)
+	case path == "/api/build" && r.Method == "POST":
+		h.build(w, r)
+	case path == "/api/build/status" && r.Method == "GET":
+		h.getBuildStatus(w, r)
+	case path == "/api/build/log" && r.Method == "GET":
+		h.getBuildLog(w, r)
+	case path == "/api/deploy" && r.Method == "POST":
+		h.deploy(w, r)
+	case path == "/api/inventory" && r.Method == "GET":
+		h.getInventory(w, r)
+	case path == "/api/files" && r.Method == "GET":
+		h.listFiles(w, r)
+	case path == "/api/files/content" && r.Method == "GET":
+		h.getFile(w, r)
+	case path == "/api/files/content" && r.Method == "POST":
+		h.saveFile(w, r)
+	case path == "/api/links" && r.Method == "GET":
+		h.getLinkReport(w, r)
 	case path == "/api/projects" && r.Method == "GET":
 		h.getProjects(w, r)
 	case path == "/api/projects/switch" && r.Method == "POST":
 		h.switchProject(w, r)
 	case path == "/api/projects/add" && r.Method == "POST":
 		h.addProject(w, r)
+	case path == "/api/projects/create" && r.Method == "POST":
+		h.createProject(w, r)
 	case path == "/api/projects/remove" && r.Method == "POST":
 		h.removeProject(w, r)
 	case path == "/api/projects/validate" && r.Method == "POST":
@@ -158,24 +195,20 @@ func (h *Handler) getNetworkInfo(w http.ResponseWriter, r *http.Request) {
 	localIP := getLocalIP()
 	port := h.config.Port
 	
-	// Default to HTTP for local development
-	protocol := "http"
-	// Only use HTTPS if explicitly detected from request
+	// HTTP for local development unless the server was started with TLS
+	protocol := h.config.ServerProtocol()
 	if r.TLS != nil {
 		protocol = "https"
 	}
 	
+	localhostURL, networkURL := h.config.ServerURLs(protocol, localIP)
 	response := NetworkInfoResponse{
-		LocalhostURL: fmt.Sprintf("%s://127.0.0.1:%d", protocol, port),
-		NetworkURL:   "",
+		LocalhostURL: localhostURL,
+		NetworkURL:   networkURL, // Empty unless the server listens beyond this machine
 		LocalIP:      localIP,
 		Port:         port,
 	}
 	
-	if localIP != "" {
-		response.NetworkURL = fmt.Sprintf("%s://%s:%d", protocol, localIP, port)
-	}
-	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
@@ -191,6 +224,8 @@ type ConfigResponse struct {
 	Serve      bool   `json:"serve"`
 	Verbose    bool   `json:"verbose"`
 	ImgSize    bool   `json:"imgsize"`
+	CheckLinks bool   `json:"check_links"`
+	Deploy     bool   `json:"deploy"` // Whether sniplicity.yaml says how to deploy the site
 }
 
 // getConfig returns the current configuration as JSON
@@ -205,12 +240,126 @@ func (h *Handler) getConfig(w http.ResponseWriter, r *http.Request) {
 		Serve:      h.config.Serve,
 		Verbose:    h.config.Verbose,
 		ImgSize:    h.config.ImgSize,
+		CheckLinks: h.config.CheckLinks,
+		Deploy:     h.config.CheckDeploy() == nil,
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// LinkReportResponse lists the broken internal links in the current output directory
+type LinkReportResponse struct {
+	OutputDir string                 `json:"output_dir"`
+	Broken    []linkcheck.BrokenLink `json:"broken"`
+}
+
+// getLinkReport checks the generated site for broken internal links
+func (h *Handler) getLinkReport(w http.ResponseWriter, r *http.Request) {
+	outputDir := h.config.GetAbsoluteOutputDir()
+	broken, err := linkcheck.Check(outputDir)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to check links: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	if broken == nil {
+		broken = []linkcheck.BrokenLink{}
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(LinkReportResponse{OutputDir: outputDir, Broken: broken})
+}
+
+// BuildRequest selects how much of the site a build API request rebuilds. An empty
+// request is a full build.
+type BuildRequest struct {
+	Full       bool     `json:"full"`
+	Paths      []string `json:"paths"`       // Pages relative to the input directory
+	AssetsOnly bool     `json:"assets_only"` // Copy assets without building pages
+}
+
+// scope names what the request builds, or returns an error when it asks for more than one
+func (req BuildRequest) scope() (string, error) {
+	var scopes []string
+	if req.Full {
+		scopes = append(scopes, "full")
+	}
+	if len(req.Paths) > 0 {
+		scopes = append(scopes, "paths")
+	}
+	if req.AssetsOnly {
+		scopes = append(scopes, "assets")
+	}
+	switch len(scopes) {
+	case 0:
+		return "full", nil
+	case 1:
+		return scopes[0], nil
+	}
+	return "", fmt.Errorf("choose one of full, paths and assets_only")
+}
+
+// BuildResponse reports the outcome of a build API request
+type BuildResponse struct {
+	Success     bool              `json:"success"`
+	Scope       string            `json:"scope"`
+	DurationMS  int64             `json:"duration_ms"`
+	Errors      int               `json:"errors"`
+	Warnings    int               `json:"warnings"`
+	Diagnostics []diag.Diagnostic `json:"diagnostics"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// OnBuild sets the function the build API runs builds with
+func (h *Handler) OnBuild(build func(BuildRequest) ([]diag.Diagnostic, error)) {
+	h.onBuild = build
+}
+
+// build runs a full or partial build for the web interface and external tools, waiting
+// for any build already running to finish first
+func (h *Handler) build(w http.ResponseWriter, r *http.Request) {
+	if h.onBuild == nil {
+		http.Error(w, `{"error": "No project is open"}`, http.StatusServiceUnavailable)
+		return
+	}
+	var req BuildRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, fmt.Sprintf(`{"error": "Invalid JSON: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	scope, err := req.scope()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	start := time.Now()
+	diagnostics, err := h.onBuild(req)
+	response := BuildResponse{
+		Success:     err == nil,
+		Scope:       scope,
+		DurationMS:  time.Since(start).Milliseconds(),
+		Diagnostics: diagnostics,
+	}
+	if response.Diagnostics == nil {
+		response.Diagnostics = []diag.Diagnostic{}
+	}
+	for _, d := range diagnostics {
+		switch d.Level {
+		case diag.Error:
+			response.Errors++
+		case diag.Warning:
+			response.Warnings++
+		}
+	}
+	if err != nil {
+		response.Error = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // ConfigRequest represents the configuration data received from the client
 type ConfigRequest struct {
 	Name      string `json:"name"`
@@ -221,6 +370,7 @@ type ConfigRequest struct {
 	Serve     bool   `json:"serve"`
 	Verbose   bool   `json:"verbose"`
 	ImgSize   bool   `json:"imgsize"`
+	CheckLinks bool  `json:"check_links"`
 }
 
 // saveConfig updates the configuration from the web interface
@@ -251,6 +401,7 @@ func (h *Handler) saveConfig(w http.ResponseWriter, r *http.Request) {
 	h.config.Serve = req.Serve
 	h.config.Verbose = req.Verbose
 	h.config.ImgSize = req.ImgSize
+	h.config.CheckLinks = req.CheckLinks
 	
 	// Save to file
 	if err := h.config.SaveConfigToFile(); err != nil {
@@ -286,6 +437,7 @@ type ProjectsResponse struct {
 	CurrentProject     *ProjectInfo  `json:"current_project,omitempty"`
 	RecentProjects     []ProjectInfo `json:"recent_projects"`
 	DefaultProjectPath string        `json:"default_project_path,omitempty"`
+	Themes             []string      `json:"themes"` // Starters a new project can be created from
 }
 
 // ProjectInfo represents project information for the client
@@ -351,6 +503,7 @@ func (h *Handler) getProjects(w http.ResponseWriter, r *http.Request) {
 		CurrentProject:     currentProject,
 		RecentProjects:     recentProjectsInfo,
 		DefaultProjectPath: defaultProjectPath,
+		Themes:             scaffold.Themes,
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
@@ -360,6 +513,8 @@ func (h *Handler) getProjects(w http.ResponseWriter, r *http.Request) {
 // ProjectRequest represents project operations from the client
 type ProjectRequest struct {
 	ProjectPath string `json:"project_path"`
+	Theme       string `json:"theme,omitempty"` // Starter for createProject, default basic
+	Name        string `json:"name,omitempty"`  // Name for createProject, default the folder's name
 }
 
 // switchProject switches to a different project
@@ -438,6 +593,51 @@ func (h *Handler) addProject(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// createProject scaffolds a starter project (like `sniplicity init`) and adds it to the recent projects list
+func (h *Handler) createProject(w http.ResponseWriter, r *http.Request) {
+	var req ProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Invalid JSON: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	
+	if req.ProjectPath == "" {
+		http.Error(w, `{"error": "Project path is required"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Theme == "" {
+		req.Theme = "basic"
+	}
+	
+	projectPath, err := filepath.Abs(req.ProjectPath)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Invalid project path: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	
+	created, err := scaffold.CreateNamed(projectPath, req.Theme, req.Name)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": %q}`, "Failed to create project: "+err.Error()), http.StatusBadRequest)
+		return
+	}
+	
+	// Add to recent projects
+	if err := h.recentProjects.AddProject(projectPath); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to add project: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	
+	// Return success, with the full path for the client to switch to
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Project created successfully",
+		"path":    projectPath,
+		"created": created,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
 // removeProject removes a project from the recent projects list
 func (h *Handler) removeProject(w http.ResponseWriter, r *http.Request) {
 	var req ProjectRequest
@@ -0,0 +1,55 @@
+package web
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+//go:embed inventory.html
+var inventoryHTML string
+
+// Definition is a snippet, template or global, where it is defined and the pages using it
+type Definition struct {
+	Name    string   `json:"name"`
+	Path    string   `json:"path,omitempty"`    // File defining it, relative to the input directory when inside it
+	Line    int      `json:"line,omitempty"`    // 1-based line of the definition, 0 when unknown
+	Value   string   `json:"value,omitempty"`   // Value of a global
+	Missing bool     `json:"missing,omitempty"` // Used by a page but defined nowhere
+	UsedBy  []string `json:"used_by"`           // Pages using it, directly or through templates, snippets and includes
+}
+
+// Inventory lists everything the site defines for its pages to use
+type Inventory struct {
+	Snippets  []Definition `json:"snippets"`
+	Templates []Definition `json:"templates"`
+	Globals   []Definition `json:"globals"`
+}
+
+// OnInventory sets the function the inventory API lists the site's definitions with
+func (h *Handler) OnInventory(inventory func() (Inventory, error)) {
+	h.inventory = inventory
+}
+
+// serveInventory serves the snippet, template and global inspector
+func (h *Handler) serveInventory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(inventoryHTML))
+}
+
+// getInventory returns the site's snippets, templates and globals
+func (h *Handler) getInventory(w http.ResponseWriter, r *http.Request) {
+	if h.inventory == nil || h.config.ProjectDir == "" {
+		http.Error(w, `{"error": "No project is open"}`, http.StatusServiceUnavailable)
+		return
+	}
+	inventory, err := h.inventory()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": %q}`, err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(inventory)
+}
@@ -0,0 +1,47 @@
+package web
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+// sameOrigin reports whether a request comes from a page served by this server, going
+// by the Sec-Fetch-Site and Origin headers browsers send. Without confirmed a request
+// carrying neither, like one from curl, is allowed; with it one of them must say so.
+func sameOrigin(r *http.Request, confirmed bool) bool {
+	site := r.Header.Get("Sec-Fetch-Site")
+	if site != "" && site != "same-origin" {
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host != r.Host {
+			return false
+		}
+	}
+	return !confirmed || site != "" || origin != ""
+}
+
+// isJSON reports whether a request says its body is JSON. Browsers only send that across
+// sites after a preflight this server never answers, so other sites can't make them.
+func isJSON(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// checkPost rejects a POST that another site could have made the browser send, so a page
+// the user visits can't edit files, change settings or build the site. It reports
+// whether the request may go on.
+func checkPost(w http.ResponseWriter, r *http.Request) bool {
+	if !sameOrigin(r, false) {
+		http.Error(w, `{"error": "Requests from other sites are not allowed"}`, http.StatusForbidden)
+		return false
+	}
+	if !isJSON(r) {
+		http.Error(w, `{"error": "Requests must be sent as application/json"}`, http.StatusUnsupportedMediaType)
+		return false
+	}
+	return true
+}
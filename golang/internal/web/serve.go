@@ -0,0 +1,168 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"sniplicity/internal/builtin"
+	"sniplicity/internal/logging"
+)
+
+// NotFoundPage is the page served, relative to the output directory, for paths that
+// don't exist, as static hosts do
+const NotFoundPage = "404.html"
+
+// serveOutput serves a file from the output directory with the routing most static hosts
+// use: /about finds about.html when there is no about folder, folders serve their
+// index.html, and anything missing gets 404.html with a 404 status. Responses are
+// compressed, and pages are marked for checking on every load while assets may be
+// reused for a short while.
+func (s *Server) serveOutput(w http.ResponseWriter, r *http.Request) {
+	cw := newCompressWriter(w, r)
+	defer cw.Close()
+	routeOutput(cw, r, s.config.GetAbsoluteOutputDir(), builtin.New(s.config.GetAbsoluteBuiltinTemplates()))
+}
+
+// routeOutput finds and serves what a request to the preview server asks for. Folder
+// listings and the fallback 404 page come from the built-in templates.
+func routeOutput(w http.ResponseWriter, r *http.Request, outputDir string, templates *builtin.Templates) {
+	requestedPath := strings.TrimPrefix(r.URL.Path, "/")
+	filePath := filepath.Clean(filepath.Join(outputDir, requestedPath))
+
+	// Security: prevent directory traversal
+	if !strings.HasPrefix(filePath, outputDir) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	logging.Debugf("Requested path: %s -> File path: %s", r.URL.Path, filePath)
+
+	info, err := os.Stat(filePath)
+	if err == nil && !info.IsDir() {
+		// File exists and is not a directory, serve it directly
+		if !servePrecompressed(w, r, filePath) {
+			http.ServeFile(w, r, filePath)
+		}
+		return
+	}
+	folder := err == nil
+	if folder && isFile(filepath.Join(filePath, "index.html")) {
+		// Redirects to a trailing slash and serves index.html
+		http.FileServer(http.Dir(outputDir)).ServeHTTP(w, r)
+		return
+	}
+
+	// Pretty URL: /about or /about/ for about.html, even beside an about folder
+	if htmlPath := filePath + ".html"; filePath != outputDir && isFile(htmlPath) {
+		logging.Debugf("Serving %s for %s", htmlPath, r.URL.Path)
+		if !servePrecompressed(w, r, htmlPath) {
+			serveFileContent(w, r, htmlPath, http.StatusOK)
+		}
+		return
+	}
+	if folder {
+		// No page for it, so list its files
+		serveListing(w, r, filePath, templates)
+		return
+	}
+
+	if notFound := filepath.Join(outputDir, NotFoundPage); isFile(notFound) {
+		serveFileContent(w, r, notFound, http.StatusNotFound)
+		return
+	}
+	page, err := templates.Render(builtin.NotFoundPage, builtin.NotFound{Path: r.URL.Path})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writePage(w, r, page, http.StatusNotFound)
+}
+
+// serveListing shows the files and folders in dir
+func serveListing(w http.ResponseWriter, r *http.Request, dir string, templates *builtin.Templates) {
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		// Links in the listing are relative to the folder
+		http.Redirect(w, r, path.Base(r.URL.Path)+"/", http.StatusMovedPermanently)
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		http.Error(w, "Cannot read folder", http.StatusInternalServerError)
+		return
+	}
+
+	listing := builtin.Listing{Path: r.URL.Path}
+	if r.URL.Path != "/" {
+		listing.Parent = "../"
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		href := (&url.URL{Path: entry.Name()}).EscapedPath()
+		if entry.IsDir() {
+			href += "/"
+		}
+		listing.Entries = append(listing.Entries, builtin.ListingEntry{
+			Name:     entry.Name(),
+			Href:     "./" + href, // Keeps names with a colon from reading as a scheme
+			Dir:      entry.IsDir(),
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		})
+	}
+
+	page, err := templates.Render(builtin.ListingPage, listing)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writePage(w, r, page, http.StatusOK)
+}
+
+// writePage writes a generated HTML page with the given status
+func writePage(w http.ResponseWriter, r *http.Request, page []byte, status int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		w.Write(page)
+	}
+}
+
+// serveFileContent writes the file at path with the given status. Unlike http.ServeFile
+// it doesn't redirect, so a page can be served under a URL that isn't its file name.
+func serveFileContent(w http.ResponseWriter, r *http.Request, path string, status int) {
+	if status == http.StatusOK {
+		file, err := os.Open(path)
+		if err != nil {
+			http.Error(w, "Cannot read file", http.StatusInternalServerError)
+			return
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			http.Error(w, "Cannot read file", http.StatusInternalServerError)
+			return
+		}
+		http.ServeContent(w, r, path, info.ModTime(), file)
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		http.Error(w, "Cannot read file", http.StatusInternalServerError)
+		return
+	}
+	writePage(w, r, data, status)
+}
+
+// isFile reports whether path is a file rather than a folder or missing
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
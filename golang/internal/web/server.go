@@ -0,0 +1,107 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"sniplicity/internal/config"
+	"sniplicity/internal/launcher"
+	"sniplicity/internal/logging"
+
+	"github.com/fatih/color"
+)
+
+// Server is the web server of both serve and the project selector: the web interface
+// under /sniplicity and the built site everywhere else, behind auth, TLS and compression
+type Server struct {
+	config        *config.Config // Shared with the builder, so saved settings and project switches apply at once
+	ui            *Handler
+	selector      bool // Started without a project, so the project selector is opened rather than the site
+	clipboardOnly bool // Copy the URL without opening a browser
+	server        *http.Server
+}
+
+// NewServer returns a server for the host and port in cfg, with ui at /sniplicity. selector
+// is set when sniplicity started without a project, and clipboardOnly when the command
+// only copies the URL.
+func NewServer(cfg *config.Config, ui *Handler, selector, clipboardOnly bool) *Server {
+	s := &Server{config: cfg, ui: ui, selector: selector, clipboardOnly: clipboardOnly}
+	s.server = &http.Server{
+		Addr:    cfg.ListenAddr(),
+		Handler: s.requireAuth(http.HandlerFunc(s.route)),
+	}
+	return s
+}
+
+// route sends a request to the web interface, or to the built site once a project is open
+func (s *Server) route(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/sniplicity"):
+		s.ui.ServeHTTP(w, r)
+	case s.config.ProjectDir == "" || s.config.OutputDir == "":
+		http.Redirect(w, r, "/sniplicity", http.StatusTemporaryRedirect)
+	default:
+		s.serveOutput(w, r)
+	}
+}
+
+// Start checks the TLS and auth settings, then serves in the background and hands the URL
+// to the user. Errors while serving are logged.
+func (s *Server) Start() error {
+	if err := s.configureTLS(s.server); err != nil {
+		return err
+	}
+	if err := s.config.CheckAuth(); err != nil {
+		return err
+	}
+
+	go func() {
+		url := s.announce()
+		if s.selector {
+			launcher.Launch(s.signInURL(url+"/sniplicity"), "project selector", s.launchOptions())
+		} else {
+			launcher.Launch(s.signInURL(url), "", s.launchOptions())
+		}
+		logging.Infof("")
+
+		if err := s.listenAndServe(s.server); err != nil && err != http.ErrServerClosed {
+			logging.Errorf("HTTP server: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops the server, letting requests in progress finish until ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// announce prints where the web server can be reached, warning when other devices
+// on the network can reach it too, and returns the URL for this machine
+func (s *Server) announce() string {
+	cyan := color.New(color.FgCyan)
+	serverURL, networkURL := s.config.ServerURLs(s.config.ServerProtocol(), getLocalIP())
+	logging.Infof("Starting web server at %s", cyan.Sprint(serverURL))
+	if s.config.IsPublicHost() {
+		if networkURL != "" && networkURL != serverURL {
+			logging.Infof("Network access available at %s", cyan.Sprint(networkURL))
+		}
+		if !s.config.RequiresAuth() {
+			logging.Warnf("Listening on %s: anyone on your network can see the site and use the web interface (set auth to ask for a password)", s.config.ServerHost())
+		}
+	}
+	if s.config.RequiresAuth() {
+		logging.Infof("Visitors must sign in with the credentials set by auth")
+	}
+	return serverURL
+}
+
+// launchOptions returns what is done with the server's URL when it starts: it is copied
+// unless clipboard is off, and opened unless open_browser is off or the command only copies
+func (s *Server) launchOptions() launcher.Options {
+	return launcher.Options{
+		Clipboard:   s.config.Clipboard,
+		OpenBrowser: s.config.OpenBrowser && !s.clipboardOnly,
+	}
+}
@@ -0,0 +1,130 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"time"
+
+	"sniplicity/internal/logging"
+)
+
+// configureTLS gives server the certificate set by tls_cert and tls_key, or a self-signed
+// one made for this run, when the server uses HTTPS
+func (s *Server) configureTLS(server *http.Server) error {
+	if !s.config.UsesTLS() {
+		return nil
+	}
+
+	certFile, keyFile := s.config.GetAbsoluteTLSCert(), s.config.GetAbsoluteTLSKey()
+	if (certFile == "") != (keyFile == "") {
+		return fmt.Errorf("tls_cert and tls_key must be set together")
+	}
+
+	var cert tls.Certificate
+	var err error
+	if certFile != "" {
+		cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("loading TLS certificate: %w", err)
+		}
+	} else {
+		cert, err = generateSelfSignedCert(s.config.ServerHost())
+		if err != nil {
+			return fmt.Errorf("generating TLS certificate: %w", err)
+		}
+		logging.Warnf("Using a self-signed certificate: browsers will ask you to accept it once per run")
+	}
+	server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	return nil
+}
+
+// listenAndServe runs server over HTTPS when configureTLS gave it a certificate, and over
+// plain HTTP otherwise
+func (s *Server) listenAndServe(server *http.Server) error {
+	if server.TLSConfig != nil {
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
+
+// generateSelfSignedCert generates an in-memory self-signed certificate for HTTPS, valid
+// for localhost, this machine's network address and host when it is an IP address
+func generateSelfSignedCert(host string) (tls.Certificate, error) {
+	// Generate a new RSA private key
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("generating private key: %w", err)
+	}
+
+	// Create certificate template
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Organization:  []string{"Sniplicity Dev"},
+			Country:       []string{"US"},
+			Province:      []string{""},
+			Locality:      []string{"Local"},
+			StreetAddress: []string{""},
+			PostalCode:    []string{""},
+		},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour), // Valid for 1 year
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		DNSNames:              []string{"localhost"},
+	}
+
+	// Add local IP to certificate if available
+	if localIP := getLocalIP(); localIP != "" {
+		if ip := net.ParseIP(localIP); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		}
+	}
+	if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() && !ip.IsLoopback() {
+		template.IPAddresses = append(template.IPAddresses, ip)
+	} else if ip == nil && host != "" && host != "localhost" {
+		template.DNSNames = append(template.DNSNames, host)
+	}
+
+	// Generate subject key identifier
+	pubKeyBytes, err := x509.MarshalPKIXPublicKey(&privKey.PublicKey)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("marshaling public key: %w", err)
+	}
+	template.SubjectKeyId = pubKeyBytes[:20] // Use first 20 bytes as key ID
+
+	// Create certificate
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &privKey.PublicKey, privKey)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("creating certificate: %w", err)
+	}
+
+	// Encode certificate
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+
+	// Encode private key
+	privKeyDER, err := x509.MarshalPKCS8PrivateKey(privKey)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("marshaling private key: %w", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privKeyDER})
+
+	// Create TLS certificate
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("creating X509 key pair: %w", err)
+	}
+
+	return cert, nil
+}